@@ -0,0 +1,63 @@
+// Copyright 2019 HAProxy Technologies
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=ratelimitpolicies,singular=ratelimitpolicy,scope=Namespaced
+// +kubebuilder:metadata:annotations="haproxy.org/custom-annotations=v1.0.0"
+
+// RateLimitPolicy is a specification for a RateLimitPolicy resource, rate limiting
+// the ingresses of its namespace selected by their labels
+type RateLimitPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RateLimitPolicySpec `json:"spec"`
+}
+
+type RateLimitPolicySpec struct {
+	// IngressSelector selects the ingresses of the namespace the policy applies to,
+	// an empty selector selects them all
+	IngressSelector metav1.LabelSelector `json:"ingressSelector"`
+	// Tiers are the limits of the policy, like the values of rate-limit-requests and rate-limit-period
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=3
+	Tiers []RateLimitTier `json:"tiers"`
+	// Options are the other rate-limit annotations, named without their rate-limit- prefix
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type RateLimitTier struct {
+	// Requests is the number of requests allowed over the period
+	// +kubebuilder:validation:Minimum=1
+	Requests int64 `json:"requests"`
+	// Period is the period requests are counted over, like the values of rate-limit-period
+	Period string `json:"period,omitempty" example:"10s"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RateLimitPolicyList is a list of RateLimitPolicy resources
+type RateLimitPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []RateLimitPolicy `json:"items"`
+}
@@ -65,6 +65,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&FrontendList{},
 		&Global{},
 		&GlobalList{},
+		&RateLimitPolicy{},
+		&RateLimitPolicyList{},
 		&TCP{},
 		&TCPList{},
 		&ValidationRules{},
@@ -20,13 +20,17 @@ var ValidationRules []byte
 //go:embed ingress.v3.haproxy.org_frontends.yaml
 var Frontends []byte
 
+//go:embed ingress.v3.haproxy.org_ratelimitpolicies.yaml
+var RateLimitPolicies []byte
+
 func GetCRDs() map[string][]byte {
 	return map[string][]byte{
-		"defaults.ingress.v3.haproxy.org":        Defaults,
-		"globals.ingress.v3.haproxy.org":         Globals,
-		"backends.ingress.v3.haproxy.org":        Backends,
-		"tcps.ingress.v3.haproxy.org":            TCPs,
-		"validationrules.ingress.v3.haproxy.org": ValidationRules,
-		"frontends.ingress.v3.haproxy.org":       Frontends,
+		"defaults.ingress.v3.haproxy.org":          Defaults,
+		"globals.ingress.v3.haproxy.org":           Globals,
+		"backends.ingress.v3.haproxy.org":          Backends,
+		"tcps.ingress.v3.haproxy.org":              TCPs,
+		"validationrules.ingress.v3.haproxy.org":   ValidationRules,
+		"frontends.ingress.v3.haproxy.org":         Frontends,
+		"ratelimitpolicies.ingress.v3.haproxy.org": RateLimitPolicies,
 	}
 }
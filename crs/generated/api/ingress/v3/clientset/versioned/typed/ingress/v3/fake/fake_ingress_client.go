@@ -43,6 +43,10 @@ func (c *FakeIngressV3) Globals(namespace string) v3.GlobalInterface {
 	return &FakeGlobals{c, namespace}
 }
 
+func (c *FakeIngressV3) RateLimitPolicies(namespace string) v3.RateLimitPolicyInterface {
+	return &FakeRateLimitPolicies{c, namespace}
+}
+
 func (c *FakeIngressV3) TCPs(namespace string) v3.TCPInterface {
 	return &FakeTCPs{c, namespace}
 }
@@ -25,6 +25,8 @@ type FrontendExpansion interface{}
 
 type GlobalExpansion interface{}
 
+type RateLimitPolicyExpansion interface{}
+
 type TCPExpansion interface{}
 
 type ValidationRulesExpansion interface{}
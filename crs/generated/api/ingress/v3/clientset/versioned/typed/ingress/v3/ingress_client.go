@@ -31,6 +31,7 @@ type IngressV3Interface interface {
 	DefaultsGetter
 	FrontendsGetter
 	GlobalsGetter
+	RateLimitPoliciesGetter
 	TCPsGetter
 	ValidationRulesGetter
 }
@@ -56,6 +57,10 @@ func (c *IngressV3Client) Globals(namespace string) GlobalInterface {
 	return newGlobals(c, namespace)
 }
 
+func (c *IngressV3Client) RateLimitPolicies(namespace string) RateLimitPolicyInterface {
+	return newRateLimitPolicies(c, namespace)
+}
+
 func (c *IngressV3Client) TCPs(namespace string) TCPInterface {
 	return newTCPs(c, namespace)
 }
@@ -60,6 +60,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Ingress().V3().Frontends().Informer()}, nil
 	case v3.SchemeGroupVersion.WithResource("globals"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Ingress().V3().Globals().Informer()}, nil
+	case v3.SchemeGroupVersion.WithResource("ratelimitpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ingress().V3().RateLimitPolicies().Informer()}, nil
 	case v3.SchemeGroupVersion.WithResource("tcps"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Ingress().V3().TCPs().Informer()}, nil
 	case v3.SchemeGroupVersion.WithResource("validationrules"):
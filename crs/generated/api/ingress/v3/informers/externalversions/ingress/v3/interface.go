@@ -31,6 +31,8 @@ type Interface interface {
 	Frontends() FrontendInformer
 	// Globals returns a GlobalInformer.
 	Globals() GlobalInformer
+	// RateLimitPolicies returns a RateLimitPolicyInformer.
+	RateLimitPolicies() RateLimitPolicyInformer
 	// TCPs returns a TCPInformer.
 	TCPs() TCPInformer
 	// ValidationRules returns a ValidationRulesInformer.
@@ -68,6 +70,11 @@ func (v *version) Globals() GlobalInformer {
 	return &globalInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// RateLimitPolicies returns a RateLimitPolicyInformer.
+func (v *version) RateLimitPolicies() RateLimitPolicyInformer {
+	return &rateLimitPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // TCPs returns a TCPInformer.
 func (v *version) TCPs() TCPInformer {
 	return &tCPInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
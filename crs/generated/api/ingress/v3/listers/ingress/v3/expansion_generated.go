@@ -49,6 +49,14 @@ type GlobalListerExpansion interface{}
 // GlobalNamespaceLister.
 type GlobalNamespaceListerExpansion interface{}
 
+// RateLimitPolicyListerExpansion allows custom methods to be added to
+// RateLimitPolicyLister.
+type RateLimitPolicyListerExpansion interface{}
+
+// RateLimitPolicyNamespaceListerExpansion allows custom methods to be added to
+// RateLimitPolicyNamespaceLister.
+type RateLimitPolicyNamespaceListerExpansion interface{}
+
 // TCPListerExpansion allows custom methods to be added to
 // TCPLister.
 type TCPListerExpansion interface{}
@@ -98,22 +98,24 @@ func (a annImpl) Defaults(d *models.Defaults) []Annotation {
 		global.NewTimeout("timeout-server-fin", d),
 		global.NewTimeout("timeout-tunnel", d),
 		global.NewTimeout("timeout-http-keep-alive", d),
+		global.NewTimeout("rate-limit-tarpit-duration", d),
 		global.NewLogFormat("log-format", d),
 		global.NewHTTPConnectionMode("http-connection-mode", d),
 	}
 }
 
 func (a annImpl) Frontend(i *store.Ingress, r *rules.List, m maps.Maps) []Annotation {
-	reqRateLimit := ingress.NewReqRateLimit(r, m)
+	reqRateLimit := ingress.NewReqRateLimit(r, i, m)
 	httpsRedirect := ingress.NewHTTPSRedirect(r, i)
 	hostRedirect := ingress.NewHostRedirect(r)
 	reqAuth := ingress.NewReqAuth(r, i)
 	reqCapture := ingress.NewReqCapture(r)
 	resSetCORS := ingress.NewResSetCORS(r)
-	return []Annotation{
+	annotations := []Annotation{
 		// Simple annoations
 		ingress.NewDenyList("deny-list", r, m),
 		ingress.NewAllowList("allow-list", r, m),
+		ingress.NewSourceAllowList("source-allowlist", r, i, m),
 		ingress.NewSrcIPHdr("src-ip-header", r),
 		ingress.NewReqSetHost("set-host", r),
 		ingress.NewReqPathRewrite("path-rewrite", r),
@@ -125,11 +127,11 @@ func (a annImpl) Frontend(i *store.Ingress, r *rules.List, m maps.Maps) []Annota
 		httpsRedirect.NewAnnotation("ssl-redirect-code"),
 		hostRedirect.NewAnnotation("request-redirect"),
 		hostRedirect.NewAnnotation("request-redirect-code"),
-		reqRateLimit.NewAnnotation("rate-limit-requests"),
-		reqRateLimit.NewAnnotation("rate-limit-period"),
-		reqRateLimit.NewAnnotation("rate-limit-size"),
-		reqRateLimit.NewAnnotation("rate-limit-status-code"),
-		reqRateLimit.NewAnnotation("rate-limit-whitelist"),
+	}
+	for _, name := range ingress.ReqRateLimitAnnotations {
+		annotations = append(annotations, reqRateLimit.NewAnnotation(name))
+	}
+	return append(annotations,
 		reqAuth.NewAnnotation("auth-type"),
 		reqAuth.NewAnnotation("auth-realm"),
 		reqAuth.NewAnnotation("auth-secret"),
@@ -143,7 +145,7 @@ func (a annImpl) Frontend(i *store.Ingress, r *rules.List, m maps.Maps) []Annota
 		resSetCORS.NewAnnotation("cors-max-age"),
 		resSetCORS.NewAnnotation("cors-allow-credentials"),
 		resSetCORS.NewAnnotation("cors-respond-to-options"),
-	}
+	)
 }
 
 func (a annImpl) Backend(b *models.Backend, s store.K8s, c certs.Certificates) []Annotation {
@@ -247,39 +249,131 @@ func Timeout(name string, annotations ...map[string]string) (out *int64, err err
 	return out, err
 }
 
+// RateLimitAnnotations returns the rate-limit annotations among the given ones,
+// which are also read from the services an ingress routes to.
+func RateLimitAnnotations(annotations map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, name := range ingress.ReqRateLimitAnnotations {
+		if value, ok := annotations[name]; ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// RateLimitPolicy returns the rate-limit annotations of the RateLimitPolicy selecting the ingress,
+// which apply unless the ingress or its services set them.
+func RateLimitPolicy(k store.K8s, i *store.Ingress) (map[string]string, error) {
+	return ingress.RateLimitPolicyAnnotations(k, i)
+}
+
+// RateLimit returns the rate-limit annotations, adding their rules to r, without ingress.
+// They limit the connections of the TCP service exposed on the given port.
+func RateLimit(r *rules.List, m maps.Maps, namespace, service string, port int64) []Annotation {
+	reqRateLimit := ingress.NewTCPReqRateLimit(r, m, namespace, service, port)
+	annotations := make([]Annotation, 0, len(ingress.ReqRateLimitAnnotations))
+	for _, name := range ingress.ReqRateLimitAnnotations {
+		annotations = append(annotations, reqRateLimit.NewAnnotation(name))
+	}
+	return annotations
+}
+
+// RateLimitSpecs returns the specs of the values accepted by the rate-limit annotations.
+func RateLimitSpecs() map[string]ingress.RateLimitAnnotationSpec {
+	return ingress.RateLimitAnnotationSpecs
+}
+
 // SpecificAnnotations is a set of annotations that uses rules to produce specific configuration with rule ID in configuration file.
 // These annotations in an ingress can't be merged with other ingresses annotations when these ingresses point to the same service because specific paths must be treated specifically.
 var SpecificAnnotations = map[string]struct{}{
-	"backend-config-snippet":  {},
-	"deny-list":               {},
-	"blacklist":               {},
-	"allow-list":              {},
-	"whitelist":               {},
-	"src-ip-header":           {},
-	"auth-type":               {},
-	"auth-realm":              {},
-	"auth-secret":             {},
-	"ssl-redirect":            {},
-	"ssl-redirect-port":       {},
-	"ssl-redirect-code":       {},
-	"request-redirect":        {},
-	"request-redirect-code":   {},
-	"request-capture":         {},
-	"request-capture-len":     {},
-	"path-rewrite":            {},
-	"rate-limit-requests":     {},
-	"rate-limit-period":       {},
-	"rate-limit-size":         {},
-	"rate-limit-status-code":  {},
-	"rate-limit-whitelist":    {},
-	"request-set-header":      {},
-	"response-set-header":     {},
-	"set-host":                {},
-	"cors-enable":             {},
-	"cors-allow-origin":       {},
-	"cors-allow-methods":      {},
-	"cors-allow-headers":      {},
-	"cors-max-age":            {},
-	"cors-allow-credentials":  {},
-	"cors-respond-to-options": {},
+	"backend-config-snippet":                {},
+	"deny-list":                             {},
+	"blacklist":                             {},
+	"allow-list":                            {},
+	"source-allowlist":                      {},
+	"whitelist":                             {},
+	"src-ip-header":                         {},
+	"auth-type":                             {},
+	"auth-realm":                            {},
+	"auth-secret":                           {},
+	"ssl-redirect":                          {},
+	"ssl-redirect-port":                     {},
+	"ssl-redirect-code":                     {},
+	"request-redirect":                      {},
+	"request-redirect-code":                 {},
+	"request-capture":                       {},
+	"request-capture-len":                   {},
+	"path-rewrite":                          {},
+	"rate-limit-rps":                        {},
+	"rate-limit-requests":                   {},
+	"rate-limit-period":                     {},
+	"rate-limit-size":                       {},
+	"rate-limit-table-expire":               {},
+	"rate-limit-key":                        {},
+	"rate-limit-forwarded-for-depth":        {},
+	"rate-limit-composite-key":              {},
+	"rate-limit-path-template":              {},
+	"rate-limit-anonymize":                  {},
+	"rate-limit-missing-key-action":         {},
+	"rate-limit-path":                       {},
+	"rate-limit-exempt-methods":             {},
+	"rate-limit-geo-map":                    {},
+	"rate-limit-exempt-countries":           {},
+	"rate-limit-target-countries":           {},
+	"rate-limit-exempt-user-agents":         {},
+	"rate-limit-cost-header":                {},
+	"rate-limit-shared-table":               {},
+	"rate-limit-table-name":                 {},
+	"rate-limit-auto-headroom":              {},
+	"rate-limit-per-replica":                {},
+	"rate-limit-burst":                      {},
+	"rate-limit-burst-period":               {},
+	"rate-limit-connections":                {},
+	"rate-limit-bytes-in":                   {},
+	"rate-limit-bytes-out":                  {},
+	"rate-limit-errors":                     {},
+	"rate-limit-error-status":               {},
+	"rate-limit-streams":                    {},
+	"rate-limit-peers":                      {},
+	"rate-limit-sc-slot":                    {},
+	"rate-limit-id":                         {},
+	"rate-limit-denied-metric":              {},
+	"rate-limit-status-code":                {},
+	"rate-limit-retry-after":                {},
+	"rate-limit-action":                     {},
+	"rate-limit-deny-rate":                  {},
+	"rate-limit-position":                   {},
+	"rate-limit-percentage":                 {},
+	"rate-limit-hysteresis":                 {},
+	"rate-limit-deny-message":               {},
+	"rate-limit-errorfile":                  {},
+	"rate-limit-redirect":                   {},
+	"rate-limit-log":                        {},
+	"rate-limit-log-target":                 {},
+	"rate-limit-headers":                    {},
+	"rate-limit-headers-threshold":          {},
+	"rate-limit-track-only":                 {},
+	"rate-limit-grace-period":               {},
+	"rate-limit-whitelist-strict":           {},
+	"rate-limit-whitelist-merge":            {},
+	"rate-limit-whitelist-inline-threshold": {},
+	"rate-limit-whitelist-max-entries":      {},
+	"rate-limit-whitelist-watch":            {},
+	"rate-limit-whitelist":                  {},
+	"rate-limit-whitelist-header":           {},
+	"rate-limit-blacklist":                  {},
+	"rate-limit-blacklist-status-code":      {},
+	"rate-limit-list-precedence":            {},
+	"rate-limit-only":                       {},
+	"rate-limit-scope":                      {},
+	"request-set-header":                    {},
+	"response-set-header":                   {},
+	"set-host":                              {},
+	"cors-enable":                           {},
+	"cors-allow-origin":                     {},
+	"cors-allow-methods":                    {},
+	"cors-allow-headers":                    {},
+	"cors-max-age":                          {},
+	"cors-allow-credentials":                {},
+	"cors-respond-to-options":               {},
 }
@@ -5,6 +5,22 @@ import (
 	"strings"
 )
 
+// AnnotationError is the error of an annotation processing, with the annotation
+// name and the processed value, so it can be reported on the resource.
+type AnnotationError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *AnnotationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AnnotationError) Unwrap() error {
+	return e.Err
+}
+
 // GetValue returns value by checking in multiple annotations.
 func GetValue(annotationName string, annotations ...map[string]string) string {
 	for _, a := range annotations {
@@ -53,6 +53,8 @@ func (a *Timeout) Process(k store.K8s, annotations ...map[string]string) error {
 		a.defaults.ServerFinTimeout = timeout
 	case "timeout-tunnel":
 		a.defaults.TunnelTimeout = timeout
+	case "rate-limit-tarpit-duration":
+		a.defaults.TarpitTimeout = timeout
 	default:
 		return errors.New("unknown param")
 	}
@@ -0,0 +1,49 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package global
+
+import (
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// TestTimeout_RateLimitTarpitDuration tests the rate-limit-tarpit-duration ConfigMap option.
+// It validates that:
+// - The duration is parsed like the other timeouts, with utils.ParseTime, into the timeout tarpit of the defaults
+// - Without the option, the timeout tarpit is unset
+// - Malformed durations are rejected
+func TestTimeout_RateLimitTarpitDuration(t *testing.T) {
+	defaults := &models.Defaults{}
+	timeout := NewTimeout("rate-limit-tarpit-duration", defaults)
+
+	for input, want := range map[string]int64{"10s": 10000, "1m": 60000, "1500": 1500} {
+		require.NoError(t, timeout.Process(store.K8s{}, map[string]string{"rate-limit-tarpit-duration": input}), input)
+		parsed, err := utils.ParseTime(input)
+		require.NoError(t, err)
+		assert.Equal(t, want, *parsed, input)
+		assert.Equal(t, parsed, defaults.TarpitTimeout, input)
+	}
+
+	require.NoError(t, timeout.Process(store.K8s{}, map[string]string{}))
+	assert.Nil(t, defaults.TarpitTimeout)
+
+	assert.Error(t, timeout.Process(store.K8s{}, map[string]string{"rate-limit-tarpit-duration": "soon"}))
+}
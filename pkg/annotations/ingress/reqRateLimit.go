@@ -3,11 +3,19 @@ package ingress
 import (
 	"errors"
 	"fmt"
-	"net"
+	maps0 "maps"
+	"math"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/haproxytech/kubernetes-ingress/pkg/annotations/common"
+	"github.com/haproxytech/kubernetes-ingress/pkg/fs"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
@@ -15,19 +23,462 @@ import (
 )
 
 type ReqRateLimit struct {
+	// limit and track are the rules of the first tier
 	limit *rules.ReqRateLimit
 	track *rules.ReqTrack
-	rules *rules.List
-	maps  maps.Maps
+	// tiers holds the rules of every tier, including the first one
+	tiers   []rateLimitTier
+	rules   *rules.List
+	ingress *store.Ingress
+	maps    maps.Maps
+	// disabled is set when rate-limit-requests turns rate limiting off
+	disabled bool
+	// whitelistStrict makes unresolvable whitelist hostnames an error instead of a warning
+	whitelistStrict bool
+	// whitelistMerge merges the ingress whitelist with the ConfigMap one instead of overriding it
+	whitelistMerge bool
+	// whitelistInlineThreshold is the number of addresses from which a ConfigMap whitelist
+	// is loaded in a map, smaller ones are written inline in the rules
+	whitelistInlineThreshold int64
+	// whitelistMaxEntries is the number of addresses and hostnames an annotation whitelist
+	// can list, larger ones belong in a pattern file or a ConfigMap. 0 disables the check.
+	whitelistMaxEntries int64
+	// whitelistWatch reloads HAProxy when a pattern file of the whitelist is modified
+	whitelistWatch bool
+	// tcp is set for the rate limits of TCP services, whose whitelist can list ip:port entries
+	tcp bool
+	// tcpNamespace and tcpService identify the TCP service of the rate limit, as <service>:<port>
+	tcpNamespace string
+	tcpService   string
+	// watcher is notified of the pattern files of the whitelist when whitelistWatch is set
+	watcher fs.Watcher
+	// lookupHost resolves the hostnames of the whitelist
+	lookupHost func(host string) ([]string, error)
+	// auto is set when rate-limit-requests is auto, the limit is derived from the observed peak rate
+	auto bool
+	// rateSource reports the peak rates auto limits are derived from
+	rateSource RateSource
+	// dryRun skips loading ConfigMaps and resolving hostnames, see Validate
+	dryRun bool
+	// activations records when the rate limits with a grace period were first configured
+	activations *activationRegistry
+	// now returns the current time, compared to the activations
+	now func() time.Time
+	// whitelistMaps are the maps registered by the whitelist
+	whitelistMaps []maps.Name
+	// whitelistEntries is the number of addresses whitelisted inline or in whitelistMaps
+	whitelistEntries int
+	// config holds the annotations the rules were last built from
+	config *rateLimitConfig
 }
 
+// rateLimitConfig holds the rate-limit annotations of an ingress, a service or the
+// ConfigMap, collected when the first of them is processed, and the errors of building
+// the rules from them. The rules are built once whatever the order the annotations
+// are processed in.
+type rateLimitConfig struct {
+	// values are the effective values of the annotations, see ResolveRateLimitAnnotations
+	values map[string]string
+	// errs are the errors of the annotations
+	errs map[string]error
+	// processed are the annotations whose error was returned by Process
+	processed map[string]struct{}
+}
+
+// rateLimitTier is a pair of rules limiting the request rate over one period.
+type rateLimitTier struct {
+	limit *rules.ReqRateLimit
+	track *rules.ReqTrack
+}
+
+const (
+	// maxRateLimitTiers is the number of stick counters available in HAProxy (sc0 to sc2).
+	maxRateLimitTiers = 3
+	// defaultRateLimitPeriod is the period in milliseconds used when rate-limit-period is not set.
+	defaultRateLimitPeriod int64 = 1000
+	// defaultRateLimitSize is the number of entries of a rate limit table when
+	// rate-limit-size is not set, in the ingress or in the controller configmap (100k).
+	defaultRateLimitSize int64 = 100 * 1024
+	// defaultBurstPeriod is the period in milliseconds of rate-limit-burst when rate-limit-burst-period is not set.
+	defaultBurstPeriod int64 = 1000
+	// maxDenyMessageLength is the maximum length of rate-limit-deny-message,
+	// which is sent in a single response buffer.
+	maxDenyMessageLength = 1024
+	// rateLimitErrorFileDir is the directory the errorfiles of --configmap-errorfiles are written to,
+	// relative to the configuration directory HAProxy runs from, see env.ErrFileDir.
+	rateLimitErrorFileDir = "errorfiles"
+	// defaultRateLimitLogTag tags the log of denied requests when rate-limit-log is true.
+	defaultRateLimitLogTag = "ratelimit"
+	// maxLogTagLength is the maximum length of the rate-limit-log tag.
+	maxLogTagLength = 64
+	// maxRateLimitIDLength is the maximum length of rate-limit-id, the one of a Kubernetes label value.
+	maxRateLimitIDLength = 63
+	// hostLookupTimeout bounds the resolution of a whitelist hostname.
+	hostLookupTimeout = 2 * time.Second
+	// minRateLimitSize is the table size below which entries are likely evicted
+	// before the end of the period, letting clients bypass the limit.
+	minRateLimitSize int64 = 1000
+	// maxRateLimitSize is the largest stick-table size HAProxy accepts, an unsigned 32 bits integer.
+	maxRateLimitSize int64 = math.MaxUint32
+	// autoRateLimitSizePerEndpoint is the number of client sources expected per
+	// backend endpoint when rate-limit-size is auto.
+	autoRateLimitSizePerEndpoint int64 = 20000
+	// maxScaledRateLimit bounds the limits scaled by rate-limit-per-replica, stick-table
+	// rates being counted on unsigned 32 bits integers.
+	maxScaledRateLimit int64 = math.MaxUint32
+	// maxInlineWhitelistAddresses is the number of addresses of a configmap or hostnames
+	// whitelist which are written inline in the rule when maps are unavailable.
+	maxInlineWhitelistAddresses = 64
+	// defaultAutoRateLimitHeadroom is the percentage added to the observed peak rate
+	// by rate-limit-requests auto when rate-limit-auto-headroom is not set.
+	defaultAutoRateLimitHeadroom int64 = 50
+	// defaultWhitelistInlineThreshold is the rate-limit-whitelist-inline-threshold default:
+	// ConfigMaps of one or two addresses don't get a map file.
+	defaultWhitelistInlineThreshold int64 = 3
+	// defaultWhitelistMaxEntries is the rate-limit-whitelist-max-entries default, well below
+	// the 256KiB Kubernetes allows for the annotations of an object.
+	defaultWhitelistMaxEntries int64 = 1000
+	// activationTTL is the time after which a rate limit which is no longer configured is
+	// forgotten, so it gets a new rate-limit-grace-period when configured again. Configured
+	// rate limits are seen on every sync, which happens far more often.
+	activationTTL = 10 * time.Minute
+)
+
+var (
+	// ErrMissingRateLimitRequests is returned when a rate-limit annotation is set without rate-limit-requests.
+	ErrMissingRateLimitRequests = errors.New("requires rate-limit-requests to be set")
+	// ErrInvalidAddress is returned when a rate-limit address list has an entry which is not an IP address or CIDR.
+	ErrInvalidAddress = errors.New("incorrect address")
+	// ErrInvalidStatusCode is returned when rate-limit-status-code is not a status HAProxy can deny with.
+	ErrInvalidStatusCode = errors.New("unsupported status code")
+	// ErrMapsUnavailable is returned when a whitelist needs a map while maps failed to initialize.
+	ErrMapsUnavailable = errors.New("maps are unavailable")
+	// ErrWhitelistTooLarge is returned when an annotation whitelist has more entries than rate-limit-whitelist-max-entries.
+	ErrWhitelistTooLarge = errors.New("whitelist is too large")
+	// ErrUnknownErrorFile is returned when rate-limit-errorfile is not an errorfile of --configmap-errorfiles.
+	ErrUnknownErrorFile = errors.New("unknown errorfile")
+)
+
+// rateLimitStatusCodes are the status codes HAProxy has an errorfile for,
+// which rate limited requests can be denied with.
+var rateLimitStatusCodes = []int64{200, 400, 403, 405, 408, 425, 429, 500, 502, 503, 504}
+
+// ReqRateLimitAnnotations are the rate-limit annotations, in the order the rules are
+// built from them: most annotations depend on the rules created by the previous ones.
+// They can be processed in any order, see ReqRateLimitAnn.Process.
+var ReqRateLimitAnnotations = []string{
+	"rate-limit-rps",
+	"rate-limit-requests",
+	"rate-limit-period",
+	"rate-limit-size",
+	"rate-limit-table-expire",
+	"rate-limit-key",
+	"rate-limit-forwarded-for-depth",
+	"rate-limit-composite-key",
+	"rate-limit-path-template",
+	"rate-limit-anonymize",
+	"rate-limit-missing-key-action",
+	"rate-limit-path",
+	"rate-limit-exempt-methods",
+	"rate-limit-geo-map",
+	"rate-limit-exempt-countries",
+	"rate-limit-target-countries",
+	"rate-limit-exempt-user-agents",
+	"rate-limit-cost-header",
+	"rate-limit-shared-table",
+	"rate-limit-table-name",
+	"rate-limit-auto-headroom",
+	"rate-limit-per-replica",
+	"rate-limit-burst",
+	"rate-limit-burst-period",
+	"rate-limit-connections",
+	"rate-limit-bytes-in",
+	"rate-limit-bytes-out",
+	"rate-limit-errors",
+	"rate-limit-error-status",
+	"rate-limit-streams",
+	"rate-limit-peers",
+	"rate-limit-sc-slot",
+	"rate-limit-id",
+	"rate-limit-denied-metric",
+	"rate-limit-status-code",
+	"rate-limit-retry-after",
+	"rate-limit-action",
+	"rate-limit-tarpit-duration",
+	"rate-limit-deny-rate",
+	"rate-limit-position",
+	"rate-limit-percentage",
+	"rate-limit-hysteresis",
+	"rate-limit-deny-message",
+	"rate-limit-errorfile",
+	"rate-limit-redirect",
+	"rate-limit-log",
+	"rate-limit-log-target",
+	"rate-limit-headers",
+	"rate-limit-headers-threshold",
+	"rate-limit-track-only",
+	"rate-limit-grace-period",
+	"rate-limit-whitelist-strict",
+	"rate-limit-whitelist-merge",
+	"rate-limit-whitelist-inline-threshold",
+	"rate-limit-whitelist-max-entries",
+	"rate-limit-whitelist-watch",
+	"rate-limit-whitelist",
+	"rate-limit-whitelist-header",
+	"rate-limit-blacklist",
+	"rate-limit-blacklist-status-code",
+	"rate-limit-list-precedence",
+	"rate-limit-only",
+	"rate-limit-scope",
+}
+
+// rateLimitIndependentAnnotations are the annotations which don't require the tiers of
+// rate-limit-requests: the ones creating tiers of their own, the ones checking the annotation
+// they depend on, and rate-limit-tarpit-duration which applies to every rate limit.
+var rateLimitIndependentAnnotations = map[string]struct{}{
+	"rate-limit-rps":             {},
+	"rate-limit-requests":        {},
+	"rate-limit-auto-headroom":   {},
+	"rate-limit-burst-period":    {},
+	"rate-limit-connections":     {},
+	"rate-limit-bytes-in":        {},
+	"rate-limit-bytes-out":       {},
+	"rate-limit-errors":          {},
+	"rate-limit-error-status":    {},
+	"rate-limit-tarpit-duration": {},
+}
+
+// httpMethods are the HTTP methods rate-limit-exempt-methods accepts.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+// rateLimitDefaults are the annotations making the default rate limit of every
+// ingress when set in the controller ConfigMap.
+var rateLimitDefaults = []string{"rate-limit-rps", "rate-limit-requests", "rate-limit-period"}
+
+// fetchExprRegex matches a HAProxy sample fetch optionally followed by converters,
+// e.g. "src", "hdr(X-Forwarded-For)" or "req.cook(session),lower".
+var fetchExprRegex = regexp.MustCompile(`^[a-z][a-z0-9_.]*(\([^()]*\))?(,[a-z][a-z0-9_.]*(\([^()]*\))?)*$`)
+
+// sniTrackKey is the key tracked for the "sni" rate-limit-key, lowercased
+// so clients can't escape their rate limit by changing the case of the SNI.
+const sniTrackKey = "ssl_fc_sni,lower"
+
+// pathTemplatePlaceholder replaces the parts of the path matching rate-limit-path-template.
+const pathTemplatePlaceholder = ":id"
+
+// jwtKeyRegex matches the "jwt(<claim>)" rate-limit-key, the claim being a name or a
+// dot-separated path to a nested claim, e.g. "jwt(sub)" or "jwt(user.id)".
+var jwtKeyRegex = regexp.MustCompile(`^jwt\(([A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*)\)$`)
+
+// tableNameRegex matches the characters allowed in a HAProxy section name.
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// headerNameRegex matches a HTTP header name.
+var headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// headerValueRegex matches a header value which can be used as is in an ACL pattern:
+// visible characters, without whitespaces, braces, quotes, backslashes or '#'.
+var headerValueRegex = regexp.MustCompile(`^[!$-&(-\[\]-z|~]+$`)
+
+// redirectLocationRegex matches a rate-limit-redirect location, an http(s) URL or an absolute
+// path not starting with "//", which would redirect to another host. It is written as is in the
+// rules: visible characters, without whitespaces, braces, quotes, backslashes or '#'.
+var redirectLocationRegex = regexp.MustCompile(`^(https?://[A-Za-z0-9_.:\[\]-]+(/[!$-&(-\[\]-z|~]*)?|/([!$-&(-.0-\[\]-z|~][!$-&(-\[\]-z|~]*)?)$`)
+
+// patternFileRegex matches a reference to a file of the pattern files ConfigMap,
+// like rate-limit-geo-map.
+var patternFileRegex = regexp.MustCompile(`^patterns/[A-Za-z0-9_.-]+$`)
+
+// countryCodeRegex matches an ISO 3166-1 alpha-2 country code, as found in GeoIP maps.
+var countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// rateLimitIDRegex matches a rate-limit-id, shaped like a Kubernetes label value
+// so it can identify the rate limit in logs, metrics and events.
+var rateLimitIDRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]{0,61}[A-Za-z0-9])?$`)
+
+// logTagRegex matches a rate-limit-log tag, captured as a string sample in the log.
+var logTagRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// logTargetRegex matches a rate-limit-log-target, a syslog server address as accepted by the
+// log directive: a host or an IP address with an optional port and udp@ or tcp@ prefix, or
+// the absolute path of a unix socket.
+var logTargetRegex = regexp.MustCompile(`^((udp[46]?|tcp[46]?)@)?([A-Za-z0-9_.-]+|\[[0-9A-Fa-f:.]+\])(:[0-9]{1,5})?$|^/[A-Za-z0-9_./-]+$`)
+
 type ReqRateLimitAnn struct {
 	parent *ReqRateLimit
 	name   string
 }
 
-func NewReqRateLimit(r *rules.List, m maps.Maps) *ReqRateLimit {
-	return &ReqRateLimit{rules: r, maps: m}
+func NewReqRateLimit(r *rules.List, i *store.Ingress, m maps.Maps) *ReqRateLimit {
+	return &ReqRateLimit{rules: r, ingress: i, maps: m, lookupHost: lookupHost, rateSource: rateSource, watcher: fs.PatternWatcher, activations: rateLimitActivations, now: time.Now, whitelistInlineThreshold: defaultWhitelistInlineThreshold, whitelistMaxEntries: defaultWhitelistMaxEntries}
+}
+
+// setTableSuffix derives a dedicated table name from the period based one,
+// so rate limits with a different scope don't share their counters.
+func (p *ReqRateLimit) setTableSuffix(scope string) {
+	suffix := utils.Hash([]byte(scope))[:8]
+	p.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+		if track.TableName == "" {
+			return
+		}
+		tableName := fmt.Sprintf("%s-%s", track.TableName, suffix)
+		track.TableName = tableName
+		limit.TableName = tableName
+	})
+}
+
+// disable removes the rules added for every tier and makes the following
+// rate-limit annotations no-ops, as rate limiting is explicitly turned off.
+func (p *ReqRateLimit) disable() {
+	p.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+		p.rules.Remove(limit)
+		p.rules.Remove(track)
+	})
+	p.tiers = nil
+	p.limit = nil
+	p.track = nil
+	p.disabled = true
+	p.releaseMaps()
+}
+
+// logOwner returns the owner of the rate limit in logs, with its rate-limit-id when set.
+func (p *ReqRateLimit) logOwner() string {
+	if p.limit == nil || p.limit.ID == "" {
+		return p.mapOwner()
+	}
+	return fmt.Sprintf("%s (rate-limit-id %s)", p.mapOwner(), p.limit.ID)
+}
+
+// addRequestTiers adds a tier limiting the request rate over the default period
+// per comma-separated value of the input, or turns rate limiting off with "0" or "off".
+func (p *ReqRateLimit) addRequestTiers(annName, input string) error {
+	// Turn off a rate limit inherited from the ConfigMap
+	if value := strings.TrimSpace(input); value == "0" || strings.EqualFold(value, "off") {
+		p.disable()
+		return nil
+	}
+	// Track requests without limit until a peak rate is observed, see rate-limit-auto-headroom
+	if strings.EqualFold(strings.TrimSpace(input), "auto") {
+		input = "0"
+		p.auto = true
+	}
+	// Enable Ratelimiting, one tier per comma-separated value
+	values := strings.Split(input, ",")
+	if len(values) > maxRateLimitTiers {
+		return fmt.Errorf("%s annotation supports at most %d values", annName, maxRateLimitTiers)
+	}
+	for i, v := range values {
+		value, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		tableName := fmt.Sprintf("RateLimit-%d", defaultRateLimitPeriod)
+		tier := rateLimitTier{
+			limit: &rules.ReqRateLimit{TableName: tableName, ReqsLimit: value, StickCounter: int64(i)},
+			track: &rules.ReqTrack{
+				TableName:    tableName,
+				TablePeriod:  utils.PtrInt64(defaultRateLimitPeriod),
+				TableSize:    utils.PtrInt64(defaultRateLimitSize),
+				TrackKey:     "src",
+				StickCounter: int64(i),
+			},
+		}
+		p.tiers = append(p.tiers, tier)
+		p.rules.Add(tier.limit)
+		p.rules.Add(tier.track)
+	}
+	p.limit = p.tiers[0].limit
+	p.track = p.tiers[0].track
+	return nil
+}
+
+// addCounterTier adds a tier limiting the given counter, tracked with the same key,
+// scope and period as requests in its own table, with the next free stick counter.
+// Along with rate-limit-requests, the table is named after the first request table
+// with the given suffix, otherwise it gets the given table name and tracks sources.
+func (p *ReqRateLimit) addCounterTier(annName, counter, suffix, tableName string, value int64) error {
+	if len(p.tiers) == maxRateLimitTiers {
+		return fmt.Errorf("%s annotation needs a stick counter but rate limits already use %d", annName, maxRateLimitTiers)
+	}
+	track := &rules.ReqTrack{
+		TableName:   tableName,
+		TablePeriod: utils.PtrInt64(defaultRateLimitPeriod),
+		TableSize:   utils.PtrInt64(defaultRateLimitSize),
+		TrackKey:    "src",
+	}
+	if p.track != nil && p.track.Counter == "" {
+		track.TableName = p.track.TableName + "-" + suffix
+		track.TablePeriod = p.track.TablePeriod
+		track.TableSize = p.track.TableSize
+		track.TableType = p.track.TableType
+		track.TrackKey = p.track.TrackKey
+		track.KeyParts = p.track.KeyParts
+		track.SSLOnly = p.track.SSLOnly
+		track.MissingKeyAction = p.track.MissingKeyAction
+		track.PathPrefixes = p.track.PathPrefixes
+		track.ExemptMethods = p.track.ExemptMethods
+		track.Anonymize = p.track.Anonymize
+	}
+	track.StickCounter = int64(len(p.tiers))
+	track.Counter = counter
+	tier := rateLimitTier{
+		limit: &rules.ReqRateLimit{
+			TableName:     track.TableName,
+			ReqsLimit:     value,
+			PathPrefixes:  track.PathPrefixes,
+			ExemptMethods: track.ExemptMethods,
+			StickCounter:  track.StickCounter,
+			Counter:       counter,
+		},
+		track: track,
+	}
+	p.tiers = append(p.tiers, tier)
+	p.rules.Add(tier.limit)
+	p.rules.Add(tier.track)
+	if p.limit == nil {
+		p.limit = tier.limit
+		p.track = tier.track
+	}
+	return nil
+}
+
+// addBurstTier tracks the request rate over the burst period with the next free stick
+// counter, in a table named after the one of the first tier. It doesn't deny requests
+// itself: the first tier only denies them while this rate also exceeds the burst limit.
+func (p *ReqRateLimit) addBurstTier(value, period int64) error {
+	if len(p.tiers) == maxRateLimitTiers {
+		return fmt.Errorf("rate-limit-burst annotation needs a stick counter but rate limits already use %d", maxRateLimitTiers)
+	}
+	track := &rules.ReqTrack{
+		TableName:        fmt.Sprintf("%s-burst-%d", p.track.TableName, period),
+		TablePeriod:      utils.PtrInt64(period),
+		TableSize:        p.track.TableSize,
+		TableType:        p.track.TableType,
+		TrackKey:         p.track.TrackKey,
+		KeyParts:         p.track.KeyParts,
+		SSLOnly:          p.track.SSLOnly,
+		MissingKeyAction: p.track.MissingKeyAction,
+		PathPrefixes:     p.track.PathPrefixes,
+		ExemptMethods:    p.track.ExemptMethods,
+		Anonymize:        p.track.Anonymize,
+		StickCounter:     int64(len(p.tiers)),
+	}
+	// The limit of the tier is not a rule, it only keeps the tier like the others
+	p.tiers = append(p.tiers, rateLimitTier{
+		limit: &rules.ReqRateLimit{TableName: track.TableName, StickCounter: track.StickCounter},
+		track: track,
+	})
+	p.rules.Add(track)
+	p.limit.Burst = track
+	p.limit.BurstLimit = value
+	return nil
+}
+
+// forEachTier applies the given function to the rules of every tier.
+func (p *ReqRateLimit) forEachTier(f func(limit *rules.ReqRateLimit, track *rules.ReqTrack)) {
+	for _, tier := range p.tiers {
+		f(tier.limit, tier.track)
+	}
 }
 
 func (p *ReqRateLimit) NewAnnotation(n string) ReqRateLimitAnn {
@@ -41,85 +492,1052 @@ func (a ReqRateLimitAnn) GetName() string {
 	return a.name
 }
 
-func (a ReqRateLimitAnn) Process(k store.K8s, annotations ...map[string]string) (err error) {
-	input := common.GetValue(a.GetName(), annotations...)
-	if input == "" {
+// Validate runs the parsing and validation of Process on the given annotations
+// without adding rules or maps, so they can be checked before being deployed.
+// ConfigMap whitelists are not loaded and whitelist hostnames are not resolved.
+// Like Process, every annotation is checked and all the errors are returned.
+func (p *ReqRateLimit) Validate(annotations map[string]string) error {
+	scratch := NewReqRateLimit(&rules.List{}, p.ingress, nil)
+	scratch.dryRun = true
+	config := scratch.build(store.K8s{}, annotations)
+	var errs []error
+	for _, name := range ReqRateLimitAnnotations {
+		if err := config.errs[name]; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configFor returns the configuration the given annotation belongs to. The rules are
+// built again when the annotations differ from the ones they were built from, or when
+// the annotation was already processed, as a new processing of the annotations started.
+func (p *ReqRateLimit) configFor(name string, k store.K8s, annotations []map[string]string) *rateLimitConfig {
+	if config := p.config; config != nil {
+		_, processed := config.processed[name]
+		if !processed && maps0.Equal(config.values, ResolveRateLimitAnnotations(annotations...)) {
+			return config
+		}
+	}
+	p.reset()
+	p.config = p.build(k, annotations...)
+	return p.config
+}
+
+// build applies every rate-limit annotation in the order of ReqRateLimitAnnotations,
+// which is the one they depend on each other in, and records their errors.
+func (p *ReqRateLimit) build(k store.K8s, annotations ...map[string]string) *rateLimitConfig {
+	config := &rateLimitConfig{
+		values:    ResolveRateLimitAnnotations(annotations...),
+		errs:      map[string]error{},
+		processed: map[string]struct{}{},
+	}
+	for _, name := range ReqRateLimitAnnotations {
+		a := p.NewAnnotation(name)
+		input, ok := a.input(k, config.values)
+		if !ok {
+			continue
+		}
+		var err error
+		if input != "" {
+			err = ValidateRateLimitAnnotation(name, input)
+		}
+		// The other annotations configure the tiers created by the previous ones
+		if _, ok := rateLimitIndependentAnnotations[name]; !ok && err == nil && (p.limit == nil || p.track == nil) {
+			err = fmt.Errorf("%s %w", name, ErrMissingRateLimitRequests)
+		}
+		if err == nil {
+			err = a.process(k, input, config.values, annotations)
+		}
+		if err != nil {
+			config.errs[name] = err
+		}
+	}
+	return config
+}
+
+// reset removes the rules and releases the maps of the previous build, and restores
+// the defaults of the settings annotations change.
+func (p *ReqRateLimit) reset() {
+	p.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+		p.rules.Remove(limit)
+		p.rules.Remove(track)
+	})
+	p.releaseMaps()
+	p.tiers = nil
+	p.limit = nil
+	p.track = nil
+	p.disabled = false
+	p.auto = false
+	p.whitelistStrict = false
+	p.whitelistMerge = false
+	p.whitelistWatch = false
+	p.whitelistInlineThreshold = defaultWhitelistInlineThreshold
+	p.whitelistMaxEntries = defaultWhitelistMaxEntries
+	p.whitelistEntries = 0
+	p.config = nil
+}
+
+// Result returns the rate limit configured by the processed annotations,
+// as enforced by the first tier, or nil when rate limiting is not enabled.
+func (p *ReqRateLimit) Result() *store.RateLimitStatus {
+	if p.limit == nil || p.track == nil {
 		return nil
 	}
+	result := &store.RateLimitStatus{
+		TableName:   p.limit.TableName,
+		ReqsLimit:   p.limit.ReqsLimit,
+		Whitelisted: len(p.limit.WhitelistIPs) > 0 || len(p.limit.WhitelistMaps) > 0,
+		ID:          p.limit.ID,
+	}
+	if p.track.TablePeriod != nil {
+		result.Period = *p.track.TablePeriod
+	}
+	if len(p.limit.WhitelistMaps) > 0 {
+		result.WhitelistMap = string(p.limit.WhitelistMaps[0])
+	}
+	return result
+}
+
+// Process returns the error of the annotation, building the rules from all the
+// rate-limit annotations when the first of them is processed, so they can be processed
+// in any order. It then reports the resulting rate limit on the ingress, so it can be
+// written back by the status manager.
+// Errors are common.AnnotationError holding the annotation name and value.
+func (a ReqRateLimitAnn) Process(k store.K8s, annotations ...map[string]string) error {
+	config := a.parent.configFor(a.name, k, annotations)
+	config.processed[a.name] = struct{}{}
+	err := config.errs[a.name]
+	if err != nil {
+		err = &common.AnnotationError{Name: a.name, Value: config.values[a.name], Err: err}
+	}
+	if a.parent.ingress != nil {
+		a.parent.ingress.RateLimit = a.parent.Result()
+	}
+	return err
+}
+
+// input returns the effective value of the annotation, resolved in values, and whether it
+// applies: unset annotations only apply when they have a default, and none of them apply
+// once rate limiting is turned off.
+func (a ReqRateLimitAnn) input(k store.K8s, values map[string]string) (input string, ok bool) {
+	input = strings.TrimSpace(values[a.name])
+	// Cluster CIDRs are whitelisted even without rate-limit-whitelist
+	clusterWhitelist := a.name == "rate-limit-whitelist" && len(k.RateLimitClusterCIDRs) > 0 && a.parent.limit != nil
+	// Auto limits are derived with the default headroom without rate-limit-auto-headroom
+	autoLimit := a.name == "rate-limit-auto-headroom" && a.parent.auto
+	// The controller default status code applies without rate-limit-status-code
+	defaultStatusCode := a.name == "rate-limit-status-code" && k.RateLimitDefaultStatusCode != 0 && a.parent.limit != nil
+	if (input == "" && !clusterWhitelist && !autoLimit && !defaultStatusCode) || a.parent.disabled {
+		return "", false
+	}
+	return input, true
+}
 
+// process applies the validated input of the annotation, see build. The other annotations are
+// resolved in values, the layered annotations are only read by the annotations merging the
+// values of several layers.
+func (a ReqRateLimitAnn) process(k store.K8s, input string, values map[string]string, annotations []map[string]string) (err error) {
 	switch a.name {
+	case "rate-limit-rps":
+		// A shorthand for rate-limit-requests over the default 1s period
+		for _, name := range []string{"rate-limit-requests", "rate-limit-period"} {
+			if values[name] != "" {
+				return fmt.Errorf("%s annotation can't be combined with %s", a.name, name)
+			}
+		}
+		// Tiers over the same period would be redundant
+		if strings.Contains(input, ",") {
+			return fmt.Errorf("%s annotation supports a single value", a.name)
+		}
+		err = a.parent.addRequestTiers(a.name, input)
 	case "rate-limit-requests":
-		// Enable Ratelimiting
-		var value int64
-		value, err = strconv.ParseInt(input, 10, 64)
-		a.parent.limit = &rules.ReqRateLimit{ReqsLimit: value}
-		a.parent.track = &rules.ReqTrack{TrackKey: "src"}
-		a.parent.rules.Add(a.parent.limit)
-		a.parent.rules.Add(a.parent.track)
+		err = a.parent.addRequestTiers(a.name, input)
 	case "rate-limit-period":
-		if a.parent.limit == nil || a.parent.track == nil {
-			return errors.New("rate-limit-period requires rate-limit-requests to be set")
+		values := strings.Split(input, ",")
+		if len(values) != len(a.parent.tiers) {
+			return fmt.Errorf("%s annotation has %d values while rate-limit-requests has %d", a.name, len(values), len(a.parent.tiers))
+		}
+		periods := make(map[int64]struct{}, len(values))
+		for i, v := range values {
+			var value *int64
+			value, err = utils.ParseTime(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			if _, ok := periods[*value]; ok {
+				return fmt.Errorf("duplicate period '%s' in %s annotation", strings.TrimSpace(v), a.name)
+			}
+			periods[*value] = struct{}{}
+			tableName := fmt.Sprintf("RateLimit-%d", *value)
+			a.parent.tiers[i].track.TablePeriod = value
+			a.parent.tiers[i].track.TableName = tableName
+			a.parent.tiers[i].limit.TableName = tableName
 		}
-		var value *int64
-		value, err = utils.ParseTime(input)
-		tableName := fmt.Sprintf("RateLimit-%d", *value)
-		a.parent.track.TablePeriod = value
-		a.parent.track.TableName = tableName
-		a.parent.limit.TableName = tableName
 	case "rate-limit-size":
-		if a.parent.limit == nil || a.parent.track == nil {
-			return errors.New("rate-limit-size requires rate-limit-requests to be set")
+		var value *int64
+		if strings.EqualFold(strings.TrimSpace(input), "auto") {
+			value = utils.PtrInt64(a.parent.autoSize(k))
+			a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+				track.TableSize = value
+			})
+			return nil
 		}
+		value, err = utils.ParseSize(strings.TrimSpace(input))
+		if err != nil {
+			return fmt.Errorf("%s annotation: %w", a.name, err)
+		}
+		if *value <= 0 || *value > maxRateLimitSize {
+			return fmt.Errorf("%s annotation: %d entries is out of range, expecting 1 to %d", a.name, *value, maxRateLimitSize)
+		}
+		if *value < minRateLimitSize {
+			logger.Warningf("%s annotation: a table of %d entries may evict clients before the end of the period, making the rate limit leaky", a.name, *value)
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.TableSize = value
+		})
+	case "rate-limit-table-expire":
 		var value *int64
-		value, err = utils.ParseSize(input)
-		a.parent.track.TableSize = value
+		value, err = utils.ParseTime(input)
+		if err != nil {
+			return err
+		}
+		for _, tier := range a.parent.tiers {
+			if *value < *tier.track.TablePeriod {
+				return fmt.Errorf("%s annotation '%s' is shorter than the %dms rate-limit-period", a.name, input, *tier.track.TablePeriod)
+			}
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.TableExpire = value
+		})
+		// Avoid sharing a table between different expirations of the same period
+		a.parent.setTableSuffix("expire " + strconv.FormatInt(*value, 10))
+	case "rate-limit-key":
+		key := strings.TrimSpace(input)
+		if key == "sni" {
+			key = sniTrackKey
+		}
+		if strings.HasPrefix(key, "jwt(") {
+			match := jwtKeyRegex.FindStringSubmatch(key)
+			if match == nil {
+				return fmt.Errorf("incorrect JWT claim '%s' in %s annotation, expecting jwt(<claim>)", input, a.name)
+			}
+			key = jwtClaimKey(match[1])
+		}
+		if !fetchExprRegex.MatchString(key) {
+			return fmt.Errorf("incorrect fetch expression '%s' in %s annotation", input, a.name)
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.TrackKey = key
+			track.TableType = trackKeyTableType(key)
+			track.SSLOnly = sslOnlyKey(key)
+		})
+		if key != "src" {
+			// Avoid sharing a table between different keys tracked with the same period
+			a.parent.setTableSuffix(key)
+		}
+	case "rate-limit-forwarded-for-depth":
+		var depth int64
+		depth, err = strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+		if err != nil || depth < 1 {
+			return fmt.Errorf("incorrect depth '%s' in %s annotation, expecting a positive integer", input, a.name)
+		}
+		if a.parent.track.TrackKey != "src" {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-key '%s'", a.name, a.parent.track.TrackKey)
+		}
+		// Negative occurrences count from the last header value, the one added by the closest proxy
+		key := fmt.Sprintf("req.hdr_ip(X-Forwarded-For,-%d)", depth)
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.TrackKey = key
+			track.TableType = trackKeyTableType(key)
+		})
+		a.parent.setTableSuffix(key)
+	case "rate-limit-composite-key":
+		fetches := splitFetches(input)
+		if len(fetches) < 2 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting at least two comma-separated fetches", input, a.name)
+		}
+		for _, fetch := range fetches {
+			if !fetchExprRegex.MatchString(fetch) {
+				return fmt.Errorf("incorrect fetch expression '%s' in %s annotation", fetch, a.name)
+			}
+		}
+		if a.parent.track.TrackKey != "src" {
+			return fmt.Errorf("%s annotation can't be combined with the tracked key '%s'", a.name, a.parent.track.TrackKey)
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.TrackKey = fetches[0]
+			track.KeyParts = fetches[1:]
+			track.TableType = "string"
+			track.SSLOnly = sslOnlyKey(fetches...)
+		})
+		a.parent.setTableSuffix(strings.Join(fetches, ","))
+	case "rate-limit-path-template":
+		pattern := strings.TrimSpace(input)
+		if !validPathTemplate(pattern) {
+			return fmt.Errorf("incorrect regular expression '%s' in %s annotation, expecting a regular expression without quotes, commas or spaces", input, a.name)
+		}
+		// The normalized path is added to the tracked key, so /users/1 and /users/2 share an entry
+		key := pathTemplateKey(pattern)
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.KeyParts = slices.Concat(track.KeyParts, []string{key})
+			track.TableType = "string"
+		})
+		a.parent.setTableSuffix(key)
+	case "rate-limit-anonymize":
+		var anonymize bool
+		anonymize, err = utils.GetBoolValue(input, a.name)
+		if err != nil || !anonymize {
+			return err
+		}
+		if !addressKey(a.parent.track.TrackKey) {
+			return fmt.Errorf("%s annotation only masks addresses, not the tracked key '%s'", a.name, a.parent.track.TrackKey)
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.Anonymize = true
+		})
+		// Masked and full addresses can't share a table
+		a.parent.setTableSuffix(rules.AnonymizeConverter)
+	case "rate-limit-missing-key-action":
+		action := strings.TrimSpace(input)
+		if !slices.Contains([]string{rules.MissingKeyActionDeny, rules.MissingKeyActionShared, rules.MissingKeyActionExempt}, action) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting deny, shared or exempt", input, a.name)
+		}
+		// Source addresses are always found
+		if a.parent.track.TrackKey == "src" {
+			return nil
+		}
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.MissingKeyAction = action
+		})
+		if action == rules.MissingKeyActionDeny {
+			// Denied once, by the first tier
+			a.parent.limit.MissingKey = a.parent.track.TrackKey
+		}
+	case "rate-limit-path":
+		var paths []string
+		for _, path := range strings.Split(input, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t{}") {
+				return fmt.Errorf("incorrect path '%s' in %s annotation", path, a.name)
+			}
+			paths = append(paths, path)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.PathPrefixes = paths
+			limit.PathPrefixes = paths
+		})
+		// Paths are tracked in their own table so they get an independent budget
+		a.parent.setTableSuffix(strings.Join(paths, " "))
+	case "rate-limit-exempt-methods":
+		var methods []string
+		for _, method := range strings.Split(input, ",") {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if method == "" {
+				continue
+			}
+			if !slices.Contains(httpMethods, method) {
+				return fmt.Errorf("incorrect HTTP method '%s' in %s annotation", method, a.name)
+			}
+			methods = appendUnique(methods, method)
+		}
+		if len(methods) == 0 {
+			return nil
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.ExemptMethods = methods
+			limit.ExemptMethods = methods
+		})
+		// Exempt methods are not counted, so they get their own table like paths
+		a.parent.setTableSuffix("methods " + strings.Join(methods, " "))
+	case "rate-limit-geo-map":
+		geoMap := strings.TrimSpace(input)
+		if geoMap == "" {
+			return nil
+		}
+		if !patternFileRegex.MatchString(geoMap) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting patterns/<file>", input, a.name)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.GeoMap = maps.Path(geoMap)
+		})
+	case "rate-limit-exempt-countries", "rate-limit-target-countries":
+		var countries []string
+		countries, err = parseCountries(a.name, input)
+		if err != nil || len(countries) == 0 {
+			return err
+		}
+		if a.parent.limit.GeoMap == "" {
+			return fmt.Errorf("%s annotation requires rate-limit-geo-map to be set", a.name)
+		}
+		// Exempting some countries while denying only others would be ambiguous
+		if a.name == "rate-limit-target-countries" && len(a.parent.limit.GeoExemptCountries) > 0 {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-exempt-countries", a.name)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			if a.name == "rate-limit-exempt-countries" {
+				limit.GeoExemptCountries = countries
+			} else {
+				limit.GeoTargetCountries = countries
+			}
+		})
+	case "rate-limit-exempt-user-agents":
+		var agents []string
+		var patterns []maps.Path
+		for _, agent := range strings.Split(input, ",") {
+			agent = strings.TrimSpace(agent)
+			switch {
+			case agent == "":
+				continue
+			case strings.HasPrefix(agent, "patterns/"):
+				if !patternFileRegex.MatchString(agent) {
+					return fmt.Errorf("incorrect pattern file '%s' in %s annotation, expecting patterns/<file>", agent, a.name)
+				}
+				if !slices.Contains(patterns, maps.Path(agent)) {
+					patterns = append(patterns, maps.Path(agent))
+				}
+			case !headerValueRegex.MatchString(agent):
+				// Substrings with spaces, like most User-Agents, belong in a pattern file
+				return fmt.Errorf("incorrect user-agent '%s' in %s annotation, expecting a substring without whitespaces, braces, quotes, backslashes or '#'", agent, a.name)
+			default:
+				agents = appendUnique(agents, agent)
+			}
+		}
+		// Exempt requests are still counted, only the deny is bypassed
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.ExemptUserAgents = agents
+			limit.ExemptUserAgentMaps = patterns
+		})
+	case "rate-limit-cost-header":
+		if !headerNameRegex.MatchString(input) {
+			return fmt.Errorf("incorrect header name '%s' in %s annotation", input, a.name)
+		}
+		// Requests are counted by cost in gpc0 instead of the request rate
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.CostHeader = input
+			limit.Counter = rules.RateLimitCounterGpc0Rate
+		})
+		// Costs are not counted like requests, so they get their own table
+		a.parent.setTableSuffix("cost " + input)
+	case "rate-limit-shared-table":
+		if !tableNameRegex.MatchString(input) {
+			return fmt.Errorf("incorrect table name '%s' in %s annotation", input, a.name)
+		}
+		// Ingresses of a namespace using the same name share their tables, hence their budget
+		tableName := "RateLimit-" + input
+		if a.parent.ingress != nil {
+			tableName = fmt.Sprintf("RateLimit-%s-%s", a.parent.ingress.Namespace, input)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			name := tableName
+			if len(a.parent.tiers) > 1 {
+				name = fmt.Sprintf("%s-%d", tableName, *track.TablePeriod)
+			}
+			track.TableName = name
+			limit.TableName = name
+		})
+	case "rate-limit-table-name":
+		if values["rate-limit-shared-table"] != "" {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-shared-table", a.name)
+		}
+		names := strings.Split(input, ",")
+		if len(names) != len(a.parent.tiers) {
+			return fmt.Errorf("%s annotation has %d values while rate-limit-requests has %d", a.name, len(names), len(a.parent.tiers))
+		}
+		seen := make(map[string]struct{}, len(names))
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			if !tableNameRegex.MatchString(name) || name == rules.RateLimitDeniedTable {
+				return fmt.Errorf("incorrect table name '%s' in %s annotation", name, a.name)
+			}
+			if _, ok := seen[name]; ok {
+				return fmt.Errorf("duplicate table name '%s' in %s annotation", name, a.name)
+			}
+			seen[name] = struct{}{}
+			names[i] = name
+		}
+		// Names are used as is, so they don't change with the period or the key
+		for i, tier := range a.parent.tiers {
+			tier.track.TableName = names[i]
+			tier.limit.TableName = names[i]
+		}
+	case "rate-limit-auto-headroom", "rate-limit-per-replica", "rate-limit-grace-period":
+		err = a.processAuto(k, input)
+	case "rate-limit-burst":
+		if len(a.parent.tiers) > 1 {
+			return fmt.Errorf("%s annotation can't be combined with several rate-limit-requests values", a.name)
+		}
+		var value int64
+		value, err = strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+		if err != nil || value < 1 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a positive integer", input, a.name)
+		}
+		period := defaultBurstPeriod
+		if input := values["rate-limit-burst-period"]; input != "" {
+			var value *int64
+			value, err = utils.ParseTime(strings.TrimSpace(input))
+			if err != nil {
+				return fmt.Errorf("rate-limit-burst-period annotation: %w", err)
+			}
+			period = *value
+		}
+		if period >= *a.parent.track.TablePeriod {
+			return fmt.Errorf("%s annotation needs a period of %dms, shorter than the %dms of rate-limit-period", a.name, period, *a.parent.track.TablePeriod)
+		}
+		err = a.parent.addBurstTier(value, period)
+	case "rate-limit-burst-period":
+		// Processed along with rate-limit-burst, which needs it to set its table
+		if a.parent.limit == nil || a.parent.limit.Burst == nil {
+			return fmt.Errorf("%s annotation requires rate-limit-burst", a.name)
+		}
+	case "rate-limit-connections", "rate-limit-bytes-in", "rate-limit-bytes-out":
+		err = a.processConnections(input)
+	case "rate-limit-errors":
+		var value int64
+		value, err = strconv.ParseInt(input, 10, 64)
+		if err != nil || value < 1 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a positive number of responses", input, a.name)
+		}
+		if strings.TrimSpace(values["rate-limit-error-status"]) == "" {
+			return fmt.Errorf("%s annotation requires rate-limit-error-status to be set", a.name)
+		}
+		// Responses with an error status are counted in gpc1 by the tier, see rate-limit-error-status
+		err = a.parent.addCounterTier(a.name, rules.RateLimitCounterGpc1Rate, "errors", "RateLimitErrors", value)
+	case "rate-limit-error-status":
+		var errorsTrack *rules.ReqTrack
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			if track.Counter == rules.RateLimitCounterGpc1Rate {
+				errorsTrack = track
+			}
+		})
+		if errorsTrack == nil {
+			return fmt.Errorf("%s annotation requires rate-limit-errors to be set", a.name)
+		}
+		var statuses []int64
+		for _, item := range strings.Split(input, ",") {
+			var status int64
+			status, err = strconv.ParseInt(strings.TrimSpace(item), 10, 64)
+			if err != nil || status < 100 || status > 599 {
+				return fmt.Errorf("incorrect status '%s' in %s annotation, expecting HTTP statuses from 100 to 599", strings.TrimSpace(item), a.name)
+			}
+			statuses = appendUnique(statuses, status)
+		}
+		errorsTrack.ErrorStatuses = statuses
+	case "rate-limit-streams":
+		var value int64
+		value, err = strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+		if err != nil || value < 1 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a positive integer", input, a.name)
+		}
+		// Streams are counted by connection, not by tier: the first tier limits them
+		a.parent.limit.MaxStreams = value
+	case "rate-limit-peers":
+		input = strings.TrimSpace(input)
+		if !tableNameRegex.MatchString(input) {
+			return fmt.Errorf("incorrect peers section '%s' in %s annotation", input, a.name)
+		}
+		// Every table of the rate limit is synchronized, so replicas share the counters
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			track.Peers = input
+		})
+	case "rate-limit-sc-slot":
+		var slot int64
+		slot, err = strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+		if err != nil || slot < 0 || slot >= maxRateLimitTiers {
+			return fmt.Errorf("incorrect stick counter '%s' in %s annotation, expecting 0, 1 or 2", input, a.name)
+		}
+		// Tiers use consecutive stick counters from the slot, so they never share one
+		last := slot + int64(len(a.parent.tiers)) - 1
+		if last >= maxRateLimitTiers {
+			return fmt.Errorf("%s annotation: %d rate limits starting at sc%d would use sc%d, the last stick counter is sc%d", a.name, len(a.parent.tiers), slot, last, maxRateLimitTiers-1)
+		}
+		for i, tier := range a.parent.tiers {
+			tier.limit.StickCounter = slot + int64(i)
+			tier.track.StickCounter = slot + int64(i)
+		}
+	case "rate-limit-id":
+		if !rateLimitIDRegex.MatchString(input) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting up to %d alphanumeric characters, '-', '_' or '.'", input, a.name, maxRateLimitIDLength)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			limit.ID = input
+			track.ID = input
+			if limit.Burst != nil {
+				limit.Burst.ID = input
+			}
+		})
+	case "rate-limit-denied-metric":
+		var enabled bool
+		enabled, err = utils.GetBoolValue(input, a.name)
+		// Denied requests are counted per ingress, not for the ConfigMap rate limit itself
+		if err != nil || !enabled || a.parent.ingress == nil {
+			return err
+		}
+		// Denied requests are tracked with the stick counter following the ones of the rate limits
+		var counter int64
+		a.parent.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			counter = max(counter, track.StickCounter+1)
+		})
+		if counter >= maxRateLimitTiers {
+			return fmt.Errorf("%s annotation needs a stick counter but rate limits already use sc%d", a.name, counter-1)
+		}
+		// The rate-limit-id labels the count of the ingress, see metrics.ParseRateLimitDenied
+		key := a.parent.ingress.Namespace + "/" + a.parent.ingress.Name
+		if a.parent.limit.ID != "" {
+			key += "/" + a.parent.limit.ID
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.DeniedKey = key
+			limit.DeniedStickCounter = counter
+		})
 	case "rate-limit-status-code":
-		if a.parent.limit == nil || a.parent.track == nil {
-			return errors.New("rate-limit-status-code requires rate-limit-requests to be set")
+		value := k.RateLimitDefaultStatusCode
+		switch {
+		case input != "":
+			value, err = utils.ParseInt(input)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(rateLimitStatusCodes, value) {
+				return fmt.Errorf("%w %d in %s annotation", ErrInvalidStatusCode, value, a.name)
+			}
+		case !slices.Contains(rateLimitStatusCodes, value):
+			return fmt.Errorf("%w %d in --rate-limit-default-status-code", ErrInvalidStatusCode, value)
 		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.DenyStatusCode = value
+		})
+	case "rate-limit-retry-after":
+		switch input {
+		case "false":
+			return nil
+		case "true":
+			// Derive the delay from the tracking period of each tier
+			a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+				limit.RetryAfter = (*track.TablePeriod + 999) / 1000
+			})
+			return nil
+		}
+		var value *int64
+		value, err = utils.ParseTime(input)
+		if err != nil || *value <= 0 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation", input, a.name)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.RetryAfter = (*value + 999) / 1000
+		})
+	case "rate-limit-action":
+		switch input {
+		case rules.RateLimitActionDeny, rules.RateLimitActionTarpit:
+		case rules.RateLimitActionSilentDrop:
+			// No response is sent, so there is no Retry-After header to send
+			if a.parent.limit.RetryAfter > 0 {
+				return fmt.Errorf("%s annotation '%s' can't be combined with rate-limit-retry-after", a.name, input)
+			}
+		default:
+			return fmt.Errorf("incorrect action '%s' in %s annotation, expecting one of '%s', '%s' or '%s'",
+				input, a.name, rules.RateLimitActionDeny, rules.RateLimitActionTarpit, rules.RateLimitActionSilentDrop)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.Action = input
+		})
+	case "rate-limit-tarpit-duration":
+		// It sets the timeout tarpit of the defaults section, which can't differ per rate limit,
+		// see global.Timeout. Ingresses and services inheriting it from the ConfigMap are fine.
+		if k.ConfigMaps.Main == nil || input != strings.TrimSpace(k.ConfigMaps.Main.Annotations[a.name]) {
+			return fmt.Errorf("%s annotation can only be set in the controller ConfigMap, its duration applies to every rate limit", a.name)
+		}
+	case "rate-limit-deny-rate":
 		var value int64
-		value, err = utils.ParseInt(input)
-		a.parent.limit.DenyStatusCode = value
-	case "rate-limit-whitelist":
-		if a.parent.limit == nil || a.parent.track == nil {
-			return errors.New("rate-limit-whitelist requires rate-limit-requests to be set")
+		value, err = strconv.ParseInt(input, 10, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a number of responses", input, a.name)
+		}
+		if value == 0 {
+			return nil
+		}
+		// Silently dropped requests get no response to shape
+		if a.parent.limit.Action == rules.RateLimitActionSilentDrop {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-action '%s'", a.name, rules.RateLimitActionSilentDrop)
+		}
+		// Responses are counted with the stick counter following the ones of the rate limits
+		// and of rate-limit-denied-metric
+		var counter int64
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			counter = max(counter, track.StickCounter+1)
+			if limit.DeniedKey != "" {
+				counter = max(counter, limit.DeniedStickCounter+1)
+			}
+		})
+		if counter >= maxRateLimitTiers {
+			return fmt.Errorf("%s annotation needs a stick counter but rate limits already use sc%d", a.name, counter-1)
+		}
+		// Responses are counted per source of the first tier, over its period
+		denyRate := &rules.ReqTrack{
+			TableName:    a.parent.track.TableName + "-denials",
+			TablePeriod:  a.parent.track.TablePeriod,
+			TableSize:    a.parent.track.TableSize,
+			TableType:    a.parent.track.TableType,
+			TrackKey:     a.parent.track.TrackKey,
+			KeyParts:     a.parent.track.KeyParts,
+			SSLOnly:      a.parent.track.SSLOnly,
+			Anonymize:    a.parent.track.Anonymize,
+			Peers:        a.parent.track.Peers,
+			StickCounter: counter,
+			ID:           a.parent.track.ID,
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.DenyRate = denyRate
+			limit.DenyRateLimit = value
+		})
+	case "rate-limit-position":
+		if input != rules.RateLimitPositionBeforeAuth && input != rules.RateLimitPositionAfterAuth {
+			return fmt.Errorf("incorrect position '%s' in %s annotation, expecting '%s' or '%s'",
+				input, a.name, rules.RateLimitPositionBeforeAuth, rules.RateLimitPositionAfterAuth)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.Position = input
+		})
+	case "rate-limit-percentage":
+		var percent int64
+		percent, err = parsePercentage(a.name, input)
+		if err != nil {
+			return err
+		}
+		// The rules of the first tier are evaluated first, its random number is used by every tier
+		for i, tier := range a.parent.tiers {
+			tier.limit.AdmitPercentage = percent
+			tier.limit.RandDrawn = i > 0
+		}
+	case "rate-limit-hysteresis":
+		var percent int64
+		percent, err = parsePercentage(a.name, input)
+		if err != nil {
+			return err
+		}
+		if percent == 100 {
+			return fmt.Errorf("incorrect percentage '%s' in %s annotation, expecting a watermark under the limit", input, a.name)
+		}
+		if percent == 0 {
+			return nil
+		}
+		// The watermark of each tier is a percentage of its limit. Limits too low to have
+		// a watermark, and the burst tier which doesn't deny, don't get one.
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			watermark := limit.ReqsLimit * percent / 100
+			if watermark == 0 {
+				return
+			}
+			limit.LowWatermark = watermark
+			track.Hysteresis = true
+		})
+	case "rate-limit-deny-message":
+		message := strings.TrimSpace(input)
+		if message != "" && a.parent.limit.Action == rules.RateLimitActionSilentDrop {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-action '%s'", a.name, rules.RateLimitActionSilentDrop)
+		}
+		if len(message) > maxDenyMessageLength {
+			return fmt.Errorf("%s annotation is %d characters long, expecting at most %d", a.name, len(message), maxDenyMessageLength)
+		}
+		if strings.IndexFunc(message, unicode.IsControl) != -1 {
+			return fmt.Errorf("%s annotation must fit on a single line without control characters", a.name)
+		}
+		// An empty message keeps the errorfile of the status code
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.DenyMessage = message
+		})
+	case "rate-limit-errorfile":
+		name := strings.TrimSpace(input)
+		if name == "" {
+			return nil
+		}
+		switch {
+		case a.parent.limit.Action == rules.RateLimitActionSilentDrop:
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-action '%s'", a.name, rules.RateLimitActionSilentDrop)
+		case a.parent.limit.DenyMessage != "":
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-deny-message", a.name)
+		case a.parent.limit.RetryAfter > 0:
+			// An errorfile is a complete response, HAProxy adds no header to it
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-retry-after", a.name)
+		}
+		// The errorfiles are only known from the ConfigMap, which Validate doesn't load
+		if !a.parent.dryRun && !knownErrorFile(k, name) {
+			return fmt.Errorf("%w '%s' in %s annotation, expecting a key of --configmap-errorfiles", ErrUnknownErrorFile, name, a.name)
 		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.ErrorFile = filepath.Join(rateLimitErrorFileDir, name)
+		})
+	case "rate-limit-redirect":
+		location := strings.TrimSpace(input)
+		if location == "" {
+			return nil
+		}
+		if !validRedirectLocation(location) {
+			return fmt.Errorf("incorrect location '%s' in %s annotation, expecting an http(s) URL or an absolute path", input, a.name)
+		}
+		// A redirection has no body nor Retry-After header, and can't be delayed or dropped
+		switch {
+		case a.parent.limit.DenyMessage != "":
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-deny-message", a.name)
+		case a.parent.limit.ErrorFile != "":
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-errorfile", a.name)
+		case a.parent.limit.RetryAfter > 0:
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-retry-after", a.name)
+		case a.parent.limit.Action != "" && a.parent.limit.Action != rules.RateLimitActionDeny:
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-action '%s'", a.name, a.parent.limit.Action)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.RedirectLocation = location
+		})
+	case "rate-limit-log":
+		// Either a boolean enabling the default tag or a custom tag
+		tag := strings.TrimSpace(input)
+		if enabled, boolErr := strconv.ParseBool(tag); boolErr == nil {
+			if !enabled {
+				return nil
+			}
+			tag = defaultRateLimitLogTag
+		}
+		if len(tag) > maxLogTagLength || !logTagRegex.MatchString(tag) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a boolean or a tag of at most %d letters, digits, '_', '.' or '-'", input, a.name, maxLogTagLength)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.LogTag = tag
+		})
+	case "rate-limit-log-target":
+		target := strings.TrimSpace(input)
+		if !logTargetRegex.MatchString(target) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a syslog address like 10.0.0.5:514, udp@siem.example.com:514 or /dev/log", input, a.name)
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.LogTarget = target
+		})
+	case "rate-limit-headers":
+		// Headers are computed from the first tier only, as tiers would overwrite each other's
+		a.parent.limit.Headers, err = utils.GetBoolValue(input, a.name)
+	case "rate-limit-headers-threshold":
+		var percent int64
+		percent, err = parsePercentage(a.name, input)
+		if err != nil {
+			return err
+		}
+		a.parent.limit.HeadersThreshold = a.parent.limit.ReqsLimit * percent / 100
+	case "rate-limit-track-only":
+		var trackOnly bool
+		trackOnly, err = utils.GetBoolValue(input, a.name)
+		if err != nil || !trackOnly {
+			return err
+		}
+		// Keep the stick-tables and counters but never deny
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			a.parent.rules.Remove(limit)
+		})
+	case "rate-limit-whitelist-strict", "rate-limit-whitelist-merge", "rate-limit-whitelist-inline-threshold",
+		"rate-limit-whitelist-max-entries", "rate-limit-whitelist-watch", "rate-limit-whitelist", "rate-limit-whitelist-header",
+		"rate-limit-blacklist", "rate-limit-blacklist-status-code", "rate-limit-list-precedence", "rate-limit-only":
+		err = a.processWhitelist(k, input, annotations)
+	case "rate-limit-scope":
+		if input != rules.RateLimitScopeFrontend && input != rules.RateLimitScopeBackend {
+			return fmt.Errorf("incorrect scope '%s' in %s annotation, expecting '%s' or '%s'",
+				input, a.name, rules.RateLimitScopeFrontend, rules.RateLimitScopeBackend)
+		}
+		if input == rules.RateLimitScopeFrontend {
+			return nil
+		}
+		// The ConfigMap and TCP services rate limits have no backend of their own
+		if a.parent.ingress == nil {
+			return fmt.Errorf("%s annotation can only place the rate limits of ingresses in backends", a.name)
+		}
+		// Headers are added by http-response rules, which are only created in frontends
+		if a.parent.limit.Headers {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-headers in backends", a.name)
+		}
+		// So are the counts of responses with an error status
+		for _, tier := range a.parent.tiers {
+			if len(tier.track.ErrorStatuses) > 0 {
+				return fmt.Errorf("%s annotation can't be combined with rate-limit-errors in backends", a.name)
+			}
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			limit.Backend = true
+			track.Backend = true
+		})
+	default:
+		err = fmt.Errorf("unknown rate-limit annotation '%s'", a.name)
+	}
+	return err
+}
 
-		// Parse the input - can be:
-		// 1. Comma-separated IPs/CIDRs
-		// 2. One or more pattern file references (patterns/file1, patterns/file2)
-		// 3. Mix of both
+// parsePercentage parses a percentage from 0 to 100, optionally followed by '%'.
+func parsePercentage(name, input string) (int64, error) {
+	percent, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(input), "%"), 10, 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("incorrect value '%s' in %s annotation, expecting a percentage from 0 to 100", input, name)
+	}
+	return percent, nil
+}
 
-		var ips []string
-		var patterns []maps.Path
+// rateLimitValues returns the non-empty values of name in the given annotations,
+// the ingress one first, then the service and ConfigMap ones.
+func rateLimitValues(name string, annotations []map[string]string) []string {
+	var values []string
+	for _, a := range annotations {
+		if value := a[name]; value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
 
-		for _, entry := range strings.Split(input, ",") {
-			entry = strings.TrimSpace(entry)
-			if entry == "" {
-				continue
+// appendUnique appends the values which are not in slice yet.
+func appendUnique[T comparable](slice []T, values ...T) []T {
+	for _, value := range values {
+		if !slices.Contains(slice, value) {
+			slice = append(slice, value)
+		}
+	}
+	return slice
+}
+
+// ResolveRateLimitAnnotations returns the effective rate-limit annotations of the given layers,
+// ordered by precedence: the ingress annotations, then the ones of its services, then the ones
+// of its RateLimitPolicy, then the controller ConfigMap ones. The first layer setting an annotation wins, even with an empty
+// value, except that the ConfigMap layer is skipped for the rateLimitDefaults when another
+// layer sets any of them, see rateLimitSources. Annotations set in no layer are left out.
+func ResolveRateLimitAnnotations(annotations ...map[string]string) map[string]string {
+	values := map[string]string{}
+	for _, name := range ReqRateLimitAnnotations {
+		for _, a := range rateLimitSources(name, annotations) {
+			if value, ok := a[name]; ok {
+				values[name] = value
+				break
 			}
+		}
+	}
+	return values
+}
 
-			// Check if it's a pattern file reference
-			if strings.HasPrefix(entry, "patterns/") {
-				patterns = append(patterns, maps.Path(entry))
-			} else {
-				// Validate it's a valid IP or CIDR
-				if ip := net.ParseIP(entry); ip == nil {
-					if _, _, err := net.ParseCIDR(entry); err != nil {
-						return fmt.Errorf("incorrect address '%s' in %s annotation", entry, a.name)
-					}
-				}
-				ips = append(ips, entry)
+// rateLimitSources returns the annotations the value of name is read from.
+// Annotations are the ingress ones, optionally followed by the ones of its services
+// and of its RateLimitPolicy, then the controller ConfigMap ones: a layer setting
+// any of the rateLimitDefaults fully overrides the default rate limit instead
+// of merging its values with the ConfigMap ones.
+func rateLimitSources(name string, annotations []map[string]string) []map[string]string {
+	if len(annotations) < 2 || !slices.Contains(rateLimitDefaults, name) {
+		return annotations
+	}
+	resources := annotations[:len(annotations)-1]
+	for _, a := range resources {
+		for _, n := range rateLimitDefaults {
+			if _, ok := a[n]; ok {
+				return resources
 			}
 		}
+	}
+	return annotations
+}
 
-		// Store IPs/CIDRs directly in the rule
-		a.parent.limit.WhitelistIPs = ips
+// parseCountries parses a comma-separated list of country codes, ignoring case and duplicates.
+func parseCountries(annName, input string) ([]string, error) {
+	var countries []string
+	for _, country := range strings.Split(input, ",") {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			continue
+		}
+		if !countryCodeRegex.MatchString(country) {
+			return nil, fmt.Errorf("incorrect country code '%s' in %s annotation, expecting a two-letter ISO 3166-1 code", country, annName)
+		}
+		countries = appendUnique(countries, country)
+	}
+	return countries, nil
+}
 
-		// Store pattern file references
-		a.parent.limit.WhitelistMaps = patterns
-	default:
-		err = fmt.Errorf("unknown rate-limit annotation '%s'", a.name)
+// knownErrorFile returns whether name is an errorfile of --configmap-errorfiles, whose keys are status codes.
+func knownErrorFile(k store.K8s, name string) bool {
+	if k.ConfigMaps.Errorfiles == nil {
+		return false
 	}
-	return err
+	_, ok := k.ConfigMaps.Errorfiles.Annotations[name]
+	return ok
+}
+
+// validRedirectLocation returns true if location is an http or https URL with a host,
+// or an absolute path of the same site.
+func validRedirectLocation(location string) bool {
+	if !redirectLocationRegex.MatchString(location) {
+		return false
+	}
+	_, err := url.Parse(location)
+	return err == nil
+}
+
+// sslOnlyKey returns true if one of the fetches is only set on connections
+// whose TLS is terminated by HAProxy.
+func sslOnlyKey(fetches ...string) bool {
+	for _, fetch := range fetches {
+		if strings.HasPrefix(fetch, "ssl_fc_sni") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFetches splits a comma-separated list of fetches, keeping the
+// commas between the arguments of a fetch, e.g. "src,hdr_ip(X-Forwarded-For,-1)".
+func splitFetches(input string) []string {
+	var fetches []string
+	depth, start := 0, 0
+	for i, c := range input {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				fetches = append(fetches, strings.TrimSpace(input[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(fetches, strings.TrimSpace(input[start:]))
+}
+
+// jwtClaimKey returns the fetch of the claim of the JWT sent as bearer token in the Authorization header.
+// HAProxy reads the payload without checking the token, see rate-limit-key.
+func jwtClaimKey(claim string) string {
+	return fmt.Sprintf("http_auth_bearer,jwt_payload_query('$.%s')", claim)
+}
+
+// pathTemplateKey returns the fetch of the path whose parts matching pattern are replaced
+// by pathTemplatePlaceholder, see rate-limit-path-template.
+func pathTemplateKey(pattern string) string {
+	return fmt.Sprintf("path,regsub('%s',%s,g)", pattern, pathTemplatePlaceholder)
+}
+
+// validPathTemplate returns whether pattern is a regular expression which can be
+// written as an argument of the regsub converter.
+func validPathTemplate(pattern string) bool {
+	if pattern == "" || strings.ContainsAny(pattern, "'\", \t") {
+		return false
+	}
+	_, err := regexp.Compile(pattern)
+	return err == nil
+}
+
+// trackKeyTableType returns the stick-table type suitable to store the given track key.
+func trackKeyTableType(key string) string {
+	if addressKey(key) {
+		return "ip"
+	}
+	return "string"
+}
+
+// addressKey returns whether the fetch returns the address of the client.
+func addressKey(key string) bool {
+	return key == "src" || strings.HasPrefix(key, "hdr_ip(") || strings.HasPrefix(key, "req.hdr_ip(")
 }
@@ -0,0 +1,181 @@
+package ingress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// RateSource reports the peak request rate observed in a rate limit table, over the
+// period of the table. ok is false when the table has no observation yet.
+type RateSource interface {
+	PeakRate(tableName string) (rate int64, ok bool)
+}
+
+// rateSource is the RateSource of the auto rate limits, nil until SetRateSource is called.
+var rateSource RateSource
+
+// SetRateSource sets the source of the peak rates auto rate limits are derived from.
+// It must be called before annotations are processed.
+func SetRateSource(source RateSource) {
+	rateSource = source
+}
+
+// rateLimitActivations records when the rate limits were first configured, see rate-limit-grace-period.
+// It outlives the ReqRateLimit of an ingress, which is created again on every sync.
+var rateLimitActivations = &activationRegistry{}
+
+// activationRegistry records when rate limits were first and last configured.
+type activationRegistry struct {
+	mu   sync.Mutex
+	seen map[string]activation
+}
+
+type activation struct {
+	first time.Time
+	last  time.Time
+}
+
+// since returns for how long the rate limit of the given key has been configured,
+// recording it as configured at now. Rate limits not configured for activationTTL
+// are forgotten, and start again from now.
+func (r *activationRegistry) since(key string, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = map[string]activation{}
+	}
+	for k, a := range r.seen {
+		if now.Sub(a.last) > activationTTL {
+			delete(r.seen, k)
+		}
+	}
+	a, ok := r.seen[key]
+	if !ok {
+		a.first = now
+	}
+	a.last = now
+	r.seen[key] = a
+	return now.Sub(a.first)
+}
+
+// autoLimit returns the limit of the given table, its observed peak rate increased
+// by the headroom percentage, or 0 to only track requests until a rate is observed.
+func (p *ReqRateLimit) autoLimit(tableName string, headroom int64) int64 {
+	if p.rateSource == nil {
+		return 0
+	}
+	peak, ok := p.rateSource.PeakRate(tableName)
+	if !ok || peak <= 0 {
+		return 0
+	}
+	// Rounded up so a low peak rate still gets some headroom
+	return (peak*(100+headroom) + 99) / 100
+}
+
+// autoSize returns a table size derived from the number of endpoints of the
+// services the ingress routes to, falling back to the default size when they
+// are unknown. It never goes below the default size.
+func (p *ReqRateLimit) autoSize(k store.K8s) int64 {
+	size := p.readyEndpoints(k) * autoRateLimitSizePerEndpoint
+	return min(max(size, defaultRateLimitSize), maxRateLimitSize)
+}
+
+// scaledLimit returns limit multiplied by the number of ready endpoints of the services the
+// ingress routes to, see rate-limit-per-replica. The limit is kept when they are unknown, and
+// the result is bounded to the rates a stick-table can count.
+func (p *ReqRateLimit) scaledLimit(k store.K8s, limit int64) int64 {
+	replicas := max(p.readyEndpoints(k), 1)
+	if limit > maxScaledRateLimit/replicas {
+		return maxScaledRateLimit
+	}
+	return limit * replicas
+}
+
+// readyEndpoints returns the number of distinct ready endpoint addresses of the services
+// the ingress routes to, 0 without ingress. Endpoints which are not ready are not stored.
+func (p *ReqRateLimit) readyEndpoints(k store.K8s) int64 {
+	if p.ingress == nil {
+		return 0
+	}
+	paths := []*store.IngressPath{}
+	if p.ingress.DefaultBackend != nil {
+		paths = append(paths, p.ingress.DefaultBackend)
+	}
+	for _, rule := range p.ingress.Rules {
+		for _, path := range rule.Paths {
+			paths = append(paths, path)
+		}
+	}
+	addresses := map[string]struct{}{}
+	for _, path := range paths {
+		endpoints, err := k.GetEndpoints(path.SvcNamespace, path.SvcName)
+		if err != nil {
+			continue
+		}
+		for _, portEndpoints := range endpoints {
+			for address := range portEndpoints.Addresses {
+				addresses[address] = struct{}{}
+			}
+		}
+	}
+	return int64(len(addresses))
+}
+
+// processAuto applies the annotations tuning the limits: derived from the observed peak
+// rate or the number of replicas, and enforced after a grace period.
+func (a ReqRateLimitAnn) processAuto(k store.K8s, input string) (err error) {
+	switch a.name {
+	case "rate-limit-auto-headroom":
+		if !a.parent.auto {
+			return fmt.Errorf("%s annotation requires rate-limit-requests to be auto", a.name)
+		}
+		headroom := defaultAutoRateLimitHeadroom
+		if input != "" {
+			headroom, err = parsePercentage(a.name, input)
+			if err != nil {
+				return err
+			}
+		}
+		// Derived once the table names are final, as peak rates are observed per table
+		a.parent.limit.ReqsLimit = a.parent.autoLimit(a.parent.track.TableName, headroom)
+	case "rate-limit-per-replica":
+		var enabled bool
+		enabled, err = utils.GetBoolValue(input, a.name)
+		if err != nil || !enabled {
+			return err
+		}
+		// Peak rates are observed on the whole traffic, already served by every replica
+		if a.parent.auto {
+			return fmt.Errorf("%s annotation can't be combined with rate-limit-requests auto", a.name)
+		}
+		// Ingresses are processed again on every sync, so limits follow the endpoint changes
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.ReqsLimit = a.parent.scaledLimit(k, limit.ReqsLimit)
+		})
+	case "rate-limit-grace-period":
+		var grace *int64
+		grace, err = utils.ParseTime(input)
+		if err != nil || *grace < 0 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a duration", input, a.name)
+		}
+		if *grace == 0 || a.parent.dryRun {
+			return nil
+		}
+		// The deny rules are only added once the rate limit is configured for the grace period,
+		// on the first sync after it elapsed. Requests are tracked meanwhile.
+		key := a.parent.mapOwner() + "/" + a.parent.track.TableName
+		elapsed := a.parent.activations.since(key, a.parent.now())
+		if remaining := time.Duration(*grace)*time.Millisecond - elapsed; remaining > 0 {
+			logger.Debugf("rate-limit-grace-period: rate limit of %s enforced in %s", a.parent.logOwner(), remaining.Round(time.Second))
+			a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+				a.parent.rules.Remove(limit)
+			})
+		}
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	v3 "github.com/haproxytech/kubernetes-ingress/crs/api/ingress/v3"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+)
+
+// rateLimitPolicyTierAnnotations are the annotations made by the tiers of a RateLimitPolicy,
+// which can't be set as options.
+var rateLimitPolicyTierAnnotations = []string{"rate-limit-rps", "rate-limit-requests", "rate-limit-period"}
+
+// RateLimitPolicyAnnotations returns the rate-limit annotations of the RateLimitPolicy of the
+// namespace of the ingress selecting it by its labels, or nil without one. They apply unless
+// the ingress or its services set them, and are processed like the ingress ones. When several
+// policies select the ingress, the first one by name applies and the others are ignored.
+func RateLimitPolicyAnnotations(k store.K8s, ing *store.Ingress) (map[string]string, error) {
+	ns, ok := k.Namespaces[ing.Namespace]
+	if !ok {
+		return nil, nil
+	}
+	var selected string
+	for _, name := range slices.Sorted(maps.Keys(ns.CRs.RateLimitPolicies)) {
+		spec := ns.CRs.RateLimitPolicies[name]
+		selector, err := metav1.LabelSelectorAsSelector(&spec.IngressSelector)
+		if err != nil {
+			return nil, fmt.Errorf("RateLimitPolicy '%s/%s': ingressSelector: %w", ing.Namespace, name, err)
+		}
+		if !selector.Matches(labels.Set(ing.Labels)) {
+			continue
+		}
+		if selected != "" {
+			logger.Warningf("Ingress '%s/%s': ignoring RateLimitPolicy '%s', RateLimitPolicy '%s' applies", ing.Namespace, ing.Name, name, selected)
+			continue
+		}
+		selected = name
+	}
+	if selected == "" {
+		return nil, nil
+	}
+	result, err := rateLimitPolicyValues(ns.CRs.RateLimitPolicies[selected])
+	if err != nil {
+		return nil, fmt.Errorf("RateLimitPolicy '%s/%s': %w", ing.Namespace, selected, err)
+	}
+	return result, nil
+}
+
+// rateLimitPolicyValues translates a RateLimitPolicy into rate-limit annotations: its tiers
+// make rate-limit-requests and rate-limit-period, and its options the other annotations.
+// Only the option names are checked here, the values are validated when processed.
+func rateLimitPolicyValues(spec *v3.RateLimitPolicySpec) (map[string]string, error) {
+	if len(spec.Tiers) == 0 {
+		return nil, errors.New("no tier")
+	}
+	requests := make([]string, 0, len(spec.Tiers))
+	periods := make([]string, 0, len(spec.Tiers))
+	withPeriod := false
+	for _, tier := range spec.Tiers {
+		requests = append(requests, strconv.FormatInt(tier.Requests, 10))
+		period := tier.Period
+		if period == "" {
+			period = strconv.FormatInt(defaultRateLimitPeriod, 10) + "ms"
+		} else {
+			withPeriod = true
+		}
+		periods = append(periods, period)
+	}
+	result := map[string]string{"rate-limit-requests": strings.Join(requests, ", ")}
+	if withPeriod {
+		result["rate-limit-period"] = strings.Join(periods, ", ")
+	}
+	for _, option := range slices.Sorted(maps.Keys(spec.Options)) {
+		name := "rate-limit-" + option
+		if !slices.Contains(ReqRateLimitAnnotations, name) || slices.Contains(rateLimitPolicyTierAnnotations, name) {
+			return nil, fmt.Errorf("unknown option '%s', expecting the name of a rate-limit annotation without its rate-limit- prefix, other than requests, period and rps", option)
+		}
+		result[name] = spec.Options[option]
+	}
+	return result, nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// Types of the rate-limit annotation values, see RateLimitAnnotationSpec.
+const (
+	SpecTypeInteger    = "integer"
+	SpecTypeSize       = "size"     // An integer with an optional k, m or g unit
+	SpecTypeDuration   = "duration" // A time with an optional unit, in milliseconds
+	SpecTypePercentage = "percentage"
+	SpecTypeBoolean    = "boolean"
+	SpecTypeString     = "string"
+	SpecTypeAddresses  = "addresses" // IP addresses, CIDRs and pattern files, one per line or comma-separated
+	SpecTypeRegex      = "regex"     // A regular expression without quotes, commas or spaces
+)
+
+// RateLimitAnnotationSpec describes the values a rate-limit annotation accepts.
+// It is serialized in JSON for tools generating manifests, and Process checks
+// values against it before applying them, so both share the same validation.
+// Checks depending on other annotations, like the number of rate-limit-period
+// values, are left to Process.
+type RateLimitAnnotationSpec struct {
+	// Type is the type of the value, or of every item of a list.
+	Type string `json:"type"`
+	// Keywords are accepted as the whole value whatever the type, ignoring case.
+	Keywords []string `json:"keywords,omitempty"`
+	// Enum lists the accepted values.
+	Enum []string `json:"enum,omitempty"`
+	// Minimum and Maximum bound numbers, in milliseconds for durations.
+	Minimum *int64 `json:"minimum,omitempty"`
+	Maximum *int64 `json:"maximum,omitempty"`
+	// Pattern is the regular expression strings match.
+	Pattern string `json:"pattern,omitempty"`
+	// MaxLength is the maximum length of strings, in bytes.
+	MaxLength int `json:"maxLength,omitempty"`
+	// List is set when the value is a comma-separated list, commas between
+	// parentheses excepted, of MinItems to MaxItems items (0 for no bound).
+	List            bool `json:"list,omitempty"`
+	MinItems        int  `json:"minItems,omitempty"`
+	MaxItems        int  `json:"maxItems,omitempty"`
+	AllowEmptyItems bool `json:"allowEmptyItems,omitempty"`
+	// Hostnames allows hostnames, and configmap/<namespace>/<name> and secret/<namespace>/<name>
+	// references in addresses.
+	Hostnames bool `json:"hostnames,omitempty"`
+	// Ports allows ip:port and [ipv6]:port addresses, only supported by TCP services.
+	Ports bool `json:"ports,omitempty"`
+
+	pattern *regexp.Regexp
+	// err is wrapped by the errors of invalid values
+	err error
+}
+
+// RateLimitAnnotationSpecs are the specs of the ReqRateLimitAnnotations.
+var RateLimitAnnotationSpecs = map[string]RateLimitAnnotationSpec{
+	"rate-limit-rps":                        {Type: SpecTypeInteger, Keywords: []string{"off"}, Minimum: utils.PtrInt64(0), List: true, MinItems: 1, MaxItems: 1},
+	"rate-limit-requests":                   {Type: SpecTypeInteger, Keywords: []string{"off", "auto"}, Minimum: utils.PtrInt64(0), List: true, MinItems: 1, MaxItems: maxRateLimitTiers},
+	"rate-limit-period":                     {Type: SpecTypeDuration, Minimum: utils.PtrInt64(1), List: true, MinItems: 1, MaxItems: maxRateLimitTiers},
+	"rate-limit-size":                       {Type: SpecTypeSize, Keywords: []string{"auto"}, Minimum: utils.PtrInt64(1), Maximum: utils.PtrInt64(maxRateLimitSize)},
+	"rate-limit-table-expire":               {Type: SpecTypeDuration, Minimum: utils.PtrInt64(1)},
+	"rate-limit-key":                        {Type: SpecTypeString, Keywords: []string{"sni"}, Pattern: fetchExprRegex.String()},
+	"rate-limit-forwarded-for-depth":        {Type: SpecTypeInteger, Minimum: utils.PtrInt64(1)},
+	"rate-limit-composite-key":              {Type: SpecTypeString, Pattern: fetchExprRegex.String(), List: true, MinItems: 2},
+	"rate-limit-path-template":              {Type: SpecTypeRegex},
+	"rate-limit-anonymize":                  {Type: SpecTypeBoolean},
+	"rate-limit-missing-key-action":         {Type: SpecTypeString, Enum: []string{rules.MissingKeyActionDeny, rules.MissingKeyActionShared, rules.MissingKeyActionExempt}},
+	"rate-limit-path":                       {Type: SpecTypeString, Pattern: `^/[^ \t{}]*$`, List: true, AllowEmptyItems: true},
+	"rate-limit-exempt-methods":             {Type: SpecTypeString, Pattern: "^(?i)(" + strings.Join(httpMethods, "|") + ")$", List: true, AllowEmptyItems: true},
+	"rate-limit-geo-map":                    {Type: SpecTypeString, Pattern: `^\s*(patterns/[A-Za-z0-9_.-]+)?\s*$`},
+	"rate-limit-exempt-countries":           {Type: SpecTypeString, Pattern: `^(?i)[a-z]{2}$`, List: true, AllowEmptyItems: true},
+	"rate-limit-target-countries":           {Type: SpecTypeString, Pattern: `^(?i)[a-z]{2}$`, List: true, AllowEmptyItems: true},
+	"rate-limit-exempt-user-agents":         {Type: SpecTypeString, Pattern: headerValueRegex.String(), List: true, AllowEmptyItems: true},
+	"rate-limit-cost-header":                {Type: SpecTypeString, Pattern: headerNameRegex.String()},
+	"rate-limit-shared-table":               {Type: SpecTypeString, Pattern: tableNameRegex.String()},
+	"rate-limit-table-name":                 {Type: SpecTypeString, Pattern: tableNameRegex.String(), List: true, MinItems: 1, MaxItems: maxRateLimitTiers},
+	"rate-limit-auto-headroom":              {Type: SpecTypePercentage},
+	"rate-limit-per-replica":                {Type: SpecTypeBoolean},
+	"rate-limit-burst":                      {Type: SpecTypeInteger, Minimum: utils.PtrInt64(1)},
+	"rate-limit-burst-period":               {Type: SpecTypeDuration, Minimum: utils.PtrInt64(1)},
+	"rate-limit-connections":                {Type: SpecTypeInteger, Minimum: utils.PtrInt64(1)},
+	"rate-limit-bytes-in":                   {Type: SpecTypeSize, Minimum: utils.PtrInt64(1)},
+	"rate-limit-bytes-out":                  {Type: SpecTypeSize, Minimum: utils.PtrInt64(1)},
+	"rate-limit-errors":                     {Type: SpecTypeInteger, Minimum: utils.PtrInt64(1)},
+	"rate-limit-error-status":               {Type: SpecTypeInteger, Minimum: utils.PtrInt64(100), Maximum: utils.PtrInt64(599), List: true, MinItems: 1},
+	"rate-limit-streams":                    {Type: SpecTypeInteger, Minimum: utils.PtrInt64(1)},
+	"rate-limit-peers":                      {Type: SpecTypeString, Pattern: tableNameRegex.String()},
+	"rate-limit-sc-slot":                    {Type: SpecTypeInteger, Minimum: utils.PtrInt64(0), Maximum: utils.PtrInt64(maxRateLimitTiers - 1)},
+	"rate-limit-id":                         {Type: SpecTypeString, Pattern: rateLimitIDRegex.String(), MaxLength: maxRateLimitIDLength},
+	"rate-limit-denied-metric":              {Type: SpecTypeBoolean},
+	"rate-limit-status-code":                {Type: SpecTypeInteger, Enum: statusCodeEnum(), err: ErrInvalidStatusCode},
+	"rate-limit-retry-after":                {Type: SpecTypeDuration, Keywords: []string{"true", "false"}, Minimum: utils.PtrInt64(1)},
+	"rate-limit-action":                     {Type: SpecTypeString, Enum: []string{rules.RateLimitActionDeny, rules.RateLimitActionTarpit, rules.RateLimitActionSilentDrop}},
+	"rate-limit-tarpit-duration":            {Type: SpecTypeDuration, Minimum: utils.PtrInt64(0)},
+	"rate-limit-deny-rate":                  {Type: SpecTypeInteger, Minimum: utils.PtrInt64(0)},
+	"rate-limit-position":                   {Type: SpecTypeString, Enum: []string{rules.RateLimitPositionBeforeAuth, rules.RateLimitPositionAfterAuth}},
+	"rate-limit-scope":                      {Type: SpecTypeString, Enum: []string{rules.RateLimitScopeFrontend, rules.RateLimitScopeBackend}},
+	"rate-limit-percentage":                 {Type: SpecTypePercentage},
+	"rate-limit-hysteresis":                 {Type: SpecTypePercentage, Maximum: utils.PtrInt64(99)},
+	"rate-limit-deny-message":               {Type: SpecTypeString, Pattern: `^[^\p{Cc}]*$`, MaxLength: maxDenyMessageLength},
+	"rate-limit-errorfile":                  {Type: SpecTypeString, Pattern: `^\s*([0-9]{3})?\s*$`},
+	"rate-limit-redirect":                   {Type: SpecTypeString, Pattern: redirectLocationRegex.String()},
+	"rate-limit-log":                        {Type: SpecTypeString, Pattern: logTagRegex.String(), MaxLength: maxLogTagLength},
+	"rate-limit-log-target":                 {Type: SpecTypeString, Pattern: logTargetRegex.String()},
+	"rate-limit-headers":                    {Type: SpecTypeBoolean},
+	"rate-limit-headers-threshold":          {Type: SpecTypePercentage},
+	"rate-limit-track-only":                 {Type: SpecTypeBoolean},
+	"rate-limit-grace-period":               {Type: SpecTypeDuration, Minimum: utils.PtrInt64(0)},
+	"rate-limit-whitelist-strict":           {Type: SpecTypeBoolean},
+	"rate-limit-whitelist-merge":            {Type: SpecTypeBoolean},
+	"rate-limit-whitelist-inline-threshold": {Type: SpecTypeInteger, Minimum: utils.PtrInt64(0)},
+	"rate-limit-whitelist-max-entries":      {Type: SpecTypeInteger, Minimum: utils.PtrInt64(0)},
+	"rate-limit-whitelist-watch":            {Type: SpecTypeBoolean},
+	"rate-limit-whitelist":                  {Type: SpecTypeAddresses, Hostnames: true, Ports: true},
+	"rate-limit-whitelist-header":           {Type: SpecTypeString, Pattern: `^(` + unanchored(headerNameRegex) + `)\s*:\s*(` + unanchored(headerValueRegex) + `)$`},
+	"rate-limit-blacklist":                  {Type: SpecTypeAddresses},
+	"rate-limit-blacklist-status-code":      {Type: SpecTypeInteger, Enum: statusCodeEnum(), err: ErrInvalidStatusCode},
+	"rate-limit-list-precedence":            {Type: SpecTypeString, Enum: []string{rules.RateLimitListPrecedenceWhitelist, rules.RateLimitListPrecedenceBlacklist}},
+	"rate-limit-only":                       {Type: SpecTypeAddresses},
+}
+
+func init() {
+	for name, spec := range RateLimitAnnotationSpecs {
+		if spec.Pattern != "" {
+			spec.pattern = regexp.MustCompile(spec.Pattern)
+			RateLimitAnnotationSpecs[name] = spec
+		}
+	}
+}
+
+// statusCodeEnum returns the rateLimitStatusCodes as strings.
+func statusCodeEnum() []string {
+	codes := make([]string, len(rateLimitStatusCodes))
+	for i, code := range rateLimitStatusCodes {
+		codes[i] = strconv.FormatInt(code, 10)
+	}
+	return codes
+}
+
+// unanchored returns the expression of re without its leading '^' and trailing '$'.
+func unanchored(re *regexp.Regexp) string {
+	return strings.TrimSuffix(strings.TrimPrefix(re.String(), "^"), "$")
+}
+
+// ValidateRateLimitAnnotation checks the value of the rate-limit annotation name
+// against its spec. Surrounding whitespaces are ignored.
+func ValidateRateLimitAnnotation(name, value string) error {
+	spec, ok := RateLimitAnnotationSpecs[name]
+	if !ok {
+		return fmt.Errorf("unknown rate-limit annotation '%s'", name)
+	}
+	return spec.validate(name, strings.TrimSpace(value))
+}
+
+func (s RateLimitAnnotationSpec) validate(name, value string) error {
+	for _, keyword := range s.Keywords {
+		if strings.EqualFold(value, keyword) {
+			return nil
+		}
+	}
+	if !s.List {
+		return s.validateItem(name, value)
+	}
+	items := splitFetches(value)
+	if s.AllowEmptyItems {
+		items = slices.DeleteFunc(items, func(item string) bool { return item == "" })
+	}
+	if s.MaxItems == 1 && len(items) > 1 {
+		return fmt.Errorf("%s annotation supports a single value", name)
+	}
+	if len(items) < s.MinItems || (s.MaxItems > 0 && len(items) > s.MaxItems) {
+		return fmt.Errorf("incorrect value '%s' in %s annotation, expecting %s", value, name, s.describeItems())
+	}
+	for _, item := range items {
+		if err := s.validateItem(name, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s RateLimitAnnotationSpec) validateItem(name, value string) error {
+	ok := true
+	var number *int64
+	switch s.Type {
+	case SpecTypeInteger:
+		v, err := strconv.ParseInt(value, 10, 64)
+		ok, number = err == nil, &v
+	case SpecTypeSize:
+		var err error
+		number, err = utils.ParseSize(value)
+		ok = err == nil
+	case SpecTypeDuration:
+		var err error
+		number, err = utils.ParseTime(value)
+		ok = err == nil
+	case SpecTypePercentage:
+		v, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+		ok, number = err == nil && v >= 0 && v <= 100, &v
+	case SpecTypeBoolean:
+		_, err := strconv.ParseBool(value)
+		// Deprecated values still accepted by utils.GetBoolValue
+		ok = err == nil || slices.Contains([]string{"enabled", "on", "disabled", "off"}, strings.ToLower(value))
+	case SpecTypeAddresses:
+		return s.validateAddresses(name, value)
+	case SpecTypeRegex:
+		ok = validPathTemplate(value)
+	}
+	ok = ok && (s.Minimum == nil || number == nil || *number >= *s.Minimum)
+	ok = ok && (s.Maximum == nil || number == nil || *number <= *s.Maximum)
+	ok = ok && (len(s.Enum) == 0 || slices.Contains(s.Enum, value))
+	ok = ok && (s.pattern == nil || s.pattern.MatchString(value))
+	switch {
+	case ok && s.MaxLength > 0 && len(value) > s.MaxLength:
+		return fmt.Errorf("%s annotation is %d characters long, expecting at most %d", name, len(value), s.MaxLength)
+	case ok:
+		return nil
+	case s.err != nil:
+		return fmt.Errorf("%w '%s' in %s annotation, expecting %s", s.err, value, name, s.describe())
+	default:
+		return fmt.Errorf("incorrect value '%s' in %s annotation, expecting %s", value, name, s.describe())
+	}
+}
+
+func (s RateLimitAnnotationSpec) validateAddresses(name, value string) error {
+	if kind := whitelistRefKind(value); s.Hostnames && kind != "" {
+		_, _, err := parseObjectRef(name, kind, value)
+		return err
+	}
+	if s.Ports {
+		var err error
+		if _, value, err = splitAddrPorts(name, value); err != nil {
+			return err
+		}
+	}
+	_, hosts, _, err := parseRateLimitAddresses(name, value)
+	if err != nil {
+		return err
+	}
+	if len(hosts) > 0 && !s.Hostnames {
+		return fmt.Errorf("%w '%s' in %s annotation, hostnames are only supported in rate-limit-whitelist", ErrInvalidAddress, hosts[0], name)
+	}
+	return nil
+}
+
+// describeItems describes the expected list, for error messages.
+func (s RateLimitAnnotationSpec) describeItems() string {
+	switch {
+	case s.MaxItems == 0:
+		return fmt.Sprintf("at least %d comma-separated values", s.MinItems)
+	case s.MinItems == s.MaxItems:
+		return fmt.Sprintf("%d comma-separated values", s.MaxItems)
+	default:
+		return fmt.Sprintf("%d to %d comma-separated values", s.MinItems, s.MaxItems)
+	}
+}
+
+// describe describes the expected value, for error messages.
+func (s RateLimitAnnotationSpec) describe() string {
+	var description string
+	switch s.Type {
+	case SpecTypeInteger:
+		description = "an integer"
+	case SpecTypeSize:
+		description = "a size with an optional k, m or g unit"
+	case SpecTypeDuration:
+		description = "a duration with an optional unit"
+	case SpecTypePercentage:
+		return "a percentage from 0 to 100"
+	case SpecTypeBoolean:
+		return "a boolean"
+	case SpecTypeRegex:
+		return "a regular expression without quotes, commas or spaces"
+	default:
+		description = "a string"
+	}
+	switch {
+	case len(s.Enum) > 0:
+		return "one of " + strings.Join(s.Enum, ", ")
+	case s.Minimum != nil && s.Maximum != nil && *s.Maximum-*s.Minimum == 2:
+		return fmt.Sprintf("%d, %d or %d", *s.Minimum, *s.Minimum+1, *s.Maximum)
+	case s.Minimum != nil && s.Maximum != nil:
+		description += fmt.Sprintf(" from %d to %d", *s.Minimum, *s.Maximum)
+	case s.Minimum != nil && *s.Minimum == 1 && s.Type == SpecTypeInteger:
+		return "a positive integer"
+	case s.Minimum != nil:
+		description += fmt.Sprintf(" of at least %d", *s.Minimum)
+	case s.Pattern != "":
+		description += " matching " + s.Pattern
+	}
+	if s.MaxLength > 0 {
+		description += fmt.Sprintf(" of at most %d characters", s.MaxLength)
+	}
+	return description
+}
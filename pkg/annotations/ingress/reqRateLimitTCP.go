@@ -0,0 +1,99 @@
+package ingress
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// NewTCPReqRateLimit creates the rate limit of a TCP service exposed on the given port, limiting connections.
+func NewTCPReqRateLimit(r *rules.List, m maps.Maps, namespace, service string, port int64) *ReqRateLimit {
+	p := NewReqRateLimit(r, nil, m)
+	p.tcp = true
+	p.tcpNamespace = namespace
+	p.tcpService = fmt.Sprintf("%s:%d", service, port)
+	return p
+}
+
+// mapOwner returns the name the maps used by the rate limit are registered with.
+func (p *ReqRateLimit) mapOwner() string {
+	if p.tcp {
+		return "tcp/" + p.tcpNamespace + "/" + p.tcpService
+	}
+	if p.ingress == nil {
+		return "configmap"
+	}
+	return "ingress/" + p.ingress.Namespace + "/" + p.ingress.Name
+}
+
+// metricsLabels returns the namespace and name of the ingress labeling the metrics of the
+// rate limit, tcp/<service>:<port> for TCP services and empty for the ConfigMap.
+func (p *ReqRateLimit) metricsLabels() (namespace, ingress string) {
+	if p.tcp {
+		return p.tcpNamespace, "tcp/" + p.tcpService
+	}
+	if p.ingress == nil {
+		return "", ""
+	}
+	return p.ingress.Namespace, p.ingress.Name
+}
+
+// splitAddrPorts returns the ip:port and [ipv6]:port entries of the input of an address
+// list annotation, as address:port, and the input without them. An entry is only taken
+// for an address with a port when its host is an IP address.
+func splitAddrPorts(annName, input string) (addrPorts []string, rest string, err error) {
+	var others []string
+	for _, line := range utils.ParseListLines(input) {
+		for _, entry := range strings.Split(line.Text, ",") {
+			entry = strings.TrimSpace(entry)
+			host, port, splitErr := net.SplitHostPort(entry)
+			if splitErr != nil {
+				others = append(others, entry)
+				continue
+			}
+			addr, addrErr := netip.ParseAddr(host)
+			if addrErr != nil || addr.Zone() != "" {
+				others = append(others, entry)
+				continue
+			}
+			number, portErr := strconv.Atoi(port)
+			if portErr != nil || number < 1 || number > 65535 {
+				return nil, "", fmt.Errorf("%w '%s' in %s annotation, expecting a port from 1 to 65535", ErrInvalidAddress, entry, annName)
+			}
+			addrPorts = appendUnique(addrPorts, addr.Unmap().String()+":"+strconv.Itoa(number))
+		}
+	}
+	return addrPorts, strings.Join(others, ","), nil
+}
+
+// processConnections applies the annotations limiting the connections and the bytes
+// they transfer, the ones of TCP services.
+func (a ReqRateLimitAnn) processConnections(input string) (err error) {
+	switch a.name {
+	case "rate-limit-connections":
+		var value int64
+		value, err = strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+		if err != nil {
+			return err
+		}
+		err = a.parent.addCounterTier(a.name, rules.RateLimitCounterConnCur, "conn", "RateLimitConn", value)
+	case "rate-limit-bytes-in", "rate-limit-bytes-out":
+		var value *int64
+		value, err = utils.ParseSize(strings.TrimSpace(input))
+		if err != nil || *value <= 0 {
+			return fmt.Errorf("incorrect size '%s' in %s annotation, expecting a positive number of bytes with an optional k, m or g unit", input, a.name)
+		}
+		counter, suffix, tableName := rules.RateLimitCounterBytesInRate, "bytes-in", "RateLimitBytesIn"
+		if a.name == "rate-limit-bytes-out" {
+			counter, suffix, tableName = rules.RateLimitCounterBytesOutRate, "bytes-out", "RateLimitBytesOut"
+		}
+		err = a.parent.addCounterTier(a.name, counter, suffix, tableName, *value)
+	}
+	return err
+}
@@ -0,0 +1,669 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/metrics"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// hostnameRegex matches a fully qualified domain name, e.g. "partner.example.com".
+// A dot and a top-level domain starting with a letter are required so typos aren't taken for hostnames.
+var hostnameRegex = regexp.MustCompile(`^(?i)([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]([a-z0-9-]*[a-z0-9])?\.?$`)
+
+// lookupHost resolves host with the default resolver, bounded by hostLookupTimeout
+// as it is done while generating the configuration.
+func lookupHost(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+	defer cancel()
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// useMap registers the rate limit as a user of the given map,
+// so it is kept on refresh until the rate limit is gone.
+func (p *ReqRateLimit) useMap(name maps.Name) {
+	p.maps.MapRef(name, p.mapOwner())
+	p.whitelistMaps = append(p.whitelistMaps, name)
+}
+
+// updateMapMetrics reports the number of entries of a whitelist map the rate limit uses.
+// Maps are filled again on every sync, a regeneration is only counted when the content
+// filled by the rate limit differs from the one of the previous sync.
+func (p *ReqRateLimit) updateMapMetrics(name maps.Name, entries int, filled bool) {
+	namespace, ingress := p.metricsLabels()
+	regenerated := filled && p.maps.MapChanged(name)
+	metrics.New().UpdateRateLimitWhitelistMapMetrics(namespace, ingress, string(name), entries, regenerated)
+}
+
+// WhitelistEntries returns the number of addresses whitelisted by the last processing of
+// rate-limit-whitelist, written inline in the rules or in the maps it filled.
+// The content of pattern files is not counted.
+func (p *ReqRateLimit) WhitelistEntries() int {
+	return p.whitelistEntries
+}
+
+// releaseMaps deregisters the rate limit from the maps it uses,
+// which are removed on refresh when no other rule uses them.
+func (p *ReqRateLimit) releaseMaps() {
+	for _, name := range p.whitelistMaps {
+		p.maps.MapUnref(name, p.mapOwner())
+	}
+	p.whitelistMaps = nil
+}
+
+// whitelist returns the addresses and the pattern files of the given whitelists.
+// A whitelist is either a ConfigMap reference, loaded in a map, or a list of
+// addresses, pattern files and hostnames, resolved in a map. The cluster CIDRs
+// set with --rate-limit-cluster-cidrs come first. The ip:port entries of TCP services
+// are returned apart, as they are matched along with the source port.
+func (p *ReqRateLimit) whitelist(k store.K8s, inputs []string) (ips, addrPorts []string, patterns []maps.Path, err error) {
+	// Cluster internal traffic, like health checks, isn't rate limited
+	for _, cidr := range k.RateLimitClusterCIDRs {
+		address, ok := parseRateLimitAddress(strings.TrimSpace(cidr))
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%w '%s' in --rate-limit-cluster-cidrs", ErrInvalidAddress, cidr)
+		}
+		ips = appendUnique(ips, address)
+	}
+	var hosts []string
+	for _, input := range inputs {
+		if whitelistRefKind(input) != "" {
+			var mapPath maps.Path
+			var inline []string
+			mapPath, inline, err = p.objectWhitelist(k, input)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if mapPath != "" {
+				patterns = appendUnique(patterns, mapPath)
+			}
+			ips = appendUnique(ips, inline...)
+			continue
+		}
+		var inputAddrPorts []string
+		inputAddrPorts, input, err = splitAddrPorts("rate-limit-whitelist", input)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(inputAddrPorts) > 0 && !p.tcp {
+			return nil, nil, nil, fmt.Errorf("%w '%s' in rate-limit-whitelist annotation, ports can only be whitelisted for TCP services", ErrInvalidAddress, inputAddrPorts[0])
+		}
+		var inputIPs, inputHosts []string
+		var inputPatterns []maps.Path
+		inputIPs, inputHosts, inputPatterns, err = parseRateLimitAddresses("rate-limit-whitelist", input)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if entries := int64(len(inputIPs) + len(inputHosts) + len(inputAddrPorts)); p.whitelistMaxEntries > 0 && entries > p.whitelistMaxEntries {
+			return nil, nil, nil, fmt.Errorf("%w: rate-limit-whitelist annotation has %d entries, more than the %d of rate-limit-whitelist-max-entries, use a pattern file or a configmap instead",
+				ErrWhitelistTooLarge, entries, p.whitelistMaxEntries)
+		}
+		if len(inputPatterns) > 0 {
+			// Mixed with pattern files, addresses are loaded in a map matched along with them
+			var mapPath maps.Path
+			mapPath, inputIPs, err = p.mixedWhitelist(inputIPs)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if mapPath != "" {
+				patterns = appendUnique(patterns, mapPath)
+			}
+		}
+		ips = appendUnique(ips, inputIPs...)
+		addrPorts = appendUnique(addrPorts, inputAddrPorts...)
+		hosts = appendUnique(hosts, inputHosts...)
+		patterns = appendUnique(patterns, inputPatterns...)
+		if p.whitelistWatch && !p.dryRun {
+			// Pattern files managed outside the controller are only re-read on reload
+			for _, pattern := range inputPatterns {
+				p.watcher.Watch(string(pattern))
+			}
+		}
+	}
+	if len(hosts) > 0 {
+		var mapPath maps.Path
+		var inline []string
+		mapPath, inline, err = p.hostnamesWhitelist(hosts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if mapPath != "" {
+			patterns = append(patterns, mapPath)
+		}
+		ips = appendUnique(ips, inline...)
+	}
+	return ips, addrPorts, patterns, nil
+}
+
+// objectWhitelist loads the addresses of the configmap or the secret referenced as
+// configmap/namespace/name or secret/namespace/name, one IPv4/IPv6 address or CIDR
+// per line, into a whitelist map and returns the map path. Blank lines and '#'
+// comments are ignored. An empty path is returned when the object has no address,
+// and the addresses are returned instead when they are whitelisted inline.
+func (p *ReqRateLimit) objectWhitelist(k store.K8s, ref string) (maps.Path, []string, error) {
+	kind := whitelistRefKind(ref)
+	ns, name, err := parseObjectRef("rate-limit-whitelist", kind, ref)
+	if err != nil || p.dryRun {
+		return "", nil, err
+	}
+	var data map[string]string
+	if kind == whitelistRefSecret {
+		data, err = secretWhitelistData(k, ns, name)
+	} else {
+		data, err = configMapWhitelistData(k, ns, name)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("rate-limit-whitelist annotation: %w", err)
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var addresses []string
+	seen := map[string]struct{}{}
+	for _, key := range keys {
+		for _, line := range utils.ParseListLines(data[key]) {
+			address, ok := parseRateLimitAddress(line.Text)
+			if !ok {
+				// The content of secrets is confidential, it isn't written in errors
+				if kind == whitelistRefSecret {
+					return "", nil, fmt.Errorf("%w in secret '%s/%s' key '%s' line %d", ErrInvalidAddress, ns, name, key, line.Number)
+				}
+				return "", nil, fmt.Errorf("%w '%s' in configmap '%s/%s' key '%s' line %d", ErrInvalidAddress, line.Text, ns, name, key, line.Number)
+			}
+			if _, ok := seen[address]; !ok {
+				seen[address] = struct{}{}
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		logger.Warningf("rate-limit-whitelist: %s '%s/%s' has no address, ignoring it", kind, ns, name)
+		return "", nil, nil
+	}
+	// A map file isn't worth it for a few addresses, they are written in the rules.
+	// Any change of the object then changes the rules, instead of the map content.
+	if int64(len(addresses)) < p.whitelistInlineThreshold {
+		return "", addresses, nil
+	}
+	return p.whitelistMap(whitelistMapName(p.ingress, addresses), addresses)
+}
+
+// configMapWhitelistData returns the data of the configmap holding whitelisted addresses.
+func configMapWhitelistData(k store.K8s, ns, name string) (map[string]string, error) {
+	cm, err := k.GetConfigMap(ns, name)
+	if err != nil {
+		return nil, err
+	}
+	return cm.Annotations, nil
+}
+
+// secretWhitelistData returns the data of the secret holding whitelisted addresses,
+// which Kubernetes decodes from base64.
+func secretWhitelistData(k store.K8s, ns, name string) (map[string]string, error) {
+	secret, err := k.GetSecret(ns, name)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(secret.Data))
+	for key, value := range secret.Data {
+		if !utf8.Valid(value) {
+			return nil, fmt.Errorf("secret '%s/%s' key '%s' is not text", ns, name, key)
+		}
+		data[key] = string(value)
+	}
+	return data, nil
+}
+
+// secretValuesMap loads the values of the Secret referenced as secret/namespace/name, one per
+// line, into a map of the values accepted by rate-limit-whitelist-header, and returns its path.
+// The values are confidential, so they are neither written in the rules nor in errors, and
+// an empty path is returned without them.
+func (p *ReqRateLimit) secretValuesMap(k store.K8s, ref string) (maps.Path, error) {
+	ns, name, err := parseObjectRef("rate-limit-whitelist-header", whitelistRefSecret, ref)
+	if err != nil || p.dryRun {
+		return "", err
+	}
+	if p.maps == nil {
+		return "", fmt.Errorf("rate-limit-whitelist-header annotation: %w, the values of secret '%s/%s' can't be written inline", ErrMapsUnavailable, ns, name)
+	}
+	data, err := secretWhitelistData(k, ns, name)
+	if err != nil {
+		return "", fmt.Errorf("rate-limit-whitelist-header annotation: %w", err)
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var values []string
+	seen := map[string]struct{}{}
+	for _, key := range keys {
+		for _, line := range utils.ParseListLines(data[key]) {
+			if !headerValueRegex.MatchString(line.Text) {
+				return "", fmt.Errorf("rate-limit-whitelist-header annotation: incorrect value in secret '%s/%s' key '%s' line %d, expecting no whitespaces, braces, quotes, backslashes or '#'", ns, name, key, line.Number)
+			}
+			if _, ok := seen[line.Text]; !ok {
+				seen[line.Text] = struct{}{}
+				values = append(values, line.Text)
+			}
+		}
+	}
+	if len(values) == 0 {
+		logger.Warningf("rate-limit-whitelist-header: secret '%s/%s' has no value, ignoring it", ns, name)
+		return "", nil
+	}
+	mapName := addressesMapName("ratelimit-header-", p.ingress, values)
+	filled := !p.maps.MapExists(mapName)
+	if filled {
+		for _, value := range values {
+			p.maps.MapAppend(mapName, value)
+		}
+	}
+	p.useMap(mapName)
+	p.updateMapMetrics(mapName, len(values), filled)
+	return maps.GetPath(mapName), nil
+}
+
+// mixedWhitelist loads the addresses of a whitelist also referencing pattern files
+// into a whitelist map and returns the map path. A source is then whitelisted when
+// it matches either the map or one of the pattern files. Like the addresses of a
+// ConfigMap, fewer addresses than rate-limit-whitelist-inline-threshold are returned
+// to be whitelisted inline.
+func (p *ReqRateLimit) mixedWhitelist(addresses []string) (maps.Path, []string, error) {
+	if len(addresses) == 0 || p.dryRun || int64(len(addresses)) < p.whitelistInlineThreshold {
+		return "", addresses, nil
+	}
+	return p.whitelistMap(whitelistMapName(p.ingress, addresses), addresses)
+}
+
+// hostnamesWhitelist resolves the given hostnames into a whitelist map and returns
+// the map path. Hostnames which can't be resolved are skipped with a warning, or
+// make an error in strict mode. The map is filled again on every sync, so it
+// follows the DNS records without changing the configuration.
+func (p *ReqRateLimit) hostnamesWhitelist(hosts []string) (maps.Path, []string, error) {
+	if p.dryRun {
+		return "", nil, nil
+	}
+	var addresses []string
+	seen := map[string]struct{}{}
+	for _, host := range hosts {
+		resolved, err := p.lookupHost(host)
+		if err != nil {
+			if p.whitelistStrict {
+				return "", nil, fmt.Errorf("rate-limit-whitelist annotation: unable to resolve '%s': %w", host, err)
+			}
+			logger.Warningf("rate-limit-whitelist: unable to resolve '%s', ignoring it: %s", host, err)
+			continue
+		}
+		for _, address := range resolved {
+			if _, ok := seen[address]; !ok {
+				seen[address] = struct{}{}
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return "", nil, nil
+	}
+
+	// Named after the hostnames, not the addresses, so DNS changes only update the map content
+	return p.whitelistMap(whitelistMapName(p.ingress, hosts), addresses)
+}
+
+// whitelistMap fills the named map with the addresses and returns its path.
+// Without maps, when their initialization failed, up to maxInlineWhitelistAddresses
+// addresses are returned to be whitelisted inline, more make an error.
+func (p *ReqRateLimit) whitelistMap(mapName maps.Name, addresses []string) (maps.Path, []string, error) {
+	if p.maps == nil {
+		if len(addresses) > maxInlineWhitelistAddresses {
+			return "", nil, fmt.Errorf("rate-limit-whitelist annotation: %w, %d addresses can't be whitelisted inline, the limit is %d: check that the maps directory is writable", ErrMapsUnavailable, len(addresses), maxInlineWhitelistAddresses)
+		}
+		logger.Errorf("rate-limit-whitelist: maps are unavailable, whitelisting the %d addresses of map '%s' inline", len(addresses), mapName)
+		return "", addresses, nil
+	}
+	// Maps are named after a hash, the log tells which rate limit uses them.
+	// Maps are emptied on every sync, a map of the previous one is reused.
+	filled := !p.maps.MapExists(mapName)
+	action := "created"
+	if !filled || p.maps.MapExisted(mapName) {
+		action = "reused"
+	}
+	if filled {
+		for _, address := range addresses {
+			p.maps.MapAppend(mapName, address)
+		}
+	}
+	logger.Debugf("rate-limit-whitelist map %s: owner=%s map=%s entries=%d", action, p.logOwner(), mapName, len(addresses))
+	p.useMap(mapName)
+	p.updateMapMetrics(mapName, len(addresses), filled)
+	p.whitelistEntries += len(addresses)
+	return maps.GetPath(mapName), nil, nil
+}
+
+// onlyMap loads the addresses of rate-limit-only into a map and returns its path.
+// Without maps, up to maxInlineWhitelistAddresses addresses are returned to be
+// matched inline, more make an error.
+func (p *ReqRateLimit) onlyMap(addresses []string) (maps.Path, []string, error) {
+	if p.dryRun {
+		return "", addresses, nil
+	}
+	if p.maps == nil {
+		if len(addresses) > maxInlineWhitelistAddresses {
+			return "", nil, fmt.Errorf("rate-limit-only annotation: %w, %d addresses can't be listed inline, the limit is %d: check that the maps directory is writable", ErrMapsUnavailable, len(addresses), maxInlineWhitelistAddresses)
+		}
+		return "", addresses, nil
+	}
+	mapName := addressesMapName("ratelimit-only-", p.ingress, addresses)
+	if !p.maps.MapExists(mapName) {
+		for _, address := range addresses {
+			p.maps.MapAppend(mapName, address)
+		}
+	}
+	p.useMap(mapName)
+	return maps.GetPath(mapName), nil, nil
+}
+
+// whitelistMapName returns the name of the map holding the given whitelist entries.
+// The name is derived from the entries and from the ingress namespace and name,
+// so ingresses with the same whitelist content still get their own map.
+func whitelistMapName(ingress *store.Ingress, entries []string) maps.Name {
+	return addressesMapName("ratelimit-whitelist-", ingress, entries)
+}
+
+// addressesMapName returns the name, starting with prefix, of the map holding the given
+// entries for the ingress, see whitelistMapName.
+func addressesMapName(prefix string, ingress *store.Ingress, entries []string) maps.Name {
+	scope := ""
+	if ingress != nil {
+		scope = ingress.Namespace + "/" + ingress.Name
+	}
+	// Entries are sorted so the name doesn't depend on their order
+	sorted := slices.Clone(entries)
+	slices.Sort(sorted)
+	content := scope + "\n" + strings.Join(sorted, "\n")
+	return maps.Name(prefix + utils.Hash([]byte(content)))
+}
+
+// Kinds of the objects a whitelist can reference, as kind/namespace/name
+const (
+	whitelistRefConfigMap = "configmap"
+	whitelistRefSecret    = "secret"
+)
+
+// whitelistRefKind returns the kind of the object referenced by a whitelist input,
+// empty when it is not a reference.
+func whitelistRefKind(input string) string {
+	for _, kind := range []string{whitelistRefConfigMap, whitelistRefSecret} {
+		if strings.HasPrefix(input, kind+"/") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// parseObjectRef returns the namespace and the name of the object of the given kind
+// referenced as kind/namespace/name.
+func parseObjectRef(annName, kind, ref string) (ns, name string, err error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[0] != kind || parts[1] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("incorrect %s reference '%s' in %s annotation, expecting %s/namespace/name", kind, ref, annName, kind)
+	}
+	return parts[1], parts[2], nil
+}
+
+// parseRateLimitAddresses parses the input of an address list annotation.
+// Input can be:
+// 1. Comma-separated IPs/CIDRs
+// 2. One or more pattern file references (patterns/file1, patterns/file2)
+// 3. Hostnames, returned to be resolved by the caller
+// 4. Mix of them
+// Entries may also be split over several lines, with '#' comments.
+// Repeated entries are only kept once.
+func parseRateLimitAddresses(annName, input string) (ips, hosts []string, patterns []maps.Path, err error) {
+	seen := map[string]struct{}{}
+	var entries []string
+	for _, line := range utils.ParseListLines(input) {
+		entries = append(entries, strings.Split(line.Text, ",")...)
+	}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// Check if it's a pattern file reference
+		if strings.HasPrefix(entry, "patterns/") {
+			if _, ok := seen[entry]; !ok {
+				seen[entry] = struct{}{}
+				patterns = append(patterns, maps.Path(entry))
+			}
+			continue
+		}
+		// Validate it's a valid IPv4/IPv6 address or CIDR
+		address, ok := parseRateLimitAddress(entry)
+		if !ok && hostnameRegex.MatchString(entry) {
+			host := strings.ToLower(strings.TrimSuffix(entry, "."))
+			if _, ok := seen[host]; !ok {
+				seen[host] = struct{}{}
+				hosts = append(hosts, host)
+			}
+			continue
+		}
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%w '%s' in %s annotation", ErrInvalidAddress, entry, annName)
+		}
+		if _, ok := seen[address]; !ok {
+			seen[address] = struct{}{}
+			ips = append(ips, address)
+		}
+	}
+	return ips, hosts, patterns, nil
+}
+
+// WhitelistMatches returns whether the given IP address matches one of the addresses
+// or CIDRs of a parsed whitelist, as returned for rate-limit-whitelist, the way HAProxy
+// matches the source of requests. Pattern files and hostnames are ignored.
+// It returns an error when the IP address or an entry is invalid.
+func WhitelistMatches(entries []string, ip string) (bool, error) {
+	address, ok := parseRateLimitAddress(strings.TrimSpace(ip))
+	if !ok || strings.Contains(address, "/") {
+		return false, fmt.Errorf("%w '%s', expecting an IPv4 or IPv6 address", ErrInvalidAddress, ip)
+	}
+	source := net.ParseIP(address)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "patterns/") || hostnameRegex.MatchString(entry) {
+			continue
+		}
+		canonical, ok := parseRateLimitAddress(entry)
+		if !ok {
+			return false, fmt.Errorf("%w '%s' in whitelist", ErrInvalidAddress, entry)
+		}
+		if _, network, err := net.ParseCIDR(canonical); err == nil {
+			if network.Contains(source) {
+				return true, nil
+			}
+			continue
+		}
+		if net.ParseIP(canonical).Equal(source) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseRateLimitAddress validates an IPv4/IPv6 address or CIDR and returns it
+// in a form HAProxy accepts in a src ACL.
+func parseRateLimitAddress(entry string) (string, bool) {
+	address := entry
+	// IPv6 literals may be written between brackets, e.g. [2001:db8::1] or [2001:db8::]/64
+	if strings.HasPrefix(address, "[") {
+		end := strings.Index(address, "]")
+		if end == -1 {
+			return "", false
+		}
+		address = address[1:end] + address[end+1:]
+	}
+	// Zone-scoped IPv6 addresses (fe80::1%eth0) can't match a source address
+	if strings.Contains(address, "%") {
+		return "", false
+	}
+	// Addresses are canonicalized, so equivalent whitelists are written the same way
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String(), true
+	}
+	if _, network, err := net.ParseCIDR(address); err == nil {
+		return network.String(), true
+	}
+	return "", false
+}
+
+// processWhitelist applies the annotations of the whitelist, the blacklist and the
+// sources rate limits only apply to.
+func (a ReqRateLimitAnn) processWhitelist(k store.K8s, input string, annotations []map[string]string) (err error) {
+	switch a.name {
+	case "rate-limit-whitelist-strict":
+		a.parent.whitelistStrict, err = utils.GetBoolValue(input, a.name)
+	case "rate-limit-whitelist-merge":
+		a.parent.whitelistMerge, err = utils.GetBoolValue(input, a.name)
+	case "rate-limit-whitelist-inline-threshold":
+		var value int64
+		value, err = strconv.ParseInt(input, 10, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a positive integer or 0", input, a.name)
+		}
+		a.parent.whitelistInlineThreshold = value
+	case "rate-limit-whitelist-max-entries":
+		var value int64
+		value, err = strconv.ParseInt(input, 10, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting a positive integer or 0", input, a.name)
+		}
+		a.parent.whitelistMaxEntries = value
+	case "rate-limit-whitelist-watch":
+		a.parent.whitelistWatch, err = utils.GetBoolValue(input, a.name)
+	case "rate-limit-whitelist":
+		a.parent.releaseMaps()
+		a.parent.whitelistEntries = 0
+
+		// The ingress whitelist overrides the ConfigMap one, unless they are merged
+		var inputs []string
+		if input != "" {
+			inputs = []string{input}
+		}
+		if a.parent.whitelistMerge {
+			inputs = rateLimitValues(a.name, annotations)
+		}
+		var ips, addrPorts []string
+		var patterns []maps.Path
+		ips, addrPorts, patterns, err = a.parent.whitelist(k, inputs)
+		if err != nil {
+			return err
+		}
+		a.parent.whitelistEntries += len(ips) + len(addrPorts)
+
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			// Store IPs/CIDRs directly in the rule
+			limit.WhitelistIPs = ips
+
+			// Store pattern file references
+			limit.WhitelistMaps = patterns
+
+			limit.WhitelistAddrPorts = addrPorts
+		})
+	case "rate-limit-whitelist-header":
+		// Expecting "<header>: <value>", the value being a pattern file of accepted values or a single value
+		name, value, found := strings.Cut(input, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found || !headerNameRegex.MatchString(name) || !headerValueRegex.MatchString(value) {
+			return fmt.Errorf("incorrect value '%s' in %s annotation, expecting '<header>: <value>', '<header>: patterns/<file>' or '<header>: secret/<namespace>/<name>'", input, a.name)
+		}
+		var pattern maps.Path
+		switch {
+		case strings.HasPrefix(value, "patterns/"):
+			pattern, value = maps.Path(value), ""
+		case whitelistRefKind(value) == whitelistRefSecret:
+			// Accepted values like API keys are confidential, they are only matched from a map
+			pattern, err = a.parent.secretValuesMap(k, value)
+			if err != nil || pattern == "" {
+				return err
+			}
+			value = ""
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.WhitelistHeader = name
+			limit.WhitelistHeaderValue = value
+			limit.WhitelistHeaderMap = pattern
+		})
+	case "rate-limit-blacklist":
+		var ips, hosts []string
+		var patterns []maps.Path
+		ips, hosts, patterns, err = parseRateLimitAddresses(a.name, input)
+		if err != nil {
+			return err
+		}
+		if len(hosts) > 0 {
+			return fmt.Errorf("%w '%s' in %s annotation, hostnames are only supported in rate-limit-whitelist", ErrInvalidAddress, hosts[0], a.name)
+		}
+		// Blacklisted sources are denied once, by the first tier
+		a.parent.limit.BlacklistIPs = ips
+		a.parent.limit.BlacklistMaps = patterns
+	case "rate-limit-blacklist-status-code":
+		var value int64
+		value, err = utils.ParseInt(input)
+		if err != nil {
+			return err
+		}
+		a.parent.limit.BlacklistStatusCode = value
+	case "rate-limit-list-precedence":
+		if input != rules.RateLimitListPrecedenceWhitelist && input != rules.RateLimitListPrecedenceBlacklist {
+			return fmt.Errorf("incorrect precedence '%s' in %s annotation, expecting '%s' or '%s'",
+				input, a.name, rules.RateLimitListPrecedenceWhitelist, rules.RateLimitListPrecedenceBlacklist)
+		}
+		// The blacklist is only denied by the first tier
+		a.parent.limit.ListPrecedence = input
+	case "rate-limit-only":
+		var ips, hosts []string
+		var patterns []maps.Path
+		ips, hosts, patterns, err = parseRateLimitAddresses(a.name, input)
+		if err != nil {
+			return err
+		}
+		if len(hosts) > 0 {
+			return fmt.Errorf("%w '%s' in %s annotation, hostnames are only supported in rate-limit-whitelist", ErrInvalidAddress, hosts[0], a.name)
+		}
+		// Sources must match a single condition, so a pattern file can't be mixed with other entries
+		if len(patterns) > 0 && len(ips)+len(patterns) > 1 {
+			return fmt.Errorf("%s annotation: pattern file '%s' must be the only entry, list the addresses in it", a.name, patterns[0])
+		}
+		var onlyMap maps.Path
+		if len(patterns) > 0 {
+			onlyMap = patterns[0]
+		} else {
+			onlyMap, ips, err = a.parent.onlyMap(ips)
+			if err != nil {
+				return err
+			}
+		}
+		a.parent.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limit.OnlyIPs = ips
+			limit.OnlyMap = onlyMap
+		})
+	}
+	return err
+}
@@ -15,14 +15,29 @@
 package ingress
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	v3 "github.com/haproxytech/kubernetes-ingress/crs/api/ingress/v3"
+	"github.com/haproxytech/kubernetes-ingress/pkg/annotations/common"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
 )
 
 // TestReqRateLimit_Whitelist tests the rate-limit-whitelist annotation processing.
@@ -33,17 +48,21 @@ import (
 // - Single pattern file references (using "patterns/" prefix) are handled correctly
 // - Multiple pattern file references can be specified as comma-separated values
 // - Mixed IPs/CIDRs and pattern files are handled correctly (IPs stored separately from patterns)
+// - Entries may be written over several lines, with '#' comments and blank lines ignored
 // - The annotation fails with an error when rate-limit-requests is not configured first (dependency validation)
 // - Invalid IP addresses are rejected with appropriate error messages
 // - Invalid CIDR ranges (e.g., /33 prefix) are rejected with appropriate error messages
 // - Mixed valid and invalid entries in the whitelist are rejected entirely
+// - IPv6 addresses and CIDR ranges are accepted, alone or mixed with IPv4, with or without brackets
+// - Repeated IPs, CIDRs and pattern files are only stored once
+// - Invalid IPv6 CIDR ranges (e.g., /129 prefix) and zone-scoped IPv6 addresses are rejected
 //
 //revive:disable-next-line:function-length
 func TestReqRateLimit_Whitelist(t *testing.T) {
 	tests := []struct {
 		name              string
 		annotations       map[string]string
-		wantErr           bool
+		wantErr           error
 		wantWhitelistMap  bool
 		wantMapEntries    int
 		expectedMapName   string
@@ -55,7 +74,6 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "192.168.1.1",
 			},
-			wantErr:          false,
 			wantWhitelistMap: true,
 			wantMapEntries:   1,
 		},
@@ -65,7 +83,6 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "10.0.0.0/8",
 			},
-			wantErr:          false,
 			wantWhitelistMap: true,
 			wantMapEntries:   1,
 		},
@@ -75,7 +92,6 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "192.168.1.1, 10.0.0.0/8, 172.16.0.0/12",
 			},
-			wantErr:          false,
 			wantWhitelistMap: true,
 			wantMapEntries:   3,
 		},
@@ -85,7 +101,6 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "patterns/whitelist",
 			},
-			wantErr:           false,
 			wantWhitelistMap:  true,
 			wantMapEntries:    0, // No IP entries for pattern files
 			expectedWhitelist: "patterns/whitelist",
@@ -96,7 +111,6 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "patterns/whitelist1, patterns/whitelist2",
 			},
-			wantErr:          false,
 			wantWhitelistMap: true,
 			wantMapEntries:   0, // No IP entries for pattern files
 		},
@@ -106,16 +120,98 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "192.168.1.1, 10.0.0.0/8, patterns/whitelist1, patterns/whitelist2",
 			},
-			wantErr:          false,
 			wantWhitelistMap: true,
 			wantMapEntries:   2, // 2 IPs/CIDRs
 		},
+		{
+			name: "whitelist over several lines with comments",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "# trusted sources\n192.168.1.1, 10.0.0.0/8 # office\n  \t\npatterns/whitelist # partners\n",
+			},
+			wantWhitelistMap:  true,
+			wantMapEntries:    2,
+			expectedWhitelist: "patterns/whitelist",
+		},
+		{
+			name: "whitelist with duplicate IPs",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "192.168.1.1, 192.168.1.1",
+			},
+			wantWhitelistMap: true,
+			wantMapEntries:   1,
+		},
+		{
+			name: "whitelist with duplicate CIDRs, IPs and patterns",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "10.0.0.0/8, 192.168.1.1,10.0.0.0/8, patterns/whitelist, 192.168.1.1, patterns/whitelist",
+			},
+			wantWhitelistMap:  true,
+			wantMapEntries:    2,
+			expectedWhitelist: "patterns/whitelist",
+		},
+		{
+			name: "whitelist with IPv6 address",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "2001:db8::1",
+			},
+			wantWhitelistMap: true,
+			wantMapEntries:   1,
+		},
+		{
+			name: "whitelist with IPv6 CIDR",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "2001:db8::/64",
+			},
+			wantWhitelistMap: true,
+			wantMapEntries:   1,
+		},
+		{
+			name: "whitelist with bracketed IPv6 addresses",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "[2001:db8::1], [2001:db8:1::]/48",
+			},
+			wantWhitelistMap: true,
+			wantMapEntries:   2,
+		},
+		{
+			name: "whitelist with mixed IPv4 and IPv6",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "10.0.0.0/8, 2001:db8::/64, 192.168.1.1, ::1",
+			},
+			wantWhitelistMap: true,
+			wantMapEntries:   4,
+		},
+		{
+			name: "whitelist with invalid IPv6 CIDR",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "2001:db8::/129",
+			},
+			wantErr:          ErrInvalidAddress,
+			wantWhitelistMap: false,
+		},
+		{
+			name: "whitelist with zone-scoped IPv6 address",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-whitelist": "fe80::1%eth0",
+			},
+			wantErr:          ErrInvalidAddress,
+			wantWhitelistMap: false,
+		},
 		{
 			name: "whitelist without rate-limit-requests",
 			annotations: map[string]string{
 				"rate-limit-whitelist": "192.168.1.1",
 			},
-			wantErr:          true,
+			wantErr:          ErrMissingRateLimitRequests,
 			wantWhitelistMap: false,
 		},
 		{
@@ -124,7 +220,7 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "invalid-ip",
 			},
-			wantErr:          true,
+			wantErr:          ErrInvalidAddress,
 			wantWhitelistMap: false,
 		},
 		{
@@ -133,7 +229,7 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "192.168.1.0/33",
 			},
-			wantErr:          true,
+			wantErr:          ErrInvalidAddress,
 			wantWhitelistMap: false,
 		},
 		{
@@ -142,7 +238,7 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				"rate-limit-requests":  "100",
 				"rate-limit-whitelist": "192.168.1.1, invalid, 10.0.0.0/8",
 			},
-			wantErr:          true,
+			wantErr:          ErrInvalidAddress,
 			wantWhitelistMap: false,
 		},
 	}
@@ -157,7 +253,7 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 			rulesList := &rules.List{}
 
 			// Create ReqRateLimit handler
-			reqRateLimit := NewReqRateLimit(rulesList, mockMaps)
+			reqRateLimit := NewReqRateLimit(rulesList, nil, mockMaps)
 
 			// Process rate-limit-requests annotation first (if present)
 			if _, ok := tt.annotations["rate-limit-requests"]; ok {
@@ -170,8 +266,8 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 			ann := reqRateLimit.NewAnnotation("rate-limit-whitelist")
 			err = ann.Process(store.K8s{}, tt.annotations)
 
-			if tt.wantErr {
-				assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
 				return
 			}
 
@@ -181,6 +277,7 @@ func TestReqRateLimit_Whitelist(t *testing.T) {
 				assert.NotNil(t, reqRateLimit.limit)
 
 				// Check IPs/CIDRs
+				assert.Equal(t, tt.wantMapEntries, reqRateLimit.WhitelistEntries())
 				if tt.wantMapEntries > 0 {
 					assert.Len(t, reqRateLimit.limit.WhitelistIPs, tt.wantMapEntries)
 				}
@@ -211,7 +308,7 @@ func TestReqRateLimit_WhitelistWithPeriod(t *testing.T) {
 	rulesList := &rules.List{}
 
 	// Create ReqRateLimit handler
-	reqRateLimit := NewReqRateLimit(rulesList, mockMaps)
+	reqRateLimit := NewReqRateLimit(rulesList, nil, mockMaps)
 
 	annotations := map[string]string{
 		"rate-limit-requests":  "100",
@@ -253,7 +350,7 @@ func TestReqRateLimit_AllAnnotations(t *testing.T) {
 	rulesList := &rules.List{}
 
 	// Create ReqRateLimit handler
-	reqRateLimit := NewReqRateLimit(rulesList, mockMaps)
+	reqRateLimit := NewReqRateLimit(rulesList, nil, mockMaps)
 
 	annotations := map[string]string{
 		"rate-limit-requests":    "1200",
@@ -289,3 +386,4618 @@ func TestReqRateLimit_AllAnnotations(t *testing.T) {
 	assert.Contains(t, reqRateLimit.limit.WhitelistIPs, "192.168.1.100")
 	assert.NotNil(t, reqRateLimit.track.TableSize)
 }
+
+// TestReqRateLimit_Key tests the rate-limit-key annotation processing.
+// It validates that:
+// - The default track key remains "src" with an IP stick-table
+// - Header, URL parameter and cookie fetches are accepted and switch the stick-table type to string
+// - jwt(<claim>) tracks the claim of the bearer token, in a string stick-table
+// - A non-default key gets its own stick-table so it doesn't share counters with "src"
+// - Invalid fetch syntax is rejected with an error naming the annotation
+// - The annotation fails when rate-limit-requests is not configured first
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_Key(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		wantErr       bool
+		wantKey       string
+		wantTableType string
+		wantTableName string
+	}{
+		{
+			name: "source IP key",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-period":   "10s",
+				"rate-limit-key":      "src",
+			},
+			wantKey:       "src",
+			wantTableType: "ip",
+			wantTableName: "RateLimit-10000",
+		},
+		{
+			name: "header key",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-period":   "10s",
+				"rate-limit-key":      "hdr(X-Forwarded-For)",
+			},
+			wantKey:       "hdr(X-Forwarded-For)",
+			wantTableType: "string",
+		},
+		{
+			name: "url parameter key",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "url_param(api_key)",
+			},
+			wantKey:       "url_param(api_key)",
+			wantTableType: "string",
+		},
+		{
+			name: "cookie key with converter",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "req.cook(session),lower",
+			},
+			wantKey:       "req.cook(session),lower",
+			wantTableType: "string",
+		},
+		{
+			name: "JWT claim key",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "jwt(sub)",
+			},
+			wantKey:       "http_auth_bearer,jwt_payload_query('$.sub')",
+			wantTableType: "string",
+		},
+		{
+			name: "nested JWT claim key",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "jwt(user.id)",
+			},
+			wantKey:       "http_auth_bearer,jwt_payload_query('$.user.id')",
+			wantTableType: "string",
+		},
+		{
+			name: "invalid fetch syntax",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "hdr(X-Forwarded-For",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid JWT claim",
+			annotations: map[string]string{
+				"rate-limit-requests": "100",
+				"rate-limit-key":      "jwt($.sub')",
+			},
+			wantErr: true,
+		},
+		{
+			name: "key without rate-limit-requests",
+			annotations: map[string]string{
+				"rate-limit-key": "src",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+
+			for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+				if _, ok := tt.annotations[annName]; !ok {
+					continue
+				}
+				require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, tt.annotations))
+			}
+
+			err = reqRateLimit.NewAnnotation("rate-limit-key").Process(store.K8s{}, tt.annotations)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "rate-limit-key")
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantKey, reqRateLimit.track.TrackKey)
+			assert.Equal(t, tt.wantTableType, reqRateLimit.track.TableType)
+			assert.Equal(t, reqRateLimit.track.TableName, reqRateLimit.limit.TableName)
+			if tt.wantTableName != "" {
+				assert.Equal(t, tt.wantTableName, reqRateLimit.track.TableName)
+			}
+		})
+	}
+}
+
+// TestReqRateLimit_Blacklist tests the rate-limit-blacklist annotation processing.
+// It validates that:
+// - Single IP addresses and CIDR ranges are correctly parsed and stored directly
+// - Multiple IP addresses and CIDR ranges can be specified as comma-separated values
+// - Pattern file references (using "patterns/" prefix) are stored separately from IPs
+// - The annotation fails with an error when rate-limit-requests is not configured first
+// - Invalid IP addresses and CIDR ranges are rejected
+// - The blacklist doesn't alter the whitelist configuration
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_Blacklist(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantErr      error
+		wantIPs      []string
+		wantPatterns []maps.Path
+	}{
+		{
+			name: "blacklist with single IP",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "192.168.1.1",
+			},
+			wantIPs: []string{"192.168.1.1"},
+		},
+		{
+			name: "blacklist with CIDR",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "10.0.0.0/8",
+			},
+			wantIPs: []string{"10.0.0.0/8"},
+		},
+		{
+			name: "blacklist with multiple IPs and CIDRs",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "192.168.1.1, 10.0.0.0/8, 172.16.0.0/12",
+			},
+			wantIPs: []string{"192.168.1.1", "10.0.0.0/8", "172.16.0.0/12"},
+		},
+		{
+			name: "blacklist with mixed IPs and patterns",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "192.168.1.1, patterns/blacklist",
+			},
+			wantIPs:      []string{"192.168.1.1"},
+			wantPatterns: []maps.Path{"patterns/blacklist"},
+		},
+		{
+			name: "blacklist without rate-limit-requests",
+			annotations: map[string]string{
+				"rate-limit-blacklist": "192.168.1.1",
+			},
+			wantErr: ErrMissingRateLimitRequests,
+		},
+		{
+			name: "blacklist with invalid IP",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "invalid-ip",
+			},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name: "blacklist with invalid CIDR",
+			annotations: map[string]string{
+				"rate-limit-requests":  "100",
+				"rate-limit-blacklist": "192.168.1.0/33",
+			},
+			wantErr: ErrInvalidAddress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+
+			if _, ok := tt.annotations["rate-limit-requests"]; ok {
+				require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, tt.annotations))
+			}
+
+			err = reqRateLimit.NewAnnotation("rate-limit-blacklist").Process(store.K8s{}, tt.annotations)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantIPs, reqRateLimit.limit.BlacklistIPs)
+			assert.Equal(t, tt.wantPatterns, reqRateLimit.limit.BlacklistMaps)
+			assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+			assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+		})
+	}
+}
+
+// TestReqRateLimit_Path tests the rate-limit-path annotation processing.
+// It validates that:
+// - Path prefixes are stored on both the track and the limit rules
+// - Multiple comma-separated path prefixes are supported
+// - Ingresses with the same period but different paths get independent stick-tables
+// - Paths not starting with "/" are rejected
+// - The annotation fails when rate-limit-requests is not configured first
+func TestReqRateLimit_Path(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-path"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations)
+			if err != nil {
+				return reqRateLimit, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	api, err := process(t, map[string]string{
+		"rate-limit-requests": "10",
+		"rate-limit-period":   "10s",
+		"rate-limit-path":     "/api, /v2/api",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/api", "/v2/api"}, api.track.PathPrefixes)
+	assert.Equal(t, []string{"/api", "/v2/api"}, api.limit.PathPrefixes)
+	assert.Equal(t, api.track.TableName, api.limit.TableName)
+
+	static, err := process(t, map[string]string{
+		"rate-limit-requests": "1000",
+		"rate-limit-period":   "10s",
+		"rate-limit-path":     "/static",
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, api.track.TableName, static.track.TableName)
+	assert.NotEqual(t, "RateLimit-10000", static.track.TableName)
+
+	_, err = process(t, map[string]string{
+		"rate-limit-requests": "10",
+		"rate-limit-path":     "api",
+	})
+	assert.ErrorContains(t, err, "rate-limit-path")
+
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	err = NewReqRateLimit(&rules.List{}, nil, mockMaps).NewAnnotation("rate-limit-path").Process(store.K8s{}, map[string]string{"rate-limit-path": "/api"})
+	assert.ErrorIs(t, err, ErrMissingRateLimitRequests)
+}
+
+// TestReqRateLimit_RetryAfter tests the rate-limit-retry-after annotation processing.
+// It validates that:
+// - "true" derives the Retry-After delay from rate-limit-period, rounded up to seconds
+// - "true" without rate-limit-period uses the default 1s period
+// - An explicit time value is converted to seconds
+// - "false" leaves the Retry-After header disabled
+// - Invalid values are rejected with an error naming the annotation
+func TestReqRateLimit_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+		want        int64
+	}{
+		{
+			name:        "derived from period",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-period": "10s", "rate-limit-retry-after": "true"},
+			want:        10,
+		},
+		{
+			name:        "derived from sub-second period",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-period": "1500ms", "rate-limit-retry-after": "true"},
+			want:        2,
+		},
+		{
+			name:        "derived from default period",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-retry-after": "true"},
+			want:        1,
+		},
+		{
+			name:        "explicit value",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-period": "10s", "rate-limit-retry-after": "1m"},
+			want:        60,
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-retry-after": "false"},
+			want:        0,
+		},
+		{
+			name:        "invalid value",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-retry-after": "soon"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+			for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+				require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, tt.annotations))
+			}
+
+			err = reqRateLimit.NewAnnotation("rate-limit-retry-after").Process(store.K8s{}, tt.annotations)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "rate-limit-retry-after")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, reqRateLimit.limit.RetryAfter)
+		})
+	}
+}
+
+// TestReqRateLimit_TarpitDuration tests rate-limit-tarpit-duration outside of the ConfigMap.
+// It validates that:
+// - Ingresses inheriting the ConfigMap duration, or setting the same one, are accepted
+// - Ingresses and TCP services setting another duration get an error naming the annotation
+func TestReqRateLimit_TarpitDuration(t *testing.T) {
+	mockMaps, err := maps.New(t.TempDir(), nil)
+	require.NoError(t, err)
+	k := store.NewK8sStore(utils.OSArgs{})
+	k.ConfigMaps.Main.Annotations = map[string]string{"rate-limit-tarpit-duration": "10s"}
+	process := func(reqRateLimit *ReqRateLimit, annotations map[string]string) error {
+		return reqRateLimit.NewAnnotation("rate-limit-tarpit-duration").Process(k, annotations, k.ConfigMaps.Main.Annotations)
+	}
+
+	annotations := map[string]string{"rate-limit-requests": "100", "rate-limit-action": "tarpit"}
+	require.NoError(t, process(NewReqRateLimit(&rules.List{}, nil, mockMaps), annotations))
+	annotations["rate-limit-tarpit-duration"] = "10s"
+	require.NoError(t, process(NewReqRateLimit(&rules.List{}, nil, mockMaps), annotations))
+
+	annotations["rate-limit-tarpit-duration"] = "5s"
+	err = process(NewReqRateLimit(&rules.List{}, nil, mockMaps), annotations)
+	var annErr *common.AnnotationError
+	require.ErrorAs(t, err, &annErr)
+	assert.Equal(t, "rate-limit-tarpit-duration", annErr.Name)
+	assert.Equal(t, "5s", annErr.Value)
+	assert.ErrorContains(t, err, "can only be set in the controller ConfigMap")
+
+	err = NewTCPReqRateLimit(&rules.List{}, mockMaps, "default", "ssh", 2222).NewAnnotation("rate-limit-tarpit-duration").Process(k, annotations)
+	assert.ErrorContains(t, err, "can only be set in the controller ConfigMap")
+}
+
+// TestReqRateLimit_Action tests the rate-limit-action annotation processing.
+// It validates that:
+// - "deny" and "tarpit" are accepted and stored on the limit rule
+// - Unknown actions, or both actions at once, are rejected with an error naming the annotation
+// - The annotation fails when rate-limit-requests is not configured first
+func TestReqRateLimit_Action(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+		want        string
+	}{
+		{
+			name:        "deny",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-action": "deny"},
+			want:        rules.RateLimitActionDeny,
+		},
+		{
+			name:        "tarpit",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-action": "tarpit"},
+			want:        rules.RateLimitActionTarpit,
+		},
+		{
+			name:        "silent-drop",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-action": "silent-drop"},
+			want:        rules.RateLimitActionSilentDrop,
+		},
+		{
+			name:        "deny and tarpit",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-action": "deny,tarpit"},
+			wantErr:     true,
+		},
+		{
+			name:        "unknown action",
+			annotations: map[string]string{"rate-limit-requests": "100", "rate-limit-action": "drop"},
+			wantErr:     true,
+		},
+		{
+			name:        "action without rate-limit-requests",
+			annotations: map[string]string{"rate-limit-action": "tarpit"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+			require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, tt.annotations))
+
+			err = reqRateLimit.NewAnnotation("rate-limit-action").Process(store.K8s{}, tt.annotations)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "rate-limit-action")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, reqRateLimit.limit.Action)
+		})
+	}
+}
+
+// TestReqRateLimit_Position tests the rate-limit-position annotation processing.
+// It validates that:
+// - The position is set on the limit of every tier, changing the rule type they are evaluated with
+// - Without the annotation, limits are evaluated after the authentication
+// - Unknown positions are rejected
+func TestReqRateLimit_Position(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		annotations["rate-limit-connections"] = "20"
+		for _, annName := range ReqRateLimitAnnotations {
+			if err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				break
+			}
+		}
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-position": "before-auth"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 3)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, rules.RateLimitPositionBeforeAuth, tier.limit.Position)
+		assert.Equal(t, rules.REQ_RATELIMIT_BEFORE_AUTH, tier.limit.GetType())
+		assert.Equal(t, rules.REQ_TRACK, tier.track.GetType())
+	}
+
+	reqRateLimit, err = process(t, map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, rules.REQ_RATELIMIT, reqRateLimit.limit.GetType())
+
+	_, err = process(t, map[string]string{"rate-limit-position": "first"})
+	assert.ErrorContains(t, err, "rate-limit-position")
+}
+
+// TestReqRateLimit_Tiers tests multiple rate limit tiers defined with comma-separated values.
+// It validates that:
+// - Each rate-limit-requests value creates its own limit and track rules
+// - Each tier gets its own stick-table named after its period and its own stick counter
+// - Settings shared by all tiers (e.g. status code) are applied to every tier
+// - The number of periods must match the number of request limits
+// - Duplicate periods and more tiers than available stick counters are rejected
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_Tiers(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, *rules.List, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		rulesList := &rules.List{}
+		reqRateLimit := NewReqRateLimit(rulesList, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-status-code"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations)
+			if err != nil {
+				return reqRateLimit, rulesList, err
+			}
+		}
+		return reqRateLimit, rulesList, nil
+	}
+
+	reqRateLimit, rulesList, err := process(t, map[string]string{
+		"rate-limit-requests":    "20, 1000",
+		"rate-limit-period":      "1s, 1m",
+		"rate-limit-status-code": "429",
+	})
+	require.NoError(t, err)
+	assert.Len(t, *rulesList, 4)
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.Same(t, reqRateLimit.limit, reqRateLimit.tiers[0].limit)
+	assert.Same(t, reqRateLimit.track, reqRateLimit.tiers[0].track)
+
+	assert.Equal(t, int64(20), reqRateLimit.tiers[0].limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-1000", reqRateLimit.tiers[0].limit.TableName)
+	assert.Equal(t, "RateLimit-1000", reqRateLimit.tiers[0].track.TableName)
+	assert.Equal(t, int64(0), reqRateLimit.tiers[0].track.StickCounter)
+
+	assert.Equal(t, int64(1000), reqRateLimit.tiers[1].limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-60000", reqRateLimit.tiers[1].limit.TableName)
+	assert.Equal(t, "RateLimit-60000", reqRateLimit.tiers[1].track.TableName)
+	assert.Equal(t, int64(1), reqRateLimit.tiers[1].track.StickCounter)
+	assert.Equal(t, int64(1), reqRateLimit.tiers[1].limit.StickCounter)
+
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, int64(429), tier.limit.DenyStatusCode)
+	}
+
+	_, _, err = process(t, map[string]string{
+		"rate-limit-requests": "20, 1000",
+		"rate-limit-period":   "1s",
+	})
+	assert.ErrorContains(t, err, "rate-limit-period")
+
+	_, _, err = process(t, map[string]string{
+		"rate-limit-requests": "20, 1000",
+		"rate-limit-period":   "1s, 1000ms",
+	})
+	assert.ErrorContains(t, err, "duplicate period")
+
+	_, _, err = process(t, map[string]string{
+		"rate-limit-requests": "1, 2, 3, 4",
+	})
+	assert.ErrorContains(t, err, "rate-limit-requests")
+}
+
+// TestReqRateLimit_WhitelistIPv6Error tests that an invalid IPv6 entry is reported by name.
+// It validates that:
+// - The error message names the offending entry and the annotation
+// - Brackets around IPv6 literals are removed from the stored entries
+func TestReqRateLimit_WhitelistIPv6Error(t *testing.T) {
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+	annotations := map[string]string{
+		"rate-limit-requests":  "100",
+		"rate-limit-whitelist": "10.0.0.0/8, 2001:db8::/129",
+	}
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+	err = reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "'2001:db8::/129'")
+	assert.ErrorContains(t, err, "rate-limit-whitelist")
+
+	annotations["rate-limit-whitelist"] = "[2001:db8::1], [2001:db8:1::]/48"
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations))
+	assert.Equal(t, []string{"2001:db8::1", "2001:db8:1::/48"}, reqRateLimit.limit.WhitelistIPs)
+}
+
+// TestReqRateLimit_WhitelistPorts tests ip:port entries in rate-limit-whitelist.
+// It validates that:
+// - The spec accepts them
+// - TCP services whitelist them apart from the addresses, IPv6 ones without brackets
+// - Ports out of range are rejected
+// - Ingresses reject them, ports being only known to TCP services
+func TestReqRateLimit_WhitelistPorts(t *testing.T) {
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	annotations := map[string]string{
+		"rate-limit-requests":  "100",
+		"rate-limit-whitelist": "10.0.0.0/8, 192.168.1.1:5000, [2001:db8::1]:6000",
+	}
+	require.NoError(t, ValidateRateLimitAnnotation("rate-limit-whitelist", annotations["rate-limit-whitelist"]))
+	reqRateLimit := NewTCPReqRateLimit(&rules.List{}, mockMaps, "default", "ssh", 2222)
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations))
+	assert.Equal(t, []string{"10.0.0.0/8"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []string{"192.168.1.1:5000", "2001:db8::1:6000"}, reqRateLimit.limit.WhitelistAddrPorts)
+
+	annotations["rate-limit-whitelist"] = "192.168.1.1:70000"
+	err = reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations)
+	require.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "expecting a port from 1 to 65535")
+
+	reqRateLimit = NewReqRateLimit(&rules.List{}, nil, mockMaps)
+	annotations["rate-limit-whitelist"] = "10.0.0.0/8, 192.168.1.1:5000"
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+	err = reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations)
+	require.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "ports can only be whitelisted for TCP services")
+}
+
+// TestReqRateLimit_TCPOwner tests the owner of the rate limits of TCP services.
+// It validates that:
+// - Maps are registered with tcp/<namespace>/<service>:<port>, so TCP services don't share the ConfigMap owner
+// - Metrics are labeled with the namespace of the service and tcp/<service>:<port>
+func TestReqRateLimit_TCPOwner(t *testing.T) {
+	mockMaps, err := maps.New(t.TempDir(), nil)
+	require.NoError(t, err)
+	reqRateLimit := NewTCPReqRateLimit(&rules.List{}, mockMaps, "default", "ssh", 2222)
+	assert.Equal(t, "tcp/default/ssh:2222", reqRateLimit.mapOwner())
+	namespace, ingress := reqRateLimit.metricsLabels()
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, "tcp/ssh:2222", ingress)
+
+	reqRateLimit = NewReqRateLimit(&rules.List{}, nil, mockMaps)
+	assert.Equal(t, "configmap", reqRateLimit.mapOwner())
+}
+
+// TestReqRateLimit_WhitelistConfigMap tests rate-limit-whitelist referencing a ConfigMap.
+// It validates that:
+// - Addresses of every ConfigMap key are loaded into a whitelist map referenced by all tiers
+// - Blank lines and '#' comments are ignored
+// - A malformed line is reported with the ConfigMap key and line number
+// - Missing ConfigMaps and malformed references are rejected
+// - A ConfigMap without any address leaves the rate limit without whitelist
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_WhitelistConfigMap(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.ConfigMaps["trusted"] = &store.ConfigMap{
+		Namespace: "default",
+		Name:      "trusted",
+		Annotations: map[string]string{
+			"office": "# office network\n192.168.1.0/24\n  \t\n10.0.0.1  # gateway\n",
+			"vpn":    "2001:db8::/32\n10.0.0.1",
+		},
+	}
+	ns.ConfigMaps["broken"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "broken",
+		Annotations: map[string]string{"office": "192.168.1.0/24\n\nnot-an-ip"},
+	}
+	ns.ConfigMaps["empty"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "empty",
+		Annotations: map[string]string{"office": "# nothing yet\n"},
+	}
+
+	process := func(t *testing.T, whitelist string) (*ReqRateLimit, maps.Maps, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":  "10, 100",
+			"rate-limit-period":    "1s, 1m",
+			"rate-limit-whitelist": whitelist,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(k, annotations)
+		return reqRateLimit, mockMaps, err
+	}
+
+	reqRateLimit, mockMaps, err := process(t, "configmap/default/trusted")
+	require.NoError(t, err)
+	mapName := whitelistMapName(nil, []string{"192.168.1.0/24", "10.0.0.1", "2001:db8::/32"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	for _, tier := range reqRateLimit.tiers {
+		assert.Empty(t, tier.limit.WhitelistIPs)
+		assert.Equal(t, []maps.Path{maps.GetPath(mapName)}, tier.limit.WhitelistMaps)
+	}
+
+	_, _, err = process(t, "configmap/default/broken")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "'not-an-ip'")
+	assert.ErrorContains(t, err, "key 'office' line 3")
+
+	_, _, err = process(t, "configmap/default/missing")
+	assert.ErrorContains(t, err, "does not exist")
+
+	_, _, err = process(t, "configmap/trusted")
+	assert.ErrorContains(t, err, "expecting configmap/namespace/name")
+
+	reqRateLimit, _, err = process(t, "configmap/default/empty")
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+}
+
+// TestReqRateLimit_WhitelistSecret tests rate-limit-whitelist referencing a Secret.
+// It validates that:
+// - Addresses of every Secret key, decoded by Kubernetes, are loaded into a whitelist map
+// - Blank lines and '#' comments are ignored
+// - A malformed line is reported with the Secret key and line number, without its content
+// - Missing Secrets, binary values and malformed references are rejected
+func TestReqRateLimit_WhitelistSecret(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.Secret["partners"] = &store.Secret{
+		Namespace: "default",
+		Name:      "partners",
+		Data: map[string][]byte{
+			"acme":   []byte("# acme offices\n203.0.113.0/24\n\n198.51.100.7  # gateway\n"),
+			"globex": []byte("2001:db8:beef::/48\n198.51.100.7"),
+		},
+	}
+	ns.Secret["broken"] = &store.Secret{
+		Namespace: "default",
+		Name:      "broken",
+		Data:      map[string][]byte{"acme": []byte("203.0.113.0/24\nconfidential-typo")},
+	}
+	ns.Secret["binary"] = &store.Secret{
+		Namespace: "default",
+		Name:      "binary",
+		Data:      map[string][]byte{"tls.key": {0xff, 0xfe, 0x00}},
+	}
+
+	process := func(t *testing.T, whitelist string) (*ReqRateLimit, maps.Maps, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":  "10",
+			"rate-limit-whitelist": whitelist,
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(k, annotations)
+		return reqRateLimit, mockMaps, err
+	}
+
+	reqRateLimit, mockMaps, err := process(t, "secret/default/partners")
+	require.NoError(t, err)
+	mapName := whitelistMapName(nil, []string{"203.0.113.0/24", "198.51.100.7", "2001:db8:beef::/48"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName)}, reqRateLimit.limit.WhitelistMaps)
+
+	_, _, err = process(t, "secret/default/broken")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "secret 'default/broken' key 'acme' line 2")
+	assert.NotContains(t, err.Error(), "confidential-typo")
+
+	_, _, err = process(t, "secret/default/binary")
+	assert.ErrorContains(t, err, "secret 'default/binary' key 'tls.key' is not text")
+
+	_, _, err = process(t, "secret/default/missing")
+	assert.ErrorContains(t, err, "secret 'default/missing' does not exist")
+
+	_, _, err = process(t, "secret/partners")
+	assert.ErrorContains(t, err, "expecting secret/namespace/name")
+}
+
+// TestReqRateLimit_DefaultSize tests the table size used when rate-limit-size is omitted.
+// It validates that:
+// - Omitting rate-limit-size yields the 100k default on every tier instead of nil
+// - rate-limit-size set in the controller configmap overrides the default
+// - rate-limit-size set in the ingress overrides the controller configmap
+// - Units are accepted in upper case and sizes HAProxy rejects are rejected
+func TestReqRateLimit_DefaultSize(t *testing.T) {
+	process := func(t *testing.T, annotations ...map[string]string) *ReqRateLimit {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-size"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations...))
+		}
+		return reqRateLimit
+	}
+	ingressAnns := map[string]string{
+		"rate-limit-requests": "10, 100",
+		"rate-limit-period":   "1s, 1m",
+	}
+
+	reqRateLimit := process(t, ingressAnns)
+	for _, tier := range reqRateLimit.tiers {
+		require.NotNil(t, tier.track.TableSize)
+		assert.Equal(t, int64(102400), *tier.track.TableSize)
+	}
+
+	reqRateLimit = process(t, ingressAnns, map[string]string{"rate-limit-size": "1m"})
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, int64(1048576), *tier.track.TableSize)
+	}
+
+	ingressAnns["rate-limit-size"] = "500"
+	reqRateLimit = process(t, ingressAnns, map[string]string{"rate-limit-size": "1m"})
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, int64(500), *tier.track.TableSize)
+	}
+
+	for size, want := range map[string]int64{"200K": 200 * 1024, " 2G ": 2 * 1024 * 1024 * 1024} {
+		ingressAnns["rate-limit-size"] = size
+		reqRateLimit = process(t, ingressAnns)
+		assert.Equal(t, want, *reqRateLimit.track.TableSize, size)
+	}
+
+	for _, size := range []string{"200kb", "0", "-1k", "4g"} {
+		reqRateLimit = NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-size": size}
+		require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+		err := reqRateLimit.NewAnnotation("rate-limit-size").Process(store.K8s{}, annotations)
+		assert.ErrorContains(t, err, "rate-limit-size", size)
+	}
+}
+
+// TestReqRateLimit_AutoSize tests the auto value of the rate-limit-size annotation.
+// It validates that:
+// - The size is derived from the distinct endpoint addresses of the services the ingress routes to
+// - The size never goes below the default one nor above the HAProxy maximum
+// - The default size is used when the ingress or its endpoints are unknown
+func TestReqRateLimit_AutoSize(t *testing.T) {
+	endpoints := func(count int) map[string]*store.PortEndpoints {
+		addresses := map[string]struct{}{}
+		for i := range count {
+			addresses[fmt.Sprintf("10.%d.%d.%d", i>>16, i>>8&255, i&255)] = struct{}{}
+		}
+		return map[string]*store.PortEndpoints{"http": {Addresses: addresses, Port: 8080}}
+	}
+	k8s := func(counts map[string]int) store.K8s {
+		ns := &store.Namespace{Endpoints: map[string]map[string]*store.Endpoints{}}
+		for svc, count := range counts {
+			ns.Endpoints[svc] = map[string]*store.Endpoints{svc + "-slice": {Ports: endpoints(count)}}
+		}
+		return store.K8s{Namespaces: map[string]*store.Namespace{"ns": ns}}
+	}
+	ingress := &store.Ingress{IngressCore: store.IngressCore{
+		Namespace: "ns",
+		Name:      "app",
+		Rules: map[string]*store.IngressRule{"example.com": {Paths: map[string]*store.IngressPath{
+			"/":    {SvcNamespace: "ns", SvcName: "front"},
+			"/api": {SvcNamespace: "ns", SvcName: "api"},
+		}}},
+	}}
+	process := func(t *testing.T, k store.K8s, ingress *store.Ingress) int64 {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ingress, nil)
+		annotations := map[string]string{"rate-limit-requests": "10, 100", "rate-limit-period": "1s, 1m", "rate-limit-size": " Auto "}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-size"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		require.Len(t, reqRateLimit.tiers, 2)
+		assert.Equal(t, *reqRateLimit.tiers[0].track.TableSize, *reqRateLimit.tiers[1].track.TableSize)
+		return *reqRateLimit.track.TableSize
+	}
+
+	// The api endpoints are the first front ones, counted once
+	assert.Equal(t, 12*autoRateLimitSizePerEndpoint, process(t, k8s(map[string]int{"front": 12, "api": 3}), ingress))
+	assert.Equal(t, defaultRateLimitSize, process(t, k8s(map[string]int{"front": 2}), ingress))
+	assert.Equal(t, defaultRateLimitSize, process(t, k8s(map[string]int{}), ingress))
+	assert.Equal(t, defaultRateLimitSize, process(t, store.K8s{}, ingress))
+	assert.Equal(t, defaultRateLimitSize, process(t, k8s(map[string]int{"front": 12}), nil))
+	assert.Equal(t, maxRateLimitSize, process(t, k8s(map[string]int{"front": 65536 * 4}), ingress))
+}
+
+// TestReqRateLimit_PerReplica tests the rate-limit-per-replica annotation.
+// It validates that:
+// - The limit of every tier is multiplied by the ready endpoints of the services the ingress routes to
+// - The limit is kept when the endpoints or the ingress are unknown, and when the annotation is false
+// - The scaled limit is bounded, and it can't be combined with auto limits
+func TestReqRateLimit_PerReplica(t *testing.T) {
+	k8s := func(counts map[string]int) store.K8s {
+		ns := &store.Namespace{Endpoints: map[string]map[string]*store.Endpoints{}}
+		for svc, count := range counts {
+			addresses := map[string]struct{}{}
+			for i := range count {
+				addresses[fmt.Sprintf("10.0.%d.%d", i>>8, i&255)] = struct{}{}
+			}
+			ns.Endpoints[svc] = map[string]*store.Endpoints{svc + "-slice": {Ports: map[string]*store.PortEndpoints{
+				"http": {Addresses: addresses, Port: 8080},
+			}}}
+		}
+		return store.K8s{Namespaces: map[string]*store.Namespace{"ns": ns}}
+	}
+	ingress := &store.Ingress{IngressCore: store.IngressCore{
+		Namespace: "ns",
+		Name:      "app",
+		Rules: map[string]*store.IngressRule{"example.com": {Paths: map[string]*store.IngressPath{
+			"/": {SvcNamespace: "ns", SvcName: "front"},
+		}}},
+	}}
+	process := func(t *testing.T, k store.K8s, ingress *store.Ingress, annotations map[string]string) ([]int64, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ingress, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		var limits []int64
+		reqRateLimit.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+			limits = append(limits, limit.ReqsLimit)
+		})
+		return limits, errors.Join(errs...)
+	}
+	annotations := map[string]string{"rate-limit-requests": "10, 100", "rate-limit-period": "1s, 1m", "rate-limit-per-replica": "true"}
+
+	for replicas, want := range map[int][]int64{3: {30, 300}, 1: {10, 100}, 0: {10, 100}} {
+		limits, err := process(t, k8s(map[string]int{"front": replicas}), ingress, annotations)
+		require.NoError(t, err)
+		assert.Equal(t, want, limits, replicas)
+	}
+	limits, err := process(t, store.K8s{}, ingress, annotations)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 100}, limits)
+	limits, err = process(t, k8s(map[string]int{"front": 3}), nil, annotations)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 100}, limits)
+	limits, err = process(t, k8s(map[string]int{"front": 3}), ingress, map[string]string{"rate-limit-requests": "10", "rate-limit-per-replica": "false"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10}, limits)
+
+	limits, err = process(t, k8s(map[string]int{"front": 4}), ingress, map[string]string{"rate-limit-requests": "2000000000", "rate-limit-per-replica": "true"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{maxScaledRateLimit}, limits)
+
+	_, err = process(t, k8s(map[string]int{"front": 3}), ingress, map[string]string{"rate-limit-requests": "auto", "rate-limit-per-replica": "true"})
+	assert.ErrorContains(t, err, "rate-limit-per-replica annotation can't be combined")
+}
+
+// TestReqRateLimit_TrackOnly tests the rate-limit-track-only annotation.
+// It validates that:
+// - No deny rule is added when track-only is enabled, while every tier is still tracked
+// - Deny rules are kept when track-only is disabled
+// - An invalid boolean is rejected
+// - The annotation requires rate-limit-requests
+func TestReqRateLimit_TrackOnly(t *testing.T) {
+	process := func(t *testing.T, trackOnly string) (*rules.List, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		ruleList := &rules.List{}
+		reqRateLimit := NewReqRateLimit(ruleList, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":   "10, 100",
+			"rate-limit-period":     "1s, 1m",
+			"rate-limit-track-only": trackOnly,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-track-only").Process(store.K8s{}, annotations)
+		return ruleList, err
+	}
+	countRules := func(ruleList *rules.List) (limits, tracks int) {
+		for _, rule := range *ruleList {
+			switch rule.(type) {
+			case *rules.ReqRateLimit:
+				limits++
+			case *rules.ReqTrack:
+				tracks++
+			}
+		}
+		return limits, tracks
+	}
+
+	ruleList, err := process(t, "true")
+	require.NoError(t, err)
+	limits, tracks := countRules(ruleList)
+	assert.Equal(t, 0, limits)
+	assert.Equal(t, 2, tracks)
+
+	ruleList, err = process(t, "false")
+	require.NoError(t, err)
+	limits, tracks = countRules(ruleList)
+	assert.Equal(t, 2, limits)
+	assert.Equal(t, 2, tracks)
+
+	_, err = process(t, "maybe")
+	require.Error(t, err)
+
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+	err = reqRateLimit.NewAnnotation("rate-limit-track-only").Process(store.K8s{}, map[string]string{"rate-limit-track-only": "true"})
+	assert.ErrorIs(t, err, ErrMissingRateLimitRequests)
+}
+
+// TestReqRateLimit_CompoundPeriod tests the units and compound durations accepted by rate-limit-period.
+// It validates that:
+// - Seconds, minutes and hours are tracked over their duration in milliseconds, e.g. 1m in the RateLimit-60000 table
+// - 1m30s is tracked over 90 seconds in the RateLimit-90000 table
+func TestReqRateLimit_CompoundPeriod(t *testing.T) {
+	for period, want := range map[string]int64{
+		"10s":   10000,
+		"1m":    60000,
+		"5m":    300000,
+		"1h":    3600000,
+		"1m30s": 90000,
+	} {
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests": "100",
+			"rate-limit-period":   period,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		tableName := fmt.Sprintf("RateLimit-%d", want)
+		assert.Equal(t, want, *reqRateLimit.track.TablePeriod, period)
+		assert.Equal(t, tableName, reqRateLimit.track.TableName, period)
+		assert.Equal(t, tableName, reqRateLimit.limit.TableName, period)
+	}
+}
+
+// fakeRateSource reports fixed peak rates per table.
+type fakeRateSource map[string]int64
+
+func (f fakeRateSource) PeakRate(tableName string) (int64, bool) {
+	rate, ok := f[tableName]
+	return rate, ok
+}
+
+// TestReqRateLimit_Auto tests rate-limit-requests auto, deriving the limit from an observed peak rate.
+// It validates that:
+// - The limit is the peak rate of the final table increased by the default or the given headroom, rounded up
+// - Without observed rate, or without rate source, requests are only tracked
+// - rate-limit-auto-headroom requires rate-limit-requests auto
+func TestReqRateLimit_Auto(t *testing.T) {
+	source := fakeRateSource{"RateLimit-60000": 200, "RateLimit-1000": 3}
+	process := func(t *testing.T, source RateSource, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		reqRateLimit.rateSource = source
+		for _, annName := range ReqRateLimitAnnotations {
+			if err := reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				return reqRateLimit, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, source, map[string]string{"rate-limit-requests": "auto", "rate-limit-period": "1m"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), reqRateLimit.limit.ReqsLimit)
+
+	reqRateLimit, err = process(t, source, map[string]string{"rate-limit-requests": "AUTO", "rate-limit-period": "1m", "rate-limit-auto-headroom": "10%"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(220), reqRateLimit.limit.ReqsLimit)
+
+	reqRateLimit, err = process(t, source, map[string]string{"rate-limit-requests": "auto"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), reqRateLimit.limit.ReqsLimit)
+
+	for _, source := range []RateSource{fakeRateSource{}, nil} {
+		reqRateLimit, err = process(t, source, map[string]string{"rate-limit-requests": "auto", "rate-limit-period": "1m"})
+		require.NoError(t, err)
+		assert.Zero(t, reqRateLimit.limit.ReqsLimit)
+		assert.Equal(t, "RateLimit-60000", reqRateLimit.track.TableName)
+	}
+
+	_, err = process(t, source, map[string]string{"rate-limit-requests": "100", "rate-limit-auto-headroom": "10%"})
+	assert.ErrorContains(t, err, "requires rate-limit-requests to be auto")
+}
+
+// TestReqRateLimit_Burst tests the rate-limit-burst and rate-limit-burst-period annotations processing.
+// It validates that:
+// - The burst is tracked with the next stick counter, over 1s by default, in a table named after the limit one
+// - The burst track follows the key and the stick counter slot of the rate limit
+// - The rate limit denies requests when its limit and the burst limit are both exceeded
+// - A burst period not shorter than the rate-limit-period, several request tiers, or a period without burst are rejected
+func TestReqRateLimit_Burst(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range ReqRateLimitAnnotations {
+			if err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				break
+			}
+		}
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-requests": "600", "rate-limit-period": "1m", "rate-limit-burst": "20"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	burst := reqRateLimit.tiers[1].track
+	assert.Same(t, burst, reqRateLimit.limit.Burst)
+	assert.Equal(t, int64(20), reqRateLimit.limit.BurstLimit)
+	assert.Equal(t, "RateLimit-60000-burst-1000", burst.TableName)
+	assert.Equal(t, int64(1000), *burst.TablePeriod)
+	assert.Equal(t, int64(1), burst.StickCounter)
+	assert.Contains(t, *reqRateLimit.rules, rules.Rule(burst))
+	assert.NotContains(t, *reqRateLimit.rules, rules.Rule(reqRateLimit.tiers[1].limit))
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-requests":     "600",
+		"rate-limit-period":       "1m",
+		"rate-limit-key":          "req.hdr(X-Api-Key)",
+		"rate-limit-burst":        "20",
+		"rate-limit-burst-period": "500ms",
+		"rate-limit-sc-slot":      "1",
+	})
+	require.NoError(t, err)
+	burst = reqRateLimit.limit.Burst
+	assert.Equal(t, reqRateLimit.track.TableName+"-burst-500", burst.TableName)
+	assert.Equal(t, "req.hdr(X-Api-Key)", burst.TrackKey)
+	assert.Equal(t, "string", burst.TableType)
+	assert.Equal(t, int64(2), burst.StickCounter)
+
+	for _, annotations := range []map[string]string{
+		{"rate-limit-requests": "600", "rate-limit-burst": "20"},
+		{"rate-limit-requests": "600", "rate-limit-period": "1m", "rate-limit-burst": "20", "rate-limit-burst-period": "1m"},
+		{"rate-limit-requests": "10, 600", "rate-limit-period": "1s, 1m", "rate-limit-burst": "20"},
+		{"rate-limit-requests": "600", "rate-limit-period": "1m", "rate-limit-burst-period": "1s"},
+	} {
+		_, err = process(t, annotations)
+		assert.ErrorContains(t, err, "rate-limit-burst", annotations)
+	}
+}
+
+// TestReqRateLimit_GlobalDefault tests the default rate limit set in the controller ConfigMap.
+// It validates that:
+// - An ingress without rate-limit annotations uses the ConfigMap requests and period
+// - An ingress setting rate-limit-requests fully overrides the default: the ConfigMap period is not used
+// - An ingress setting both rate-limit-requests and rate-limit-period uses its own values
+// - Other rate-limit annotations of the ConfigMap still apply to ingresses overriding the default
+func TestReqRateLimit_GlobalDefault(t *testing.T) {
+	configMap := map[string]string{
+		"rate-limit-requests":    "10, 100",
+		"rate-limit-period":      "10s, 1m",
+		"rate-limit-status-code": "429",
+	}
+	process := func(t *testing.T, ingress map[string]string) *ReqRateLimit {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-status-code"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, ingress, configMap))
+		}
+		return reqRateLimit
+	}
+
+	reqRateLimit := process(t, map[string]string{})
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.Equal(t, int64(10), reqRateLimit.tiers[0].limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-10000", reqRateLimit.tiers[0].limit.TableName)
+	assert.Equal(t, int64(100), reqRateLimit.tiers[1].limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-60000", reqRateLimit.tiers[1].limit.TableName)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-requests": "50"})
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, int64(50), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(1000), *reqRateLimit.track.TablePeriod)
+	assert.Equal(t, "RateLimit-1000", reqRateLimit.limit.TableName)
+	assert.Equal(t, int64(429), reqRateLimit.limit.DenyStatusCode)
+
+	reqRateLimit = process(t, map[string]string{
+		"rate-limit-requests": "50",
+		"rate-limit-period":   "5s",
+	})
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, int64(50), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-5000", reqRateLimit.limit.TableName)
+}
+
+// TestReqRateLimit_DefaultStatusCode tests the status code set with --rate-limit-default-status-code.
+// It validates that:
+// - Every tier of a rate limit without rate-limit-status-code is denied with the controller default
+// - rate-limit-status-code overrides it, whether set on the ingress or in the ConfigMap
+// - Without controller default, the status code is left to the rule default
+// - An invalid controller default is rejected
+func TestReqRateLimit_DefaultStatusCode(t *testing.T) {
+	process := func(t *testing.T, statusCode int64, annotations ...map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		k := store.K8s{RateLimitDefaultStatusCode: statusCode}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations...))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+	ingress := map[string]string{"rate-limit-requests": "10, 100", "rate-limit-period": "1s, 1m"}
+
+	reqRateLimit, err := process(t, 429, ingress)
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, int64(429), tier.limit.DenyStatusCode)
+	}
+
+	reqRateLimit, err = process(t, 429, map[string]string{"rate-limit-requests": "10", "rate-limit-status-code": "503"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(503), reqRateLimit.limit.DenyStatusCode)
+
+	reqRateLimit, err = process(t, 429, ingress, map[string]string{"rate-limit-status-code": "403"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(403), reqRateLimit.limit.DenyStatusCode)
+
+	reqRateLimit, err = process(t, 0, ingress)
+	require.NoError(t, err)
+	assert.Zero(t, reqRateLimit.limit.DenyStatusCode)
+
+	_, err = process(t, 302, ingress)
+	require.ErrorIs(t, err, ErrInvalidStatusCode)
+	assert.ErrorContains(t, err, "--rate-limit-default-status-code")
+}
+
+// TestReqRateLimit_SharedTable tests the rate-limit-shared-table annotation processing.
+// It validates that:
+// - Two ingresses of the same namespace referencing the same name track requests in the same table
+// - Ingresses of different namespaces referencing the same name get distinct tables
+// - The shared name replaces the period derived one, tiers being told apart by their period
+// - Invalid table names are rejected
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_SharedTable(t *testing.T) {
+	process := func(t *testing.T, ingress *store.Ingress) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ingress, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-shared-table"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, ingress.Annotations)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+	newIngress := func(namespace, name string, annotations map[string]string) *store.Ingress {
+		return &store.Ingress{IngressCore: store.IngressCore{Namespace: namespace, Name: name, Annotations: annotations}}
+	}
+
+	shop, err := process(t, newIngress("default", "shop", map[string]string{
+		"rate-limit-requests":     "100",
+		"rate-limit-period":       "10s",
+		"rate-limit-shared-table": "frontend",
+	}))
+	require.NoError(t, err)
+	cart, err := process(t, newIngress("default", "cart", map[string]string{
+		"rate-limit-requests":     "50",
+		"rate-limit-period":       "10s",
+		"rate-limit-shared-table": "frontend",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "RateLimit-default-frontend", shop.track.TableName)
+	assert.Equal(t, "RateLimit-default-frontend", shop.limit.TableName)
+	assert.Equal(t, shop.track.TableName, cart.track.TableName)
+	assert.Equal(t, shop.limit.TableName, cart.limit.TableName)
+
+	other, err := process(t, newIngress("staging", "shop", map[string]string{
+		"rate-limit-requests":     "100",
+		"rate-limit-period":       "10s",
+		"rate-limit-shared-table": "frontend",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "RateLimit-staging-frontend", other.track.TableName)
+
+	tiers, err := process(t, newIngress("default", "api", map[string]string{
+		"rate-limit-requests":     "10, 100",
+		"rate-limit-period":       "1s, 1m",
+		"rate-limit-shared-table": "api",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "RateLimit-default-api-1000", tiers.tiers[0].track.TableName)
+	assert.Equal(t, "RateLimit-default-api-60000", tiers.tiers[1].track.TableName)
+	assert.Equal(t, "RateLimit-default-api-60000", tiers.tiers[1].limit.TableName)
+
+	_, err = process(t, newIngress("default", "api", map[string]string{
+		"rate-limit-requests":     "10",
+		"rate-limit-shared-table": "my table",
+	}))
+	assert.ErrorContains(t, err, "rate-limit-shared-table")
+}
+
+// TestReqRateLimit_Connections tests the rate-limit-connections annotation processing.
+// It validates that:
+// - Alone, it tracks conn_cur of source IPs in the RateLimitConn table with sc0
+// - Along with rate-limit-requests, it uses the next stick counter and the key of the request tables
+// - The whitelist applies to the connections limit too
+// - It is rejected when rate-limit-requests already uses every stick counter
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_Connections(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-key", "rate-limit-connections", "rate-limit-whitelist"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-connections": "20"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, "RateLimitConn", reqRateLimit.track.TableName)
+	assert.Equal(t, "src", reqRateLimit.track.TrackKey)
+	assert.Equal(t, rules.RateLimitCounterConnCur, reqRateLimit.track.Counter)
+	assert.Equal(t, rules.RateLimitCounterConnCur, reqRateLimit.limit.Counter)
+	assert.Equal(t, int64(20), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(0), reqRateLimit.limit.StickCounter)
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-requests":    "100",
+		"rate-limit-period":      "10s",
+		"rate-limit-key":         "hdr(X-Client-Id)",
+		"rate-limit-connections": "20",
+		"rate-limit-whitelist":   "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	conn := reqRateLimit.tiers[1]
+	assert.Equal(t, reqRateLimit.track.TableName+"-conn", conn.track.TableName)
+	assert.Equal(t, conn.track.TableName, conn.limit.TableName)
+	assert.Equal(t, "hdr(X-Client-Id)", conn.track.TrackKey)
+	assert.Equal(t, "string", conn.track.TableType)
+	assert.Equal(t, int64(1), conn.track.StickCounter)
+	assert.Equal(t, int64(1), conn.limit.StickCounter)
+	assert.Equal(t, []string{"10.0.0.0/8"}, conn.limit.WhitelistIPs)
+
+	_, err = process(t, map[string]string{
+		"rate-limit-requests":    "10, 100, 1000",
+		"rate-limit-period":      "1s, 1m, 1h",
+		"rate-limit-connections": "20",
+	})
+	assert.ErrorContains(t, err, "rate-limit-connections")
+}
+
+// TestReqRateLimit_DenyMessage tests the rate-limit-deny-message annotation processing.
+// It validates that:
+// - The message is set on every tier, without surrounding whitespace
+// - A blank message keeps the plain status response
+// - Messages longer than 1024 characters or with control characters are rejected
+func TestReqRateLimit_DenyMessage(t *testing.T) {
+	process := func(t *testing.T, message string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":     "10, 100",
+			"rate-limit-period":       "1s, 1m",
+			"rate-limit-deny-message": message,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-deny-message").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, " Too many requests ")
+	require.NoError(t, err)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "Too many requests", tier.limit.DenyMessage)
+	}
+
+	reqRateLimit, err = process(t, "   ")
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.DenyMessage)
+
+	_, err = process(t, strings.Repeat("a", 1025))
+	assert.ErrorContains(t, err, "1025 characters")
+
+	_, err = process(t, "Too many\nrequests")
+	assert.ErrorContains(t, err, "rate-limit-deny-message")
+}
+
+// TestReqRateLimit_ErrorFile tests the rate-limit-errorfile annotation processing.
+// It validates that:
+// - The errorfile of --configmap-errorfiles is set on every tier, as a path of the errorfiles directory
+// - A blank value keeps the errorfile of the status code
+// - Errorfiles missing from --configmap-errorfiles are rejected, but not by Validate which doesn't load it
+// - It can't be combined with rate-limit-deny-message, rate-limit-retry-after or silent-drop
+func TestReqRateLimit_ErrorFile(t *testing.T) {
+	k := store.K8s{ConfigMaps: store.ConfigMaps{Errorfiles: &store.ConfigMap{
+		Annotations: map[string]string{"429": "HTTP/1.1 429 Too Many Requests\r\n\r\nSlow down"},
+	}}}
+	process := func(t *testing.T, k store.K8s, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, k, map[string]string{"rate-limit-errorfile": " 429 "})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "errorfiles/429", tier.limit.ErrorFile)
+	}
+
+	reqRateLimit, err = process(t, k, map[string]string{"rate-limit-errorfile": ""})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.ErrorFile)
+
+	_, err = process(t, k, map[string]string{"rate-limit-errorfile": "503"})
+	require.ErrorIs(t, err, ErrUnknownErrorFile)
+	assert.ErrorContains(t, err, "'503' in rate-limit-errorfile")
+	_, err = process(t, store.K8s{}, map[string]string{"rate-limit-errorfile": "429"})
+	require.ErrorIs(t, err, ErrUnknownErrorFile)
+	require.NoError(t, NewReqRateLimit(&rules.List{}, nil, nil).Validate(map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-errorfile": "503",
+	}))
+
+	for _, annotations := range []map[string]string{
+		{"rate-limit-deny-message": "Slow down"},
+		{"rate-limit-retry-after": "true"},
+		{"rate-limit-action": "silent-drop"},
+	} {
+		annotations["rate-limit-errorfile"] = "429"
+		_, err = process(t, k, annotations)
+		assert.ErrorContains(t, err, "rate-limit-errorfile annotation can't be combined")
+	}
+}
+
+// TestReqRateLimit_Redirect tests the rate-limit-redirect annotation processing.
+// It validates that:
+// - The location is set on every tier, as an absolute path or an http(s) URL
+// - A blank location keeps the deny action
+// - Relative, protocol-relative and non http(s) locations are rejected
+// - It can't be combined with rate-limit-deny-message, rate-limit-retry-after or another action than deny
+func TestReqRateLimit_Redirect(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	for _, location := range []string{"/slow-down", "https://www.example.com/slow-down?from=api", "http://[2001:db8::1]:8080/"} {
+		reqRateLimit, err := process(t, map[string]string{"rate-limit-redirect": " " + location + " "})
+		require.NoError(t, err, location)
+		for _, tier := range reqRateLimit.tiers {
+			assert.Equal(t, location, tier.limit.RedirectLocation)
+		}
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-redirect": " "})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.RedirectLocation)
+
+	for _, location := range []string{"slow-down", "//example.com/slow-down", "ftp://example.com/", "/slow down", "/slow\\"} {
+		_, err = process(t, map[string]string{"rate-limit-redirect": location})
+		assert.ErrorContains(t, err, "rate-limit-redirect", location)
+	}
+
+	for name, value := range map[string]string{
+		"rate-limit-deny-message": "Slow down",
+		"rate-limit-retry-after":  "true",
+		"rate-limit-action":       "tarpit",
+	} {
+		_, err = process(t, map[string]string{"rate-limit-redirect": "/slow-down", name: value})
+		assert.ErrorContains(t, err, "rate-limit-redirect annotation can't be combined with "+name, name)
+	}
+	_, err = process(t, map[string]string{"rate-limit-redirect": "/slow-down", "rate-limit-action": "deny"})
+	assert.NoError(t, err)
+}
+
+// TestReqRateLimit_Result tests the rate limit reported once the annotations are processed.
+// It validates that:
+// - Without rate-limit-requests, there is no result
+// - The result captures the table name, period and requests limit of the first tier
+// - The whitelist pattern file is reported
+// - The result is set on the ingress, so the status manager can write it back
+func TestReqRateLimit_Result(t *testing.T) {
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+	assert.Nil(t, reqRateLimit.Result())
+
+	annotations := map[string]string{
+		"rate-limit-requests":  "100, 1000",
+		"rate-limit-period":    "1m, 1h",
+		"rate-limit-whitelist": "10.0.0.0/8, patterns/whitelist",
+	}
+	for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-whitelist"} {
+		require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+	}
+
+	result := reqRateLimit.Result()
+	require.NotNil(t, result)
+	assert.Equal(t, "RateLimit-60000", result.TableName)
+	assert.Equal(t, int64(60000), result.Period)
+	assert.Equal(t, int64(100), result.ReqsLimit)
+	assert.True(t, result.Whitelisted)
+	assert.Equal(t, "patterns/whitelist", result.WhitelistMap)
+	assert.Equal(t, result, ing.RateLimit)
+
+	// Processing an ingress without rate limit clears the previous result
+	reqRateLimit = NewReqRateLimit(&rules.List{}, ing, mockMaps)
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, map[string]string{}))
+	assert.Nil(t, ing.RateLimit)
+}
+
+// TestReqRateLimit_Disable tests turning rate limiting off with rate-limit-requests.
+// It validates that:
+// - "0" and "off" (case insensitive) remove the ReqRateLimit and ReqTrack rules previously added
+// - The other rate-limit annotations, e.g. inherited from the ConfigMap, are then ignored without error
+// - No rate limit is reported on the ingress
+func TestReqRateLimit_Disable(t *testing.T) {
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	configMap := map[string]string{
+		"rate-limit-requests":    "10, 100",
+		"rate-limit-period":      "1s, 1m",
+		"rate-limit-status-code": "429",
+		"rate-limit-whitelist":   "10.0.0.0/8",
+	}
+	annNames := []string{"rate-limit-requests", "rate-limit-period", "rate-limit-status-code", "rate-limit-whitelist"}
+
+	for _, value := range []string{"0", "off", " OFF "} {
+		t.Run(value, func(t *testing.T) {
+			// Rules added before rate limiting is turned off are removed
+			list := &rules.List{}
+			reqRateLimit := NewReqRateLimit(list, nil, mockMaps)
+			require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, configMap))
+			require.Len(t, *list, 4)
+			require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, map[string]string{"rate-limit-requests": value}))
+			assert.Empty(t, *list)
+			assert.Empty(t, reqRateLimit.tiers)
+
+			// An ingress turning off the ConfigMap rate limit gets no rule
+			list = &rules.List{}
+			ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+			reqRateLimit = NewReqRateLimit(list, ing, mockMaps)
+			ingress := map[string]string{"rate-limit-requests": value}
+			for _, annName := range annNames {
+				require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, ingress, configMap))
+			}
+			assert.Empty(t, *list)
+			assert.Nil(t, ing.RateLimit)
+		})
+	}
+}
+
+// TestReqRateLimit_StatusCode tests the validation of rate-limit-status-code.
+// It validates that:
+// - Status codes HAProxy can deny with are set on every tier
+// - Other codes are rejected with ErrInvalidStatusCode, naming the code
+// - Non numeric values are rejected
+func TestReqRateLimit_StatusCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantErr  error
+		wantCode int64
+	}{
+		{name: "too many requests", input: "429", wantCode: 429},
+		{name: "forbidden", input: "403", wantCode: 403},
+		{name: "service unavailable", input: "503", wantCode: 503},
+		{name: "ok", input: "200", wantCode: 200},
+		{name: "typo with extra digit", input: "4299", wantErr: ErrInvalidStatusCode},
+		{name: "typo with missing digit", input: "42", wantErr: ErrInvalidStatusCode},
+		{name: "redirection", input: "302", wantErr: ErrInvalidStatusCode},
+		{name: "without errorfile", input: "418", wantErr: ErrInvalidStatusCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+			annotations := map[string]string{
+				"rate-limit-requests":    "10, 100",
+				"rate-limit-period":      "1s, 1m",
+				"rate-limit-status-code": tt.input,
+			}
+			for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+				require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+			}
+			err = reqRateLimit.NewAnnotation("rate-limit-status-code").Process(store.K8s{}, annotations)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, tt.input)
+				return
+			}
+			require.NoError(t, err)
+			for _, tier := range reqRateLimit.tiers {
+				assert.Equal(t, tt.wantCode, tier.limit.DenyStatusCode)
+			}
+		})
+	}
+
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+	annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-status-code": "abc"}
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+	require.Error(t, reqRateLimit.NewAnnotation("rate-limit-status-code").Process(store.K8s{}, annotations))
+	assert.Zero(t, reqRateLimit.limit.DenyStatusCode)
+}
+
+// TestReqRateLimit_WhitelistMapIsolation tests the name of the maps generated for ConfigMap whitelists.
+// It validates that:
+// - Ingresses of different namespaces referencing the same ConfigMap get distinct map paths
+// - Ingresses with the same name in the same namespace get the same map path
+// - Map paths have the .map suffix
+func TestReqRateLimit_WhitelistMapIsolation(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	k.GetNamespace("default").ConfigMaps["trusted"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "trusted",
+		Annotations: map[string]string{"office": "192.168.1.0/24\n10.0.0.1"},
+	}
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+
+	whitelistMap := func(t *testing.T, namespace, name string) maps.Path {
+		t.Helper()
+		ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: namespace, Name: name}}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":                   "10",
+			"rate-limit-whitelist-inline-threshold": "0",
+			"rate-limit-whitelist":                  "configmap/default/trusted",
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-whitelist-inline-threshold", "rate-limit-whitelist"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		require.Len(t, reqRateLimit.limit.WhitelistMaps, 1)
+		return reqRateLimit.limit.WhitelistMaps[0]
+	}
+
+	teamA := whitelistMap(t, "team-a", "api")
+	teamB := whitelistMap(t, "team-b", "api")
+	assert.NotEqual(t, teamA, teamB)
+	assert.Equal(t, teamA, whitelistMap(t, "team-a", "api"))
+	assert.True(t, strings.HasSuffix(string(teamA), ".map"))
+	assert.True(t, mockMaps.MapExists(whitelistMapName(
+		&store.Ingress{IngressCore: store.IngressCore{Namespace: "team-b", Name: "api"}},
+		[]string{"192.168.1.0/24", "10.0.0.1"},
+	)))
+}
+
+// TestReqRateLimit_WhitelistInlineThreshold tests the inlining of small ConfigMap whitelists.
+// It validates that:
+// - Below the default threshold, the addresses are whitelisted inline without map
+// - At the threshold, the addresses are loaded in a map
+// - The threshold is configurable, 0 loading every ConfigMap in a map
+func TestReqRateLimit_WhitelistInlineThreshold(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	for name, addresses := range map[string]string{
+		"pair":  "192.168.1.0/24\n10.0.0.1",
+		"three": "192.168.1.0/24\n10.0.0.1\n2001:db8::/32",
+	} {
+		k.GetNamespace("default").ConfigMaps[name] = &store.ConfigMap{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{"addresses": addresses},
+		}
+	}
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, maps.Maps) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10"
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		return reqRateLimit, mockMaps
+	}
+
+	reqRateLimit, mockMaps := process(t, map[string]string{"rate-limit-whitelist": "configmap/default/pair"})
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.1"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+	assert.False(t, mockMaps.MapExists(whitelistMapName(nil, []string{"192.168.1.0/24", "10.0.0.1"})))
+
+	reqRateLimit, mockMaps = process(t, map[string]string{"rate-limit-whitelist": "configmap/default/three"})
+	mapName := whitelistMapName(nil, []string{"192.168.1.0/24", "10.0.0.1", "2001:db8::/32"})
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName)}, reqRateLimit.limit.WhitelistMaps)
+	assert.True(t, mockMaps.MapExists(mapName))
+
+	reqRateLimit, _ = process(t, map[string]string{
+		"rate-limit-whitelist-inline-threshold": "4",
+		"rate-limit-whitelist":                  "configmap/default/three",
+	})
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.1", "2001:db8::/32"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, _ = process(t, map[string]string{
+		"rate-limit-whitelist-inline-threshold": "0",
+		"rate-limit-whitelist":                  "configmap/default/pair",
+	})
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Len(t, reqRateLimit.limit.WhitelistMaps, 1)
+}
+
+// TestReqRateLimit_WhitelistMixed tests a whitelist mixing addresses and pattern files.
+// It validates that:
+// - The addresses are loaded in a map, matched before the pattern files
+// - Fewer addresses than rate-limit-whitelist-inline-threshold stay inline
+// - A whitelist of addresses only isn't loaded in a map
+func TestReqRateLimit_WhitelistMixed(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, maps.Maps) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10"
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		return reqRateLimit, mockMaps
+	}
+	addresses := []string{"192.168.1.0/24", "10.0.0.1", "2001:db8::/32"}
+	mapName := whitelistMapName(nil, addresses)
+
+	reqRateLimit, mockMaps := process(t, map[string]string{
+		"rate-limit-whitelist": "192.168.1.0/24, patterns/partners, 10.0.0.1, 2001:db8::/32, patterns/monitoring",
+	})
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName), "patterns/partners", "patterns/monitoring"}, reqRateLimit.limit.WhitelistMaps)
+	assert.True(t, mockMaps.MapExists(mapName))
+
+	reqRateLimit, mockMaps = process(t, map[string]string{"rate-limit-whitelist": "10.0.0.1, patterns/partners"})
+	assert.Equal(t, []string{"10.0.0.1"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{"patterns/partners"}, reqRateLimit.limit.WhitelistMaps)
+	assert.False(t, mockMaps.MapExists(whitelistMapName(nil, []string{"10.0.0.1"})))
+
+	reqRateLimit, _ = process(t, map[string]string{
+		"rate-limit-whitelist-inline-threshold": "0",
+		"rate-limit-whitelist":                  "10.0.0.1, patterns/partners",
+	})
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Len(t, reqRateLimit.limit.WhitelistMaps, 2)
+
+	reqRateLimit, _ = process(t, map[string]string{"rate-limit-whitelist": strings.Join(addresses, ", ")})
+	assert.Equal(t, addresses, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+}
+
+// TestReqRateLimit_WhitelistEntries tests the number of entries reported after processing rate-limit-whitelist.
+// It validates that:
+// - Addresses are counted whether they are whitelisted inline or written in a map
+// - Pattern files are not counted
+// - The count is reset when the whitelist is processed again
+func TestReqRateLimit_WhitelistEntries(t *testing.T) {
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+	process := func(t *testing.T, whitelist string) int {
+		t.Helper()
+		annotations := map[string]string{
+			"rate-limit-requests":  "10",
+			"rate-limit-whitelist": whitelist,
+		}
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit.WhitelistEntries()
+	}
+
+	assert.Equal(t, 1, process(t, "192.168.1.1"))
+	assert.Len(t, reqRateLimit.limit.WhitelistIPs, 1)
+
+	assert.Equal(t, 3, process(t, "10.0.0.0/8, patterns/partners, 2001:db8::/32, 192.168.1.1"))
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+	assert.Len(t, reqRateLimit.limit.WhitelistMaps, 2)
+
+	assert.Equal(t, 0, process(t, "patterns/partners"))
+}
+
+// TestReqRateLimit_WhitelistMetrics tests the metrics of the whitelist maps.
+// It validates that:
+// - The entries gauge of a map reflects the number of addresses it was created with, mixed with a pattern file
+// - A regeneration is only counted when the content changed since the previous sync, not on every sync
+// - The entries of a map removed on refresh, no rate limit using it anymore, are not reported anymore
+func TestReqRateLimit_WhitelistMetrics(t *testing.T) {
+	mockMaps, err := maps.New(t.TempDir(), nil)
+	require.NoError(t, err)
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "metrics", Name: "api"}}
+	// A sync empties the maps, and processes the annotations with a new rate limit
+	process := func(t *testing.T, whitelist string) {
+		t.Helper()
+		mockMaps.CleanMaps()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":  "10",
+			"rate-limit-whitelist": whitelist,
+		}
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+	}
+	whitelist := []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "172.16.0.0/12"}
+	mapName := string(whitelistMapName(ing, whitelist))
+
+	process(t, strings.Join(whitelist, ", ")+", patterns/partners")
+	entries, ok := whitelistMapMetric(t, "haproxy_ingress_ratelimit_whitelist_map_entries", mapName)
+	require.True(t, ok)
+	assert.InDelta(t, 4, entries, 0)
+	regenerations, _ := whitelistMapMetric(t, "haproxy_ingress_ratelimit_whitelist_map_regenerations_total", mapName)
+	assert.InDelta(t, 1, regenerations, 0)
+
+	process(t, strings.Join(whitelist, ", ")+", patterns/partners")
+	regenerations, _ = whitelistMapMetric(t, "haproxy_ingress_ratelimit_whitelist_map_regenerations_total", mapName)
+	assert.InDelta(t, 1, regenerations, 0)
+
+	process(t, "192.168.1.1")
+	_, ok = whitelistMapMetric(t, "haproxy_ingress_ratelimit_whitelist_map_entries", mapName)
+	assert.True(t, ok)
+	mockMaps.RefreshMaps(mapsClient{})
+	_, ok = whitelistMapMetric(t, "haproxy_ingress_ratelimit_whitelist_map_entries", mapName)
+	assert.False(t, ok)
+}
+
+// mapsClient is the HAProxy client maps are refreshed with, without runtime updates.
+type mapsClient struct {
+	api.HAProxyClient
+}
+
+func (mapsClient) SetMapContent(string, []string) error {
+	return nil
+}
+
+// whitelistMapMetric returns the value of the named metric of the whitelist map of the
+// metrics/api ingress, and whether it is reported.
+func whitelistMapMetric(t *testing.T, name, mapName string) (float64, bool) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["namespace"] != "metrics" || labels["ingress"] != "api" || labels["map"] != mapName {
+				continue
+			}
+			if metric.GetGauge() != nil {
+				return metric.GetGauge().GetValue(), true
+			}
+			return metric.GetCounter().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// TestReqRateLimit_WhitelistMaxEntries tests the limit of entries of an annotation whitelist.
+// It validates that:
+// - Whitelists of more addresses and hostnames than rate-limit-whitelist-max-entries are rejected
+// - The default allows 1000 entries, and 0 disables the limit
+// - Pattern files are not counted
+func TestReqRateLimit_WhitelistMaxEntries(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		reqRateLimit.dryRun = true
+		annotations["rate-limit-requests"] = "10"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+	addresses := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("10.%d.%d.1", i/256, i%256)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-whitelist": addresses(1000)})
+	require.NoError(t, err)
+	assert.Len(t, reqRateLimit.limit.WhitelistIPs, 1000)
+
+	_, err = process(t, map[string]string{"rate-limit-whitelist": addresses(1001)})
+	require.ErrorIs(t, err, ErrWhitelistTooLarge)
+	assert.ErrorContains(t, err, "1001 entries, more than the 1000 of rate-limit-whitelist-max-entries")
+
+	_, err = process(t, map[string]string{
+		"rate-limit-whitelist-max-entries": "2",
+		"rate-limit-whitelist":             "10.0.0.1, 10.0.0.2, partner.example.com",
+	})
+	assert.ErrorIs(t, err, ErrWhitelistTooLarge)
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-whitelist-max-entries": "1",
+		"rate-limit-whitelist":             "patterns/partners, 10.0.0.1, patterns/monitoring",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []maps.Path{"patterns/partners", "patterns/monitoring"}, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-whitelist-max-entries": "0",
+		"rate-limit-whitelist":             addresses(1500),
+	})
+	require.NoError(t, err)
+	assert.Len(t, reqRateLimit.limit.WhitelistIPs, 1500)
+}
+
+// fakeWatcher records the paths registered for watching.
+type fakeWatcher struct {
+	watched []string
+}
+
+func (w *fakeWatcher) Watch(path string) {
+	w.watched = append(w.watched, path)
+}
+
+func (w *fakeWatcher) Modified() []string {
+	return nil
+}
+
+// TestReqRateLimit_WhitelistWatch tests the registration of the whitelist pattern files for watching.
+// It validates that:
+// - With rate-limit-whitelist-watch, the pattern files of the whitelist are watched
+// - Addresses and the maps generated by the controller are not watched
+// - Without it, or when only validating, nothing is watched
+func TestReqRateLimit_WhitelistWatch(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string, dryRun bool) *fakeWatcher {
+		t.Helper()
+		watcher := &fakeWatcher{}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		reqRateLimit.watcher = watcher
+		reqRateLimit.dryRun = dryRun
+		annotations["rate-limit-requests"] = "10"
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return watcher
+	}
+
+	watcher := process(t, map[string]string{
+		"rate-limit-whitelist":       "patterns/partners, 10.0.0.1, patterns/monitoring",
+		"rate-limit-whitelist-watch": "true",
+	}, false)
+	assert.Equal(t, []string{"patterns/partners", "patterns/monitoring"}, watcher.watched)
+
+	watcher = process(t, map[string]string{"rate-limit-whitelist": "patterns/partners"}, false)
+	assert.Empty(t, watcher.watched)
+
+	watcher = process(t, map[string]string{
+		"rate-limit-whitelist":       "patterns/partners",
+		"rate-limit-whitelist-watch": "true",
+	}, true)
+	assert.Empty(t, watcher.watched)
+}
+
+// TestReqRateLimit_WhitelistPatternFiles tests a whitelist of several pattern files.
+// It validates that:
+// - Two or three pattern files are all kept, in their order, without map
+// - The same pattern file is only kept once
+func TestReqRateLimit_WhitelistPatternFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		whitelist string
+		want      []maps.Path
+	}{
+		{
+			name:      "two pattern files",
+			whitelist: "patterns/monitoring, patterns/partners",
+			want:      []maps.Path{"patterns/monitoring", "patterns/partners"},
+		},
+		{
+			name:      "three pattern files",
+			whitelist: "patterns/monitoring,patterns/partners, patterns/internal",
+			want:      []maps.Path{"patterns/monitoring", "patterns/partners", "patterns/internal"},
+		},
+		{
+			name:      "three pattern files over several lines",
+			whitelist: "patterns/monitoring # probes\npatterns/partners\npatterns/internal, patterns/partners",
+			want:      []maps.Path{"patterns/monitoring", "patterns/partners", "patterns/internal"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMaps, err := maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+			reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+			annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-whitelist": tt.whitelist}
+			for _, annName := range ReqRateLimitAnnotations {
+				require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+			}
+			assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+			assert.Equal(t, tt.want, reqRateLimit.limit.WhitelistMaps)
+		})
+	}
+}
+
+// TestReqRateLimit_TableExpire tests the rate-limit-table-expire annotation processing.
+// It validates that:
+// - The expiration is set on the table of every tier, keeping their periods
+// - Tables get a dedicated name, so they are not shared with tables expiring after the period
+// - An expiration shorter than a period is rejected
+func TestReqRateLimit_TableExpire(t *testing.T) {
+	process := func(t *testing.T, expire string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":     "10, 100",
+			"rate-limit-period":       "10s, 1m",
+			"rate-limit-table-expire": expire,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-table-expire").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, "5m")
+	require.NoError(t, err)
+	periods := []int64{10000, 60000}
+	for i, tier := range reqRateLimit.tiers {
+		assert.Equal(t, periods[i], *tier.track.TablePeriod)
+		require.NotNil(t, tier.track.TableExpire)
+		assert.Equal(t, int64(300000), *tier.track.TableExpire)
+		assert.Regexp(t, fmt.Sprintf(`^RateLimit-%d-[0-9a-f]{8}$`, periods[i]), tier.track.TableName)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+
+	_, err = process(t, "30s")
+	assert.ErrorContains(t, err, "shorter than the 60000ms rate-limit-period")
+
+	_, err = process(t, "forever")
+	assert.Error(t, err)
+}
+
+// TestReqRateLimit_StickCounterSlot tests the rate-limit-sc-slot annotation processing.
+// It validates that:
+// - The track and limit rules use the selected stick counter
+// - Tiers and the connections limit use consecutive stick counters from the slot, never the same one
+// - Slots outside 0-2, or leaving too few stick counters for the rate limits, are rejected
+func TestReqRateLimit_StickCounterSlot(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-connections"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-sc-slot").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+	stickCounters := func(reqRateLimit *ReqRateLimit) []int64 {
+		var counters []int64
+		reqRateLimit.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			assert.Equal(t, track.StickCounter, limit.StickCounter)
+			counters = append(counters, track.StickCounter)
+		})
+		return counters
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-requests": "10", "rate-limit-sc-slot": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, stickCounters(reqRateLimit))
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-requests": "10", "rate-limit-sc-slot": "2"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, stickCounters(reqRateLimit))
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-requests":    "10",
+		"rate-limit-connections": "5",
+		"rate-limit-sc-slot":     "1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, stickCounters(reqRateLimit))
+
+	_, err = process(t, map[string]string{
+		"rate-limit-requests": "10, 100",
+		"rate-limit-period":   "1s, 1m",
+		"rate-limit-sc-slot":  "2",
+	})
+	assert.ErrorContains(t, err, "would use sc3")
+
+	for _, slot := range []string{"3", "-1", "sc1"} {
+		_, err = process(t, map[string]string{"rate-limit-requests": "10", "rate-limit-sc-slot": slot})
+		assert.ErrorContains(t, err, "expecting 0, 1 or 2")
+	}
+}
+
+// TestReqRateLimit_DeniedMetric tests the rate-limit-denied-metric annotation processing.
+// It validates that:
+// - Denied requests of every tier are counted with the ingress namespace and name
+// - The stick counter following the ones of the rate limits is used
+// - It is rejected when every stick counter is already used
+// - The ConfigMap rate limit itself, without ingress, is not counted
+func TestReqRateLimit_DeniedMetric(t *testing.T) {
+	process := func(t *testing.T, ing *store.Ingress, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-sc-slot"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-denied-metric").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+
+	reqRateLimit, err := process(t, ing, map[string]string{
+		"rate-limit-requests":      "10, 100",
+		"rate-limit-period":        "1s, 1m",
+		"rate-limit-denied-metric": "true",
+	})
+	require.NoError(t, err)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "default/api", tier.limit.DeniedKey)
+		assert.Equal(t, int64(2), tier.limit.DeniedStickCounter)
+	}
+
+	reqRateLimit, err = process(t, ing, map[string]string{
+		"rate-limit-requests":      "10",
+		"rate-limit-denied-metric": "false",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.DeniedKey)
+
+	_, err = process(t, ing, map[string]string{
+		"rate-limit-requests":      "10, 100",
+		"rate-limit-period":        "1s, 1m",
+		"rate-limit-sc-slot":       "1",
+		"rate-limit-denied-metric": "true",
+	})
+	assert.ErrorContains(t, err, "rate limits already use sc2")
+
+	reqRateLimit, err = process(t, nil, map[string]string{
+		"rate-limit-requests":      "10",
+		"rate-limit-denied-metric": "true",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.DeniedKey)
+}
+
+// TestReqRateLimit_ID tests the rate-limit-id annotation processing.
+// It validates that:
+// - The id is set on the rules of every tier, including the deny rate and burst tables, and shown in their debug output
+// - The id is kept whatever the table names, and reported in the rate limit status and the logs
+// - Denied requests are counted with the id following the ingress name
+// - Invalid ids are rejected
+func TestReqRateLimit_ID(t *testing.T) {
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	tables := map[string]struct{}{}
+	for _, period := range []string{"1s", "1m"} {
+		reqRateLimit, err := process(t, map[string]string{
+			"rate-limit-requests":      "100",
+			"rate-limit-period":        period,
+			"rate-limit-deny-rate":     "50",
+			"rate-limit-denied-metric": "true",
+			"rate-limit-id":            "checkout-api",
+		})
+		require.NoError(t, err)
+		tables[reqRateLimit.track.TableName] = struct{}{}
+		reqRateLimit.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			assert.Equal(t, "checkout-api", limit.ID)
+			assert.Equal(t, "checkout-api", track.ID)
+			assert.Equal(t, "checkout-api", limit.DenyRate.ID)
+			assert.Equal(t, "default/api/checkout-api", limit.DeniedKey)
+			assert.True(t, strings.HasSuffix(limit.String(), " # rate-limit-id checkout-api"), limit.String())
+			assert.True(t, strings.HasSuffix(track.String(), " # rate-limit-id checkout-api"), track.String())
+		})
+		assert.Equal(t, "checkout-api", reqRateLimit.Result().ID)
+		assert.Equal(t, "ingress/default/api (rate-limit-id checkout-api)", reqRateLimit.logOwner())
+	}
+	assert.Len(t, tables, 2)
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-requests": "100", "rate-limit-period": "1m", "rate-limit-burst": "20", "rate-limit-id": "checkout-api"})
+	require.NoError(t, err)
+	require.NotNil(t, reqRateLimit.limit.Burst)
+	assert.Equal(t, "checkout-api", reqRateLimit.limit.Burst.ID)
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-requests": "100", "rate-limit-denied-metric": "true"})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.Result().ID)
+	assert.Equal(t, "default/api", reqRateLimit.limit.DeniedKey)
+	assert.NotContains(t, reqRateLimit.limit.String(), "rate-limit-id")
+	assert.Equal(t, "ingress/default/api", reqRateLimit.logOwner())
+
+	for _, id := range []string{"checkout api", "-api", "api-", strings.Repeat("a", 64)} {
+		_, err = process(t, map[string]string{"rate-limit-requests": "100", "rate-limit-id": id})
+		assert.ErrorContains(t, err, "rate-limit-id", id)
+	}
+}
+
+// TestReqRateLimit_WhitelistWithoutMaps tests whitelists needing a map when maps failed to initialize.
+// It validates that:
+// - Addresses of a ConfigMap and resolved hostnames are whitelisted inline instead of in a map
+// - A whitelist too large to be inlined is an error wrapping ErrMapsUnavailable
+func TestReqRateLimit_WhitelistWithoutMaps(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.ConfigMaps["trusted"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "trusted",
+		Annotations: map[string]string{"office": "192.168.1.0/24\n10.0.0.1"},
+	}
+	var large strings.Builder
+	for i := range maxInlineWhitelistAddresses + 1 {
+		fmt.Fprintf(&large, "10.1.%d.0/24\n", i)
+	}
+	ns.ConfigMaps["large"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "large",
+		Annotations: map[string]string{"office": large.String()},
+	}
+	process := func(t *testing.T, whitelist string) (*ReqRateLimit, error) {
+		t.Helper()
+		// maps.New fails without a directory, leaving no maps
+		mockMaps, err := maps.New("", nil)
+		require.Error(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		reqRateLimit.lookupHost = func(string) ([]string, error) {
+			return []string{"203.0.113.10"}, nil
+		}
+		annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-whitelist": whitelist}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-whitelist"} {
+			if err = reqRateLimit.NewAnnotation(annName).Process(k, annotations); err != nil {
+				break
+			}
+		}
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, "configmap/default/trusted")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.1"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, err = process(t, "10.0.0.2, partner.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2", "203.0.113.10"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+
+	_, err = process(t, "configmap/default/large")
+	require.ErrorIs(t, err, ErrMapsUnavailable)
+	assert.ErrorContains(t, err, "maps directory is writable")
+}
+
+// TestReqRateLimit_WhitelistHostnames tests hostnames in rate-limit-whitelist, resolved with a mock resolver.
+// It validates that:
+// - Hostnames are resolved into a map next to the inlined IPs/CIDRs
+// - Hostnames which can't be resolved are skipped, the other ones are kept
+// - In strict mode, a hostname which can't be resolved is an error
+// - Hostnames are rejected in rate-limit-blacklist
+func TestReqRateLimit_WhitelistHostnames(t *testing.T) {
+	resolver := func(host string) ([]string, error) {
+		switch host {
+		case "partner.example.com":
+			return []string{"203.0.113.10", "2001:db8::10"}, nil
+		case "backup.example.com":
+			return []string{"203.0.113.20", "203.0.113.10"}, nil
+		}
+		return nil, fmt.Errorf("lookup %s: no such host", host)
+	}
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, maps.Maps, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+		reqRateLimit.lookupHost = resolver
+		require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(store.K8s{}, annotations))
+		for _, annName := range []string{"rate-limit-whitelist-strict", "rate-limit-whitelist", "rate-limit-blacklist"} {
+			if err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				break
+			}
+		}
+		return reqRateLimit, mockMaps, err
+	}
+
+	reqRateLimit, mockMaps, err := process(t, map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "10.0.0.0/8, Partner.Example.com., backup.example.com",
+	})
+	require.NoError(t, err)
+	mapName := whitelistMapName(reqRateLimit.ingress, []string{"partner.example.com", "backup.example.com"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	assert.Equal(t, []string{"10.0.0.0/8"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName)}, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, mockMaps, err = process(t, map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "partner.example.com, gone.example.com",
+	})
+	require.NoError(t, err)
+	mapName = whitelistMapName(reqRateLimit.ingress, []string{"partner.example.com", "gone.example.com"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName)}, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, _, err = process(t, map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "10.0.0.1, gone.example.com",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+
+	_, _, err = process(t, map[string]string{
+		"rate-limit-requests":         "10",
+		"rate-limit-whitelist-strict": "true",
+		"rate-limit-whitelist":        "partner.example.com, gone.example.com",
+	})
+	assert.ErrorContains(t, err, "unable to resolve 'gone.example.com'")
+
+	_, _, err = process(t, map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-blacklist": "partner.example.com",
+	})
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
+
+// TestReqRateLimit_Validate tests validating rate-limit annotations without applying them.
+// It validates that:
+// - Valid annotations return no error
+// - Validate returns the same errors as Process for an invalid address and a missing rate-limit-requests
+// - Neither the rules nor the maps are modified
+func TestReqRateLimit_Validate(t *testing.T) {
+	processErrors := func(t *testing.T, annotations map[string]string) []error {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			if err := reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	}
+
+	list := &rules.List{}
+	reqRateLimit := NewReqRateLimit(list, nil, nil)
+	require.NoError(t, reqRateLimit.Validate(map[string]string{
+		"rate-limit-requests":  "10, 100",
+		"rate-limit-period":    "1s, 1m",
+		"rate-limit-whitelist": "10.0.0.0/8, partner.example.com",
+	}))
+	require.NoError(t, reqRateLimit.Validate(map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "configmap/default/trusted",
+	}))
+	assert.Empty(t, *list)
+	assert.Nil(t, reqRateLimit.limit)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     error
+	}{
+		{
+			name: "invalid address",
+			annotations: map[string]string{
+				"rate-limit-requests":  "10",
+				"rate-limit-whitelist": "10.0.0.0/8, not-an-ip",
+			},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name: "missing requests",
+			annotations: map[string]string{
+				"rate-limit-period":      "1m",
+				"rate-limit-status-code": "429",
+			},
+			wantErr: ErrMissingRateLimitRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reqRateLimit.Validate(tt.annotations)
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, errors.Join(processErrors(t, tt.annotations)...).Error(), err.Error())
+			assert.Empty(t, *list)
+		})
+	}
+}
+
+// TestReqRateLimit_ForwardedForDepth tests the rate-limit-forwarded-for-depth annotation processing.
+// It validates that:
+// - Every tier tracks the Nth-from-last X-Forwarded-For entry in an ip table of its own
+// - The depth must be a positive integer
+// - It can't be combined with a custom rate-limit-key
+func TestReqRateLimit_ForwardedForDepth(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-key"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-forwarded-for-depth").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-requests":            "10, 100",
+		"rate-limit-period":              "1s, 1m",
+		"rate-limit-forwarded-for-depth": "2",
+	})
+	require.NoError(t, err)
+	periods := []int64{1000, 60000}
+	for i, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "req.hdr_ip(X-Forwarded-For,-2)", tier.track.TrackKey)
+		assert.Equal(t, "ip", tier.track.TableType)
+		assert.Regexp(t, fmt.Sprintf(`^RateLimit-%d-[0-9a-f]{8}$`, periods[i]), tier.track.TableName)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+
+	for _, depth := range []string{"0", "-1", "last"} {
+		_, err = process(t, map[string]string{"rate-limit-requests": "10", "rate-limit-forwarded-for-depth": depth})
+		assert.ErrorContains(t, err, "expecting a positive integer")
+	}
+
+	_, err = process(t, map[string]string{
+		"rate-limit-requests":            "10",
+		"rate-limit-key":                 "req.cook(session)",
+		"rate-limit-forwarded-for-depth": "1",
+	})
+	assert.ErrorContains(t, err, "can't be combined with rate-limit-key")
+}
+
+// refMaps records the owners registered on the maps.
+type refMaps struct {
+	maps.Maps
+	owners map[maps.Name][]string
+}
+
+func (m *refMaps) MapRef(name maps.Name, owner string) {
+	m.Maps.MapRef(name, owner)
+	m.owners[name] = append(m.owners[name], owner)
+}
+
+func (m *refMaps) MapUnref(name maps.Name, owner string) {
+	m.Maps.MapUnref(name, owner)
+	m.owners[name] = slices.DeleteFunc(m.owners[name], func(o string) bool { return o == owner })
+}
+
+// TestReqRateLimit_WhitelistMapRef tests the registration of the whitelist maps.
+// It validates that:
+// - The whitelist map is registered with the ingress as owner
+// - The map is deregistered when the whitelist is replaced or rate limiting turned off
+func TestReqRateLimit_WhitelistMapRef(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	for name, addresses := range map[string]string{"office": "192.168.1.0/24", "partners": "10.0.0.1"} {
+		k.GetNamespace("default").ConfigMaps[name] = &store.ConfigMap{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{"addresses": addresses},
+		}
+	}
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	refs := &refMaps{Maps: mockMaps, owners: map[maps.Name][]string{}}
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	reqRateLimit := NewReqRateLimit(&rules.List{}, ing, refs)
+
+	whitelist := func(ref string) maps.Name {
+		t.Helper()
+		annotations := map[string]string{
+			"rate-limit-requests": "10",
+			// Single addresses are loaded in maps too
+			"rate-limit-whitelist-inline-threshold": "0",
+			"rate-limit-whitelist":                  ref,
+		}
+		require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(k, annotations))
+		require.Len(t, reqRateLimit.limit.WhitelistMaps, 1)
+		path := string(reqRateLimit.limit.WhitelistMaps[0])
+		return maps.Name(strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".map"))
+	}
+
+	office := whitelist("configmap/default/office")
+	assert.Equal(t, []string{"ingress/default/api"}, refs.owners[office])
+
+	partners := whitelist("configmap/default/partners")
+	assert.Empty(t, refs.owners[office])
+	assert.Equal(t, []string{"ingress/default/api"}, refs.owners[partners])
+
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(k, map[string]string{"rate-limit-requests": "off"}))
+	assert.Empty(t, refs.owners[partners])
+}
+
+// TestReqRateLimit_Log tests the rate-limit-log annotation processing.
+// It validates that:
+// - true sets the default tag on every tier, false sets none
+// - A custom tag is set as is on every tier
+// - Tags with other characters than letters, digits, '_', '.' or '-' and too long tags are rejected
+// - The annotation requires rate-limit-requests
+func TestReqRateLimit_Log(t *testing.T) {
+	process := func(t *testing.T, log string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests": "10, 100",
+			"rate-limit-period":   "1s, 1m",
+			"rate-limit-log":      log,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-log").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	for log, want := range map[string]string{"true": "ratelimit", "false": "", " security-audit ": "security-audit"} {
+		reqRateLimit, err := process(t, log)
+		require.NoError(t, err)
+		require.Len(t, reqRateLimit.tiers, 2)
+		for _, tier := range reqRateLimit.tiers {
+			assert.Equal(t, want, tier.limit.LogTag, log)
+		}
+	}
+
+	for _, log := range []string{"security audit", "audit)", "audit|x", strings.Repeat("a", 65)} {
+		_, err := process(t, log)
+		assert.ErrorContains(t, err, "rate-limit-log", log)
+	}
+
+	err := NewReqRateLimit(&rules.List{}, nil, nil).NewAnnotation("rate-limit-log").Process(store.K8s{}, map[string]string{"rate-limit-log": "true"})
+	assert.ErrorIs(t, err, ErrMissingRateLimitRequests)
+}
+
+// TestReqRateLimit_LogTarget tests the rate-limit-log-target annotation processing.
+// It validates that:
+// - The syslog address is set on every tier
+// - Addresses with a protocol prefix, a port, brackets for IPv6 or a unix socket path are accepted
+// - Invalid addresses are rejected
+func TestReqRateLimit_LogTarget(t *testing.T) {
+	process := func(t *testing.T, target string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":   "10, 100",
+			"rate-limit-period":     "1s, 1m",
+			"rate-limit-log-target": target,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-log-target").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	for target, want := range map[string]string{
+		" 10.0.0.5:514 ":              "10.0.0.5:514",
+		"udp@siem.example.com:514":    "udp@siem.example.com:514",
+		"tcp6@[2001:db8::5]:6514":     "tcp6@[2001:db8::5]:6514",
+		"/var/run/syslog/haproxy.log": "/var/run/syslog/haproxy.log",
+	} {
+		reqRateLimit, err := process(t, target)
+		require.NoError(t, err, target)
+		require.Len(t, reqRateLimit.tiers, 2)
+		for _, tier := range reqRateLimit.tiers {
+			assert.Equal(t, want, tier.limit.LogTarget, target)
+		}
+	}
+
+	for _, target := range []string{"siem.example.com 514", "http://siem.example.com", "quic@siem:514", "10.0.0.5:514,10.0.0.6:514"} {
+		_, err := process(t, target)
+		assert.ErrorContains(t, err, "rate-limit-log-target", target)
+	}
+}
+
+// TestReqRateLimit_Headers tests the rate-limit-headers and rate-limit-headers-threshold annotations processing.
+// It validates that:
+// - Headers are only enabled on the first tier
+// - The threshold is a percentage of the limit, with an optional '%' sign
+// - Invalid booleans and percentages are rejected
+func TestReqRateLimit_Headers(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "50, 1000"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-headers": "true"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.True(t, reqRateLimit.tiers[0].limit.Headers)
+	assert.Zero(t, reqRateLimit.tiers[0].limit.HeadersThreshold)
+	assert.False(t, reqRateLimit.tiers[1].limit.Headers)
+
+	for threshold, want := range map[string]int64{"80": 40, " 90% ": 45, "0": 0, "100": 50} {
+		reqRateLimit, err = process(t, map[string]string{"rate-limit-headers": "true", "rate-limit-headers-threshold": threshold})
+		require.NoError(t, err, threshold)
+		assert.Equal(t, want, reqRateLimit.limit.HeadersThreshold, threshold)
+	}
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-headers": "false"})
+	require.NoError(t, err)
+	assert.False(t, reqRateLimit.limit.Headers)
+
+	_, err = process(t, map[string]string{"rate-limit-headers": "yes please"})
+	assert.Error(t, err)
+	for _, threshold := range []string{"-1", "101", "80.5", "high"} {
+		_, err = process(t, map[string]string{"rate-limit-headers": "true", "rate-limit-headers-threshold": threshold})
+		assert.ErrorContains(t, err, "rate-limit-headers-threshold", threshold)
+	}
+}
+
+// TestReqRateLimit_CompositeKey tests the rate-limit-composite-key annotation processing.
+// It validates that:
+// - The first fetch is the track key and the others its key parts, on every tier
+// - Commas between the arguments of a fetch don't split it
+// - Tables are string typed and get a dedicated name
+// - Less than two fetches, invalid fetches and a key already set by rate-limit-key are rejected
+func TestReqRateLimit_CompositeKey(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-key"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-composite-key").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-composite-key": " src , path "})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "src", tier.track.TrackKey)
+		assert.Equal(t, []string{"path"}, tier.track.KeyParts)
+		assert.Equal(t, "string", tier.track.TableType)
+		assert.NotEqual(t, "RateLimit-1000", tier.track.TableName)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+	assert.True(t, strings.HasPrefix(reqRateLimit.track.TableName, "RateLimit-1000-"))
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-composite-key": "hdr_ip(X-Forwarded-For,-1),path,req.cook(session)"})
+	require.NoError(t, err)
+	assert.Equal(t, "hdr_ip(X-Forwarded-For,-1)", reqRateLimit.track.TrackKey)
+	assert.Equal(t, []string{"path", "req.cook(session)"}, reqRateLimit.track.KeyParts)
+
+	for _, key := range []string{"src", "src,", "src,path)", "src,path extra"} {
+		_, err = process(t, map[string]string{"rate-limit-composite-key": key})
+		assert.ErrorContains(t, err, "rate-limit-composite-key", key)
+	}
+
+	_, err = process(t, map[string]string{"rate-limit-key": "hdr(X-Api-Key)", "rate-limit-composite-key": "src,path"})
+	assert.ErrorContains(t, err, "can't be combined")
+}
+
+// TestReqRateLimit_PathTemplate tests the rate-limit-path-template annotation processing.
+// It validates that:
+// - The normalized path is appended to the tracked key of every tier, after the composite key parts
+// - The track expression replaces the parts of the path matching the pattern with :id
+// - Tables are string typed and get a dedicated name
+// - Invalid regular expressions, and ones with quotes, commas or spaces, are rejected
+func TestReqRateLimit_PathTemplate(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-composite-key"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		err = reqRateLimit.NewAnnotation("rate-limit-path-template").Process(store.K8s{}, annotations)
+		return reqRateLimit, err
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-path-template": " [0-9]+ "})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "src", tier.track.TrackKey)
+		assert.Equal(t, []string{"path,regsub('[0-9]+',:id,g)"}, tier.track.KeyParts)
+		assert.Equal(t, "string", tier.track.TableType)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+	assert.True(t, strings.HasPrefix(reqRateLimit.track.TableName, "RateLimit-1000-"))
+	assert.Equal(t, "http-request track-sc0 src,concat(|,txn.ratelimit_key_"+utils.Hash([]byte("path,regsub('[0-9]+',:id,g)"))[:8]+") table "+reqRateLimit.track.TableName,
+		reqRateLimit.track.String())
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-composite-key": "src, hdr(X-Tenant)", "rate-limit-path-template": "[0-9a-f]{8}-[0-9a-f-]{27}"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hdr(X-Tenant)", "path,regsub('[0-9a-f]{8}-[0-9a-f-]{27}',:id,g)"}, reqRateLimit.track.KeyParts)
+	assert.Equal(t, []string{"hdr(X-Tenant)", "path,regsub('[0-9a-f]{8}-[0-9a-f-]{27}',:id,g)"}, reqRateLimit.tiers[1].track.KeyParts)
+
+	for _, pattern := range []string{"[0-9", "[0-9]{1,3}", "it's", "a b"} {
+		_, err = process(t, map[string]string{"rate-limit-path-template": pattern})
+		assert.ErrorContains(t, err, "rate-limit-path-template", pattern)
+	}
+}
+
+// TestReqRateLimit_MissingKeyAction tests the rate-limit-missing-key-action annotation processing.
+// It validates that:
+// - The action is set on the track of every tier, including the connections one
+// - Only deny makes the first limit deny requests without the key
+// - Sources, always found, are left unchanged
+// - Unknown actions are rejected
+func TestReqRateLimit_MissingKeyAction(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		for _, annName := range ReqRateLimitAnnotations {
+			if err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				break
+			}
+		}
+		return reqRateLimit, err
+	}
+
+	for _, action := range []string{rules.MissingKeyActionExempt, rules.MissingKeyActionShared, rules.MissingKeyActionDeny} {
+		reqRateLimit, err := process(t, map[string]string{
+			"rate-limit-key":                "req.hdr(X-Api-Key)",
+			"rate-limit-connections":        "20",
+			"rate-limit-missing-key-action": action,
+		})
+		require.NoError(t, err)
+		require.Len(t, reqRateLimit.tiers, 3)
+		for _, tier := range reqRateLimit.tiers {
+			assert.Equal(t, action, tier.track.MissingKeyAction)
+		}
+		if action == rules.MissingKeyActionDeny {
+			assert.Equal(t, "req.hdr(X-Api-Key)", reqRateLimit.limit.MissingKey)
+		} else {
+			assert.Empty(t, reqRateLimit.limit.MissingKey)
+		}
+		assert.Empty(t, reqRateLimit.tiers[1].limit.MissingKey)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-missing-key-action": rules.MissingKeyActionDeny})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.track.MissingKeyAction)
+	assert.Empty(t, reqRateLimit.limit.MissingKey)
+
+	_, err = process(t, map[string]string{"rate-limit-key": "req.hdr(X-Api-Key)", "rate-limit-missing-key-action": "drop"})
+	assert.ErrorContains(t, err, "rate-limit-missing-key-action")
+}
+
+// TestReqRateLimit_Bytes tests the rate-limit-bytes-in and rate-limit-bytes-out annotations processing.
+// It validates that:
+// - Sizes are parsed with their k, m or g unit
+// - Each direction gets its own tier, table and stick counter, using the period of the request rate limit
+// - Without rate-limit-requests, tables are named RateLimitBytesIn and RateLimitBytesOut
+// - The whitelist exempts sources from the bandwidth limits
+// - Invalid and non positive sizes are rejected, as well as a fourth rate limit
+func TestReqRateLimit_Bytes(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-bytes-in", "rate-limit-bytes-out", "rate-limit-whitelist"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-requests":  "100",
+		"rate-limit-period":    "1m",
+		"rate-limit-bytes-in":  "50m",
+		"rate-limit-bytes-out": "1g",
+		"rate-limit-whitelist": "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 3)
+	for i, want := range []struct {
+		counter string
+		table   string
+		limit   int64
+	}{
+		{counter: rules.RateLimitCounterBytesInRate, table: "RateLimit-60000-bytes-in", limit: 50 * 1024 * 1024},
+		{counter: rules.RateLimitCounterBytesOutRate, table: "RateLimit-60000-bytes-out", limit: 1024 * 1024 * 1024},
+	} {
+		tier := reqRateLimit.tiers[i+1]
+		assert.Equal(t, want.counter, tier.track.Counter)
+		assert.Equal(t, want.counter, tier.limit.Counter)
+		assert.Equal(t, want.table, tier.track.TableName)
+		assert.Equal(t, want.table, tier.limit.TableName)
+		assert.Equal(t, want.limit, tier.limit.ReqsLimit)
+		assert.Equal(t, int64(60000), *tier.track.TablePeriod)
+		assert.Equal(t, int64(i+1), tier.track.StickCounter)
+		assert.Equal(t, int64(i+1), tier.limit.StickCounter)
+		assert.Equal(t, []string{"10.0.0.0/8"}, tier.limit.WhitelistIPs)
+	}
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-bytes-in": "1024", "rate-limit-bytes-out": "10k"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.Equal(t, "RateLimitBytesIn", reqRateLimit.track.TableName)
+	assert.Equal(t, int64(1024), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, "RateLimitBytesOut", reqRateLimit.tiers[1].track.TableName)
+	assert.Equal(t, int64(10*1024), reqRateLimit.tiers[1].limit.ReqsLimit)
+	assert.Equal(t, int64(defaultRateLimitPeriod), *reqRateLimit.track.TablePeriod)
+
+	for _, size := range []string{"10x", "0", "-5k", "m"} {
+		_, err = process(t, map[string]string{"rate-limit-bytes-in": size})
+		assert.ErrorContains(t, err, "rate-limit-bytes-in", size)
+	}
+
+	_, err = process(t, map[string]string{
+		"rate-limit-requests":  "10, 100",
+		"rate-limit-period":    "1s, 1m",
+		"rate-limit-bytes-in":  "50m",
+		"rate-limit-bytes-out": "1g",
+	})
+	assert.ErrorContains(t, err, "rate-limit-bytes-out")
+}
+
+// TestReqRateLimit_TableName tests the rate-limit-table-name annotation processing.
+// It validates that:
+// - Every tier gets its name as is, whatever its period and key
+// - Connection tables are named after the first overridden name
+// - Names out of the HAProxy identifier charset, duplicate and missing names are rejected
+// - The annotation can't be combined with rate-limit-shared-table
+func TestReqRateLimit_TableName(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-key", "rate-limit-shared-table", "rate-limit-table-name", "rate-limit-connections"} {
+			err = reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-key":         "hdr(X-Api-Key)",
+		"rate-limit-table-name":  "api-burst, api.minute_1",
+		"rate-limit-connections": "20",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 3)
+	for i, name := range []string{"api-burst", "api.minute_1", "api-burst-conn"} {
+		assert.Equal(t, name, reqRateLimit.tiers[i].track.TableName)
+		assert.Equal(t, name, reqRateLimit.tiers[i].limit.TableName)
+	}
+	assert.Equal(t, int64(60000), *reqRateLimit.tiers[1].track.TablePeriod)
+
+	for _, names := range []string{"api burst, api-minute", "api/burst, api-minute", "api-burst", "api, api", "api, RateLimitDenied"} {
+		_, err = process(t, map[string]string{"rate-limit-table-name": names})
+		assert.ErrorContains(t, err, "rate-limit-table-name", names)
+	}
+
+	_, err = process(t, map[string]string{"rate-limit-shared-table": "storefront", "rate-limit-table-name": "a, b"})
+	assert.ErrorContains(t, err, "can't be combined")
+}
+
+// TestReqRateLimit_WhitelistMerge tests the inheritance of the ConfigMap whitelist.
+// It validates that:
+// - Without rate-limit-whitelist-merge, the ingress whitelist overrides the ConfigMap one
+// - With it, addresses and pattern files of both whitelists are merged, without duplicates
+// - A ConfigMap reference is merged as a map with the addresses of the other whitelist
+// - The ConfigMap whitelist applies to ingresses without whitelist, and an ingress can opt out of the merge
+func TestReqRateLimit_WhitelistMerge(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	k.GetNamespace("default").ConfigMaps["scanners"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "scanners",
+		Annotations: map[string]string{"addresses": "172.16.0.0/12\n198.51.100.0/24"},
+	}
+	process := func(t *testing.T, ingress, configMap map[string]string) *ReqRateLimit {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, ingress, configMap))
+		}
+		return reqRateLimit
+	}
+	configMap := map[string]string{
+		"rate-limit-requests":                   "100",
+		"rate-limit-whitelist-inline-threshold": "2",
+		"rate-limit-whitelist":                  "10.10.0.0/16, patterns/monitoring",
+	}
+
+	reqRateLimit := process(t, map[string]string{"rate-limit-whitelist": "192.168.1.100"}, configMap)
+	assert.Equal(t, []string{"192.168.1.100"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+
+	configMap["rate-limit-whitelist-merge"] = "true"
+	reqRateLimit = process(t, map[string]string{"rate-limit-whitelist": "192.168.1.100, 10.10.0.0/16"}, configMap)
+	assert.Equal(t, []string{"192.168.1.100", "10.10.0.0/16"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{"patterns/monitoring"}, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-whitelist": "configmap/default/scanners"}, configMap)
+	assert.Equal(t, []string{"10.10.0.0/16"}, reqRateLimit.limit.WhitelistIPs)
+	require.Len(t, reqRateLimit.limit.WhitelistMaps, 2)
+	assert.Equal(t, maps.GetPath(whitelistMapName(nil, []string{"172.16.0.0/12", "198.51.100.0/24"})), reqRateLimit.limit.WhitelistMaps[0])
+	assert.Equal(t, maps.Path("patterns/monitoring"), reqRateLimit.limit.WhitelistMaps[1])
+
+	reqRateLimit = process(t, map[string]string{}, configMap)
+	assert.Equal(t, []string{"10.10.0.0/16"}, reqRateLimit.limit.WhitelistIPs)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-whitelist": "192.168.1.100", "rate-limit-whitelist-merge": "false"}, configMap)
+	assert.Equal(t, []string{"192.168.1.100"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Empty(t, reqRateLimit.limit.WhitelistMaps)
+}
+
+// TestReqRateLimit_SNIKey tests the tracking of the TLS SNI with rate-limit-key.
+// It validates that:
+// - "sni" tracks the lowercased SNI in a dedicated string table, on every tier
+// - ssl_fc_sni keys, alone or in a composite key, are only tracked on TLS connections
+// - Other keys are tracked on every connection
+func TestReqRateLimit_SNIKey(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) *ReqRateLimit {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		for _, annName := range ReqRateLimitAnnotations {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit
+	}
+
+	reqRateLimit := process(t, map[string]string{"rate-limit-key": "sni", "rate-limit-connections": "20"})
+	require.Len(t, reqRateLimit.tiers, 3)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "ssl_fc_sni,lower", tier.track.TrackKey)
+		assert.Equal(t, "string", tier.track.TableType)
+		assert.True(t, tier.track.SSLOnly)
+	}
+	assert.True(t, strings.HasPrefix(reqRateLimit.track.TableName, "RateLimit-1000-"))
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-key": "ssl_fc_sni"})
+	assert.Equal(t, "ssl_fc_sni", reqRateLimit.track.TrackKey)
+	assert.True(t, reqRateLimit.track.SSLOnly)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-composite-key": "ssl_fc_sni,path"})
+	assert.True(t, reqRateLimit.track.SSLOnly)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-key": "hdr(host)"})
+	assert.False(t, reqRateLimit.track.SSLOnly)
+}
+
+// TestReqRateLimit_ServiceAnnotations tests rate limits set on the services of an ingress.
+// It validates that:
+// - Service rate-limit annotations apply to an ingress without rate-limit annotations
+// - A service setting rate-limit-requests overrides the ConfigMap default rate limit
+// - Ingress annotations take precedence over the service ones
+func TestReqRateLimit_ServiceAnnotations(t *testing.T) {
+	configMap := map[string]string{
+		"rate-limit-requests":    "10",
+		"rate-limit-period":      "10s",
+		"rate-limit-status-code": "429",
+	}
+	service := map[string]string{
+		"rate-limit-requests":    "200",
+		"rate-limit-status-code": "503",
+	}
+	process := func(t *testing.T, ingress map[string]string) *ReqRateLimit {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-status-code"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, ingress, service, configMap))
+		}
+		return reqRateLimit
+	}
+
+	reqRateLimit := process(t, map[string]string{})
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, int64(200), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(1000), *reqRateLimit.track.TablePeriod)
+	assert.Equal(t, int64(503), reqRateLimit.limit.DenyStatusCode)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-period": "1m"})
+	assert.Equal(t, int64(200), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(60000), *reqRateLimit.track.TablePeriod)
+
+	reqRateLimit = process(t, map[string]string{"rate-limit-requests": "20", "rate-limit-status-code": "429"})
+	assert.Equal(t, int64(20), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(1000), *reqRateLimit.track.TablePeriod)
+	assert.Equal(t, int64(429), reqRateLimit.limit.DenyStatusCode)
+}
+
+// TestResolveRateLimitAnnotations tests the resolution of the effective rate-limit annotations
+// of the ingress, service and ConfigMap layers.
+// It validates that:
+// - The first layer setting an annotation wins, even with an empty value
+// - Annotations set in a single layer are kept, whatever the layer
+// - A layer setting the default rate limit drops the ConfigMap requests and period, not its other annotations
+// - Annotations which are not rate-limit ones, or set in no layer, are left out
+func TestResolveRateLimitAnnotations(t *testing.T) {
+	configMap := map[string]string{
+		"rate-limit-requests":    "10",
+		"rate-limit-period":      "10s",
+		"rate-limit-status-code": "429",
+		"rate-limit-whitelist":   "10.0.0.0/8",
+		"rate-limit-size":        "1m",
+		"timeout-client":         "50s",
+	}
+	tests := []struct {
+		name    string
+		ingress map[string]string
+		service map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "ConfigMap only",
+			want: map[string]string{
+				"rate-limit-requests":    "10",
+				"rate-limit-period":      "10s",
+				"rate-limit-status-code": "429",
+				"rate-limit-whitelist":   "10.0.0.0/8",
+				"rate-limit-size":        "1m",
+			},
+		},
+		{
+			name:    "service over ConfigMap",
+			service: map[string]string{"rate-limit-status-code": "503", "rate-limit-key": "hdr(host)"},
+			want: map[string]string{
+				"rate-limit-requests":    "10",
+				"rate-limit-period":      "10s",
+				"rate-limit-status-code": "503",
+				"rate-limit-whitelist":   "10.0.0.0/8",
+				"rate-limit-size":        "1m",
+				"rate-limit-key":         "hdr(host)",
+			},
+		},
+		{
+			name:    "ingress over service over ConfigMap",
+			ingress: map[string]string{"rate-limit-status-code": "403", "rate-limit-whitelist": ""},
+			service: map[string]string{"rate-limit-status-code": "503", "rate-limit-whitelist": "192.168.0.0/16"},
+			want: map[string]string{
+				"rate-limit-requests":    "10",
+				"rate-limit-period":      "10s",
+				"rate-limit-status-code": "403",
+				"rate-limit-whitelist":   "",
+				"rate-limit-size":        "1m",
+			},
+		},
+		{
+			name:    "service default rate limit",
+			ingress: map[string]string{"rate-limit-status-code": "403"},
+			service: map[string]string{"rate-limit-requests": "200"},
+			want: map[string]string{
+				"rate-limit-requests":    "200",
+				"rate-limit-status-code": "403",
+				"rate-limit-whitelist":   "10.0.0.0/8",
+				"rate-limit-size":        "1m",
+			},
+		},
+		{
+			name:    "ingress default rate limit",
+			ingress: map[string]string{"rate-limit-rps": "5"},
+			service: map[string]string{"rate-limit-period": "1m"},
+			want: map[string]string{
+				"rate-limit-rps":         "5",
+				"rate-limit-period":      "1m",
+				"rate-limit-status-code": "429",
+				"rate-limit-whitelist":   "10.0.0.0/8",
+				"rate-limit-size":        "1m",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress, service := tt.ingress, tt.service
+			if ingress == nil {
+				ingress = map[string]string{}
+			}
+			if service == nil {
+				service = map[string]string{}
+			}
+			assert.Equal(t, tt.want, ResolveRateLimitAnnotations(ingress, service, configMap))
+		})
+	}
+}
+
+// TestReqRateLimit_ExemptMethods tests the rate-limit-exempt-methods annotation processing.
+// It validates that:
+// - Methods are upper cased, deduplicated and stored on the track and the limit rules of every tier
+// - Exempting methods gets the rate limit a dedicated table
+// - Unknown methods are rejected
+func TestReqRateLimit_ExemptMethods(t *testing.T) {
+	process := func(t *testing.T, methods string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":       "10, 100",
+			"rate-limit-period":         "1s, 1m",
+			"rate-limit-exempt-methods": methods,
+		}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-period", "rate-limit-exempt-methods"} {
+			if err := reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				return reqRateLimit, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, "get, HEAD,head, options")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, []string{"GET", "HEAD", "OPTIONS"}, tier.track.ExemptMethods)
+		assert.Equal(t, []string{"GET", "HEAD", "OPTIONS"}, tier.limit.ExemptMethods)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+	assert.NotEqual(t, "RateLimit-1000", reqRateLimit.tiers[0].track.TableName)
+
+	for _, methods := range []string{"GET,FETCH", "GET HEAD", "*"} {
+		_, err = process(t, methods)
+		assert.ErrorContains(t, err, "rate-limit-exempt-methods", methods)
+	}
+}
+
+// TestReqRateLimit_Geo tests the rate-limit-geo-map, rate-limit-exempt-countries and
+// rate-limit-target-countries annotations processing.
+// It validates that:
+// - The map and the upper cased, deduplicated countries are set on the limit of every tier
+// - Countries require a map, and exempt and target countries can't be combined
+// - Maps out of the pattern files and invalid country codes are rejected
+func TestReqRateLimit_Geo(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-geo-map":          "patterns/geo.map",
+		"rate-limit-exempt-countries": "us, FR,fr",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, maps.Path("patterns/geo.map"), tier.limit.GeoMap)
+		assert.Equal(t, []string{"US", "FR"}, tier.limit.GeoExemptCountries)
+		assert.Empty(t, tier.limit.GeoTargetCountries)
+	}
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-geo-map":          "patterns/geo.map",
+		"rate-limit-target-countries": "CN",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CN"}, reqRateLimit.limit.GeoTargetCountries)
+	assert.Empty(t, reqRateLimit.limit.GeoExemptCountries)
+
+	_, err = process(t, map[string]string{"rate-limit-exempt-countries": "US"})
+	assert.ErrorContains(t, err, "requires rate-limit-geo-map")
+	_, err = process(t, map[string]string{
+		"rate-limit-geo-map":          "patterns/geo.map",
+		"rate-limit-exempt-countries": "US",
+		"rate-limit-target-countries": "CN",
+	})
+	assert.ErrorContains(t, err, "rate-limit-target-countries annotation can't be combined")
+	_, err = process(t, map[string]string{"rate-limit-geo-map": "/etc/geo.map"})
+	assert.ErrorContains(t, err, "rate-limit-geo-map")
+	for _, countries := range []string{"USA", "U1", "US FR"} {
+		_, err = process(t, map[string]string{"rate-limit-geo-map": "patterns/geo.map", "rate-limit-exempt-countries": countries})
+		assert.ErrorContains(t, err, "rate-limit-exempt-countries", countries)
+	}
+}
+
+// TestReqRateLimit_ExemptUserAgents tests the rate-limit-exempt-user-agents annotation processing.
+// It validates that:
+// - Substrings and pattern files are set on the limit of every tier, deduplicated, while requests are still tracked
+// - Substrings with whitespaces and references out of the pattern files are rejected
+func TestReqRateLimit_ExemptUserAgents(t *testing.T) {
+	process := func(t *testing.T, agents string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":           "10, 100",
+			"rate-limit-period":             "1s, 1m",
+			"rate-limit-exempt-user-agents": agents,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, "kube-probe")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, []string{"kube-probe"}, tier.limit.ExemptUserAgents)
+		assert.Empty(t, tier.limit.ExemptUserAgentMaps)
+		assert.Empty(t, tier.track.ExemptMethods)
+	}
+
+	reqRateLimit, err = process(t, "kube-probe, Pingdom, patterns/monitors, kube-probe, patterns/monitors")
+	require.NoError(t, err)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, []string{"kube-probe", "Pingdom"}, tier.limit.ExemptUserAgents)
+		assert.Equal(t, []maps.Path{"patterns/monitors"}, tier.limit.ExemptUserAgentMaps)
+	}
+
+	reqRateLimit, err = process(t, "patterns/uptime-monitors")
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.ExemptUserAgents)
+	assert.Equal(t, []maps.Path{"patterns/uptime-monitors"}, reqRateLimit.limit.ExemptUserAgentMaps)
+
+	for _, agents := range []string{"kube probe", "patterns/../monitors", `"kube-probe"`} {
+		_, err = process(t, agents)
+		assert.ErrorContains(t, err, "rate-limit-exempt-user-agents", agents)
+	}
+}
+
+// TestReqRateLimit_CostHeader tests the rate-limit-cost-header annotation processing.
+// It validates that:
+// - The header is set on the track rule of every tier, whose limit compares the gpc0 rate
+// - Counting costs gets the rate limit a dedicated table
+// - Invalid header names are rejected
+func TestReqRateLimit_CostHeader(t *testing.T) {
+	process := func(t *testing.T, header string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":    "10, 100",
+			"rate-limit-period":      "1s, 1m",
+			"rate-limit-cost-header": header,
+		}
+		for _, annName := range ReqRateLimitAnnotations {
+			if err := reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				return reqRateLimit, err
+			}
+		}
+		return reqRateLimit, nil
+	}
+
+	reqRateLimit, err := process(t, " X-Request-Cost ")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "X-Request-Cost", tier.track.CostHeader)
+		assert.Equal(t, rules.RateLimitCounterGpc0Rate, tier.limit.Counter)
+		assert.Equal(t, tier.track.TableName, tier.limit.TableName)
+	}
+	assert.NotEqual(t, "RateLimit-1000", reqRateLimit.tiers[0].track.TableName)
+
+	for _, header := range []string{"X Cost", "X-Cost: 2", "req.hdr(X-Cost)"} {
+		_, err = process(t, header)
+		assert.ErrorContains(t, err, "rate-limit-cost-header", header)
+	}
+}
+
+// TestReqRateLimit_Percentage tests the rate-limit-percentage annotation processing.
+// It validates that:
+// - The percentage of admitted requests is set on every tier, with an optional '%' sign
+// - Only the first tier draws the random number
+// - Values out of 0 to 100 are rejected
+func TestReqRateLimit_Percentage(t *testing.T) {
+	process := func(t *testing.T, percentage string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":   "10, 100",
+			"rate-limit-period":     "1s, 1m",
+			"rate-limit-percentage": percentage,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	for percentage, want := range map[string]int64{"30": 30, " 75% ": 75, "0": 0, "100": 100} {
+		reqRateLimit, err := process(t, percentage)
+		require.NoError(t, err, percentage)
+		require.Len(t, reqRateLimit.tiers, 2)
+		for i, tier := range reqRateLimit.tiers {
+			assert.Equal(t, want, tier.limit.AdmitPercentage, percentage)
+			assert.Equal(t, i > 0, tier.limit.RandDrawn, percentage)
+		}
+	}
+
+	for _, percentage := range []string{"-5", "101", "12.5", "half"} {
+		_, err := process(t, percentage)
+		assert.ErrorContains(t, err, "rate-limit-percentage", percentage)
+	}
+}
+
+// TestReqRateLimit_SilentDrop tests the rate-limit-action silent-drop combined with the other annotations.
+// It validates that:
+// - silent-drop can't be combined with rate-limit-retry-after or rate-limit-deny-message, as no response is sent
+// - rate-limit-retry-after set to false and an empty rate-limit-deny-message are accepted
+func TestReqRateLimit_SilentDrop(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) error {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "100"
+		annotations["rate-limit-action"] = rules.RateLimitActionSilentDrop
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return errors.Join(errs...)
+	}
+
+	err := process(t, map[string]string{"rate-limit-retry-after": "30s"})
+	assert.ErrorContains(t, err, "can't be combined with rate-limit-retry-after")
+
+	err = process(t, map[string]string{"rate-limit-deny-message": "Slow down"})
+	assert.ErrorContains(t, err, "rate-limit-deny-message annotation can't be combined")
+
+	require.NoError(t, process(t, map[string]string{"rate-limit-retry-after": "false", "rate-limit-deny-message": " "}))
+}
+
+// TestReqRateLimit_WhitelistHeader tests the rate-limit-whitelist-header annotation processing.
+// It validates that:
+// - A single accepted value is set on every tier
+// - A value starting with patterns/ is a pattern file of accepted values
+// - Malformed headers and values which can't be used in an ACL are rejected
+func TestReqRateLimit_WhitelistHeader(t *testing.T) {
+	process := func(t *testing.T, header string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":         "10, 100",
+			"rate-limit-period":           "1s, 1m",
+			"rate-limit-whitelist-header": header,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, "X-Internal: s3cr3t-t0ken")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "X-Internal", tier.limit.WhitelistHeader)
+		assert.Equal(t, "s3cr3t-t0ken", tier.limit.WhitelistHeaderValue)
+		assert.Empty(t, tier.limit.WhitelistHeaderMap)
+	}
+
+	reqRateLimit, err = process(t, "X-Internal:patterns/internal-tokens")
+	require.NoError(t, err)
+	assert.Equal(t, "X-Internal", reqRateLimit.limit.WhitelistHeader)
+	assert.Empty(t, reqRateLimit.limit.WhitelistHeaderValue)
+	assert.Equal(t, maps.Path("patterns/internal-tokens"), reqRateLimit.limit.WhitelistHeaderMap)
+
+	for _, header := range []string{"X-Internal", "X-Internal:", ": trusted", "X Internal: trusted", "X-Internal: two words", "X-Internal: }", `X-Internal: a"b`} {
+		_, err = process(t, header)
+		assert.ErrorContains(t, err, "rate-limit-whitelist-header", header)
+	}
+}
+
+// TestReqRateLimit_WhitelistHeaderSecret tests rate-limit-whitelist-header accepting the values of a Secret.
+// It validates that:
+// - The values of every Secret key are loaded into a map the header is matched against, never inline
+// - A malformed value is reported with its Secret key and line number, without its content
+// - An empty Secret accepts no value, and missing Secrets or maps are errors
+func TestReqRateLimit_WhitelistHeaderSecret(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.Secret["api-keys"] = &store.Secret{
+		Namespace: "default",
+		Name:      "api-keys",
+		Data: map[string][]byte{
+			"free":    []byte("# trial plans\nk-7f3a9c\n\nk-0b12de\n"),
+			"premium": []byte("k-99aa01\nk-7f3a9c"),
+		},
+	}
+	ns.Secret["broken"] = &store.Secret{
+		Namespace: "default",
+		Name:      "broken",
+		Data:      map[string][]byte{"keys": []byte("k-1\nconfidential key")},
+	}
+	ns.Secret["empty"] = &store.Secret{
+		Namespace: "default",
+		Name:      "empty",
+		Data:      map[string][]byte{"keys": []byte("# none yet\n")},
+	}
+
+	process := func(t *testing.T, header string, withMaps bool) (*ReqRateLimit, maps.Maps, error) {
+		t.Helper()
+		var mockMaps maps.Maps
+		if withMaps {
+			var err error
+			mockMaps, err = maps.New("/tmp/maps", nil)
+			require.NoError(t, err)
+		}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":         "10",
+			"rate-limit-whitelist-header": header,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		return reqRateLimit, mockMaps, errors.Join(errs...)
+	}
+
+	reqRateLimit, mockMaps, err := process(t, "X-API-Key: secret/default/api-keys", true)
+	require.NoError(t, err)
+	mapName := addressesMapName("ratelimit-header-", nil, []string{"k-7f3a9c", "k-0b12de", "k-99aa01"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	assert.Equal(t, "X-API-Key", reqRateLimit.limit.WhitelistHeader)
+	assert.Empty(t, reqRateLimit.limit.WhitelistHeaderValue)
+	assert.Equal(t, maps.GetPath(mapName), reqRateLimit.limit.WhitelistHeaderMap)
+	assert.Contains(t, reqRateLimit.limit.String(), "!{ req.hdr(X-API-Key) -m str -f "+string(maps.GetPath(mapName))+" }")
+	assert.NotContains(t, reqRateLimit.limit.String(), "k-7f3a9c")
+
+	_, _, err = process(t, "X-API-Key: secret/default/broken", true)
+	assert.ErrorContains(t, err, "secret 'default/broken' key 'keys' line 2")
+	assert.NotContains(t, err.Error(), "confidential")
+
+	reqRateLimit, _, err = process(t, "X-API-Key: secret/default/empty", true)
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.WhitelistHeader)
+
+	_, _, err = process(t, "X-API-Key: secret/default/missing", true)
+	assert.ErrorContains(t, err, "rate-limit-whitelist-header")
+	_, _, err = process(t, "X-API-Key: secret/default/api-keys", false)
+	assert.ErrorIs(t, err, ErrMapsUnavailable)
+}
+
+// TestReqRateLimit_WhitelistMapNormalized tests that equivalent whitelists share their map.
+// It validates that:
+// - ConfigMaps listing the same addresses in a different order, spacing or notation make the same map
+// - Hostnames listed in a different order or case make the same map
+// - Addresses are canonicalized: masked CIDRs, lower case and compressed IPv6 without brackets
+func TestReqRateLimit_WhitelistMapNormalized(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.ConfigMaps["compact"] = &store.ConfigMap{
+		Namespace:   "default",
+		Name:        "compact",
+		Annotations: map[string]string{"trusted": "192.168.1.1\n10.0.0.0/8\n2001:db8::1"},
+	}
+	ns.ConfigMaps["verbose"] = &store.ConfigMap{
+		Namespace: "default",
+		Name:      "verbose",
+		Annotations: map[string]string{
+			"ipv6": "  [2001:DB8:0:0::1]  ",
+			"ipv4": "10.1.2.3/8   # whole network\n\n 192.168.1.1 ",
+		},
+	}
+	process := func(t *testing.T, whitelist string) (maps.Maps, []maps.Path) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		reqRateLimit.lookupHost = func(string) ([]string, error) {
+			return []string{"203.0.113.10"}, nil
+		}
+		annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-whitelist": whitelist}
+		for _, annName := range []string{"rate-limit-requests", "rate-limit-whitelist"} {
+			require.NoError(t, reqRateLimit.NewAnnotation(annName).Process(k, annotations))
+		}
+		return mockMaps, reqRateLimit.limit.WhitelistMaps
+	}
+
+	mockMaps, compact := process(t, "configmap/default/compact")
+	_, verbose := process(t, "configmap/default/verbose")
+	require.Len(t, compact, 1)
+	assert.Equal(t, compact, verbose)
+	assert.True(t, mockMaps.MapExists(whitelistMapName(nil, []string{"2001:db8::1", "10.0.0.0/8", "192.168.1.1"})))
+
+	_, hosts := process(t, "partner.example.com, backup.example.com")
+	_, reordered := process(t, "Backup.example.com,partner.example.com.")
+	require.Len(t, hosts, 1)
+	assert.Equal(t, hosts, reordered)
+
+	for entry, want := range map[string]string{
+		"10.1.2.3/8":         "10.0.0.0/8",
+		"[2001:DB8::]/32":    "2001:db8::/32",
+		"2001:0db8:0:0:0::1": "2001:db8::1",
+		"192.168.1.1":        "192.168.1.1",
+	} {
+		address, ok := parseRateLimitAddress(entry)
+		assert.True(t, ok, entry)
+		assert.Equal(t, want, address, entry)
+	}
+}
+
+// TestReqRateLimit_Peers tests the rate-limit-peers annotation processing.
+// It validates that:
+// - Every table of the rate limit, including the counter tiers, is synchronized with the peers section
+// - Tables are synchronized with the local instance only by default
+// - Incorrect section names are rejected
+func TestReqRateLimit_Peers(t *testing.T) {
+	process := func(t *testing.T, peers string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests":    "10, 100",
+			"rate-limit-period":      "1s, 1m",
+			"rate-limit-connections": "5",
+		}
+		if peers != "" {
+			annotations["rate-limit-peers"] = peers
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, " haproxy-peers ")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 3)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, "haproxy-peers", tier.track.Peers, tier.track.TableName)
+	}
+
+	reqRateLimit, err = process(t, "")
+	require.NoError(t, err)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Empty(t, tier.track.Peers, tier.track.TableName)
+	}
+
+	for _, peers := range []string{"ha peers", "peers{1}"} {
+		_, err = process(t, peers)
+		assert.ErrorContains(t, err, "rate-limit-peers", peers)
+	}
+}
+
+// TestReqRateLimit_Streams tests the rate-limit-streams annotation processing.
+// It validates that:
+// - The limit of concurrent streams is set on the first tier only
+// - Non positive integers are rejected
+func TestReqRateLimit_Streams(t *testing.T) {
+	process := func(t *testing.T, streams string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{
+			"rate-limit-requests": "10, 100",
+			"rate-limit-period":   "1s, 1m",
+			"rate-limit-streams":  streams,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, " 64 ")
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.Equal(t, int64(64), reqRateLimit.tiers[0].limit.MaxStreams)
+	assert.Zero(t, reqRateLimit.tiers[1].limit.MaxStreams)
+
+	for _, streams := range []string{"0", "-1", "many"} {
+		_, err = process(t, streams)
+		assert.ErrorContains(t, err, "rate-limit-streams", streams)
+	}
+}
+
+// TestReqRateLimit_RPS tests the rate-limit-rps annotation processing.
+// It validates that:
+// - A requests per second value tracks requests over the 1s default period table
+// - An ingress rps value overrides the requests and period set in the ConfigMap
+// - Setting rps along with requests or period on the same resource is rejected
+// - Invalid and comma-separated values are rejected
+func TestReqRateLimit_RPS(t *testing.T) {
+	process := func(t *testing.T, annotations ...map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations...))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-rps": "20"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, int64(20), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, "RateLimit-1000", reqRateLimit.limit.TableName)
+	assert.Equal(t, "RateLimit-1000", reqRateLimit.track.TableName)
+	assert.Equal(t, int64(1000), *reqRateLimit.track.TablePeriod)
+
+	reqRateLimit, err = process(t,
+		map[string]string{"rate-limit-rps": "20"},
+		map[string]string{"rate-limit-requests": "100", "rate-limit-period": "1m"},
+	)
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, int64(20), reqRateLimit.limit.ReqsLimit)
+	assert.Equal(t, int64(1000), *reqRateLimit.track.TablePeriod)
+
+	for conflict, value := range map[string]string{"rate-limit-requests": "100", "rate-limit-period": "10s"} {
+		_, err = process(t, map[string]string{"rate-limit-rps": "20", conflict: value})
+		assert.ErrorContains(t, err, "rate-limit-rps annotation can't be combined with "+conflict)
+	}
+
+	_, err = process(t, map[string]string{"rate-limit-rps": "fast"})
+	assert.Error(t, err)
+	_, err = process(t, map[string]string{"rate-limit-rps": "10, 20"})
+	assert.ErrorContains(t, err, "rate-limit-rps annotation supports a single value")
+}
+
+// TestReqRateLimit_ClusterCIDRs tests the cluster CIDRs set with --rate-limit-cluster-cidrs.
+// It validates that:
+// - Cluster CIDRs are whitelisted on every tier without rate-limit-whitelist
+// - They come first and are unioned with the rate-limit-whitelist entries, repeated entries kept once
+// - They are whitelisted along with pattern files and merged whitelists
+// - Nothing is whitelisted without rate limit, nor without cluster CIDRs
+// - An invalid cluster CIDR is rejected
+func TestReqRateLimit_ClusterCIDRs(t *testing.T) {
+	process := func(t *testing.T, cidrs []string, annotations ...map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		k := store.K8s{RateLimitClusterCIDRs: cidrs}
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations...))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+	cidrs := []string{"10.244.0.0/16", " 10.96.0.0/12", "fd00::/64"}
+
+	reqRateLimit, err := process(t, cidrs, map[string]string{"rate-limit-requests": "10, 100", "rate-limit-period": "1s, 1m"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, []string{"10.244.0.0/16", "10.96.0.0/12", "fd00::/64"}, tier.limit.WhitelistIPs)
+	}
+	assert.True(t, reqRateLimit.Result().Whitelisted)
+	assert.Contains(t, reqRateLimit.limit.String(), "!{ src 10.244.0.0/16 10.96.0.0/12 fd00::/64 }")
+
+	reqRateLimit, err = process(t, cidrs, map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "192.168.1.1, 10.96.0.0/12, patterns/trusted",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.244.0.0/16", "10.96.0.0/12", "fd00::/64", "192.168.1.1"}, reqRateLimit.limit.WhitelistIPs)
+	assert.Equal(t, []maps.Path{"patterns/trusted"}, reqRateLimit.limit.WhitelistMaps)
+
+	reqRateLimit, err = process(t, cidrs[:1],
+		map[string]string{"rate-limit-requests": "10", "rate-limit-whitelist": "192.168.1.1", "rate-limit-whitelist-merge": "true"},
+		map[string]string{"rate-limit-whitelist": "172.16.0.0/12"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.244.0.0/16", "192.168.1.1", "172.16.0.0/12"}, reqRateLimit.limit.WhitelistIPs)
+
+	reqRateLimit, err = process(t, cidrs, map[string]string{})
+	require.NoError(t, err)
+	assert.Nil(t, reqRateLimit.limit)
+
+	reqRateLimit, err = process(t, nil, map[string]string{"rate-limit-requests": "10"})
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.WhitelistIPs)
+
+	_, err = process(t, []string{"10.244.0.0/33"}, map[string]string{"rate-limit-requests": "10"})
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	assert.ErrorContains(t, err, "--rate-limit-cluster-cidrs")
+}
+
+// TestReqRateLimit_Specs tests the specs describing the rate-limit annotation values.
+// It validates that:
+// - Every rate-limit annotation has a spec, and every spec is a rate-limit annotation handled by Process
+// - Valid values are accepted by both the spec and Process
+// - Values rejected by Process are rejected by the spec, every annotation having rejected values
+// - Specs are serialized in JSON without their compiled pattern
+//
+//revive:disable-next-line:function-length
+func TestReqRateLimit_Specs(t *testing.T) {
+	valid := map[string][]string{
+		"rate-limit-rps":                        {"20", "off"},
+		"rate-limit-requests":                   {"10", "OFF", "0", "auto"},
+		"rate-limit-period":                     {"1s", " 1m30s "},
+		"rate-limit-size":                       {"100k", "auto", "1000"},
+		"rate-limit-table-expire":               {"1m"},
+		"rate-limit-key":                        {"sni", "req.cook(session),lower"},
+		"rate-limit-forwarded-for-depth":        {"2"},
+		"rate-limit-composite-key":              {"src, hdr(X-Tenant)", "src,req.fhdr(X-Id,1)"},
+		"rate-limit-path-template":              {"[0-9]+", "[0-9a-f]{8}-[0-9a-f-]{27}"},
+		"rate-limit-anonymize":                  {"true", "false"},
+		"rate-limit-missing-key-action":         {"deny", "shared", "exempt"},
+		"rate-limit-path":                       {"/api, /v2/", "/api,"},
+		"rate-limit-exempt-methods":             {"get, HEAD", "OPTIONS,"},
+		"rate-limit-geo-map":                    {"patterns/geo.map", ""},
+		"rate-limit-exempt-countries":           {"us, FR", "CN,"},
+		"rate-limit-target-countries":           {"de"},
+		"rate-limit-exempt-user-agents":         {"kube-probe", "kube-probe, patterns/monitors,"},
+		"rate-limit-cost-header":                {"X-Request-Cost"},
+		"rate-limit-shared-table":               {"api"},
+		"rate-limit-table-name":                 {"api-table"},
+		"rate-limit-auto-headroom":              {"25%"},
+		"rate-limit-per-replica":                {"true", "false"},
+		"rate-limit-burst":                      {"20"},
+		"rate-limit-burst-period":               {"500ms", "2s"},
+		"rate-limit-connections":                {"5"},
+		"rate-limit-bytes-in":                   {"1m"},
+		"rate-limit-bytes-out":                  {"512k"},
+		"rate-limit-errors":                     {"5"},
+		"rate-limit-error-status":               {"401", "401, 403"},
+		"rate-limit-streams":                    {"64"},
+		"rate-limit-peers":                      {"mypeers"},
+		"rate-limit-sc-slot":                    {"1"},
+		"rate-limit-id":                         {"checkout-api", "v2.orders_1"},
+		"rate-limit-denied-metric":              {"true"},
+		"rate-limit-status-code":                {"429"},
+		"rate-limit-retry-after":                {"true", "30s"},
+		"rate-limit-action":                     {"tarpit", "silent-drop"},
+		"rate-limit-tarpit-duration":            {"10s", "500"},
+		"rate-limit-deny-rate":                  {"20", "0"},
+		"rate-limit-position":                   {"before-auth", "after-auth"},
+		"rate-limit-percentage":                 {"50%", "100"},
+		"rate-limit-hysteresis":                 {"80%", "0"},
+		"rate-limit-deny-message":               {"Too many requests"},
+		"rate-limit-errorfile":                  {"429", " "},
+		"rate-limit-redirect":                   {"/slow-down", "https://example.com/slow-down?from=api"},
+		"rate-limit-log":                        {"true", "api.limits"},
+		"rate-limit-log-target":                 {"10.0.0.5:514", "udp@siem.example.com:514"},
+		"rate-limit-headers":                    {"false"},
+		"rate-limit-headers-threshold":          {"80%"},
+		"rate-limit-track-only":                 {"true"},
+		"rate-limit-grace-period":               {"30s", "0"},
+		"rate-limit-whitelist-strict":           {"false"},
+		"rate-limit-whitelist-merge":            {"true"},
+		"rate-limit-whitelist-inline-threshold": {"0", "5"},
+		"rate-limit-whitelist-max-entries":      {"0", "5000"},
+		"rate-limit-whitelist-watch":            {"true", "false"},
+		"rate-limit-whitelist":                  {"10.0.0.0/8, 2001:db8::1\npatterns/trusted", "configmap/default/trusted", "secret/default/partners"},
+		"rate-limit-whitelist-header":           {"X-API-Key: secret", "X-Partner:patterns/partners", "X-API-Key: secret/default/api-keys"},
+		"rate-limit-blacklist":                  {"192.168.1.1, patterns/banned"},
+		"rate-limit-blacklist-status-code":      {"403"},
+		"rate-limit-list-precedence":            {"whitelist", "blacklist"},
+		"rate-limit-only":                       {"203.0.113.0/24, 198.51.100.7", "patterns/abusers"},
+		"rate-limit-scope":                      {"frontend"},
+	}
+	invalid := map[string][]string{
+		"rate-limit-rps":                        {"-1", "fast", "10, 20"},
+		"rate-limit-requests":                   {"-1", "ten", "10,,20", "1, 2, 3, 4"},
+		"rate-limit-period":                     {"0", "-1s", "fast"},
+		"rate-limit-size":                       {"0", "4g", "200kb"},
+		"rate-limit-table-expire":               {"0", "never"},
+		"rate-limit-key":                        {"src)", "hdr(X-Id"},
+		"rate-limit-forwarded-for-depth":        {"0", "last"},
+		"rate-limit-composite-key":              {"src", "src, bad fetch"},
+		"rate-limit-path-template":              {"[0-9", "[0-9]{1,3}", "'[0-9]+'"},
+		"rate-limit-anonymize":                  {"masked"},
+		"rate-limit-missing-key-action":         {"drop", "deny, exempt"},
+		"rate-limit-path":                       {"api", "/a b"},
+		"rate-limit-exempt-methods":             {"FETCH", "GET, FETCH"},
+		"rate-limit-geo-map":                    {"/etc/geo.map", "patterns/../geo.map"},
+		"rate-limit-exempt-countries":           {"USA", "US FR"},
+		"rate-limit-target-countries":           {"1"},
+		"rate-limit-exempt-user-agents":         {"kube probe", "Mozilla/5.0 (compatible)"},
+		"rate-limit-cost-header":                {"X Cost", "X-Cost: 2"},
+		"rate-limit-shared-table":               {"a b", "a/b"},
+		"rate-limit-table-name":                 {"a b", "a, b, c, d"},
+		"rate-limit-auto-headroom":              {"150%", "twice"},
+		"rate-limit-per-replica":                {"always"},
+		"rate-limit-burst":                      {"0", "fast"},
+		"rate-limit-burst-period":               {"0", "soon"},
+		"rate-limit-connections":                {"0", "many"},
+		"rate-limit-bytes-in":                   {"0", "1kb"},
+		"rate-limit-bytes-out":                  {"-1k"},
+		"rate-limit-errors":                     {"0", "many"},
+		"rate-limit-error-status":               {"99", "401, 600", "unauthorized"},
+		"rate-limit-streams":                    {"0", "-1"},
+		"rate-limit-peers":                      {"ha peers", "peers{1}"},
+		"rate-limit-sc-slot":                    {"3", "-1", "sc1"},
+		"rate-limit-id":                         {"checkout api", "-api", strings.Repeat("a", 64)},
+		"rate-limit-denied-metric":              {"maybe"},
+		"rate-limit-status-code":                {"302", "abc"},
+		"rate-limit-retry-after":                {"0", "soon"},
+		"rate-limit-action":                     {"Deny", "reject"},
+		"rate-limit-tarpit-duration":            {"-1s", "soon"},
+		"rate-limit-deny-rate":                  {"-1", "ten"},
+		"rate-limit-position":                   {"first", "Before-Auth"},
+		"rate-limit-percentage":                 {"101", "-1", "half"},
+		"rate-limit-hysteresis":                 {"100%", "half"},
+		"rate-limit-deny-message":               {"Too many\nrequests", strings.Repeat("a", 1025)},
+		"rate-limit-errorfile":                  {"../429", "too-many"},
+		"rate-limit-redirect":                   {"slow-down.html", "ftp://example.com/slow", "/slow down", "https://", "//example.com/slow"},
+		"rate-limit-log":                        {"my tag", strings.Repeat("a", 65)},
+		"rate-limit-log-target":                 {"siem.example.com 514", "syslog://siem"},
+		"rate-limit-headers":                    {"maybe"},
+		"rate-limit-headers-threshold":          {"150%"},
+		"rate-limit-track-only":                 {"yes"},
+		"rate-limit-grace-period":               {"soon", "-30s"},
+		"rate-limit-whitelist-strict":           {"2"},
+		"rate-limit-whitelist-merge":            {"nope"},
+		"rate-limit-whitelist-inline-threshold": {"-1", "few"},
+		"rate-limit-whitelist-max-entries":      {"-1", "many"},
+		"rate-limit-whitelist-watch":            {"sometimes"},
+		"rate-limit-whitelist":                  {"not_an_ip!", "10.0.0.1:70000", "10.0.0.0/33", "configmap/trusted", "secret/partners", "secret//partners"},
+		"rate-limit-whitelist-header":           {"X-API-Key", "X API Key: secret", "X-API-Key: two words"},
+		"rate-limit-blacklist":                  {"example.com", "1.2.3.4/33"},
+		"rate-limit-blacklist-status-code":      {"404", "forbidden"},
+		"rate-limit-list-precedence":            {"Whitelist", "allow"},
+		"rate-limit-only":                       {"abusers.example.com", "10.0.0.0/33"},
+		"rate-limit-scope":                      {"Backend", "server"},
+	}
+	process := func(name, value string) error {
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations := map[string]string{"rate-limit-requests": "10", name: value}
+		if name == "rate-limit-rps" {
+			delete(annotations, "rate-limit-requests")
+		}
+		if name == "rate-limit-auto-headroom" {
+			annotations["rate-limit-requests"] = "auto"
+		}
+		if strings.HasPrefix(name, "rate-limit-burst") {
+			annotations["rate-limit-period"] = "1m"
+			if name == "rate-limit-burst-period" {
+				annotations["rate-limit-burst"] = "20"
+			}
+		}
+		if strings.HasSuffix(name, "-countries") {
+			annotations["rate-limit-geo-map"] = "patterns/geo.map"
+		}
+		// Response statuses are counted by the tier of rate-limit-errors
+		if name == "rate-limit-errors" {
+			annotations["rate-limit-error-status"] = "401"
+		}
+		if name == "rate-limit-error-status" {
+			annotations["rate-limit-errors"] = "5"
+		}
+		return reqRateLimit.Validate(annotations)
+	}
+
+	require.Len(t, RateLimitAnnotationSpecs, len(ReqRateLimitAnnotations))
+	for _, name := range ReqRateLimitAnnotations {
+		require.Contains(t, RateLimitAnnotationSpecs, name)
+		require.NotEmpty(t, valid[name], name)
+		require.NotEmpty(t, invalid[name], name)
+		for _, value := range valid[name] {
+			assert.NoError(t, ValidateRateLimitAnnotation(name, value), "%s: %q", name, value)
+			// Rejected outside of the ConfigMap whatever its value, see TestReqRateLimit_TarpitDuration
+			if name == "rate-limit-tarpit-duration" {
+				assert.ErrorContains(t, process(name, value), "ConfigMap", value)
+				continue
+			}
+			assert.NoError(t, process(name, value), "%s: %q", name, value)
+		}
+		for _, value := range invalid[name] {
+			assert.Error(t, process(name, value), "%s: %q", name, value)
+			assert.ErrorContains(t, ValidateRateLimitAnnotation(name, value), name, value)
+		}
+	}
+	assert.ErrorIs(t, ValidateRateLimitAnnotation("rate-limit-status-code", "302"), ErrInvalidStatusCode)
+	assert.ErrorIs(t, ValidateRateLimitAnnotation("rate-limit-blacklist", "example.com"), ErrInvalidAddress)
+	assert.ErrorContains(t, ValidateRateLimitAnnotation("rate-limit-unknown", "1"), "unknown rate-limit annotation")
+
+	data, err := json.Marshal(RateLimitAnnotationSpecs["rate-limit-sc-slot"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"integer","minimum":0,"maximum":2}`, string(data))
+	data, err = json.Marshal(RateLimitAnnotationSpecs["rate-limit-shared-table"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","pattern":"^[A-Za-z0-9_.-]+$"}`, string(data))
+}
+
+// TestReqRateLimit_BlacklistStatusCode tests the rate-limit-blacklist-status-code annotation.
+// It validates that:
+// - Blacklisted sources and rate-exceeded clients get their own status code
+// - Without it, blacklisted sources get the rate-limit-status-code
+// - Unsupported status codes are rejected
+// - The annotation requires rate-limit-requests
+func TestReqRateLimit_BlacklistStatusCode(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-requests":              "10",
+		"rate-limit-status-code":           "429",
+		"rate-limit-blacklist":             "192.168.1.1",
+		"rate-limit-blacklist-status-code": "403",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(429), reqRateLimit.limit.DenyStatusCode)
+	assert.Equal(t, int64(403), reqRateLimit.limit.BlacklistStatusCode)
+
+	reqRateLimit, err = process(t, map[string]string{
+		"rate-limit-requests":    "10",
+		"rate-limit-status-code": "429",
+		"rate-limit-blacklist":   "192.168.1.1",
+	})
+	require.NoError(t, err)
+	assert.Zero(t, reqRateLimit.limit.BlacklistStatusCode)
+
+	_, err = process(t, map[string]string{"rate-limit-requests": "10", "rate-limit-blacklist-status-code": "302"})
+	assert.ErrorIs(t, err, ErrInvalidStatusCode)
+
+	_, err = process(t, map[string]string{"rate-limit-blacklist-status-code": "403"})
+	assert.ErrorIs(t, err, ErrMissingRateLimitRequests)
+}
+
+// TestReqRateLimit_ListPrecedence tests the rate-limit-list-precedence annotation processing.
+// It validates that:
+// - The precedence is set on the first tier, which denies the blacklist
+// - Without it, the precedence is left to the rules, where the blacklist prevails
+// - Unknown precedences are rejected
+func TestReqRateLimit_ListPrecedence(t *testing.T) {
+	process := func(t *testing.T, precedence string) (*ReqRateLimit, error) {
+		t.Helper()
+		mockMaps, err := maps.New("/tmp/maps", nil)
+		require.NoError(t, err)
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, mockMaps)
+		annotations := map[string]string{
+			"rate-limit-requests":        "10, 100",
+			"rate-limit-period":          "1s, 1m",
+			"rate-limit-whitelist":       "192.0.2.1",
+			"rate-limit-blacklist":       "192.0.2.1",
+			"rate-limit-list-precedence": precedence,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	for _, precedence := range []string{rules.RateLimitListPrecedenceWhitelist, rules.RateLimitListPrecedenceBlacklist, ""} {
+		reqRateLimit, err := process(t, precedence)
+		require.NoError(t, err)
+		require.Len(t, reqRateLimit.tiers, 2)
+		assert.Equal(t, precedence, reqRateLimit.limit.ListPrecedence)
+		assert.Empty(t, reqRateLimit.tiers[1].limit.ListPrecedence)
+	}
+
+	_, err := process(t, "allow")
+	assert.ErrorContains(t, err, "rate-limit-list-precedence")
+}
+
+// TestReqRateLimit_Only tests the rate-limit-only annotation processing.
+// It validates that:
+// - The listed addresses are loaded in a map restricting every tier, distinct from the whitelist
+// - A pattern file is used as is, but can't be mixed with other entries
+// - Without maps, addresses are listed inline
+func TestReqRateLimit_Only(t *testing.T) {
+	process := func(t *testing.T, m maps.Maps, only string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, m)
+		annotations := map[string]string{
+			"rate-limit-requests":  "10, 100",
+			"rate-limit-period":    "1s, 1m",
+			"rate-limit-whitelist": "198.51.100.7",
+			"rate-limit-only":      only,
+		}
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+
+	reqRateLimit, err := process(t, mockMaps, "198.51.100.0/24, 203.0.113.7")
+	require.NoError(t, err)
+	mapName := addressesMapName("ratelimit-only-", nil, []string{"198.51.100.0/24", "203.0.113.7"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	require.Len(t, reqRateLimit.tiers, 2)
+	for _, tier := range reqRateLimit.tiers {
+		assert.Equal(t, maps.GetPath(mapName), tier.limit.OnlyMap)
+		assert.Empty(t, tier.limit.OnlyIPs)
+		assert.Equal(t, []string{"198.51.100.7"}, tier.limit.WhitelistIPs)
+	}
+
+	reqRateLimit, err = process(t, mockMaps, "patterns/abusers")
+	require.NoError(t, err)
+	assert.Equal(t, maps.Path("patterns/abusers"), reqRateLimit.limit.OnlyMap)
+
+	_, err = process(t, mockMaps, "patterns/abusers, 203.0.113.7")
+	assert.ErrorContains(t, err, "pattern file 'patterns/abusers' must be the only entry")
+
+	reqRateLimit, err = process(t, nil, "198.51.100.0/24, 203.0.113.7")
+	require.NoError(t, err)
+	assert.Empty(t, reqRateLimit.limit.OnlyMap)
+	assert.Equal(t, []string{"198.51.100.0/24", "203.0.113.7"}, reqRateLimit.limit.OnlyIPs)
+}
+
+// TestReqRateLimit_AnnotationError tests that processing errors identify the annotation.
+// It validates that:
+// - A malformed whitelist returns an AnnotationError with the annotation name and value
+// - The error message carries both, and the underlying error is kept
+func TestReqRateLimit_AnnotationError(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	whitelist := "192.168.1.1, not_an_ip!"
+	annotations := map[string]string{"rate-limit-requests": "10", "rate-limit-whitelist": whitelist}
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+	require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-requests").Process(k, annotations))
+
+	err := reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(k, annotations)
+	require.Error(t, err)
+	var annErr *common.AnnotationError
+	require.True(t, errors.As(err, &annErr))
+	assert.Equal(t, "rate-limit-whitelist", annErr.Name)
+	assert.Equal(t, whitelist, annErr.Value)
+	assert.Contains(t, err.Error(), "rate-limit-whitelist")
+	assert.Contains(t, err.Error(), "not_an_ip!")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
+
+// TestReqRateLimit_MissingRequests tests the annotations set without rate-limit-requests.
+// It validates that:
+// - Each of them returns an AnnotationError pointing to the missing rate-limit-requests, instead of being ignored
+// - They are ignored without error when rate-limit-requests turns the rate limit off
+func TestReqRateLimit_MissingRequests(t *testing.T) {
+	annotations := map[string]string{
+		"rate-limit-period":      "1m",
+		"rate-limit-size":        "50k",
+		"rate-limit-status-code": "503",
+		"rate-limit-whitelist":   "10.0.0.0/8",
+	}
+	reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+	for name := range annotations {
+		err := reqRateLimit.NewAnnotation(name).Process(store.K8s{}, annotations)
+		require.ErrorIs(t, err, ErrMissingRateLimitRequests, name)
+		var annErr *common.AnnotationError
+		require.True(t, errors.As(err, &annErr), name)
+		assert.Equal(t, name, annErr.Name)
+		assert.ErrorContains(t, err, name+" requires rate-limit-requests to be set")
+	}
+
+	annotations["rate-limit-requests"] = "off"
+	reqRateLimit = NewReqRateLimit(&rules.List{}, nil, nil)
+	for name := range annotations {
+		assert.NoError(t, reqRateLimit.NewAnnotation(name).Process(store.K8s{}, annotations), name)
+	}
+}
+
+// TestReqRateLimit_ProcessingOrder tests that the annotations can be processed in any order.
+// It validates that:
+// - The rules are the same whatever the order the annotations are processed in
+// - Each annotation returns its own error, whatever the order
+// - Processing the annotations again rebuilds the rules instead of adding tiers
+func TestReqRateLimit_ProcessingOrder(t *testing.T) {
+	annotations := map[string]string{
+		"rate-limit-whitelist":   "10.0.0.0/8",
+		"rate-limit-status-code": "503",
+		"rate-limit-key":         "hdr(X-Api-Key)",
+		"rate-limit-size":        "50k",
+		"rate-limit-period":      "1s, 1m",
+		"rate-limit-requests":    "10, 100",
+		"rate-limit-log":         "bad tag",
+	}
+	process := func(t *testing.T, reqRateLimit *ReqRateLimit, order []string) map[string]error {
+		t.Helper()
+		errs := map[string]error{}
+		for _, annName := range order {
+			if err := reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations); err != nil {
+				errs[annName] = err
+			}
+		}
+		return errs
+	}
+
+	inOrder := &rules.List{}
+	reqRateLimit := NewReqRateLimit(inOrder, nil, nil)
+	inOrderErrs := process(t, reqRateLimit, ReqRateLimitAnnotations)
+	require.Len(t, inOrderErrs, 1)
+	assert.ErrorContains(t, inOrderErrs["rate-limit-log"], "rate-limit-log")
+
+	reversed := slices.Clone(ReqRateLimitAnnotations)
+	slices.Reverse(reversed)
+	outOfOrder := &rules.List{}
+	assert.Equal(t, inOrderErrs, process(t, NewReqRateLimit(outOfOrder, nil, nil), reversed))
+	assert.Equal(t, inOrder, outOfOrder)
+	require.Len(t, *outOfOrder, 4)
+	track := (*outOfOrder)[1].(*rules.ReqTrack)
+	assert.Equal(t, "hdr(X-Api-Key)", track.TrackKey)
+	assert.Equal(t, int64(51200), *track.TableSize)
+	limit := (*outOfOrder)[0].(*rules.ReqRateLimit)
+	assert.Equal(t, []string{"10.0.0.0/8"}, limit.WhitelistIPs)
+	assert.Equal(t, int64(503), limit.DenyStatusCode)
+
+	// Only the first annotation processed, before the rate limit is enabled
+	outOfOrder = &rules.List{}
+	require.NoError(t, NewReqRateLimit(outOfOrder, nil, nil).NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations))
+	assert.Equal(t, inOrder, outOfOrder)
+
+	process(t, reqRateLimit, ReqRateLimitAnnotations)
+	assert.Equal(t, outOfOrder, inOrder)
+	assert.Len(t, reqRateLimit.tiers, 2)
+}
+
+// TestReqRateLimit_Scope tests the rate-limit-scope annotation processing.
+// It validates that:
+// - backend places the rules of every tier of an ingress in backends, frontend keeps them in frontends
+// - The ConfigMap rate limit, without ingress, can't be placed in backends
+// - Headers, added by http-response rules, can't be combined with backends
+func TestReqRateLimit_Scope(t *testing.T) {
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	process := func(t *testing.T, ingress *store.Ingress, annotations map[string]string) (rules.List, error) {
+		t.Helper()
+		list := &rules.List{}
+		reqRateLimit := NewReqRateLimit(list, ingress, nil)
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return *list, errors.Join(errs...)
+	}
+
+	list, err := process(t, ing, map[string]string{"rate-limit-scope": "backend"})
+	require.NoError(t, err)
+	frontend, backend := list.SplitBackend()
+	assert.Empty(t, frontend)
+	assert.Len(t, backend, 4)
+
+	for _, annotations := range []map[string]string{{"rate-limit-scope": "frontend"}, {}} {
+		list, err = process(t, ing, annotations)
+		require.NoError(t, err)
+		frontend, backend = list.SplitBackend()
+		assert.Len(t, frontend, 4)
+		assert.Empty(t, backend)
+	}
+
+	list, err = process(t, nil, map[string]string{"rate-limit-scope": "backend"})
+	assert.ErrorContains(t, err, "rate-limit-scope annotation can only place the rate limits of ingresses in backends")
+	_, backend = list.SplitBackend()
+	assert.Empty(t, backend)
+
+	_, err = process(t, ing, map[string]string{"rate-limit-scope": "backend", "rate-limit-headers": "true"})
+	assert.ErrorContains(t, err, "can't be combined with rate-limit-headers")
+}
+
+// TestReqRateLimit_Anonymize tests the rate-limit-anonymize annotation processing.
+// It validates that:
+// - Source addresses, including the ones of X-Forwarded-For and composite keys, are masked on every tier
+// - Masked addresses are tracked in tables of their own
+// - Keys which are not addresses are rejected
+func TestReqRateLimit_Anonymize(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "10"
+		annotations["rate-limit-connections"] = "5"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{})
+	require.NoError(t, err)
+	tableName := reqRateLimit.track.TableName
+
+	for _, annotations := range []map[string]string{
+		{"rate-limit-anonymize": "true"},
+		{"rate-limit-anonymize": "true", "rate-limit-forwarded-for-depth": "1"},
+		{"rate-limit-anonymize": "true", "rate-limit-composite-key": "src, hdr(X-Tenant)"},
+	} {
+		reqRateLimit, err = process(t, annotations)
+		require.NoError(t, err)
+		require.Len(t, reqRateLimit.tiers, 2)
+		reqRateLimit.forEachTier(func(_ *rules.ReqRateLimit, track *rules.ReqTrack) {
+			assert.True(t, track.Anonymize)
+		})
+		assert.NotEqual(t, tableName, reqRateLimit.track.TableName)
+	}
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-anonymize": "false"})
+	require.NoError(t, err)
+	assert.False(t, reqRateLimit.track.Anonymize)
+	assert.Equal(t, tableName, reqRateLimit.track.TableName)
+
+	_, err = process(t, map[string]string{"rate-limit-anonymize": "true", "rate-limit-key": "hdr(X-Api-Key)"})
+	assert.ErrorContains(t, err, "rate-limit-anonymize annotation only masks addresses, not the tracked key 'hdr(X-Api-Key)'")
+}
+
+// TestWhitelistMatches tests matching an address against a parsed whitelist.
+// It validates that:
+// - Addresses match the entries equal to them and the CIDRs containing them
+// - Addresses out of every entry don't match, and pattern files and hostnames are ignored
+// - IPv6 addresses match IPv6 entries whatever their notation, and IPv4-mapped ones match IPv4 CIDRs
+// - Invalid addresses are rejected
+func TestWhitelistMatches(t *testing.T) {
+	ips, hosts, patterns, err := parseRateLimitAddresses("rate-limit-whitelist", "10.0.0.0/8, 192.168.1.1, [2001:db8::]/32, 2001:db8:ffff::/48\npatterns/trusted, partner.example.com")
+	require.NoError(t, err)
+	entries := append(ips, hosts...)
+	for _, pattern := range patterns {
+		entries = append(entries, string(pattern))
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.1.2.3", want: true},
+		{ip: "192.168.1.1", want: true},
+		{ip: " 10.255.255.255 ", want: true},
+		{ip: "11.0.0.1", want: false},
+		{ip: "192.168.1.2", want: false},
+		{ip: "2001:db8::1", want: true},
+		{ip: "2001:0db8:0000::0001", want: true},
+		{ip: "[2001:db8:1::1]", want: true},
+		{ip: "2001:db9::1", want: false},
+		{ip: "::ffff:10.1.2.3", want: true},
+	}
+	for _, tt := range tests {
+		got, err := WhitelistMatches(entries, tt.ip)
+		require.NoError(t, err, tt.ip)
+		assert.Equal(t, tt.want, got, tt.ip)
+	}
+
+	for _, ip := range []string{"", "10.0.0.0/8", "not-an-ip", "fe80::1%eth0"} {
+		_, err = WhitelistMatches(entries, ip)
+		require.ErrorIs(t, err, ErrInvalidAddress, ip)
+	}
+	_, err = WhitelistMatches([]string{"10.0.0.0/33"}, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
+
+// TestReqRateLimit_DenyRate tests the rate-limit-deny-rate annotation processing.
+// It validates that:
+// - Every tier shares a table counting the responses per source of the first tier, over its period
+// - The table is tracked with the stick counter following the ones of the tiers and of the denied metric
+// - 0 disables the shaping, and silently dropped requests have no response to shape
+// - It is rejected when no stick counter is left
+func TestReqRateLimit_DenyRate(t *testing.T) {
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, nil)
+		annotations["rate-limit-requests"] = "10"
+		annotations["rate-limit-period"] = "10s"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-deny-rate": "20", "rate-limit-connections": "5", "rate-limit-key": "hdr(X-Api-Key)"})
+	require.NoError(t, err)
+	denyRate := reqRateLimit.limit.DenyRate
+	require.NotNil(t, denyRate)
+	assert.Equal(t, reqRateLimit.track.TableName+"-denials", denyRate.TableName)
+	assert.Equal(t, "hdr(X-Api-Key)", denyRate.TrackKey)
+	assert.Equal(t, int64(10000), *denyRate.TablePeriod)
+	assert.Equal(t, int64(2), denyRate.StickCounter)
+	reqRateLimit.forEachTier(func(limit *rules.ReqRateLimit, _ *rules.ReqTrack) {
+		assert.Same(t, denyRate, limit.DenyRate)
+		assert.Equal(t, int64(20), limit.DenyRateLimit)
+	})
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-deny-rate": "20", "rate-limit-denied-metric": "true"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reqRateLimit.limit.DeniedStickCounter)
+	assert.Equal(t, int64(2), reqRateLimit.limit.DenyRate.StickCounter)
+
+	for _, annotations := range []map[string]string{{"rate-limit-deny-rate": "0"}, {}} {
+		reqRateLimit, err = process(t, annotations)
+		require.NoError(t, err)
+		assert.Nil(t, reqRateLimit.limit.DenyRate)
+	}
+
+	_, err = process(t, map[string]string{"rate-limit-deny-rate": "20", "rate-limit-action": "silent-drop"})
+	assert.ErrorContains(t, err, "rate-limit-deny-rate annotation can't be combined with rate-limit-action 'silent-drop'")
+
+	_, err = process(t, map[string]string{"rate-limit-deny-rate": "20", "rate-limit-connections": "5", "rate-limit-denied-metric": "true"})
+	assert.ErrorContains(t, err, "rate-limit-deny-rate annotation needs a stick counter but rate limits already use sc2")
+}
+
+// TestReqRateLimit_GracePeriod tests the rate-limit-grace-period annotation processing.
+// It validates that:
+// - Requests are tracked without being denied until the grace period elapsed since the rate limit
+// was first configured, and denied from the following processing
+// - Rate limits of other ingresses or tables have a grace period of their own
+// - Rate limits no longer configured are forgotten and get a new grace period
+// - 0 enforces the rate limit immediately, and validation doesn't start the grace period
+func TestReqRateLimit_GracePeriod(t *testing.T) {
+	activations := &activationRegistry{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	process := func(t *testing.T, ingress *store.Ingress, now time.Time, annotations map[string]string) (rules.List, error) {
+		t.Helper()
+		list := &rules.List{}
+		reqRateLimit := NewReqRateLimit(list, ingress, nil)
+		reqRateLimit.activations = activations
+		reqRateLimit.now = func() time.Time { return now }
+		annotations["rate-limit-requests"] = "10, 100"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return *list, errors.Join(errs...)
+	}
+	limits := func(list rules.List) int {
+		var count int
+		for _, rule := range list {
+			if _, ok := rule.(*rules.ReqRateLimit); ok {
+				count++
+			}
+		}
+		return count
+	}
+	api := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	web := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "web"}}
+	grace := func() map[string]string { return map[string]string{"rate-limit-grace-period": "2m"} }
+
+	for _, elapsed := range []time.Duration{0, time.Minute, 2*time.Minute - time.Second} {
+		list, err := process(t, api, start.Add(elapsed), grace())
+		require.NoError(t, err)
+		assert.Equal(t, 0, limits(list), elapsed)
+		assert.Len(t, list, 2, elapsed)
+	}
+	list, err := process(t, api, start.Add(2*time.Minute), grace())
+	require.NoError(t, err)
+	assert.Equal(t, 2, limits(list))
+
+	list, err = process(t, web, start.Add(2*time.Minute), grace())
+	require.NoError(t, err)
+	assert.Equal(t, 0, limits(list))
+	list, err = process(t, api, start.Add(2*time.Minute), map[string]string{"rate-limit-grace-period": "2m", "rate-limit-key": "hdr(X-Api-Key)"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, limits(list))
+
+	list, err = process(t, api, start.Add(3*time.Minute), grace())
+	require.NoError(t, err)
+	assert.Equal(t, 2, limits(list))
+	list, err = process(t, api, start.Add(3*time.Minute+activationTTL+time.Second), grace())
+	require.NoError(t, err)
+	assert.Equal(t, 0, limits(list))
+
+	list, err = process(t, web, start, map[string]string{"rate-limit-grace-period": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, limits(list))
+
+	require.NoError(t, NewReqRateLimit(&rules.List{}, api, nil).Validate(map[string]string{"rate-limit-requests": "10", "rate-limit-grace-period": "2m"}))
+	_, ok := rateLimitActivations.seen["ingress/default/api/RateLimit-1000"]
+	assert.False(t, ok)
+}
+
+// TestReqRateLimit_Errors tests the rate-limit-errors and rate-limit-error-status annotations processing.
+// It validates that:
+// - A tier counts the responses with one of the statuses in gpc1, with the key and the period of the request tiers
+// - Without rate-limit-requests, the tier tracks sources in a table of its own
+// - Each annotation requires the other one, and statuses must be HTTP statuses
+// - The counts can't be placed in backends, as they are http-response rules
+func TestReqRateLimit_Errors(t *testing.T) {
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{
+		"rate-limit-requests":     "100",
+		"rate-limit-period":       "1m",
+		"rate-limit-key":          "hdr(X-Api-Key)",
+		"rate-limit-errors":       "5",
+		"rate-limit-error-status": "401, 403, 401",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	tier := reqRateLimit.tiers[1]
+	assert.Equal(t, reqRateLimit.track.TableName+"-errors", tier.track.TableName)
+	assert.Equal(t, "hdr(X-Api-Key)", tier.track.TrackKey)
+	assert.Equal(t, int64(60000), *tier.track.TablePeriod)
+	assert.Equal(t, int64(1), tier.track.StickCounter)
+	assert.Equal(t, rules.RateLimitCounterGpc1Rate, tier.track.Counter)
+	assert.Equal(t, []int64{401, 403}, tier.track.ErrorStatuses)
+	assert.Equal(t, rules.RateLimitCounterGpc1Rate, tier.limit.Counter)
+	assert.Equal(t, int64(5), tier.limit.ReqsLimit)
+	assert.Empty(t, reqRateLimit.track.ErrorStatuses)
+
+	reqRateLimit, err = process(t, map[string]string{"rate-limit-errors": "5", "rate-limit-error-status": "401"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 1)
+	assert.Equal(t, "RateLimitErrors", reqRateLimit.track.TableName)
+	assert.Equal(t, "src", reqRateLimit.track.TrackKey)
+	assert.Equal(t, []int64{401}, reqRateLimit.track.ErrorStatuses)
+
+	_, err = process(t, map[string]string{"rate-limit-errors": "5"})
+	assert.ErrorContains(t, err, "rate-limit-errors annotation requires rate-limit-error-status to be set")
+	_, err = process(t, map[string]string{"rate-limit-requests": "100", "rate-limit-error-status": "401"})
+	assert.ErrorContains(t, err, "rate-limit-error-status annotation requires rate-limit-errors to be set")
+	_, err = process(t, map[string]string{"rate-limit-errors": "5", "rate-limit-error-status": "401, 6000"})
+	assert.ErrorContains(t, err, "incorrect value '6000' in rate-limit-error-status annotation")
+
+	_, err = process(t, map[string]string{"rate-limit-errors": "5", "rate-limit-error-status": "401", "rate-limit-scope": "backend"})
+	assert.ErrorContains(t, err, "rate-limit-scope annotation can't be combined with rate-limit-errors in backends")
+}
+
+// TestReqRateLimit_WhitelistMapLog tests the log of the whitelist maps used by rate limits.
+// It validates that:
+// - Creating a map logs the owner of the rate limit, the map name and the number of entries at debug level
+// - Using the map of the previous sync, emptied between syncs, logs the same fields as reused
+// - Nothing is logged above debug level
+func TestReqRateLimit_WhitelistMapLog(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	level := logger.Level
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		logger.SetLevel(level)
+	})
+
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+	ing := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api"}}
+	annotations := map[string]string{
+		"rate-limit-requests":  "10",
+		"rate-limit-whitelist": "10.0.0.1, 10.0.0.2, 10.0.0.3, patterns/partners",
+	}
+	process := func(t *testing.T) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, ing, mockMaps)
+		require.NoError(t, reqRateLimit.NewAnnotation("rate-limit-whitelist").Process(store.K8s{}, annotations))
+	}
+	mapName := whitelistMapName(ing, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+
+	logger.SetLevel(utils.Debug)
+	process(t)
+	assert.Contains(t, output.String(), fmt.Sprintf("rate-limit-whitelist map created: owner=ingress/default/api map=%s entries=3", mapName))
+	output.Reset()
+	mockMaps.CleanMaps()
+	process(t)
+	assert.Contains(t, output.String(), fmt.Sprintf("rate-limit-whitelist map reused: owner=ingress/default/api map=%s entries=3", mapName))
+
+	output.Reset()
+	logger.SetLevel(utils.Info)
+	process(t)
+	assert.NotContains(t, output.String(), "rate-limit-whitelist map")
+}
+
+// TestReqRateLimit_Hysteresis tests the rate-limit-hysteresis annotation processing.
+// It validates that:
+// - The low watermark of each tier is the percentage of its limit, and its table stores the flag
+// - Tiers whose limit is too low to have a watermark, and the burst tier, are left as is
+// - 0 disables the hysteresis, and 100% is rejected
+func TestReqRateLimit_Hysteresis(t *testing.T) {
+	process := func(t *testing.T, annotations map[string]string) (*ReqRateLimit, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		annotations["rate-limit-requests"] = "100, 1"
+		annotations["rate-limit-period"] = "1s, 1m"
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(store.K8s{}, annotations))
+		}
+		return reqRateLimit, errors.Join(errs...)
+	}
+
+	reqRateLimit, err := process(t, map[string]string{"rate-limit-hysteresis": "80%"})
+	require.NoError(t, err)
+	require.Len(t, reqRateLimit.tiers, 2)
+	assert.Equal(t, int64(80), reqRateLimit.tiers[0].limit.LowWatermark)
+	assert.True(t, reqRateLimit.tiers[0].track.Hysteresis)
+	assert.Zero(t, reqRateLimit.tiers[1].limit.LowWatermark)
+	assert.False(t, reqRateLimit.tiers[1].track.Hysteresis)
+
+	for _, annotations := range []map[string]string{{"rate-limit-hysteresis": "0"}, {}} {
+		reqRateLimit, err = process(t, annotations)
+		require.NoError(t, err)
+		reqRateLimit.forEachTier(func(limit *rules.ReqRateLimit, track *rules.ReqTrack) {
+			assert.Zero(t, limit.LowWatermark)
+			assert.False(t, track.Hysteresis)
+		})
+	}
+
+	_, err = process(t, map[string]string{"rate-limit-hysteresis": "100%"})
+	assert.ErrorContains(t, err, "rate-limit-hysteresis")
+}
+
+// TestReqRateLimit_Policy tests the rate limits defined by a RateLimitPolicy custom resource.
+// It validates that:
+// - A policy selecting an ingress by its labels produces the rules of the equivalent annotations
+// - The ingress annotations take precedence over the policy, and unselected ingresses aren't limited
+// - The first policy by name applies when several select the ingress
+// - Unknown options are rejected, and option values are validated like the annotation ones
+func TestReqRateLimit_Policy(t *testing.T) {
+	k := store.NewK8sStore(utils.OSArgs{})
+	ns := k.GetNamespace("default")
+	ns.CRs.RateLimitPolicies["api"] = &v3.RateLimitPolicySpec{
+		IngressSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "api"}},
+		Tiers:           []v3.RateLimitTier{{Requests: 20, Period: "1s"}, {Requests: 1000, Period: "1m"}},
+		Options:         map[string]string{"status-code": "503", "whitelist": "10.0.0.0/8"},
+	}
+	ns.CRs.RateLimitPolicies["zz-api"] = &v3.RateLimitPolicySpec{
+		IngressSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpExists}}},
+		Tiers:           []v3.RateLimitTier{{Requests: 5}},
+	}
+	ingress := func(labels map[string]string, annotations map[string]string) *store.Ingress {
+		return &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "api", Labels: labels, Annotations: annotations}}
+	}
+	rulesOf := func(t *testing.T, annotations ...map[string]string) ([]string, error) {
+		t.Helper()
+		reqRateLimit := NewReqRateLimit(&rules.List{}, nil, nil)
+		var errs []error
+		for _, annName := range ReqRateLimitAnnotations {
+			errs = append(errs, reqRateLimit.NewAnnotation(annName).Process(k, annotations...))
+		}
+		var result []string
+		for _, tier := range reqRateLimit.tiers {
+			result = append(result, tier.track.String(), tier.limit.String())
+		}
+		return result, errors.Join(errs...)
+	}
+
+	ing := ingress(map[string]string{"tier": "api"}, map[string]string{})
+	policy, err := RateLimitPolicyAnnotations(k, ing)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"rate-limit-requests":    "20, 1000",
+		"rate-limit-period":      "1s, 1m",
+		"rate-limit-status-code": "503",
+		"rate-limit-whitelist":   "10.0.0.0/8",
+	}, policy)
+	got, err := rulesOf(t, ing.Annotations, nil, policy, map[string]string{})
+	require.NoError(t, err)
+	want, err := rulesOf(t, policy)
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	assert.Equal(t, want, got)
+	assert.Equal(t, "http-request deny deny_status 503 if { sc0_http_req_rate(RateLimit-1000) gt 20 } !{ src 10.0.0.0/8 }", got[1])
+
+	// The ingress annotations take precedence over the policy
+	ing = ingress(map[string]string{"tier": "api"}, map[string]string{"rate-limit-status-code": "429"})
+	got, err = rulesOf(t, ing.Annotations, nil, policy, map[string]string{})
+	require.NoError(t, err)
+	assert.Contains(t, got[1], "deny_status 429")
+
+	policy, err = RateLimitPolicyAnnotations(k, ingress(map[string]string{"tier": "web"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"rate-limit-requests": "5"}, policy)
+	policy, err = RateLimitPolicyAnnotations(k, ingress(nil, nil))
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	ns.CRs.RateLimitPolicies["api"].Options = map[string]string{"status-code": "abc"}
+	policy, err = RateLimitPolicyAnnotations(k, ing)
+	require.NoError(t, err)
+	_, err = rulesOf(t, map[string]string{}, nil, policy, map[string]string{})
+	assert.ErrorContains(t, err, "rate-limit-status-code")
+
+	for _, option := range []string{"requests", "rate-limit-status-code", "unknown"} {
+		ns.CRs.RateLimitPolicies["api"].Options = map[string]string{option: "1"}
+		_, err = RateLimitPolicyAnnotations(k, ing)
+		assert.ErrorContains(t, err, "RateLimitPolicy 'default/api': unknown option '"+option+"'")
+	}
+}
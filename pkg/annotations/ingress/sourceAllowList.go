@@ -0,0 +1,71 @@
+package ingress
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/annotations/common"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+)
+
+// SourceAllowList denies the requests of every source but the listed ones, whatever
+// their rate. Its value is parsed like rate-limit-whitelist: IPv4/IPv6 addresses,
+// CIDRs and pattern files, comma-separated or one per line with '#' comments.
+type SourceAllowList struct {
+	name    string
+	rules   *rules.List
+	ingress *store.Ingress
+	maps    maps.Maps
+}
+
+func NewSourceAllowList(n string, r *rules.List, i *store.Ingress, m maps.Maps) *SourceAllowList {
+	return &SourceAllowList{name: n, rules: r, ingress: i, maps: m}
+}
+
+func (a *SourceAllowList) GetName() string {
+	return a.name
+}
+
+func (a *SourceAllowList) Process(k store.K8s, annotations ...map[string]string) error {
+	input := common.GetValue(a.name, annotations...)
+	if input == "" {
+		return nil
+	}
+	ips, hosts, patterns, err := parseRateLimitAddresses(a.name, input)
+	if err != nil {
+		return err
+	}
+	if len(hosts) > 0 {
+		return fmt.Errorf("%w '%s' in %s annotation, hostnames are only supported in rate-limit-whitelist", ErrInvalidAddress, hosts[0], a.name)
+	}
+	if len(ips) == 0 && len(patterns) == 0 {
+		return fmt.Errorf("%s annotation has no address nor pattern file, it would deny every request", a.name)
+	}
+
+	rule := &rules.ReqSourceAllowList{SrcIPs: ips, SrcMaps: patterns}
+	// Addresses are loaded in a map, matched before the pattern files.
+	// Without maps, when their initialization failed, they are written inline.
+	if len(ips) > 0 && a.maps != nil {
+		mapName := addressesMapName("source-allowlist-", a.ingress, ips)
+		if !a.maps.MapExists(mapName) {
+			for _, address := range ips {
+				a.maps.MapAppend(mapName, address)
+			}
+		}
+		a.maps.MapRef(mapName, a.mapOwner())
+		rule.SrcIPs = nil
+		rule.SrcMaps = append([]maps.Path{maps.GetPath(mapName)}, patterns...)
+	}
+	a.rules.Add(rule)
+	return nil
+}
+
+// mapOwner returns the owner of the maps of the allow list, which differs
+// from the one of the rate limit maps so they are released separately.
+func (a *SourceAllowList) mapOwner() string {
+	if a.ingress == nil {
+		return "source-allowlist/configmap"
+	}
+	return "source-allowlist/ingress/" + a.ingress.Namespace + "/" + a.ingress.Name
+}
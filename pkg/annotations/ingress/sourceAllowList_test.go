@@ -0,0 +1,76 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+)
+
+// TestSourceAllowList tests the source-allowlist annotation processing.
+// It validates that:
+// - Addresses are loaded in a map, matched before the pattern files
+// - Pattern files alone don't need a map
+// - Addresses are written inline without maps
+// - Invalid addresses, hostnames and lists without entries are rejected
+func TestSourceAllowList(t *testing.T) {
+	ingress := &store.Ingress{IngressCore: store.IngressCore{Namespace: "default", Name: "admin"}}
+	process := func(t *testing.T, m maps.Maps, value string) (*rules.ReqSourceAllowList, error) {
+		t.Helper()
+		list := &rules.List{}
+		err := NewSourceAllowList("source-allowlist", list, ingress, m).Process(store.K8s{}, map[string]string{"source-allowlist": value})
+		if err != nil || len(*list) == 0 {
+			return nil, err
+		}
+		require.Len(t, *list, 1)
+		return (*list)[0].(*rules.ReqSourceAllowList), nil
+	}
+	mockMaps, err := maps.New("/tmp/maps", nil)
+	require.NoError(t, err)
+
+	rule, err := process(t, mockMaps, "10.0.0.0/8, patterns/admins\n2001:db8::1 # vpn")
+	require.NoError(t, err)
+	mapName := addressesMapName("source-allowlist-", ingress, []string{"10.0.0.0/8", "2001:db8::1"})
+	assert.True(t, mockMaps.MapExists(mapName))
+	assert.Empty(t, rule.SrcIPs)
+	assert.Equal(t, []maps.Path{maps.GetPath(mapName), "patterns/admins"}, rule.SrcMaps)
+
+	rule, err = process(t, mockMaps, "patterns/admins")
+	require.NoError(t, err)
+	assert.Empty(t, rule.SrcIPs)
+	assert.Equal(t, []maps.Path{"patterns/admins"}, rule.SrcMaps)
+
+	rule, err = process(t, nil, "10.0.0.1, 10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, rule.SrcIPs)
+	assert.Empty(t, rule.SrcMaps)
+
+	rule, err = process(t, mockMaps, "")
+	require.NoError(t, err)
+	assert.Nil(t, rule)
+
+	for _, value := range []string{"10.0.0.0/33", "admin.example.com", "# nobody"} {
+		_, err = process(t, mockMaps, value)
+		assert.ErrorContains(t, err, "source-allowlist", value)
+	}
+	_, err = process(t, mockMaps, "10.0.0.0/33")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
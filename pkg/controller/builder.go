@@ -17,6 +17,7 @@ package controller
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"log"
 	"net"
@@ -182,10 +183,15 @@ func (builder *Builder) Build() *HAProxyController {
 	logger.Error(errPrefix)
 
 	builder.store.GatewayControllerName = builder.osArgs.GatewayControllerName
+	builder.store.RateLimitClusterCIDRs = builder.osArgs.RateLimitClusterCIDRs
+	builder.store.RateLimitDefaultStatusCode = builder.osArgs.RateLimitDefaultStatusCode
 	gatewayManager := builder.gatewayManager
 	if gatewayManager == nil {
 		gatewayManager = gateway.New(builder.store, haproxy.HAProxyClient, builder.osArgs, builder.restClientSet)
 	}
+	if builder.clientSet != nil {
+		ingress.SetEventClient(builder.clientSet)
+	}
 	updateStatusManager := builder.updateStatusManager
 	if updateStatusManager == nil {
 		updateStatusManager = status.New(builder.clientSet, builder.osArgs.IngressClass, builder.osArgs.EmptyIngressClass, builder.osArgs.DisableIngressStatusUpdate)
@@ -211,6 +217,11 @@ func (builder *Builder) Build() *HAProxyController {
 		PodIP:                    podIP,
 		Hostname:                 hostname,
 	}
+	if builder.osArgs.PrometheusEnabled {
+		haproxyController.prometheusMetricsManager.SetRateLimitDeniedSource(func() (string, error) {
+			return haproxy.ExecuteRaw("show table " + rules.RateLimitDeniedTable)
+		})
+	}
 	haproxyController.processIngress = haproxyController.processIngressesDefaultImplementation
 	if builder.osArgs.Experimental.UseIngressMerge {
 		haproxyController.processIngress = haproxyController.processIngressesWithMerge
@@ -229,6 +240,8 @@ func addControllerMetricData(builder *Builder, chShutdown chan struct{}) {
 		rtr.GET(handler.PROMETHEUS_URL_PATH, prometheusHandler())
 		runningServices += ", prometheus"
 	}
+	rtr.GET(rateLimitTablesPath, rateLimitTablesHandler)
+	rtr.GET(rateLimitAnnotationsPath, rateLimitAnnotationsHandler)
 	rtr.GET("/healtz", requestHandler)
 	rtr.GET("/healthz", requestHandler)
 	// all others will be 404
@@ -301,6 +314,36 @@ func requestHandler(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+// rateLimitTablesPath lists the stick-tables of the rate limits, see rateLimitTablesHandler.
+const rateLimitTablesPath = "/rate-limit/tables"
+
+// rateLimitTablesHandler returns the JSON list of the stick-tables tracked by rate limits,
+// so tools can inspect them with the "show table" command of the HAProxy Runtime API.
+func rateLimitTablesHandler(ctx *fasthttp.RequestCtx) {
+	body, err := json.Marshal(rules.RateLimitTables())
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
+
+// rateLimitAnnotationsPath describes the rate-limit annotations, see rateLimitAnnotationsHandler.
+const rateLimitAnnotationsPath = "/rate-limit/annotations"
+
+// rateLimitAnnotationsHandler returns the JSON specs of the values accepted by the
+// rate-limit annotations, so tools can validate them before deploying manifests.
+func rateLimitAnnotationsHandler(ctx *fasthttp.RequestCtx) {
+	body, err := json.Marshal(annotations.RateLimitSpecs())
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
+
 func prometheusHandler() func(ctx *fasthttp.RequestCtx) {
 	prometheusHandler := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
 	return func(ctx *fasthttp.RequestCtx) {
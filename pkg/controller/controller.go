@@ -449,6 +449,10 @@ func (c *HAProxyController) processIngressesWithMerge() {
 				// Back to the usual processing of the ingress
 
 				c.manageIngress(&consolidatedIngress)
+				// Keep the rate limit to report it in the annotations of the ingress,
+				// and the annotation errors for the next comparison
+				ingressToMerge.RateLimit = consolidatedIngress.RateLimit
+				ingressToMerge.AnnotationErrors = consolidatedIngress.AnnotationErrors
 			}
 			// Now process the standalone ingresses as usual.
 			for _, standaloneIngress := range standaloneIngresses {
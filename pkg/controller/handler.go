@@ -16,6 +16,7 @@ package controller
 
 import (
 	"github.com/haproxytech/kubernetes-ingress/pkg/annotations"
+	"github.com/haproxytech/kubernetes-ingress/pkg/fs"
 	"github.com/haproxytech/kubernetes-ingress/pkg/handler"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
@@ -49,7 +50,7 @@ func (c *HAProxyController) initHandlers() {
 			IPv6:     !c.osArgs.DisableIPV6,
 			AddrIPv6: c.osArgs.IPV6BindAddr,
 		},
-		&handler.PatternFiles{},
+		&handler.PatternFiles{Watcher: fs.PatternWatcher},
 		annotations.ConfigSnippetHandler{},
 		c.updateStatusManager,
 		handler.NewTCPCustomResource(c.osArgs.IngressClass, c.osArgs.EmptyIngressClass),
@@ -0,0 +1,99 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Watcher reports the modifications of files the configuration references but
+// the controller doesn't write, like pattern files mounted from a volume.
+type Watcher interface {
+	// Watch registers path for the next call to Modified.
+	Watch(path string)
+	// Modified returns the registered paths modified since they were last checked,
+	// and stops watching the paths not registered again since the previous call.
+	Modified() []string
+}
+
+// PatternWatcher watches the pattern files referenced by annotations.
+// Relative paths are resolved from the working directory, the HAProxy configuration one.
+var PatternWatcher Watcher = NewPollWatcher()
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+type pollWatcher struct {
+	stamps     map[string]fileStamp
+	registered map[string]struct{}
+	mu         sync.Mutex
+}
+
+// NewPollWatcher creates a Watcher comparing the modification time and size of
+// the files each time Modified is called, which happens on every sync.
+func NewPollWatcher() *pollWatcher {
+	return &pollWatcher{
+		stamps:     map[string]fileStamp{},
+		registered: map[string]struct{}{},
+	}
+}
+
+func (w *pollWatcher) Watch(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.registered[path] = struct{}{}
+	// Files are watched from their registration, their current content being the one loaded
+	if _, ok := w.stamps[path]; !ok {
+		w.stamps[path] = stat(path)
+	}
+}
+
+func (w *pollWatcher) Modified() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var modified []string
+	for path, previous := range w.stamps {
+		if _, ok := w.registered[path]; !ok {
+			delete(w.stamps, path)
+			continue
+		}
+		current := stat(path)
+		if !current.equal(previous) {
+			w.stamps[path] = current
+			modified = append(modified, path)
+		}
+	}
+	clear(w.registered)
+	slices.Sort(modified)
+	return modified
+}
+
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func stat(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partners")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1\n"), 0o644))
+	w := NewPollWatcher()
+
+	w.Watch(path)
+	assert.Empty(t, w.Modified())
+
+	// Modified files are reported once
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1\n10.0.0.2\n"), 0o644))
+	w.Watch(path)
+	assert.Equal(t, []string{path}, w.Modified())
+	w.Watch(path)
+	assert.Empty(t, w.Modified())
+
+	// Same size but newer modification time
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	w.Watch(path)
+	assert.Equal(t, []string{path}, w.Modified())
+
+	require.NoError(t, os.Remove(path))
+	w.Watch(path)
+	assert.Equal(t, []string{path}, w.Modified())
+
+	// Files not registered again are no longer watched
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.3\n"), 0o644))
+	assert.Empty(t, w.Modified())
+	assert.Empty(t, w.stamps)
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/google/renameio"
 
 	"github.com/haproxytech/kubernetes-ingress/pkg/annotations"
+	"github.com/haproxytech/kubernetes-ingress/pkg/fs"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/instance"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
@@ -28,11 +29,18 @@ import (
 )
 
 type PatternFiles struct {
-	files files
+	// Watcher reports the modifications of the pattern files written outside the controller
+	Watcher fs.Watcher
+	files   files
 }
 
 func (handler *PatternFiles) Update(k store.K8s, h haproxy.HAProxy, a annotations.Annotations) (err error) {
 	handler.files.dir = h.Env.PatternDir
+	if handler.Watcher != nil {
+		for _, path := range handler.Watcher.Modified() {
+			instance.Reload("watched patternfile '%s' modified", path)
+		}
+	}
 	if k.ConfigMaps.PatternFiles == nil {
 		return nil
 	}
@@ -0,0 +1,59 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/env"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/instance"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+)
+
+// mockWatcher reports the paths of modified, once.
+type mockWatcher struct {
+	modified []string
+}
+
+func (w *mockWatcher) Watch(path string) {}
+
+func (w *mockWatcher) Modified() []string {
+	modified := w.modified
+	w.modified = nil
+	return modified
+}
+
+func TestPatternFiles_WatchedFileModified(t *testing.T) {
+	watcher := &mockWatcher{}
+	handler := &PatternFiles{Watcher: watcher}
+	h := haproxy.HAProxy{Env: env.Env{PatternDir: t.TempDir()}}
+	t.Cleanup(instance.Reset)
+
+	instance.Reset()
+	require.NoError(t, handler.Update(store.K8s{}, h, nil))
+	assert.False(t, instance.NeedReload())
+
+	watcher.modified = []string{"patterns/partners"}
+	require.NoError(t, handler.Update(store.K8s{}, h, nil))
+	assert.True(t, instance.NeedReload())
+
+	instance.Reset()
+	require.NoError(t, handler.Update(store.K8s{}, h, nil))
+	assert.False(t, instance.NeedReload())
+}
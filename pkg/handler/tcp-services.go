@@ -9,6 +9,7 @@ import (
 	"github.com/haproxytech/kubernetes-ingress/pkg/annotations"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/instance"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
 	"github.com/haproxytech/kubernetes-ingress/pkg/service"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
 	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
@@ -130,6 +131,7 @@ func (handler TCPServices) clearFrontends(k store.K8s, h haproxy.HAProxy) {
 		_, isRequired := k.ConfigMaps.TCPServices.Annotations[strings.TrimPrefix(ft.Name, "tcp-")]
 		isTCPSvc := strings.HasPrefix(ft.Name, "tcp-")
 		if isTCPSvc && !isRequired {
+			h.DeleteFTRules(ft.Name)
 			err = h.FrontendDelete(ft.Name)
 			if err != nil {
 				logger.Errorf("error deleting tcp frontend '%s': %s", ft.Name, err)
@@ -223,5 +225,20 @@ func (handler TCPServices) updateTCPFrontend(k store.K8s, h haproxy.HAProxy, fro
 	if svc, err = service.New(k, path, nil, true, nil, k.ConfigMaps.Main.Annotations); err == nil {
 		err = svc.SetDefaultBackend(k, h, []string{frontend.Name}, a)
 	}
+	handler.rateLimit(k, h, frontend.Name, p.service, p.port)
 	return err
 }
+
+// rateLimit limits the connections of the TCP frontend with the rate-limit annotations
+// of its service. The ConfigMap ones only apply to HTTP requests.
+func (handler TCPServices) rateLimit(k store.K8s, h haproxy.HAProxy, frontendName string, svc *store.Service, port int64) {
+	list := rules.List{}
+	for _, a := range annotations.RateLimit(&list, h.Maps, svc.Namespace, svc.Name, port) {
+		if err := a.Process(k, svc.Annotations); err != nil {
+			logger.Errorf("TCP frontend '%s': annotation %s: %s", frontendName, a.GetName(), err)
+		}
+	}
+	for _, rule := range list {
+		logger.Error(h.AddRule(frontendName, rule, false))
+	}
+}
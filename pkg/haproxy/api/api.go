@@ -165,6 +165,10 @@ type LogTarget interface {
 	LogTargetsGet(parentType, parentName string) (models.LogTargets, error)
 	LogTargetDeleteAll(parentType, parentName string) (err error)
 	LogTargetsReplace(parentType, parentName string, rules models.LogTargets) error
+	// LogProfileCreateOrUpdate declares the log-profile section, or updates it when it differs.
+	LogProfileCreateOrUpdate(profile models.LogProfile) error
+	// LogProfileDelete deletes the log-profile section, if declared.
+	LogProfileDelete(name string) error
 }
 
 type TCPRequestRule interface {
@@ -99,3 +99,30 @@ func (c *clientNative) LogTargetsReplace(parentType, parentName string, rules mo
 	}
 	return nil
 }
+
+func (c *clientNative) LogProfileCreateOrUpdate(profile models.LogProfile) error {
+	configuration, err := c.nativeAPI.Configuration()
+	if err != nil {
+		return err
+	}
+	_, current, err := configuration.GetLogProfile(profile.Name, c.activeTransaction)
+	if err != nil {
+		return configuration.CreateLogProfile(&profile, c.activeTransaction, 0)
+	}
+	if current.Equal(profile) {
+		return nil
+	}
+	return configuration.EditLogProfile(profile.Name, &profile, c.activeTransaction, 0)
+}
+
+func (c *clientNative) LogProfileDelete(name string) error {
+	configuration, err := c.nativeAPI.Configuration()
+	if err != nil {
+		return err
+	}
+	_, _, err = configuration.GetLogProfile(name, c.activeTransaction)
+	if err != nil {
+		return nil
+	}
+	return configuration.DeleteLogProfile(name, c.activeTransaction, 0)
+}
@@ -60,6 +60,7 @@ func New(osArgs utils.OSArgs, env env.Env, cfgFile []byte, p process.Process, cl
 		err = fmt.Errorf("failed to initialize haproxy maps: %w", err)
 		return h, err
 	}
+	maps.SetWriteRetry(osArgs.MapsWriteRetries, osArgs.MapsWriteTimeout)
 	if p == nil {
 		h.Process = process.New(h.Env, osArgs, h.AuxCFGFile, h.HAProxyClient)
 	}
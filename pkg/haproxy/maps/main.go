@@ -22,11 +22,14 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/google/renameio"
 	"github.com/haproxytech/kubernetes-ingress/pkg/fs"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/instance"
+	"github.com/haproxytech/kubernetes-ingress/pkg/metrics"
 	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
 )
 
@@ -35,10 +38,18 @@ type Maps interface {
 	MapAppend(name Name, row string)
 	// Exists returns true if a map exists and is not empty
 	MapExists(name Name) bool
+	// MapExisted returns true if the map was not empty in the previous sync
+	MapExisted(name Name) bool
+	// MapChanged returns true if the map content differs from the one of the previous sync
+	MapChanged(name Name) bool
 	// Refresh refreshs maps content
 	RefreshMaps(client api.HAProxyClient)
 	// Clean cleans maps content
 	CleanMaps()
+	// MapRef registers owner as a user of the map
+	MapRef(name Name, owner string)
+	// MapUnref deregisters owner as a user of the map
+	MapUnref(name Name, owner string)
 }
 
 type mapFiles map[Name]*mapFile
@@ -52,12 +63,40 @@ var logger = utils.GetLogger()
 
 var mapDir string
 
+const (
+	// DefaultWriteRetries is the number of times a map file write failing on a transient error is retried.
+	DefaultWriteRetries = 3
+	// DefaultWriteTimeout bounds the time spent retrying a map file write.
+	DefaultWriteTimeout = 5 * time.Second
+	// initialWriteBackoff is the delay before the first retry, doubled before each following one.
+	initialWriteBackoff = 100 * time.Millisecond
+)
+
+var (
+	writeRetries = DefaultWriteRetries
+	writeTimeout = DefaultWriteTimeout
+	writeBackoff = initialWriteBackoff
+	// writeFile writes a map file, replaced in tests
+	writeFile = writeMapFile
+)
+
 type mapFile struct {
 	rows       []string
 	hash       uint64
 	persistent bool
 	// A persistent map will not be removed even if the map is empty
 	// because it is always referenced in a haproxy rule.
+	owners map[string]struct{}
+	// A map with owners is reference counted: it is removed on refresh
+	// once every owner is gone, whatever its content.
+	previous uint64
+	// previous is the hash of the content of the previous sync, kept by
+	// CleanMaps, 0 when the map was empty.
+}
+
+// unreferenced returns true if the map is reference counted and has no owner left.
+func (mf *mapFile) unreferenced() bool {
+	return mf.owners != nil && len(mf.owners) == 0 && !mf.persistent
 }
 
 // getContent returns the content of a haproxy map file in a list of chunks
@@ -97,6 +136,21 @@ func (m mapFiles) MapExists(name Name) bool {
 	return m[name] != nil && len(m[name].rows) != 0
 }
 
+func (m mapFiles) MapExisted(name Name) bool {
+	return m[name] != nil && m[name].previous != 0
+}
+
+func (m mapFiles) MapChanged(name Name) bool {
+	if m[name] == nil {
+		return false
+	}
+	var hash uint64
+	if len(m[name].rows) > 0 {
+		_, hash = m[name].getContent()
+	}
+	return hash != m[name].previous
+}
+
 func (m mapFiles) MapAppend(name Name, row string) {
 	if row == "" {
 		return
@@ -107,9 +161,35 @@ func (m mapFiles) MapAppend(name Name, row string) {
 	m[name].rows = append(m[name].rows, row)
 }
 
+func (m mapFiles) MapRef(name Name, owner string) {
+	if m[name] == nil {
+		m[name] = &mapFile{}
+	}
+	if m[name].owners == nil {
+		m[name].owners = map[string]struct{}{}
+	}
+	m[name].owners[owner] = struct{}{}
+}
+
+func (m mapFiles) MapUnref(name Name, owner string) {
+	if m[name] == nil || m[name].owners == nil {
+		return
+	}
+	delete(m[name].owners, owner)
+}
+
+// CleanMaps empties the maps and drops their owners, which are
+// registered again by the rules still referencing them.
 func (m mapFiles) CleanMaps() {
 	for _, mapFile := range m {
+		mapFile.previous = 0
+		if len(mapFile.rows) > 0 {
+			_, mapFile.previous = mapFile.getContent()
+		}
 		mapFile.rows = []string{}
+		if mapFile.owners != nil {
+			clear(mapFile.owners)
+		}
 	}
 }
 
@@ -119,6 +199,9 @@ func (m mapFiles) RefreshMaps(client api.HAProxyClient) {
 	var mapMutex sync.Mutex
 
 	for name, mapFile := range m {
+		if mapFile.unreferenced() {
+			mapFile.rows = nil
+		}
 		content, hash := mapFile.getContent()
 		if mapFile.hash == hash {
 			continue
@@ -143,24 +226,18 @@ func (m mapFiles) RefreshMaps(client api.HAProxyClient) {
 					logger.Error(err)
 					return
 				}
-				err = renameio.WriteFile(string(filename), []byte{}, 0o666)
+				// A new map is written with its content right away, never empty,
+				// so a reload can't load it without its rows.
+				err = writeMapFileRetry(filename, content)
 				if err != nil {
 					logger.Error(err)
 					return
 				}
+			} else {
+				fs.AddDelayedFunc(string(filename), func() {
+					logger.Error(writeMapFileRetry(filename, content))
+				})
 			}
-			var buff strings.Builder
-			buff.Grow(api.BufferSize * len(content))
-			for _, d := range content {
-				buff.WriteString(d)
-			}
-			fs.AddDelayedFunc(string(filename), func() {
-				err = renameio.WriteFile(string(filename), []byte(buff.String()), 0o666)
-				if err != nil {
-					logger.Error(err)
-					return
-				}
-			})
 
 			mapFile.hash = hash
 			if err = client.SetMapContent(string(name), content); err != nil {
@@ -176,7 +253,59 @@ func (m mapFiles) RefreshMaps(client api.HAProxyClient) {
 	wgWriter.Wait()
 	for _, mapFileToDelete := range mapFilesToDelete {
 		delete(m, mapFileToDelete)
+		metrics.New().DeleteMapMetrics(string(mapFileToDelete))
+	}
+}
+
+// SetWriteRetry sets how many times, and for how long, map file writes failing
+// on a transient error are retried. No retry is done with 0 retries.
+func SetWriteRetry(retries int, timeout time.Duration) {
+	writeRetries = retries
+	writeTimeout = timeout
+}
+
+// writeMapFileRetry writes the map file, retrying the writes failing on a transient error,
+// as seen on network filesystems, with an exponential backoff. The retries stop after
+// writeRetries attempts or once the next one would end after writeTimeout.
+func writeMapFileRetry(filename Path, content []string) error {
+	deadline := time.Now().Add(writeTimeout)
+	backoff := writeBackoff
+	for retry := 0; ; retry++ {
+		err := writeFile(filename, content)
+		if err == nil || !transientWriteError(err) || retry >= writeRetries || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		logger.Warningf("writing map file '%s' failed, retrying in %s: %s", filename, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// transientWriteError returns true if the error of a map file write may not happen again,
+// like a file vanishing on a network filesystem or an I/O error.
+func transientWriteError(err error) bool {
+	return errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EAGAIN)
+}
+
+// writeMapFile replaces the map file with the given content chunks. They are
+// written to a temporary file of the map directory, renamed over the map file
+// once complete, so HAProxy never reads a partially written map. The temporary
+// file is removed on error.
+func writeMapFile(filename Path, content []string) error {
+	f, err := renameio.TempFile(path.Dir(string(filename)), string(filename))
+	if err != nil {
+		return err
+	}
+	defer f.Cleanup() //nolint:errcheck
+	if err = f.Chmod(0o666); err != nil {
+		return err
+	}
+	for _, chunk := range content {
+		if _, err = f.WriteString(chunk); err != nil {
+			return err
+		}
 	}
+	return f.CloseAtomicallyReplace()
 }
 
 func GetPath(name Name) Path {
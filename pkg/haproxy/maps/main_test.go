@@ -0,0 +1,227 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maps
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/fs"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
+)
+
+type fakeClient struct {
+	api.HAProxyClient
+}
+
+func (fakeClient) SetMapContent(string, []string) error {
+	return nil
+}
+
+// refresh refreshes the maps as done at the end of a sync and writes the map files.
+func refresh(m Maps) {
+	m.RefreshMaps(fakeClient{})
+	fs.RunDelayedFuncs()
+}
+
+func mapFileExists(name Name) bool {
+	_, err := os.Stat(string(GetPath(name)))
+	return err == nil
+}
+
+// TestMapRef validates that:
+// - a map is kept while one of its owners registers it again after a clean
+// - a map whose owners are all gone is removed, with its file
+// - a map deregistered by its last owner is removed even if it has content
+// - maps which are not reference counted are not affected
+func TestMapRef(t *testing.T) {
+	m, err := New(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	// Sync with two ingresses, both using "shared" and only the first one using "deleted"
+	m.MapRef("deleted", "ingress/ns/first")
+	m.MapAppend("deleted", "10.0.0.1")
+	for _, owner := range []string{"ingress/ns/first", "ingress/ns/second"} {
+		m.MapRef("shared", owner)
+		m.MapAppend("shared", "10.0.0.2")
+	}
+	m.MapAppend("unmanaged", "10.0.0.3")
+	refresh(m)
+	assert.True(t, mapFileExists("deleted"))
+	assert.True(t, mapFileExists("shared"))
+	assert.True(t, mapFileExists("unmanaged"))
+
+	// The first ingress is deleted
+	m.CleanMaps()
+	m.MapRef("shared", "ingress/ns/second")
+	m.MapAppend("shared", "10.0.0.2")
+	m.MapAppend("unmanaged", "10.0.0.3")
+	refresh(m)
+	assert.False(t, m.MapExists("deleted"))
+	assert.False(t, mapFileExists("deleted"))
+	assert.True(t, m.MapExists("shared"))
+	assert.True(t, mapFileExists("shared"))
+	assert.True(t, mapFileExists("unmanaged"))
+
+	// The second ingress stops using the map in the middle of the sync
+	m.CleanMaps()
+	m.MapRef("shared", "ingress/ns/second")
+	m.MapAppend("shared", "10.0.0.2")
+	m.MapUnref("shared", "ingress/ns/second")
+	m.MapAppend("unmanaged", "10.0.0.3")
+	refresh(m)
+	assert.False(t, m.MapExists("shared"))
+	assert.False(t, mapFileExists("shared"))
+	assert.True(t, mapFileExists("unmanaged"))
+}
+
+// TestMapRefPersistent validates that a persistent map is never removed, even without owner.
+func TestMapRefPersistent(t *testing.T) {
+	m, err := New(t.TempDir(), []Name{"persistent"})
+	require.NoError(t, err)
+
+	m.MapRef("persistent", "ingress/ns/first")
+	m.MapAppend("persistent", "10.0.0.1")
+	refresh(m)
+	m.MapUnref("persistent", "ingress/ns/first")
+	m.MapAppend("persistent", "10.0.0.2")
+	refresh(m)
+	assert.True(t, m.MapExists("persistent"))
+	assert.True(t, mapFileExists("persistent"))
+}
+
+// TestMapExisted validates that:
+// - a map filled for the first time did not exist in the previous sync, and changed
+// - a map emptied by a clean existed in the previous sync, until the following clean
+// - a map filled again with the content of the previous sync didn't change, whatever the row order
+// - a map filled with other rows changed
+func TestMapExisted(t *testing.T) {
+	m, err := New(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	m.MapAppend("whitelist", "10.0.0.1")
+	m.MapAppend("whitelist", "10.0.0.2")
+	assert.False(t, m.MapExisted("whitelist"))
+	assert.True(t, m.MapChanged("whitelist"))
+
+	m.CleanMaps()
+	assert.False(t, m.MapExists("whitelist"))
+	assert.True(t, m.MapExisted("whitelist"))
+	m.MapAppend("whitelist", "10.0.0.2")
+	m.MapAppend("whitelist", "10.0.0.1")
+	assert.False(t, m.MapChanged("whitelist"))
+
+	m.CleanMaps()
+	m.MapAppend("whitelist", "10.0.0.3")
+	assert.True(t, m.MapChanged("whitelist"))
+
+	m.CleanMaps()
+	m.CleanMaps()
+	assert.False(t, m.MapExisted("whitelist"))
+}
+
+// TestWriteMapFile validates that:
+// - the map file holds the full content, its chunks concatenated, once written
+// - an existing map file is replaced
+// - no temporary file is left in the map directory, on success or on error
+func TestWriteMapFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := Path(filepath.Join(dir, "whitelist.map"))
+
+	require.NoError(t, writeMapFile(filename, []string{"10.0.0.1\n10.0.0.2\n", "192.168.0.0/16\n"}))
+	content, err := os.ReadFile(string(filename))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1\n10.0.0.2\n192.168.0.0/16\n", string(content))
+
+	require.NoError(t, writeMapFile(filename, []string{"172.16.0.1\n"}))
+	content, err = os.ReadFile(string(filename))
+	require.NoError(t, err)
+	assert.Equal(t, "172.16.0.1\n", string(content))
+
+	// The rename fails when a directory is in the way of the map file
+	blocked := Path(filepath.Join(dir, "blocked.map"))
+	require.NoError(t, os.MkdirAll(filepath.Join(string(blocked), "entry"), 0o755))
+	assert.Error(t, writeMapFile(blocked, []string{"10.0.0.1\n"}))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"whitelist.map", "blocked.map"}, names)
+}
+
+// failingWrites replaces the map file writes with ones failing with the given errors
+// before writing the file, and returns the number of attempts.
+func failingWrites(t *testing.T, errs ...error) *int {
+	t.Helper()
+	attempts := 0
+	writeFile = func(filename Path, content []string) error {
+		attempts++
+		if attempts <= len(errs) {
+			return &os.PathError{Op: "write", Path: string(filename), Err: errs[attempts-1]}
+		}
+		return writeMapFile(filename, content)
+	}
+	writeBackoff = time.Millisecond
+	t.Cleanup(func() {
+		writeFile = writeMapFile
+		writeBackoff = initialWriteBackoff
+		SetWriteRetry(DefaultWriteRetries, DefaultWriteTimeout)
+	})
+	return &attempts
+}
+
+// TestWriteMapFileRetry validates that:
+// - a map file write failing once on a transient error is retried and completes, when refreshing the maps too
+// - writes are retried up to the number of retries, within the timeout
+// - other errors are not retried
+func TestWriteMapFileRetry(t *testing.T) {
+	m, err := New(t.TempDir(), nil)
+	require.NoError(t, err)
+	attempts := failingWrites(t, syscall.EIO)
+	m.MapAppend("whitelist", "10.0.0.1")
+	refresh(m)
+	assert.Equal(t, 2, *attempts)
+	content, err := os.ReadFile(string(GetPath("whitelist")))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1\n", string(content))
+
+	filename := Path(filepath.Join(t.TempDir(), "whitelist.map"))
+	attempts = failingWrites(t, syscall.ENOENT, syscall.ESTALE, syscall.EIO)
+	require.NoError(t, writeMapFileRetry(filename, []string{"10.0.0.1\n"}))
+	assert.Equal(t, 4, *attempts)
+
+	attempts = failingWrites(t, syscall.EIO, syscall.EIO, syscall.EIO)
+	SetWriteRetry(2, DefaultWriteTimeout)
+	require.ErrorIs(t, writeMapFileRetry(filename, []string{"10.0.0.1\n"}), syscall.EIO)
+	assert.Equal(t, 3, *attempts)
+
+	attempts = failingWrites(t, syscall.EIO, syscall.EIO, syscall.EIO)
+	SetWriteRetry(DefaultWriteRetries, 0)
+	require.ErrorIs(t, writeMapFileRetry(filename, []string{"10.0.0.1\n"}), syscall.EIO)
+	assert.Equal(t, 1, *attempts)
+
+	attempts = failingWrites(t, syscall.EACCES)
+	require.ErrorIs(t, writeMapFileRetry(filename, []string{"10.0.0.1\n"}), syscall.EACCES)
+	assert.Equal(t, 1, *attempts)
+}
@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
+	"github.com/haproxytech/kubernetes-ingress/pkg/rules/httprequests"
+)
+
+// ErrBackendRule is returned for the rules creating other than http-request rules in backends.
+var ErrBackendRule = errors.New("only http-request rules can be placed in backends")
+
+// backendPlaced is implemented by the rules which can be placed in backends.
+type backendPlaced interface {
+	inBackend() bool
+}
+
+// SplitBackend returns the rules to create in frontends, and the ones placed in the
+// backends requests are routed to, see BackendHTTPRequestRules.
+func (rules List) SplitBackend() (frontend, backend List) {
+	for _, rule := range rules {
+		if r, ok := rule.(backendPlaced); ok && r.inBackend() {
+			backend = append(backend, rule)
+			continue
+		}
+		frontend = append(frontend, rule)
+	}
+	return frontend, backend
+}
+
+// backendRuleSets are the http-request rules of the HTTP backends handled during the sync,
+// see SetBackendHTTPRequests. They are written by RefreshRules and reset by CleanRules.
+var backendRuleSets = map[string]*backendRuleSet{}
+
+type backendRuleSet struct {
+	// httpRequests are the http-request rules of the backend itself
+	httpRequests models.HTTPRequestRules
+	// rules are the rules placed in the backend by the ingresses routed to it
+	rules List
+	ids   map[RuleID]struct{}
+}
+
+// SetBackendHTTPRequests sets the http-request rules of the given HTTP backend, and adds the
+// rules an ingress places in it to the ones of the other ingresses routed to the backend.
+// The backend is written by RefreshRules once all the ingresses are processed, so that it
+// is only updated when its resulting rules change.
+func SetBackendHTTPRequests(backendName string, httpRequests models.HTTPRequestRules, list List) {
+	ruleSet, ok := backendRuleSets[backendName]
+	if !ok {
+		ruleSet = &backendRuleSet{ids: map[RuleID]struct{}{}}
+		backendRuleSets[backendName] = ruleSet
+	}
+	ruleSet.httpRequests = httpRequests
+	for _, rule := range list {
+		id := GetID(rule)
+		if _, ok := ruleSet.ids[id]; ok {
+			continue
+		}
+		ruleSet.ids[id] = struct{}{}
+		ruleSet.rules = append(ruleSet.rules, rule)
+	}
+}
+
+// refreshBackends writes the http-request rules of the backends handled during the sync,
+// followed by the ones placed in them by ingresses.
+func refreshBackends(client api.HAProxyClient) {
+	for backendName, ruleSet := range backendRuleSets {
+		// The rules of the backend may be the ones of a Backend CR in the store, which is kept as is
+		httpRequests := slices.Clone(ruleSet.httpRequests)
+		if len(ruleSet.rules) > 0 {
+			list, err := BackendHTTPRequestRules(client, backendName, ruleSet.rules)
+			if err != nil {
+				logger.Errorf("backend '%s': %s", backendName, err)
+			}
+			httpRequests = append(httpRequests, list...)
+		}
+		httprequests.PopulateBackend(client, backendName, httpRequests)
+	}
+}
+
+// BackendHTTPRequestRules returns the http-request rules the given rules create in the
+// given HTTP backend, in the order HAProxy evaluates them, like RefreshRules does in
+// frontends. As the backend may be shared by several ingresses, each rule is given the
+// ingress ACL of the frontends, matching the requests of the ingresses it belongs to.
+// The backends declaring stick-tables are created with client.
+func BackendHTTPRequestRules(client api.HAProxyClient, backendName string, list List) (models.HTTPRequestRules, error) {
+	recorder := &backendRules{HAProxyClient: client}
+	backend := &models.Frontend{FrontendBase: models.FrontendBase{Name: backendName, Mode: "http"}}
+	for ruleType := RES_SET_HEADER; ruleType >= REQ_ACCEPT_CONTENT; ruleType-- {
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].GetType() != ruleType {
+				continue
+			}
+			if err := list[i].Create(recorder, backend, ingressACL(HTTPACLVar, GetID(list[i]))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return recorder.rules, nil
+}
+
+// backendRules records the http-request rules created in a frontend, for a backend.
+type backendRules struct {
+	api.HAProxyClient
+	rules models.HTTPRequestRules
+}
+
+func (c *backendRules) FrontendHTTPRequestRuleCreate(id int64, _ string, rule models.HTTPRequestRule, ingressACL string) error {
+	if ingressACL != "" {
+		rule.Cond = "if"
+		rule.CondTest = strings.TrimSpace(ingressACL + " " + rule.CondTest)
+	}
+	c.rules = slices.Insert(c.rules, int(id), &rule)
+	return nil
+}
+
+func (c *backendRules) FrontendHTTPResponseRuleCreate(_ int64, _ string, _ models.HTTPResponseRule, _ string) error {
+	return ErrBackendRule
+}
+
+func (c *backendRules) FrontendTCPRequestRuleCreate(_ int64, _ string, _ models.TCPRequestRule, _ string) error {
+	return ErrBackendRule
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// TestBackendHTTPRequestRules tests the placement of rate limit rules in frontends or backends.
+// It validates that:
+// - Rules placed in backends are split from the ones created in frontends
+// - In frontends, requests are tracked then denied, whatever the backend they are routed to
+// - In backends, the same http-request rules are returned in evaluation order, and the table is declared
+// - The rules placed in backends only match the requests of their ingresses
+// - Rules creating http-response rules can't be placed in backends
+func TestBackendHTTPRequestRules(t *testing.T) {
+	tier := func(backend bool) (*ReqRateLimit, *ReqTrack) {
+		track := &ReqTrack{TableName: "RateLimit-1000", TableSize: utils.PtrInt64(102400), TablePeriod: utils.PtrInt64(1000), TrackKey: "src", Backend: backend}
+		limit := &ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, Backend: backend}
+		return limit, track
+	}
+	deny := &ReqSourceAllowList{SrcIPs: []string{"192.0.2.0/24"}}
+
+	limit, track := tier(false)
+	frontend, backend := List{deny, limit, track}.SplitBackend()
+	assert.Equal(t, List{deny, limit, track}, frontend)
+	assert.Empty(t, backend)
+	assert.Equal(t, []string{
+		"backend RateLimit-1000",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"frontend http",
+		"  http-request track-sc0 src table RateLimit-1000",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+	}, renderRules(t, track, limit))
+
+	limit, track = tier(true)
+	frontend, backend = List{deny, limit, track}.SplitBackend()
+	assert.Equal(t, List{deny}, frontend)
+	assert.Equal(t, List{limit, track}, backend)
+
+	client := &ruleRecorder{}
+	httpRequests, err := BackendHTTPRequestRules(client, "default_svc_http", backend)
+	require.NoError(t, err)
+	lines := make([]string, 0, len(httpRequests))
+	for _, rule := range httpRequests {
+		lines = append(lines, httpRequestRuleString(*rule))
+	}
+	assert.Equal(t, []string{
+		"http-request track-sc0 src table RateLimit-1000 if { var(txn.path_match) -m dom " + string(GetID(track)) + " }",
+		"http-request deny deny_status 429 if { var(txn.path_match) -m dom " + string(GetID(limit)) + " } { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+	}, lines)
+	require.Len(t, client.backends, 1)
+	assert.Equal(t, "RateLimit-1000", client.backends[0].Name)
+	assert.Empty(t, client.rules)
+
+	limit.Headers = true
+	_, err = BackendHTTPRequestRules(&ruleRecorder{}, "default_svc_http", List{limit, track})
+	assert.ErrorIs(t, err, ErrBackendRule)
+}
+
+// TestSetBackendHTTPRequests tests the backends shared by several ingresses placing rules in them.
+// It validates that:
+// - The rules of every ingress routed to the backend follow the rules of the backend itself
+// - Each rule only matches the requests of its ingresses, and is placed once whatever the ingresses and paths sharing it
+// - The rules of the backend itself, like the ones of a Backend CR, are left unchanged across syncs
+func TestSetBackendHTTPRequests(t *testing.T) {
+	t.Cleanup(SectionRules{}.CleanRules)
+	tier := func(limit int64) List {
+		return List{
+			&ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: limit, DenyStatusCode: 429, Backend: true},
+			&ReqTrack{TableName: "RateLimit-1000", TableSize: utils.PtrInt64(102400), TablePeriod: utils.PtrInt64(1000), TrackKey: "src", Backend: true},
+		}
+	}
+	ingressA, ingressB := tier(10), tier(20)
+	crRules := models.HTTPRequestRules{{Type: "deny", DenyStatus: utils.PtrInt64(403), Cond: "if", CondTest: "{ path_beg /admin }"}}
+
+	client := &ruleRecorder{}
+	client.BackendCreateOrUpdate(models.Backend{BackendBase: models.BackendBase{Name: "default_svc_http", Mode: "http"}})
+	for sync := 0; sync < 2; sync++ {
+		SectionRules{}.CleanRules()
+		SetBackendHTTPRequests("default_svc_http", crRules, ingressA)
+		SetBackendHTTPRequests("default_svc_http", crRules, ingressB)
+		SetBackendHTTPRequests("default_svc_http", crRules, ingressA)
+		refreshBackends(client)
+
+		lines := make([]string, 0, len(client.backendRules["default_svc_http"]))
+		for _, rule := range client.backendRules["default_svc_http"] {
+			lines = append(lines, httpRequestRuleString(*rule))
+		}
+		acl := func(rule Rule) string {
+			return "if { var(txn.path_match) -m dom " + string(GetID(rule)) + " }"
+		}
+		assert.Equal(t, []string{
+			"http-request deny deny_status 403 if { path_beg /admin }",
+			"http-request track-sc0 src table RateLimit-1000 " + acl(ingressA[1]),
+			"http-request deny deny_status 429 " + acl(ingressA[0]) + " { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+			"http-request deny deny_status 429 " + acl(ingressB[0]) + " { sc0_http_req_rate(RateLimit-1000) gt 20 }",
+		}, lines, sync)
+		assert.Len(t, crRules, 1, sync)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/client-native/v6/models"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// ruleRecorder records the HTTP request and response rules and the log targets of a
+// frontend, the backends declaring stick-tables and the log profiles. Other client
+// calls, but the ones RefreshRules and authentication rules make, are not implemented.
+type ruleRecorder struct {
+	api.HAProxyClient
+	tcpRules      []models.TCPRequestRule
+	rules         []models.HTTPRequestRule
+	responseRules []models.HTTPResponseRule
+	backends      []models.Backend
+	logTargets    models.LogTargets
+	logProfiles   []models.LogProfile
+	// backendRules are the http-request rules of the backends
+	backendRules map[string]models.HTTPRequestRules
+}
+
+func (c *ruleRecorder) FrontendHTTPRequestRuleCreate(_ int64, _ string, rule models.HTTPRequestRule, _ string) error {
+	c.rules = append(c.rules, rule)
+	return nil
+}
+
+func (c *ruleRecorder) FrontendTCPRequestRuleCreate(_ int64, _ string, rule models.TCPRequestRule, _ string) error {
+	c.tcpRules = append(c.tcpRules, rule)
+	return nil
+}
+
+func (c *ruleRecorder) FrontendHTTPResponseRuleCreate(_ int64, _ string, rule models.HTTPResponseRule, _ string) error {
+	c.responseRules = append(c.responseRules, rule)
+	return nil
+}
+
+func (c *ruleRecorder) FrontendGet(frontendName string) (models.Frontend, error) {
+	return models.Frontend{FrontendBase: models.FrontendBase{Name: frontendName, Mode: "http"}}, nil
+}
+
+func (c *ruleRecorder) FrontendRuleDeleteAll(_ string) {
+	c.tcpRules, c.rules, c.responseRules = nil, nil, nil
+}
+
+func (c *ruleRecorder) LogTargetsGet(_, _ string) (models.LogTargets, error) {
+	return c.logTargets, nil
+}
+
+func (c *ruleRecorder) LogTargetsReplace(_, _ string, logTargets models.LogTargets) error {
+	c.logTargets = logTargets
+	return nil
+}
+
+func (c *ruleRecorder) LogProfileCreateOrUpdate(profile models.LogProfile) error {
+	for i := range c.logProfiles {
+		if c.logProfiles[i].Name == profile.Name {
+			c.logProfiles[i] = profile
+			return nil
+		}
+	}
+	c.logProfiles = append(c.logProfiles, profile)
+	return nil
+}
+
+func (c *ruleRecorder) LogProfileDelete(name string) error {
+	c.logProfiles = slices.DeleteFunc(c.logProfiles, func(profile models.LogProfile) bool {
+		return profile.Name == name
+	})
+	return nil
+}
+
+func (c *ruleRecorder) UserListDeleteAll() error {
+	return nil
+}
+
+func (c *ruleRecorder) UserListExistsByGroup(_ string) (bool, error) {
+	return true, nil
+}
+
+func (c *ruleRecorder) BackendUsed(backendName string) bool {
+	_, err := c.BackendGet(backendName)
+	return err == nil
+}
+
+func (c *ruleRecorder) BackendGet(backendName string) (*models.Backend, error) {
+	for i := range c.backends {
+		if c.backends[i].Name == backendName {
+			return &c.backends[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backend '%s' not found", backendName)
+}
+
+func (c *ruleRecorder) BackendCreateOrUpdate(backend models.Backend) (map[string][]interface{}, bool) {
+	if existing, err := c.BackendGet(backend.Name); err == nil {
+		*existing = backend
+		return nil, false
+	}
+	c.backends = append(c.backends, backend)
+	return nil, true
+}
+
+func (c *ruleRecorder) HTTPRequestRulesGet(_, parentName string) (models.HTTPRequestRules, error) {
+	if _, err := c.BackendGet(parentName); err != nil {
+		return nil, err
+	}
+	return c.backendRules[parentName], nil
+}
+
+func (c *ruleRecorder) HTTPRequestRulesReplace(_, parentName string, rules models.HTTPRequestRules) error {
+	if c.backendRules == nil {
+		c.backendRules = map[string]models.HTTPRequestRules{}
+	}
+	c.backendRules[parentName] = rules
+	return nil
+}
+
+// lines renders the recorded log profiles and backends then the log targets and the rules
+// of the frontend, in the order HAProxy evaluates them: rules are created at index 0, so
+// the last one comes first.
+func (c *ruleRecorder) lines(frontend string) []string {
+	var lines []string
+	for _, profile := range c.logProfiles {
+		lines = append(lines, "log-profile "+profile.Name)
+		for _, step := range profile.Steps {
+			line := "  on " + step.Step
+			if step.Drop == "enabled" {
+				line += " drop"
+			}
+			if step.Format != "" {
+				line += " format " + step.Format
+			}
+			lines = append(lines, line)
+		}
+	}
+	for _, backend := range c.backends {
+		lines = append(lines, "backend "+backend.Name)
+		if backend.StickTable != nil {
+			lines = append(lines, "  "+stickTableString(backend.StickTable))
+		}
+	}
+	lines = append(lines, "frontend "+frontend)
+	for _, logTarget := range c.logTargets {
+		if logTarget.Global {
+			lines = append(lines, "  log global")
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  log %s %s profile %s", logTarget.Address, logTarget.Facility, logTarget.Profile))
+	}
+	for i := len(c.tcpRules) - 1; i >= 0; i-- {
+		lines = append(lines, "  "+tcpRequestRuleString(c.tcpRules[i]))
+	}
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		lines = append(lines, "  "+httpRequestRuleString(c.rules[i]))
+	}
+	for i := len(c.responseRules) - 1; i >= 0; i-- {
+		rule := c.responseRules[i]
+		if rule.Type == "sc-inc-gpc1" {
+			lines = append(lines, fmt.Sprintf("  http-response sc-inc-gpc1(%d) %s %s", rule.ScID, rule.Cond, rule.CondTest))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  http-response %s %s %s %s %s", rule.Type, rule.HdrName, rule.HdrFormat, rule.Cond, rule.CondTest))
+	}
+	return lines
+}
+
+// stickTableString renders the stick-table declaration of a backend.
+func stickTableString(table *models.ConfigStickTable) string {
+	line := "stick-table type " + table.Type
+	if table.Keylen != nil {
+		line += fmt.Sprintf(" len %d", *table.Keylen)
+	}
+	if table.Size != nil {
+		line += fmt.Sprintf(" size %d", *table.Size)
+	}
+	if table.Expire != nil {
+		line += fmt.Sprintf(" expire %dms", *table.Expire)
+	}
+	if table.Peers != "" {
+		line += " peers " + table.Peers
+	}
+	if table.Store != "" {
+		line += " store " + table.Store
+	}
+	return line
+}
+
+// tcpRequestRuleString renders the tcp-request rules generated by the rate limits.
+func tcpRequestRuleString(rule models.TCPRequestRule) string {
+	line := fmt.Sprintf("tcp-request %s %s", rule.Type, rule.Action)
+	switch rule.Action {
+	case "track-sc":
+		line = fmt.Sprintf("tcp-request %s track-sc%d %s table %s", rule.Type, *rule.TrackStickCounter, rule.TrackKey, rule.TrackTable)
+	case "set-var-fmt":
+		line = fmt.Sprintf("tcp-request %s set-var-fmt(%s.%s) %s", rule.Type, rule.VarScope, rule.VarName, rule.VarFormat)
+	}
+	if rule.Cond != "" {
+		line += fmt.Sprintf(" %s %s", rule.Cond, rule.CondTest)
+	}
+	return line
+}
+
+// renderRules creates the rules in the "http" frontend of a ruleRecorder and returns the
+// resulting configuration lines. Like RefreshRules, rules are created in reverse order
+// so they are evaluated in the given order.
+func renderRules(t *testing.T, rules ...Rule) []string {
+	t.Helper()
+	return renderFrontendRules(t, &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}, rules...)
+}
+
+// renderFrontendRules is renderRules in the given frontend.
+func renderFrontendRules(t *testing.T, frontend *models.Frontend, rules ...Rule) []string {
+	t.Helper()
+	client := &ruleRecorder{}
+	for i := len(rules) - 1; i >= 0; i-- {
+		require.NoError(t, rules[i].Create(client, frontend, ""))
+	}
+	return client.lines(frontend.Name)
+}
+
+// TestRenderRules tests the rendering of a tracked rate limit through actual Create calls.
+// It validates that:
+// - Without whitelist, the table is declared, and requests tracked then denied above the limit
+// - A generated whitelist map and a pattern file are both excluded with -f, alone or combined
+// - A table shared by two rules is declared once
+func TestRenderRules(t *testing.T) {
+	track := func() *ReqTrack {
+		return &ReqTrack{TableName: "RateLimit-10000", TableSize: utils.PtrInt64(102400), TablePeriod: utils.PtrInt64(10000), TrackKey: "src"}
+	}
+	tests := []struct {
+		name      string
+		whitelist []string
+		want      string
+	}{
+		{
+			name: "no whitelist",
+			want: "{ sc0_http_req_rate(RateLimit-10000) gt 100 }",
+		},
+		{
+			name:      "map whitelist",
+			whitelist: []string{"/etc/haproxy/maps/ratelimit-whitelist-0123abcd.map"},
+			want:      "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src -f /etc/haproxy/maps/ratelimit-whitelist-0123abcd.map }",
+		},
+		{
+			name:      "pattern file whitelist",
+			whitelist: []string{"patterns/trusted"},
+			want:      "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src -f patterns/trusted }",
+		},
+		{
+			name:      "map and pattern file whitelist",
+			whitelist: []string{"/etc/haproxy/maps/ratelimit-whitelist-0123abcd.map", "patterns/trusted"},
+			want:      "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src -f /etc/haproxy/maps/ratelimit-whitelist-0123abcd.map } !{ src -f patterns/trusted }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := &ReqRateLimit{TableName: "RateLimit-10000", ReqsLimit: 100, DenyStatusCode: 429}
+			for _, path := range tt.whitelist {
+				limit.WhitelistMaps = append(limit.WhitelistMaps, maps.Path(path))
+			}
+			require.Equal(t, []string{
+				"backend RateLimit-10000",
+				"  stick-table type ip size 102400 expire 10000ms peers localinstance store http_req_rate(10000)",
+				"frontend http",
+				"  http-request track-sc0 src table RateLimit-10000",
+				"  http-request deny deny_status 429 if " + tt.want,
+			}, renderRules(t, track(), limit))
+		})
+	}
+
+	lines := renderRules(t, track(), track())
+	require.Equal(t, 1, strings.Count(strings.Join(lines, "\n"), "backend RateLimit-10000"))
+}
+
+// TestRefreshRules_RateLimitPosition tests the position of the rate limit rules relative to the authentication.
+// It validates that:
+// - By default, requests are tracked before the authentication, and denied after it
+// - Before the authentication, the rate limit is evaluated right after the tracking
+// - Deny rules are evaluated before both
+func TestRefreshRules_RateLimitPosition(t *testing.T) {
+	auth := "  http-request auth if !{ http_auth_group(users) authenticated-users }"
+	track := "  http-request track-sc0 src table RateLimit-1000"
+	limit := "  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 }"
+	deny := "  http-request deny deny_status 403 if { src -f patterns/blocked }"
+	tests := []struct {
+		position string
+		want     []string
+	}{
+		{position: "", want: []string{deny, track, auth, limit}},
+		{position: RateLimitPositionAfterAuth, want: []string{deny, track, auth, limit}},
+		{position: RateLimitPositionBeforeAuth, want: []string{deny, track, limit, auth}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.position, func(t *testing.T) {
+			sectionRules := SectionRules{}
+			// Added in a different order than evaluated
+			for _, rule := range []Rule{
+				ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, Position: tt.position},
+				ReqBasicAuth{AuthGroup: "users"},
+				ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src"},
+				ReqDeny{SrcIPsMap: "patterns/blocked"},
+			} {
+				require.NoError(t, sectionRules.AddRule("http", rule, false))
+			}
+			client := &ruleRecorder{}
+			sectionRules.RefreshRules(client)
+			lines := client.lines("http")
+			assert.Equal(t, tt.want, lines[slices.Index(lines, "frontend http")+1:])
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/haproxytech/client-native/v6/models"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
@@ -72,6 +73,16 @@ func (rules *List) Add(rule Rule) {
 	*rules = append(*rules, rule)
 }
 
+// Remove removes a rule previously added to the list.
+func (rules *List) Remove(rule Rule) {
+	for i, r := range *rules {
+		if r == rule {
+			*rules = append((*rules)[:i], (*rules)[i+1:]...)
+			return
+		}
+	}
+}
+
 func (r SectionRules) AddRule(frontend string, rule Rule, ingressRule bool) error {
 	if rule == nil || frontend == "" {
 		return errors.New("invalid params")
@@ -115,10 +126,19 @@ func (r SectionRules) CleanRules() {
 			}
 		}
 	}
+	backendRuleSets = map[string]*backendRuleSet{}
 }
 
 func (r SectionRules) RefreshRules(client api.HAProxyClient) {
 	logger.Error(client.UserListDeleteAll())
+	defer rateLimitTables.commit()
+	rateLimitLogs.logged = false
+	defer func() {
+		// The log profile is removed along with the last log target using it
+		if !rateLimitLogs.logged {
+			logger.Error(client.LogProfileDelete(RateLimitLogProfile))
+		}
+	}()
 	for feName := range r {
 		fe, err := client.FrontendGet(feName)
 		if err != nil {
@@ -126,6 +146,7 @@ func (r SectionRules) RefreshRules(client api.HAProxyClient) {
 			continue
 		}
 		client.FrontendRuleDeleteAll(feName)
+		logger.Error(removeRateLimitLogTargets(client, feName))
 		// All rules are created with Index 0,
 		// Which means first rule inserted will be last in the list of HAProxy rules after iteration
 		// Thus iteration is done in reverse to preserve order between the defined rules in
@@ -136,6 +157,7 @@ func (r SectionRules) RefreshRules(client api.HAProxyClient) {
 			}
 		}
 	}
+	refreshBackends(client)
 }
 
 func (r SectionRules) refreshRule(client api.HAProxyClient, ruleType Type, i int, frontend *models.Frontend) {
@@ -154,11 +176,11 @@ func (r SectionRules) refreshRule(client api.HAProxyClient, ruleType Type, i int
 		return
 	}
 	// Create HAProxy Rule
-	ingressACL := ""
+	acl := ""
 	if frontendRuleSet.meta[id].ingress {
-		ingressACL = fmt.Sprintf("{ var(%s) -m dom %s }", aclVar, id)
+		acl = ingressACL(aclVar, id)
 	}
-	err := rules[i].Create(client, frontend, ingressACL)
+	err := rules[i].Create(client, frontend, acl)
 	if err != nil {
 		logger.Errorf("failed to create a %s rule: %s", constLookup[ruleType], err)
 		return
@@ -168,8 +190,64 @@ func (r SectionRules) refreshRule(client api.HAProxyClient, ruleType Type, i int
 		"New HAProxy rule '%s' created", constLookup[ruleType])
 }
 
+// httpRequestRuleString renders rule as the http-request line of the HAProxy configuration,
+// for the rule types generated by the rate limits.
+func httpRequestRuleString(rule models.HTTPRequestRule) string {
+	var line strings.Builder
+	line.WriteString("http-request ")
+	switch rule.Type {
+	case "track-sc":
+		var counter int64
+		if rule.TrackScStickCounter != nil {
+			counter = *rule.TrackScStickCounter
+		}
+		fmt.Fprintf(&line, "track-sc%d %s table %s", counter, rule.TrackScKey, rule.TrackScTable)
+	case "capture":
+		fmt.Fprintf(&line, "capture %s len %d", rule.CaptureSample, rule.CaptureLen)
+	case "sc-inc-gpc0":
+		fmt.Fprintf(&line, "sc-inc-gpc0(%d)", rule.ScID)
+	case "sc-set-gpt0":
+		var value int64
+		if rule.ScInt != nil {
+			value = *rule.ScInt
+		}
+		fmt.Fprintf(&line, "sc-set-gpt0(%d) %d", rule.ScID, value)
+	case "redirect":
+		fmt.Fprintf(&line, "redirect %s %s", rule.RedirType, rule.RedirValue)
+		if rule.RedirCode != nil {
+			fmt.Fprintf(&line, " code %d", *rule.RedirCode)
+		}
+	default:
+		line.WriteString(rule.Type)
+		if rule.DenyStatus != nil {
+			fmt.Fprintf(&line, " deny_status %d", *rule.DenyStatus)
+		}
+		if rule.ReturnContentFormat == "errorfile" {
+			fmt.Fprintf(&line, " errorfile %s", rule.ReturnContent)
+		}
+	}
+	if rule.Cond != "" {
+		fmt.Fprintf(&line, " %s %s", rule.Cond, rule.CondTest)
+	}
+	return line.String()
+}
+
+// withID appends the identifier of a rate limit to its rule, as a comment.
+func withID(rule, id string) string {
+	if id == "" {
+		return rule
+	}
+	return rule + " # rate-limit-id " + id
+}
+
 func GetID(rule Rule) RuleID {
 	b, _ := json.Marshal(rule) //nolint:errchkjson
 	b = append(b, byte(rule.GetType()))
 	return RuleID(utils.Hash(b))
 }
+
+// ingressACL returns the ACL matching the requests of the ingresses the rule with the
+// given id belongs to, whose routes set aclVar to their rule ids.
+func ingressACL(aclVar string, id RuleID) string {
+	return fmt.Sprintf("{ var(%s) -m dom %s }", aclVar, id)
+}
@@ -3,10 +3,13 @@ package rules
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/haproxytech/client-native/v6/models"
 
+	"github.com/haproxytech/kubernetes-ingress/pkg/controller/constants"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
 	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
@@ -18,59 +21,872 @@ type ReqRateLimit struct {
 	DenyStatusCode int64
 	WhitelistIPs   []string    // Direct IPs and CIDRs
 	WhitelistMaps  []maps.Path // Pattern file references
+	BlacklistIPs   []string    // Direct IPs and CIDRs denied regardless of rate
+	BlacklistMaps  []maps.Path // Pattern file references denied regardless of rate
+	PathPrefixes   []string    // Restrict the rate limit to these path prefixes
+	ExemptMethods  []string    // HTTP methods which are never denied
+	// WhitelistAddrPorts are the source address and port pairs which are never rejected, like
+	// 10.0.0.1:5000 or 2001:db8::1:5000. Ports are only available to the rules of TCP frontends.
+	WhitelistAddrPorts []string
+	// OnlyIPs and OnlyMap restrict the rate limit to the sources they list, written inline or
+	// loaded from a pattern file. Empty to rate limit every source
+	OnlyIPs []string
+	OnlyMap maps.Path
+	// GeoMap is the pattern file mapping source addresses to country codes, see GeoExemptCountries
+	GeoMap maps.Path
+	// GeoExemptCountries are the countries whose requests are never denied
+	GeoExemptCountries []string
+	// GeoTargetCountries are the only countries whose requests are denied, empty for every country
+	GeoTargetCountries []string
+	// ExemptUserAgents are the substrings of the User-Agent of the requests which are never denied,
+	// like the one of Kubernetes probes, ExemptUserAgentMaps the pattern files listing more of them
+	ExemptUserAgents    []string
+	ExemptUserAgentMaps []maps.Path
+	RetryAfter          int64  // Retry-After header value in seconds, 0 to disable
+	Action              string // Action applied to requests exceeding the limit, defaults to deny
+	// AdmitPercentage is the percentage of the requests exceeding the limit which are still admitted, 0 to deny them all
+	AdmitPercentage int64
+	// RandDrawn is true when the random number compared to AdmitPercentage is drawn by the
+	// first tier of the rate limit, so this tier doesn't draw another one
+	RandDrawn    bool
+	StickCounter int64  // Stick counter (scN) tracking the request rate
+	Counter      string // Stick-table counter compared to ReqsLimit, defaults to http_req_rate
+	DenyMessage  string // text/plain body of the deny response, empty for the errorfile of the status code
+	// ErrorFile is the errorfile served as the deny response instead of the one of the status code,
+	// its path being relative to the configuration directory, empty to disable
+	ErrorFile string
+	// DeniedKey counts the denied requests in the RateLimitDeniedTable entry of this key, empty to disable
+	DeniedKey          string
+	DeniedStickCounter int64 // Stick counter tracking DeniedKey
+	// LogTag is captured with the table name in the log line of denied requests, empty to disable
+	LogTag string
+	// LogTarget is the address of the syslog server denied requests are logged to, with the
+	// RateLimitLogProfile, apart from the access logs. Empty to disable, ignored in backends
+	LogTarget string
+	// Headers adds the X-RateLimit-Limit and X-RateLimit-Remaining headers to the responses
+	// of requests whose rate reaches HeadersThreshold
+	Headers          bool
+	HeadersThreshold int64
+	// WhitelistHeader exempts the requests whose header of this name has the WhitelistHeaderValue
+	// or one of the values of the WhitelistHeaderMap pattern file
+	WhitelistHeader      string
+	WhitelistHeaderValue string
+	WhitelistHeaderMap   maps.Path
+	// MaxStreams is the number of concurrent HTTP/2 or HTTP/3 streams a connection may open
+	// before its requests are refused with the Action of the rate limit, 0 to disable
+	MaxStreams int64
+	// BlacklistStatusCode is the status denying blacklisted sources, 0 for the DenyStatusCode
+	BlacklistStatusCode int64
+	// ListPrecedence decides whether sources both whitelisted and blacklisted are denied,
+	// defaults to RateLimitListPrecedenceBlacklist
+	ListPrecedence string
+	// MissingKey is the tracked key whose absence denies requests, empty to disable
+	MissingKey string
+	// Position of the rules relative to the authentication ones, defaults to RateLimitPositionAfterAuth
+	Position string
+	// BurstLimit is the request rate of the Burst table, tracked over a period shorter than the one
+	// of the rate limit, which must also be exceeded for requests to be denied, 0 to disable
+	BurstLimit int64
+	Burst      *ReqTrack
+	// RedirectLocation is the URL or path requests exceeding the limit are redirected to
+	// with a 302 instead of being denied, empty to apply the Action
+	RedirectLocation string
+	// Backend places the rules in the backends requests are routed to, see SplitBackend
+	Backend bool
+	// DenyRateLimit is the number of responses a source gets for its requests exceeding
+	// the limit over the period of the DenyRate table, beyond which they are silently
+	// dropped, so a flood doesn't cost a response per request. 0 to disable
+	DenyRateLimit int64
+	DenyRate      *ReqTrack
+	// LowWatermark keeps denying the clients which exceeded the limit until their rate drops
+	// under it, flagged in the gpt0 of the table, see ReqTrack.Hysteresis. 0 to disable
+	LowWatermark int64
+	// ID identifies the rate limit in logs whatever its table name, empty when not set.
+	// It doesn't change the rules, so it is left out of their RuleID.
+	ID string `json:"-"`
 }
 
 const (
 	defaultRateLimitStatueCode = "403"
+	// rateLimitRedirectCode is the status of the redirections to the RedirectLocation,
+	// temporary so clients come back once they slowed down.
+	rateLimitRedirectCode int64 = 302
 )
 
+// Actions applied to requests exceeding the rate limit
+const (
+	RateLimitActionDeny       = "deny"
+	RateLimitActionTarpit     = "tarpit"
+	RateLimitActionSilentDrop = "silent-drop"
+)
+
+// Positions of the rate limit rules relative to the authentication rules, see ReqRateLimit.Position.
+// Tracking rules are always evaluated before the authentication, and after the deny rules.
+const (
+	RateLimitPositionBeforeAuth = "before-auth"
+	RateLimitPositionAfterAuth  = "after-auth"
+)
+
+// Scopes of the rate limit rules: frontends count all the requests of an ingress, before
+// routing, while backends only count the ones routed to them, see ReqRateLimit.Backend.
+const (
+	RateLimitScopeFrontend = "frontend"
+	RateLimitScopeBackend  = "backend"
+)
+
+// Precedences of the lists of sources, see ReqRateLimit.ListPrecedence: with the blacklist one,
+// blacklisted sources are denied even when whitelisted, with the whitelist one they are not.
+const (
+	RateLimitListPrecedenceWhitelist = "whitelist"
+	RateLimitListPrecedenceBlacklist = "blacklist"
+)
+
+// Headers reporting the rate limit to clients, see ReqRateLimit.Headers
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+)
+
+// RateLimitLogProfile is the log-profile of the log targets of denied requests, see ReqRateLimit.LogTarget.
+// It only emits the logs of the do-log rules, so the targets don't receive the access logs.
+const RateLimitLogProfile = "ratelimit-deny"
+
+const (
+	// rateLimitLogFacility is the syslog facility of the logs of denied requests
+	rateLimitLogFacility = "local0"
+	// rateLimitLogFormat is the format of the logs of denied requests, %hr holding the LogTag
+	rateLimitLogFormat = "'%ci:%cp [%tr] %ft %hr %{+Q}r'"
+)
+
+// rateLimitSrcVar holds the source address and port of a connection, matched against
+// the WhitelistAddrPorts. Like the WhitelistAddrPorts, the port follows the last colon.
+const rateLimitSrcVar = "ratelimit_src"
+
+// rateLimitRandVar holds the random number, from 0 to 99, deciding if a request
+// exceeding the limit is admitted. It is drawn once so every rule of the rate
+// limit takes the same decision.
+const rateLimitRandVar = "ratelimit_rand"
+
+// RateLimitDeniedTable is the stick-table counting the requests denied by rate limits.
+// Every DeniedKey has an entry whose http_req_cnt is the number of denied requests.
+const RateLimitDeniedTable = "RateLimitDenied"
+
+const (
+	// deniedTableKeyLen fits a namespace (63), an ingress name (253) and a rate-limit-id (63)
+	// separated by slashes.
+	deniedTableKeyLen int64 = 384
+	deniedTableSize   int64 = 10 * 1024
+)
+
+// Stick-table counters a rate limit can be enforced on
+const (
+	RateLimitCounterReqRate      = "http_req_rate"
+	RateLimitCounterConnRate     = "conn_rate"
+	RateLimitCounterConnCur      = "conn_cur"
+	RateLimitCounterBytesInRate  = "bytes_in_rate"
+	RateLimitCounterBytesOutRate = "bytes_out_rate"
+	// RateLimitCounterGpc0Rate is incremented by the cost of requests, see ReqTrack.CostHeader
+	RateLimitCounterGpc0Rate = "gpc0_rate"
+	// RateLimitCounterGpc1Rate is incremented by the responses with an error status, see ReqTrack.ErrorStatuses
+	RateLimitCounterGpc1Rate = "gpc1_rate"
+)
+
+// String returns the rule denying the requests exceeding the rate limit,
+// as written in the HAProxy configuration.
+func (r ReqRateLimit) String() string {
+	_ = r.applyDefaults() // r is a copy, the rule itself is not modified
+	return withID(httpRequestRuleString(r.rateLimitRule()), r.ID)
+}
+
+func (r ReqRateLimit) inBackend() bool {
+	return r.Backend
+}
+
 func (r ReqRateLimit) GetType() Type {
+	if r.Position == RateLimitPositionBeforeAuth {
+		return REQ_RATELIMIT_BEFORE_AUTH
+	}
 	return REQ_RATELIMIT
 }
 
+// Create adds the rules of the rate limit to the frontend. Without ReqsLimit, like with
+// rate-limit-requests auto until a peak rate is observed, the request rate is not limited
+// but the rules denying requests whatever their rate still are.
 func (r ReqRateLimit) Create(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
 	if frontend.Mode == "tcp" {
-		return errors.New("request Track cannot be configured in TCP mode")
+		return r.createTCP(client, frontend, ingressACL)
+	}
+	if len(r.WhitelistAddrPorts) > 0 {
+		return errors.New("source ports can only be whitelisted in TCP mode")
+	}
+	err := r.applyDefaults()
+	if err != nil {
+		return err
 	}
 
-	// ReqsLimit == 0 means rate-limit disabled
-	if r.ReqsLimit == 0 {
-		return nil
+	if r.ReqsLimit > 0 {
+		err = r.createRateRules(client, frontend, ingressACL)
+		if err != nil {
+			return err
+		}
 	}
-	condTest := fmt.Sprintf("{ sc0_http_req_rate(%s) gt %d }", r.TableName, r.ReqsLimit)
 
-	err := r.applyDefaults()
+	// Blacklisted sources are denied regardless of their request rate.
+	// Rules are inserted at index 0, so creating them last makes them evaluated first.
+	for _, condTest := range r.blacklistCondTests() {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.blacklistRule(condTest), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Requests without the tracked key are denied whatever the request rate
+	if r.MissingKey != "" {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.denyRule(r.missingKeyCondTest()), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Connections multiplexing too many streams are limited whatever the request rate
+	if r.MaxStreams > 0 {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.actionRule(r.streamsCondTest()), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Clients are flagged before the rules matching flagged clients are evaluated
+	if r.ReqsLimit > 0 && r.LowWatermark > 0 {
+		for _, rule := range []models.HTTPRequestRule{r.unflagRule(), r.flagRule()} {
+			err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, rule, ingressACL)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The random number is drawn before any rule of the rate limit uses it
+	if r.ReqsLimit > 0 && r.AdmitPercentage > 0 && !r.RandDrawn {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.randRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRateRules adds the rules applied to the requests exceeding ReqsLimit.
+func (r ReqRateLimit) createRateRules(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
+	err := client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.rateLimitRule(), ingressACL)
 	if err != nil {
 		return err
 	}
 
-	// Build whitelist conditions if configured
-	// If whitelist is set, only apply rate limiting if source IP is NOT in the whitelist
-	if len(r.WhitelistIPs) > 0 || len(r.WhitelistMaps) > 0 {
-		var whitelistConditions []string
+	// Requests are dropped instead once their source got enough responses
+	if r.DenyRate != nil && r.DenyRateLimit > 0 {
+		err = r.createDenyRate(client, frontend.Name, ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Denied requests are logged to the log target before they are denied, after being tagged, so
+	// the log line holds the LogTag. Backends don't have the log targets of the frontends.
+	if r.LogTarget != "" && !r.Backend {
+		err = addRateLimitLogTarget(client, frontend.Name, r.LogTarget)
+		if err != nil {
+			return err
+		}
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.doLogRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Denied requests are tagged by capturing the tag before they are denied
+	if r.LogTag != "" {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.logRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Denied requests are counted by tracking them before they are denied
+	if r.DeniedKey != "" {
+		if !client.BackendUsed(RateLimitDeniedTable) {
+			client.BackendCreateOrUpdate(models.Backend{
+				BackendBase: models.BackendBase{
+					From:       constants.DefaultsSectionName,
+					Name:       RateLimitDeniedTable,
+					StickTable: deniedStickTable(),
+				},
+			})
+		}
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.deniedTrackRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
 
-		// Add direct IP/CIDR condition
-		if len(r.WhitelistIPs) > 0 {
-			whitelistConditions = append(whitelistConditions,
-				fmt.Sprintf("!{ src %s }", strings.Join(r.WhitelistIPs, " ")))
+	// Clients are warned before being denied
+	if r.Headers {
+		for _, rule := range r.headerRules() {
+			err = client.FrontendHTTPResponseRuleCreate(0, frontend.Name, rule, ingressACL)
+			if err != nil {
+				return err
+			}
 		}
+	}
+	return nil
+}
 
-		// Add pattern file conditions
-		for _, mapPath := range r.WhitelistMaps {
-			whitelistConditions = append(whitelistConditions,
-				fmt.Sprintf("!{ src -f %s }", mapPath))
+// createTCP rejects the connections of a TCP frontend exceeding the rate limit, or from
+// blacklisted sources, unless whitelisted. Like ReqTrack, the connection rate is limited
+// instead of the request rate, and what depends on requests or responses doesn't apply.
+func (r ReqRateLimit) createTCP(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
+	// Response statuses are not counted in TCP mode, see ReqTrack.createTCP
+	if r.Counter == RateLimitCounterGpc1Rate {
+		return nil
+	}
+	r.TableName = tcpTableName(r.TableName)
+	r.Counter = tcpCounter(r.Counter)
+	if r.ReqsLimit > 0 {
+		condTest := fmt.Sprintf("{ %s gt %d }", r.counterFetch(), r.ReqsLimit)
+		if only := r.onlyCondTest(); only != "" {
+			condTest += " " + only
 		}
+		if whitelist := r.whitelistCondTest(); whitelist != "" {
+			condTest += " " + whitelist
+		}
+		if addrPorts := r.addrPortsCondTest(); addrPorts != "" {
+			condTest += " " + addrPorts
+		}
+		err := client.FrontendTCPRequestRuleCreate(0, frontend.Name, tcpRejectRule(condTest), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+	// Created last to be evaluated first
+	for _, condTest := range r.blacklistCondTests() {
+		if addrPorts := r.addrPortsCondTest(); addrPorts != "" && r.ListPrecedence == RateLimitListPrecedenceWhitelist {
+			condTest += " " + addrPorts
+		}
+		err := client.FrontendTCPRequestRuleCreate(0, frontend.Name, tcpRejectRule(condTest), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+	if len(r.WhitelistAddrPorts) > 0 {
+		return client.FrontendTCPRequestRuleCreate(0, frontend.Name, models.TCPRequestRule{
+			Type:      "connection",
+			Action:    "set-var-fmt",
+			VarScope:  "sess",
+			VarName:   rateLimitSrcVar,
+			VarFormat: "%[src]:%[src_port]",
+		}, ingressACL)
+	}
+	return nil
+}
+
+// tcpRejectRule returns the rule rejecting the connections matching condTest.
+func tcpRejectRule(condTest string) models.TCPRequestRule {
+	return models.TCPRequestRule{
+		Type:     "connection",
+		Action:   "reject",
+		Cond:     "if",
+		CondTest: condTest,
+	}
+}
+
+// counterFetch returns the fetch of the stick-table counter compared to ReqsLimit.
+func (r ReqRateLimit) counterFetch() string {
+	counter := r.Counter
+	if counter == "" {
+		counter = RateLimitCounterReqRate
+	}
+	return fmt.Sprintf("sc%d_%s(%s)", r.StickCounter, counter, r.TableName)
+}
+
+// exceedCondTest returns the condition matching requests whose rate exceeds the limit.
+func (r ReqRateLimit) exceedCondTest() string {
+	condTest := fmt.Sprintf("{ %s gt %d }", r.counterFetch(), r.ReqsLimit)
+	if r.Burst != nil && r.BurstLimit > 0 {
+		// Requests exceeding the limit are only denied while the client bursts
+		condTest = fmt.Sprintf("%s { sc%d_%s(%s) gt %d }", condTest, r.Burst.StickCounter, RateLimitCounterReqRate, r.Burst.TableName, r.BurstLimit)
+	}
+	return condTest
+}
+
+// condTest returns the condition matching requests exceeding the rate limit.
+// With a LowWatermark, the requests of the clients flagged as exceeding it.
+func (r ReqRateLimit) condTest() string {
+	condTest := r.exceedCondTest()
+	if r.LowWatermark > 0 {
+		condTest = fmt.Sprintf("{ sc%d_get_gpt0(%s) gt 0 }", r.StickCounter, r.TableName)
+	}
+	if len(r.PathPrefixes) > 0 {
+		condTest = fmt.Sprintf("%s { path_beg %s }", condTest, strings.Join(r.PathPrefixes, " "))
+	}
+	if len(r.ExemptMethods) > 0 {
+		condTest = fmt.Sprintf("%s !{ method %s }", condTest, strings.Join(r.ExemptMethods, " "))
+	}
+	if geo := r.geoCondTest(); geo != "" {
+		condTest += " " + geo
+	}
+	if only := r.onlyCondTest(); only != "" {
+		condTest += " " + only
+	}
+	if r.AdmitPercentage > 0 {
+		condTest = fmt.Sprintf("%s { var(txn.%s) ge %d }", condTest, rateLimitRandVar, r.AdmitPercentage)
+	}
+	return r.withExclusions(condTest)
+}
 
-		condTest = fmt.Sprintf("%s %s", condTest, strings.Join(whitelistConditions, " "))
+// geoCondTest returns the condition restricting the denied requests to the countries of their source,
+// empty without GeoMap. Sources missing from the GeoMap match no country.
+func (r ReqRateLimit) geoCondTest() string {
+	switch {
+	case r.GeoMap == "":
+		return ""
+	case len(r.GeoTargetCountries) > 0:
+		return fmt.Sprintf("{ src,map_ip(%s) -m str %s }", r.GeoMap, strings.Join(r.GeoTargetCountries, " "))
+	case len(r.GeoExemptCountries) > 0:
+		return fmt.Sprintf("!{ src,map_ip(%s) -m str %s }", r.GeoMap, strings.Join(r.GeoExemptCountries, " "))
 	}
+	return ""
+}
+
+// withExclusions appends the conditions excluding whitelisted requests to condTest.
+func (r ReqRateLimit) withExclusions(condTest string) string {
+	for _, exclusion := range []string{r.whitelistCondTest(), r.headerWhitelistCondTest(), r.userAgentCondTest()} {
+		if exclusion != "" {
+			condTest += " " + exclusion
+		}
+	}
+	return condTest
+}
+
+// whitelistCondTest returns the condition excluding whitelisted sources, empty without whitelist.
+// Conditions are ANDed, so a source matching any of the addresses or pattern files is excluded.
+func (r ReqRateLimit) whitelistCondTest() string {
+	var whitelistConditions []string
+
+	// Add direct IP/CIDR condition
+	if len(r.WhitelistIPs) > 0 {
+		whitelistConditions = append(whitelistConditions,
+			fmt.Sprintf("!{ src %s }", strings.Join(r.WhitelistIPs, " ")))
+	}
+
+	// Add pattern file conditions
+	for _, mapPath := range r.WhitelistMaps {
+		whitelistConditions = append(whitelistConditions,
+			fmt.Sprintf("!{ src -f %s }", mapPath))
+	}
+	return strings.Join(whitelistConditions, " ")
+}
+
+// onlyCondTest returns the condition restricting the rate limit to the OnlyIPs or the sources of
+// the OnlyMap, empty without them. Unlike the whitelist, sources must match it to be limited.
+func (r ReqRateLimit) onlyCondTest() string {
+	switch {
+	case r.OnlyMap != "":
+		return fmt.Sprintf("{ src -f %s }", r.OnlyMap)
+	case len(r.OnlyIPs) > 0:
+		return fmt.Sprintf("{ src %s }", strings.Join(r.OnlyIPs, " "))
+	}
+	return ""
+}
+
+// addrPortsCondTest returns the condition excluding the connections from the WhitelistAddrPorts,
+// empty without them. The rateLimitSrcVar is only set by the rules of TCP frontends.
+func (r ReqRateLimit) addrPortsCondTest() string {
+	if len(r.WhitelistAddrPorts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("!{ var(sess.%s) -m str %s }", rateLimitSrcVar, strings.Join(r.WhitelistAddrPorts, " "))
+}
+
+// userAgentCondTest returns the condition excluding requests whose User-Agent contains one of the
+// ExemptUserAgents or of the substrings of the ExemptUserAgentMaps, empty without them.
+func (r ReqRateLimit) userAgentCondTest() string {
+	var conditions []string
+	if len(r.ExemptUserAgents) > 0 {
+		conditions = append(conditions, fmt.Sprintf("!{ req.hdr(User-Agent) -m sub %s }", strings.Join(r.ExemptUserAgents, " ")))
+	}
+	for _, mapPath := range r.ExemptUserAgentMaps {
+		conditions = append(conditions, fmt.Sprintf("!{ req.hdr(User-Agent) -m sub -f %s }", mapPath))
+	}
+	return strings.Join(conditions, " ")
+}
+
+// headerWhitelistCondTest returns the condition excluding requests with a whitelisted header value,
+// empty without WhitelistHeader. Request headers are not available to response rules.
+func (r ReqRateLimit) headerWhitelistCondTest() string {
+	switch {
+	case r.WhitelistHeader == "":
+		return ""
+	case r.WhitelistHeaderMap != "":
+		return fmt.Sprintf("!{ req.hdr(%s) -m str -f %s }", r.WhitelistHeader, r.WhitelistHeaderMap)
+	default:
+		return fmt.Sprintf("!{ req.hdr(%s) -m str %s }", r.WhitelistHeader, r.WhitelistHeaderValue)
+	}
+}
+
+// headerRules returns the rules reporting the limit and the remaining requests to clients
+// whose rate is between HeadersThreshold and the limit.
+// The path is not available in responses: requests out of PathPrefixes are not tracked,
+// so their counter can't be fetched and they get no header.
+func (r ReqRateLimit) headerRules() []models.HTTPResponseRule {
+	fetch := r.counterFetch()
+	condTest := fmt.Sprintf("{ %s le %d }", fetch, r.ReqsLimit)
+	if r.HeadersThreshold > 0 {
+		condTest = fmt.Sprintf("{ %s ge %d } %s", fetch, r.HeadersThreshold, condTest)
+	}
+	for _, condition := range []string{r.onlyCondTest(), r.whitelistCondTest()} {
+		if condition != "" {
+			condTest += " " + condition
+		}
+	}
+	return []models.HTTPResponseRule{
+		{
+			Type:      "set-header",
+			HdrName:   RateLimitLimitHeader,
+			HdrFormat: strconv.FormatInt(r.ReqsLimit, 10),
+			Cond:      "if",
+			CondTest:  condTest,
+		},
+		{
+			Type:      "set-header",
+			HdrName:   RateLimitRemainingHeader,
+			HdrFormat: fmt.Sprintf("%%[%s,neg,add(%d)]", fetch, r.ReqsLimit),
+			Cond:      "if",
+			CondTest:  condTest,
+		},
+	}
+}
+
+// blacklistCondTests returns one condition per blacklist source.
+// They are not ORed in a single condition because the ingress ACL is prepended to it.
+func (r ReqRateLimit) blacklistCondTests() []string {
+	var condTests []string
+	if len(r.BlacklistIPs) > 0 {
+		condTests = append(condTests, fmt.Sprintf("{ src %s }", strings.Join(r.BlacklistIPs, " ")))
+	}
+	for _, mapPath := range r.BlacklistMaps {
+		condTests = append(condTests, fmt.Sprintf("{ src -f %s }", mapPath))
+	}
+	// Whitelisted sources are only spared with the whitelist precedence
+	if whitelist := r.whitelistCondTest(); whitelist != "" && r.ListPrecedence == RateLimitListPrecedenceWhitelist {
+		for i := range condTests {
+			condTests[i] += " " + whitelist
+		}
+	}
+	return condTests
+}
+
+// blacklistRule returns the rule denying the blacklisted sources matching condTest.
+func (r ReqRateLimit) blacklistRule(condTest string) models.HTTPRequestRule {
+	httpRule := r.denyRule(condTest)
+	if r.BlacklistStatusCode != 0 {
+		httpRule.DenyStatus = utils.PtrInt64(r.BlacklistStatusCode)
+	}
+	return httpRule
+}
+
+// streamsCondTest returns the condition matching the requests of HTTP/2 and HTTP/3
+// connections with more than MaxStreams concurrent streams.
+func (r ReqRateLimit) streamsCondTest() string {
+	condTest := fmt.Sprintf("{ fc_http_major ge 2 } { fc_nb_streams gt %d }", r.MaxStreams)
+	return r.withExclusions(condTest)
+}
+
+// missingKeyCondTest returns the condition matching the rate limited requests without MissingKey.
+func (r ReqRateLimit) missingKeyCondTest() string {
+	condTest := fmt.Sprintf("!{ %s -m found }", r.MissingKey)
+	if len(r.PathPrefixes) > 0 {
+		condTest = fmt.Sprintf("%s { path_beg %s }", condTest, strings.Join(r.PathPrefixes, " "))
+	}
+	if len(r.ExemptMethods) > 0 {
+		condTest = fmt.Sprintf("%s !{ method %s }", condTest, strings.Join(r.ExemptMethods, " "))
+	}
+	return r.withExclusions(condTest)
+}
+
+// rateLimitRule returns the rule denying requests exceeding the rate limit.
+func (r ReqRateLimit) rateLimitRule() models.HTTPRequestRule {
+	return r.actionRule(r.condTest())
+}
+
+// actionRule returns the rule applying the Action of the rate limit to the requests matching condTest.
+func (r ReqRateLimit) actionRule(condTest string) models.HTTPRequestRule {
+	if r.RedirectLocation != "" {
+		return r.redirectRule(condTest)
+	}
+	if r.Action == RateLimitActionSilentDrop {
+		// The connection is closed without any response, hence no status, message or header
+		return models.HTTPRequestRule{
+			Type:     RateLimitActionSilentDrop,
+			Cond:     "if",
+			CondTest: condTest,
+		}
+	}
+	httpRule := r.denyRule(condTest)
+	if r.Action == RateLimitActionTarpit {
+		// tarpit accepts the same status and headers as deny,
+		// the delay is set by the "timeout tarpit" of the frontend.
+		httpRule.Type = RateLimitActionTarpit
+	}
+	if r.DenyMessage != "" {
+		httpRule.ReturnContentType = utils.PtrString("text/plain")
+		httpRule.ReturnContentFormat = "string"
+		httpRule.ReturnContent = quoteString(r.DenyMessage)
+	}
+	if r.ErrorFile != "" {
+		httpRule.ReturnContentFormat = "errorfile"
+		httpRule.ReturnContent = r.ErrorFile
+	}
+	if r.RetryAfter > 0 {
+		httpRule.ReturnHeaders = []*models.ReturnHeader{{
+			Name: utils.PtrString("Retry-After"),
+			Fmt:  utils.PtrString(strconv.FormatInt(r.RetryAfter, 10)),
+		}}
+	}
+	return httpRule
+}
+
+// redirectRule returns the rule redirecting the requests matching condTest to the RedirectLocation.
+// The requests of a location on the same site are not redirected again, whatever their rate.
+func (r ReqRateLimit) redirectRule(condTest string) models.HTTPRequestRule {
+	if path, _, _ := strings.Cut(r.RedirectLocation, "?"); strings.HasPrefix(path, "/") {
+		condTest = fmt.Sprintf("%s !{ path %s }", condTest, path)
+	}
+	return models.HTTPRequestRule{
+		Type:      "redirect",
+		RedirType: "location",
+		// The location is a log-format string, where '%' starts a sample
+		RedirValue: strings.ReplaceAll(r.RedirectLocation, "%", "%%"),
+		RedirCode:  utils.PtrInt64(rateLimitRedirectCode),
+		Cond:       "if",
+		CondTest:   condTest,
+	}
+}
+
+// createDenyRate declares the DenyRate table and creates the rules counting the responses
+// to the requests exceeding the limit, and dropping them beyond the DenyRateLimit.
+// The table is shared by the tiers, which track it with the same stick counter, so
+// a request exceeding several limits counts once.
+func (r ReqRateLimit) createDenyRate(client api.HAProxyClient, frontendName, ingressACL string) error {
+	track := *r.DenyRate
+	err := track.applyDefaults()
+	if err != nil {
+		return err
+	}
+	track.declareTable(client)
+	// Requests are counted before the drop rule, created last to be evaluated first
+	for _, rule := range []models.HTTPRequestRule{r.denyRateDropRule(), r.denyRateTrackRule()} {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontendName, rule, ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+	rateLimitTables.register(track.TableName)
+	return nil
+}
+
+// denyRateTrackRule returns the rule tracking the requests the Action of the rate limit
+// applies to in the DenyRate table, which counts the responses they get.
+func (r ReqRateLimit) denyRateTrackRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:                "track-sc",
+		TrackScStickCounter: utils.PtrInt64(r.DenyRate.StickCounter),
+		TrackScKey:          r.DenyRate.trackKey(),
+		TrackScTable:        r.DenyRate.TableName,
+		Cond:                "if",
+		CondTest:            r.rateLimitRule().CondTest,
+	}
+}
+
+// denyRateDropRule returns the rule silently dropping the requests the Action of the rate
+// limit applies to, once their source got more than DenyRateLimit responses over the period.
+func (r ReqRateLimit) denyRateDropRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type: RateLimitActionSilentDrop,
+		Cond: "if",
+		CondTest: fmt.Sprintf("%s { sc%d_%s(%s) gt %d }", r.rateLimitRule().CondTest,
+			r.DenyRate.StickCounter, RateLimitCounterReqRate, r.DenyRate.TableName, r.DenyRateLimit),
+	}
+}
+
+// flagRule returns the rule flagging the clients whose rate exceeds the limit in gpt0.
+// Whitelisted clients are flagged too, the rules matching flagged clients exclude them.
+func (r ReqRateLimit) flagRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:     "sc-set-gpt0",
+		ScID:     r.StickCounter,
+		ScInt:    utils.PtrInt64(1),
+		Cond:     "if",
+		CondTest: r.exceedCondTest(),
+	}
+}
+
+// unflagRule returns the rule clearing the flag of the clients whose rate dropped under the LowWatermark.
+func (r ReqRateLimit) unflagRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:     "sc-set-gpt0",
+		ScID:     r.StickCounter,
+		ScInt:    utils.PtrInt64(0),
+		Cond:     "if",
+		CondTest: fmt.Sprintf("{ %s lt %d }", r.counterFetch(), r.LowWatermark),
+	}
+}
+
+// randRule returns the rule drawing the random number compared to AdmitPercentage.
+func (r ReqRateLimit) randRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:     "set-var",
+		VarName:  rateLimitRandVar,
+		VarScope: "txn",
+		VarExpr:  "rand(100)",
+	}
+}
+
+// deniedTrackRule returns the rule tracking the DeniedKey of requests exceeding the rate limit,
+// which increments its http_req_cnt in the RateLimitDeniedTable.
+func (r ReqRateLimit) deniedTrackRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:                "track-sc",
+		TrackScStickCounter: utils.PtrInt64(r.DeniedStickCounter),
+		TrackScKey:          fmt.Sprintf("str(%s)", r.DeniedKey),
+		TrackScTable:        RateLimitDeniedTable,
+		Cond:                "if",
+		CondTest:            r.condTest(),
+	}
+}
+
+// logRule returns the rule capturing the LogTag and the table name of requests
+// exceeding the rate limit, which shows them in the %hr field of the log line.
+func (r ReqRateLimit) logRule() models.HTTPRequestRule {
+	marker := r.LogTag + ":" + r.TableName
+	return models.HTTPRequestRule{
+		Type:          "capture",
+		CaptureSample: fmt.Sprintf("str(%s)", marker),
+		CaptureLen:    int64(len(marker)),
+		Cond:          "if",
+		CondTest:      r.condTest(),
+	}
+}
+
+// doLogRule returns the rule logging the requests exceeding the rate limit to the log
+// targets of the RateLimitLogProfile, see ReqRateLimit.LogTarget.
+func (r ReqRateLimit) doLogRule() models.HTTPRequestRule {
+	return models.HTTPRequestRule{
+		Type:     "do-log",
+		Cond:     "if",
+		CondTest: r.condTest(),
+	}
+}
+
+// rateLimitLogProfile returns the RateLimitLogProfile, dropping the logs of every step
+// but the one of the http-request do-log rules.
+func rateLimitLogProfile() models.LogProfile {
+	return models.LogProfile{
+		Name: RateLimitLogProfile,
+		Steps: models.LogProfileSteps{
+			{Step: "any", Drop: "enabled"},
+			{Step: "http-req", Format: rateLimitLogFormat},
+		},
+	}
+}
+
+// rateLimitLogs records what the log targets of the rate limits changed while refreshing the rules.
+var rateLimitLogs = &logTargetRegistry{implicitGlobal: map[string]struct{}{}}
+
+type logTargetRegistry struct {
+	// implicitGlobal are the frontends addRateLimitLogTarget added the global log target to,
+	// the only ones removeRateLimitLogTargets removes it from
+	implicitGlobal map[string]struct{}
+	// logged is set when a rate limit logs to a log target in the current refresh
+	logged bool
+}
+
+// addRateLimitLogTarget declares the RateLimitLogProfile and adds a log target of address with
+// this profile to the frontend, unless another rate limit already did. The frontend keeps the
+// global log targets it inherited from the defaults section. Removed by removeRateLimitLogTargets.
+func addRateLimitLogTarget(client api.HAProxyClient, frontendName, address string) error {
+	err := client.LogProfileCreateOrUpdate(rateLimitLogProfile())
+	if err != nil {
+		return err
+	}
+	rateLimitLogs.logged = true
+	logTargets, err := client.LogTargetsGet("frontend", frontendName)
+	if err != nil {
+		return err
+	}
+	for _, logTarget := range logTargets {
+		if logTarget.Profile == RateLimitLogProfile && logTarget.Address == address {
+			return nil
+		}
+	}
+	if len(logTargets) == 0 {
+		logTargets = models.LogTargets{{Global: true}}
+		rateLimitLogs.implicitGlobal[frontendName] = struct{}{}
+	}
+	logTargets = append(slices.Clone(logTargets), &models.LogTarget{
+		Address:  address,
+		Facility: rateLimitLogFacility,
+		Profile:  RateLimitLogProfile,
+	})
+	return client.LogTargetsReplace("frontend", frontendName, logTargets)
+}
+
+// removeRateLimitLogTargets removes the log targets added by addRateLimitLogTarget from the
+// frontend, with the global one when it was only added along with them.
+func removeRateLimitLogTargets(client api.HAProxyClient, frontendName string) error {
+	_, implicitGlobal := rateLimitLogs.implicitGlobal[frontendName]
+	delete(rateLimitLogs.implicitGlobal, frontendName)
+	logTargets, err := client.LogTargetsGet("frontend", frontendName)
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(slices.Clone(logTargets), func(logTarget *models.LogTarget) bool {
+		return logTarget.Profile == RateLimitLogProfile
+	})
+	if len(kept) == len(logTargets) {
+		return nil
+	}
+	if implicitGlobal && len(kept) == 1 && kept[0].Global {
+		kept = models.LogTargets{}
+	}
+	return client.LogTargetsReplace("frontend", frontendName, kept)
+}
+
+// deniedStickTable returns the definition of the RateLimitDeniedTable.
+// Entries don't expire so the counters keep increasing, like Prometheus counters.
+func deniedStickTable() *models.ConfigStickTable {
+	return &models.ConfigStickTable{
+		Peers:  LocalPeers,
+		Type:   "string",
+		Keylen: utils.PtrInt64(deniedTableKeyLen),
+		Size:   utils.PtrInt64(deniedTableSize),
+		Store:  "http_req_cnt",
+	}
+}
+
+// quoteString returns s between double quotes, escaping the characters
+// HAProxy interprets in a double-quoted string.
+func quoteString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + replacer.Replace(s) + `"`
+}
 
-	httpRule := models.HTTPRequestRule{
+func (r ReqRateLimit) denyRule(condTest string) models.HTTPRequestRule {
+	return models.HTTPRequestRule{
 		Type:       "deny",
 		DenyStatus: utils.PtrInt64(r.DenyStatusCode),
 		Cond:       "if",
 		CondTest:   condTest,
 	}
-	return client.FrontendHTTPRequestRuleCreate(0, frontend.Name, httpRule, ingressACL)
 }
 
 func (r *ReqRateLimit) applyDefaults() error {
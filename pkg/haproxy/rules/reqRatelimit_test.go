@@ -15,11 +15,16 @@
 package rules
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/client-native/v6/models"
 
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
 )
 
 // TestReqRateLimit_ConditionGeneration tests the HAProxy condition string generation for rate limiting.
@@ -29,6 +34,8 @@ import (
 // - With pattern files, the condition uses map file syntax: "{ rate_check } !{ src -f pattern_file }"
 // - With multiple pattern files, multiple conditions are generated: "!{ src -f pattern1 } !{ src -f pattern2 }"
 // - With mixed IPs and patterns, both syntaxes are combined correctly
+// - String renders the deny rule with the status code and the condition
+// - Create emits the same deny rule in the frontend
 // This test ensures the HAProxy ACL condition logic is correct for different whitelist scenarios.
 func TestReqRateLimit_ConditionGeneration(t *testing.T) {
 	tests := []struct {
@@ -65,6 +72,16 @@ func TestReqRateLimit_ConditionGeneration(t *testing.T) {
 			},
 			expectedCondTest: "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 192.168.1.1 10.0.0.0/8 172.16.0.0/12 }",
 		},
+		{
+			name: "rate limit with IPv4 and IPv6 addresses",
+			rateLimit: ReqRateLimit{
+				TableName:      "RateLimit-10000",
+				ReqsLimit:      100,
+				DenyStatusCode: 429,
+				WhitelistIPs:   []string{"10.0.0.0/8", "2001:db8::/64", "::1"},
+			},
+			expectedCondTest: "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 2001:db8::/64 ::1 }",
+		},
 		{
 			name: "rate limit with single pattern file",
 			rateLimit: ReqRateLimit{
@@ -85,6 +102,16 @@ func TestReqRateLimit_ConditionGeneration(t *testing.T) {
 			},
 			expectedCondTest: "{ sc0_http_req_rate(RateLimit-5000) gt 1200 } !{ src -f patterns/whitelist1 } !{ src -f patterns/whitelist2 }",
 		},
+		{
+			name: "rate limit with three pattern files",
+			rateLimit: ReqRateLimit{
+				TableName:      "RateLimit-5000",
+				ReqsLimit:      1200,
+				DenyStatusCode: 429,
+				WhitelistMaps:  []maps.Path{"patterns/monitoring", "patterns/partners", "patterns/internal"},
+			},
+			expectedCondTest: "{ sc0_http_req_rate(RateLimit-5000) gt 1200 } !{ src -f patterns/monitoring } !{ src -f patterns/partners } !{ src -f patterns/internal }",
+		},
 		{
 			name: "rate limit with mixed IPs and patterns",
 			rateLimit: ReqRateLimit{
@@ -100,10 +127,11 @@ func TestReqRateLimit_ConditionGeneration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Since we can't easily test Create without a full mock, we'll validate the struct fields
-			assert.Equal(t, tt.rateLimit.TableName, tt.rateLimit.TableName)
-			assert.Equal(t, tt.rateLimit.ReqsLimit, tt.rateLimit.ReqsLimit)
-			assert.Equal(t, tt.rateLimit.DenyStatusCode, tt.rateLimit.DenyStatusCode)
+			// Create emits the deny rule of the condition
+			assert.Equal(t, []string{
+				"frontend http",
+				fmt.Sprintf("  http-request deny deny_status %d if %s", tt.rateLimit.DenyStatusCode, tt.expectedCondTest),
+			}, renderRules(t, tt.rateLimit))
 
 			// Validate whitelist IPs/CIDRs are set correctly
 			if len(tt.rateLimit.WhitelistIPs) > 0 {
@@ -120,6 +148,9 @@ func TestReqRateLimit_ConditionGeneration(t *testing.T) {
 					assert.Contains(t, tt.expectedCondTest, string(mapPath))
 				}
 			}
+
+			assert.Equal(t, tt.expectedCondTest, tt.rateLimit.condTest())
+			assert.Equal(t, fmt.Sprintf("http-request deny deny_status %d if %s", tt.rateLimit.DenyStatusCode, tt.expectedCondTest), tt.rateLimit.String())
 		})
 	}
 }
@@ -206,3 +237,958 @@ func TestReqRateLimit_WhitelistFields(t *testing.T) {
 		})
 	}
 }
+
+// TestReqRateLimit_BlacklistConditions tests the HAProxy conditions generated for the blacklist.
+// It validates that:
+// - Without a blacklist, no unconditional deny condition is generated
+// - IPs/CIDRs are grouped in a single condition: "{ src ip1 cidr1 }"
+// - Each pattern file gets its own condition: "{ src -f pattern_file }"
+// - The rate check condition is not affected by the blacklist
+func TestReqRateLimit_BlacklistConditions(t *testing.T) {
+	tests := []struct {
+		name              string
+		rateLimit         ReqRateLimit
+		expectedCondTests []string
+	}{
+		{
+			name: "no blacklist",
+			rateLimit: ReqRateLimit{
+				TableName: "RateLimit-10000",
+				ReqsLimit: 100,
+			},
+		},
+		{
+			name: "blacklist with IPs and CIDRs",
+			rateLimit: ReqRateLimit{
+				TableName:    "RateLimit-10000",
+				ReqsLimit:    100,
+				BlacklistIPs: []string{"192.168.1.1", "10.0.0.0/8"},
+			},
+			expectedCondTests: []string{"{ src 192.168.1.1 10.0.0.0/8 }"},
+		},
+		{
+			name: "blacklist with IPs and pattern files",
+			rateLimit: ReqRateLimit{
+				TableName:     "RateLimit-10000",
+				ReqsLimit:     100,
+				BlacklistIPs:  []string{"192.168.1.1"},
+				BlacklistMaps: []maps.Path{"patterns/blacklist1", "patterns/blacklist2"},
+			},
+			expectedCondTests: []string{
+				"{ src 192.168.1.1 }",
+				"{ src -f patterns/blacklist1 }",
+				"{ src -f patterns/blacklist2 }",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedCondTests, tt.rateLimit.blacklistCondTests())
+			assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 }", tt.rateLimit.condTest())
+		})
+	}
+}
+
+// TestReqRateLimit_PathCondition tests the rate limit condition scoped to path prefixes.
+// It validates that:
+// - A single path prefix adds a "{ path_beg /api }" term ANDed with the rate check
+// - Multiple path prefixes are grouped in the same term
+// - The whitelist exclusion is still appended after the path term
+func TestReqRateLimit_PathCondition(t *testing.T) {
+	tests := []struct {
+		name             string
+		rateLimit        ReqRateLimit
+		expectedCondTest string
+	}{
+		{
+			name: "single path prefix",
+			rateLimit: ReqRateLimit{
+				TableName:    "RateLimit-10000",
+				ReqsLimit:    100,
+				PathPrefixes: []string{"/api"},
+			},
+			expectedCondTest: "{ sc0_http_req_rate(RateLimit-10000) gt 100 } { path_beg /api }",
+		},
+		{
+			name: "multiple path prefixes with whitelist",
+			rateLimit: ReqRateLimit{
+				TableName:    "RateLimit-10000",
+				ReqsLimit:    100,
+				PathPrefixes: []string{"/api", "/v2/api"},
+				WhitelistIPs: []string{"10.0.0.0/8"},
+			},
+			expectedCondTest: "{ sc0_http_req_rate(RateLimit-10000) gt 100 } { path_beg /api /v2/api } !{ src 10.0.0.0/8 }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedCondTest, tt.rateLimit.condTest())
+		})
+	}
+}
+
+// TestReqRateLimit_ExemptMethodsCondition tests the rate limit condition excluding HTTP methods.
+// It validates that:
+// - Exempt methods add a "!{ method GET HEAD }" term ANDed with the rate check
+// - The term follows the path term and precedes the whitelist exclusion
+func TestReqRateLimit_ExemptMethodsCondition(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-10000", ReqsLimit: 100, ExemptMethods: []string{"GET", "HEAD"}}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ method GET HEAD }", r.condTest())
+
+	r.PathPrefixes = []string{"/api"}
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 } { path_beg /api } !{ method GET HEAD } !{ src 10.0.0.0/8 }", r.condTest())
+}
+
+// TestReqRateLimit_RetryAfterHeader tests the Retry-After header of the generated deny rule.
+// It validates that:
+// - No header is added when RetryAfter is not set
+// - A "Retry-After" header with the delay in seconds is added to the deny rule
+// - Blacklist deny rules don't get the header since waiting doesn't lift the deny
+func TestReqRateLimit_RetryAfterHeader(t *testing.T) {
+	r := ReqRateLimit{
+		TableName:      "RateLimit-10000",
+		ReqsLimit:      100,
+		DenyStatusCode: 429,
+	}
+	assert.Empty(t, r.rateLimitRule().ReturnHeaders)
+
+	r.RetryAfter = 10
+	httpRule := r.rateLimitRule()
+	assert.Equal(t, "deny", httpRule.Type)
+	assert.Equal(t, int64(429), *httpRule.DenyStatus)
+	if assert.Len(t, httpRule.ReturnHeaders, 1) {
+		assert.Equal(t, "Retry-After", *httpRule.ReturnHeaders[0].Name)
+		assert.Equal(t, "10", *httpRule.ReturnHeaders[0].Fmt)
+	}
+	assert.Empty(t, r.denyRule("{ src 10.0.0.1 }").ReturnHeaders)
+}
+
+// TestReqRateLimit_Action tests the action of the generated rate limit rule.
+// It validates that:
+// - The default and "deny" actions generate an "http-request deny" rule
+// - The "tarpit" action generates an "http-request tarpit" rule keeping the deny status and condition
+// - Blacklist rules always deny, whatever the rate limit action
+func TestReqRateLimit_Action(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		wantType string
+	}{
+		{name: "default action", action: "", wantType: "deny"},
+		{name: "deny action", action: RateLimitActionDeny, wantType: "deny"},
+		{name: "tarpit action", action: RateLimitActionTarpit, wantType: "tarpit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ReqRateLimit{
+				TableName:      "RateLimit-10000",
+				ReqsLimit:      100,
+				DenyStatusCode: 429,
+				Action:         tt.action,
+			}
+			httpRule := r.rateLimitRule()
+			assert.Equal(t, tt.wantType, httpRule.Type)
+			assert.Equal(t, int64(429), *httpRule.DenyStatus)
+			assert.Equal(t, "if", httpRule.Cond)
+			assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 }", httpRule.CondTest)
+			assert.Equal(t, "deny", r.denyRule("{ src 10.0.0.1 }").Type)
+		})
+	}
+}
+
+// TestReqRateLimit_StickCounterCondition tests that the rate check uses the stick counter of the rule.
+// It validates that:
+// - The default stick counter is sc0
+// - A rule of another tier or slot uses its own stick counter, e.g. sc1_http_req_rate or sc2_http_req_rate
+func TestReqRateLimit_StickCounterCondition(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 20}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 20 }", r.condTest())
+
+	r = ReqRateLimit{TableName: "RateLimit-60000", ReqsLimit: 1000, StickCounter: 1}
+	assert.Equal(t, "{ sc1_http_req_rate(RateLimit-60000) gt 1000 }", r.condTest())
+
+	r = ReqRateLimit{TableName: "RateLimit-60000", ReqsLimit: 1000, StickCounter: 2}
+	assert.Equal(t, "{ sc2_http_req_rate(RateLimit-60000) gt 1000 }", r.condTest())
+}
+
+// TestReqRateLimit_ConnCurCondition tests the condition of a concurrent connections limit.
+// It validates that:
+// - The conn_cur counter of the rule stick counter is compared to the limit
+// - Whitelisted sources are excluded from the connections limit
+func TestReqRateLimit_ConnCurCondition(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimitConn", ReqsLimit: 10, Counter: RateLimitCounterConnCur}
+	assert.Equal(t, "{ sc0_conn_cur(RateLimitConn) gt 10 }", r.condTest())
+
+	r = ReqRateLimit{
+		TableName:     "RateLimit-1000-conn",
+		ReqsLimit:     10,
+		StickCounter:  1,
+		Counter:       RateLimitCounterConnCur,
+		WhitelistIPs:  []string{"10.0.0.0/8"},
+		WhitelistMaps: []maps.Path{"patterns/trusted"},
+	}
+	assert.Equal(t, "{ sc1_conn_cur(RateLimit-1000-conn) gt 10 } !{ src 10.0.0.0/8 } !{ src -f patterns/trusted }", r.condTest())
+}
+
+// TestReqRateLimit_BytesRateCondition tests the conditions of bandwidth limits.
+// It validates that:
+// - The bytes_in_rate and bytes_out_rate counters of the rule stick counter are compared to the limit
+// - Whitelisted sources are excluded from the bandwidth limits
+func TestReqRateLimit_BytesRateCondition(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimitBytesIn", ReqsLimit: 1048576, Counter: RateLimitCounterBytesInRate}
+	assert.Equal(t, "{ sc0_bytes_in_rate(RateLimitBytesIn) gt 1048576 }", r.condTest())
+
+	r = ReqRateLimit{
+		TableName:     "RateLimit-60000-bytes-out",
+		ReqsLimit:     52428800,
+		StickCounter:  2,
+		Counter:       RateLimitCounterBytesOutRate,
+		WhitelistIPs:  []string{"10.0.0.0/8"},
+		WhitelistMaps: []maps.Path{"patterns/trusted"},
+	}
+	assert.Equal(t, "{ sc2_bytes_out_rate(RateLimit-60000-bytes-out) gt 52428800 } !{ src 10.0.0.0/8 } !{ src -f patterns/trusted }", r.condTest())
+}
+
+// TestReqRateLimit_DenyMessage tests the body of the generated rate limit rule.
+// It validates that:
+// - Without message, no content is set so HAProxy returns the errorfile of the status code
+// - The message is sent as a quoted text/plain string
+// - Double quotes, backslashes and dollar signs of the message are escaped
+func TestReqRateLimit_DenyMessage(t *testing.T) {
+	rule := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429}.rateLimitRule()
+	assert.Empty(t, rule.ReturnContent)
+	assert.Empty(t, rule.ReturnContentFormat)
+	assert.Nil(t, rule.ReturnContentType)
+
+	rule = ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, DenyMessage: "Too many requests, slow down"}.rateLimitRule()
+	require.NotNil(t, rule.ReturnContentType)
+	assert.Equal(t, "text/plain", *rule.ReturnContentType)
+	assert.Equal(t, "string", rule.ReturnContentFormat)
+	assert.Equal(t, `"Too many requests, slow down"`, rule.ReturnContent)
+	assert.Equal(t, int64(429), *rule.DenyStatus)
+
+	rule = ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyMessage: `Quota of "$PLAN" plan exceeded \o/`}.rateLimitRule()
+	assert.Equal(t, `"Quota of \"\$PLAN\" plan exceeded \\o/"`, rule.ReturnContent)
+}
+
+// TestReqRateLimit_Geo tests the conditions restricting the rate limit to the countries of the sources.
+// It validates that:
+// - Exempt countries are excluded from the deny rule, and target countries are the only ones denied
+// - The countries are looked up in the GeoMap by source address
+// - No country condition is added without GeoMap or countries
+func TestReqRateLimit_Geo(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, GeoMap: "patterns/geo.map", GeoExemptCountries: []string{"US", "FR"}}
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src,map_ip(patterns/geo.map) -m str US FR }",
+	}, renderRules(t, r))
+
+	r.GeoExemptCountries = nil
+	r.GeoTargetCountries = []string{"CN"}
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } { src,map_ip(patterns/geo.map) -m str CN } !{ src 10.0.0.0/8 }", r.condTest())
+
+	r.GeoTargetCountries = nil
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 }", r.condTest())
+	r.GeoMap = ""
+	r.GeoExemptCountries = []string{"US"}
+	assert.Empty(t, r.geoCondTest())
+}
+
+// TestReqRateLimit_ID tests the identifier of the rate limit rules.
+// It validates that:
+// - The id is appended to the debug output of the limit and track rules
+// - The id doesn't change the rendered rules nor their RuleID, so tables shared with other ids are tracked once
+func TestReqRateLimit_ID(t *testing.T) {
+	track := ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TableSize: utils.PtrInt64(1000), TrackKey: "src"}
+	limit := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429}
+	trackID, limitID := GetID(track), GetID(limit)
+	rendered := renderRules(t, track, limit)
+
+	track.ID, limit.ID = "checkout-api", "checkout-api"
+	assert.Equal(t, "http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } # rate-limit-id checkout-api", limit.String())
+	assert.Equal(t, "http-request track-sc0 src table RateLimit-1000 # rate-limit-id checkout-api", track.String())
+	assert.Equal(t, trackID, GetID(track))
+	assert.Equal(t, limitID, GetID(limit))
+	assert.Equal(t, rendered, renderRules(t, track, limit))
+}
+
+// TestReqRateLimit_ErrorFile tests the deny rule serving an errorfile.
+// It validates that:
+// - The errorfile is set as the content of the deny response, with the status code of the rate limit
+// - The errorfile is also served by tarpit
+func TestReqRateLimit_ErrorFile(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, ErrorFile: "errorfiles/429"}
+	rule := r.rateLimitRule()
+	assert.Equal(t, "errorfile", rule.ReturnContentFormat)
+	assert.Equal(t, "errorfiles/429", rule.ReturnContent)
+	assert.Nil(t, rule.ReturnContentType)
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 errorfile errorfiles/429 if { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+	}, renderRules(t, r))
+
+	r.Action = RateLimitActionTarpit
+	rule = r.rateLimitRule()
+	assert.Equal(t, RateLimitActionTarpit, rule.Type)
+	assert.Equal(t, "errorfiles/429", rule.ReturnContent)
+}
+
+// TestReqRateLimit_DeniedCounter tests the configuration counting denied requests.
+// It validates that:
+// - Requests matching the rate limit condition are tracked with the DeniedKey in the RateLimitDeniedTable
+// - The tracking uses its own stick counter, not the one of the rate limit
+// - The table stores http_req_cnt for string keys long enough for a namespace and an ingress name
+func TestReqRateLimit_DeniedCounter(t *testing.T) {
+	r := ReqRateLimit{
+		TableName:          "RateLimit-1000",
+		ReqsLimit:          10,
+		WhitelistIPs:       []string{"10.0.0.0/8"},
+		DeniedKey:          "default/api",
+		DeniedStickCounter: 1,
+	}
+	rule := r.deniedTrackRule()
+	assert.Equal(t, "track-sc", rule.Type)
+	require.NotNil(t, rule.TrackScStickCounter)
+	assert.Equal(t, int64(1), *rule.TrackScStickCounter)
+	assert.Equal(t, "str(default/api)", rule.TrackScKey)
+	assert.Equal(t, RateLimitDeniedTable, rule.TrackScTable)
+	assert.Equal(t, "if", rule.Cond)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 }", rule.CondTest)
+	assert.Equal(t, r.rateLimitRule().CondTest, rule.CondTest)
+
+	table := deniedStickTable()
+	assert.Equal(t, "string", table.Type)
+	assert.Equal(t, "http_req_cnt", table.Store)
+	assert.Equal(t, "localinstance", table.Peers)
+	assert.Nil(t, table.Expire)
+	require.NotNil(t, table.Keylen)
+	assert.GreaterOrEqual(t, *table.Keylen, int64(63+1+253))
+}
+
+// TestReqRateLimit_LogTag tests the tagging of denied requests in the log.
+// It validates that:
+// - Without LogTag, no capture rule is created
+// - With LogTag, the tag and the table name are captured under the rate limit condition only
+// - The capture is created after the deny rule, so it is evaluated before it
+func TestReqRateLimit_LogTag(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, WhitelistIPs: []string{"10.0.0.0/8"}}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 1)
+	assert.Equal(t, "deny", client.rules[0].Type)
+
+	r.LogTag = "audit"
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 2)
+	assert.Equal(t, "deny", client.rules[0].Type)
+	rule := client.rules[1]
+	assert.Equal(t, "capture", rule.Type)
+	assert.Equal(t, "str(audit:RateLimit-1000)", rule.CaptureSample)
+	assert.Equal(t, int64(len("audit:RateLimit-1000")), rule.CaptureLen)
+	assert.Equal(t, "if", rule.Cond)
+	assert.Equal(t, client.rules[0].CondTest, rule.CondTest)
+}
+
+// TestReqRateLimit_LogTarget tests the logging of denied requests to a dedicated log target.
+// It validates that:
+// - The frontend logs to the target with the log profile, after the global log targets it inherited
+// - The log profile only emits the logs of the do-log rule, evaluated after the LogTag capture
+// - Rate limits logging to the same target share its log line
+// - RefreshRules removes the log targets of the rate limits deleted, with the global one it added
+// - A global log target of the frontend is kept, and the log profile is removed once no rate limit uses it
+// - Rate limits placed in backends don't log to a log target
+func TestReqRateLimit_LogTarget(t *testing.T) {
+	limit := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, LogTag: "audit", LogTarget: "10.0.0.5:514"}
+	lines := renderRules(t, limit, ReqRateLimit{TableName: "RateLimit-60000", ReqsLimit: 100, DenyStatusCode: 429, LogTarget: "10.0.0.5:514"})
+	assert.Equal(t, []string{
+		"log-profile ratelimit-deny",
+		"  on any drop",
+		"  on http-req format '%ci:%cp [%tr] %ft %hr %{+Q}r'",
+		"frontend http",
+		"  log global",
+		"  log 10.0.0.5:514 local0 profile ratelimit-deny",
+		"  http-request capture str(audit:RateLimit-1000) len 20 if { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+		"  http-request do-log if { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 }",
+		"  http-request do-log if { sc0_http_req_rate(RateLimit-60000) gt 100 }",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-60000) gt 100 }",
+	}, lines)
+
+	sectionRules := SectionRules{}
+	require.NoError(t, sectionRules.AddRule("http", limit, false))
+	client := &ruleRecorder{logTargets: models.LogTargets{{Address: "10.0.0.1:514", Facility: "local1"}}}
+	sectionRules.RefreshRules(client)
+	assert.Equal(t, models.LogTargets{
+		{Address: "10.0.0.1:514", Facility: "local1"},
+		{Address: "10.0.0.5:514", Facility: "local0", Profile: RateLimitLogProfile},
+	}, client.logTargets)
+	sectionRules.CleanRules()
+	sectionRules.RefreshRules(client)
+	assert.Equal(t, models.LogTargets{{Address: "10.0.0.1:514", Facility: "local1"}}, client.logTargets)
+
+	client = &ruleRecorder{}
+	sectionRules = SectionRules{}
+	require.NoError(t, sectionRules.AddRule("http", limit, false))
+	sectionRules.RefreshRules(client)
+	require.Len(t, client.logTargets, 2)
+	sectionRules.CleanRules()
+	sectionRules.RefreshRules(client)
+	assert.Empty(t, client.logTargets)
+	assert.Empty(t, client.logProfiles)
+
+	client = &ruleRecorder{logTargets: models.LogTargets{{Global: true}}}
+	sectionRules = SectionRules{}
+	require.NoError(t, sectionRules.AddRule("http", limit, false))
+	sectionRules.RefreshRules(client)
+	require.Len(t, client.logTargets, 2)
+	require.Len(t, client.logProfiles, 1)
+	sectionRules.CleanRules()
+	sectionRules.RefreshRules(client)
+	assert.Equal(t, models.LogTargets{{Global: true}}, client.logTargets)
+	assert.Empty(t, client.logProfiles)
+
+	limit.Backend = true
+	client = &ruleRecorder{}
+	require.NoError(t, limit.Create(client, &models.Frontend{FrontendBase: models.FrontendBase{Name: "RateLimit-1000", Mode: "http"}}, ""))
+	assert.Empty(t, client.logTargets)
+	assert.Empty(t, client.logProfiles)
+	assert.NotContains(t, client.lines("RateLimit-1000"), "  http-request do-log if { sc0_http_req_rate(RateLimit-1000) gt 10 }")
+}
+
+// TestReqRateLimit_Headers tests the response headers reporting the rate limit.
+// It validates that:
+// - Without Headers, no response rule is created
+// - The limit and the remaining requests are set while the rate doesn't exceed the limit
+// - HeadersThreshold and the whitelist restrict the responses getting the headers
+func TestReqRateLimit_Headers(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 100, StickCounter: 1}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	assert.Empty(t, client.responseRules)
+
+	r.Headers = true
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.responseRules, 2)
+	limit, remaining := client.responseRules[0], client.responseRules[1]
+	assert.Equal(t, "set-header", limit.Type)
+	assert.Equal(t, RateLimitLimitHeader, limit.HdrName)
+	assert.Equal(t, "100", limit.HdrFormat)
+	assert.Equal(t, "set-header", remaining.Type)
+	assert.Equal(t, RateLimitRemainingHeader, remaining.HdrName)
+	assert.Equal(t, "%[sc1_http_req_rate(RateLimit-1000),neg,add(100)]", remaining.HdrFormat)
+	for _, rule := range client.responseRules {
+		assert.Equal(t, "if", rule.Cond)
+		assert.Equal(t, "{ sc1_http_req_rate(RateLimit-1000) le 100 }", rule.CondTest)
+	}
+
+	r.HeadersThreshold = 80
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.responseRules, 2)
+	for _, rule := range client.responseRules {
+		assert.Equal(t, "{ sc1_http_req_rate(RateLimit-1000) ge 80 } { sc1_http_req_rate(RateLimit-1000) le 100 } !{ src 10.0.0.0/8 }", rule.CondTest)
+	}
+}
+
+// TestReqRateLimit_AdmitPercentage tests the admission of a percentage of the requests exceeding the limit.
+// It validates that:
+// - Without AdmitPercentage, no random number is drawn and every request exceeding the limit is denied
+// - The condition only denies requests whose random number is at least the percentage
+// - The random number is drawn once, by a rule created last so it is evaluated before the rules using it
+// - A tier whose random number is drawn by the first tier doesn't draw another one
+func TestReqRateLimit_AdmitPercentage(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, LogTag: "canary"}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 2)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 }", client.rules[0].CondTest)
+
+	r.AdmitPercentage = 25
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 3)
+	deny, capture, rand := client.rules[0], client.rules[1], client.rules[2]
+	assert.Equal(t, "deny", deny.Type)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } { var(txn.ratelimit_rand) ge 25 }", deny.CondTest)
+	assert.Equal(t, deny.CondTest, capture.CondTest)
+	assert.Equal(t, "set-var", rand.Type)
+	assert.Equal(t, "txn", rand.VarScope)
+	assert.Equal(t, "ratelimit_rand", rand.VarName)
+	assert.Equal(t, "rand(100)", rand.VarExpr)
+	assert.Empty(t, rand.CondTest)
+
+	r.RandDrawn = true
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 2)
+	assert.Equal(t, deny.CondTest, client.rules[0].CondTest)
+}
+
+// TestReqRateLimit_SilentDrop tests the rule of the silent-drop action.
+// It validates that:
+// - A silent-drop rule is generated with the rate limit condition
+// - No status, body or header is set, as no response is sent
+func TestReqRateLimit_SilentDrop(t *testing.T) {
+	r := ReqRateLimit{
+		TableName:      "RateLimit-10000",
+		ReqsLimit:      100,
+		DenyStatusCode: 429,
+		Action:         RateLimitActionSilentDrop,
+	}
+	httpRule := r.rateLimitRule()
+	assert.Equal(t, "silent-drop", httpRule.Type)
+	assert.Equal(t, "if", httpRule.Cond)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 }", httpRule.CondTest)
+	assert.Nil(t, httpRule.DenyStatus)
+	assert.Empty(t, httpRule.ReturnContent)
+	assert.Empty(t, httpRule.ReturnHeaders)
+}
+
+// TestReqRateLimit_Redirect tests the rule redirecting requests exceeding the limit.
+// It validates that:
+// - A 302 redirect to the location is generated instead of the deny rule
+// - Requests for a path location of the same site are not redirected again
+// - '%' of the location is escaped, as the location is a log-format string
+func TestReqRateLimit_Redirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     string
+	}{
+		{
+			name:     "path",
+			location: "/slow-down?from=api",
+			want:     "http-request redirect location /slow-down?from=api code 302 if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ path /slow-down }",
+		},
+		{
+			name:     "url",
+			location: "https://status.example.com/slow-down",
+			want:     "http-request redirect location https://status.example.com/slow-down code 302 if { sc0_http_req_rate(RateLimit-10000) gt 100 }",
+		},
+		{
+			name:     "escaped percent",
+			location: "/slow%20down",
+			want:     "http-request redirect location /slow%%20down code 302 if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ path /slow%20down }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ReqRateLimit{
+				TableName:        "RateLimit-10000",
+				ReqsLimit:        100,
+				DenyStatusCode:   429,
+				RedirectLocation: tt.location,
+			}
+			assert.Equal(t, tt.want, r.String())
+			httpRule := r.rateLimitRule()
+			assert.Nil(t, httpRule.DenyStatus)
+			assert.Empty(t, httpRule.ReturnHeaders)
+		})
+	}
+}
+
+// TestReqRateLimit_WhitelistHeaderCondition tests the exemption of requests by header value.
+// It validates that:
+// - A single accepted value adds a "!{ req.hdr(X-Internal) -m str trusted }" exclusion
+// - A pattern file of accepted values is matched with -f
+// - The header exclusion follows the source whitelist, and is left out of response rules
+func TestReqRateLimit_WhitelistHeaderCondition(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, WhitelistHeader: "X-Internal", WhitelistHeaderValue: "trusted"}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ req.hdr(X-Internal) -m str trusted }", r.condTest())
+
+	r.WhitelistHeaderValue = ""
+	r.WhitelistHeaderMap = "patterns/internal-tokens"
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 } !{ req.hdr(X-Internal) -m str -f patterns/internal-tokens }", r.condTest())
+
+	r.Headers = true
+	for _, rule := range r.headerRules() {
+		assert.NotContains(t, rule.CondTest, "req.hdr")
+	}
+}
+
+// TestReqRateLimit_ExemptUserAgents tests the exemption of requests by User-Agent.
+// It validates that:
+// - Substrings are matched with "-m sub" in a single exclusion, and pattern files with -f
+// - The exclusions follow the source whitelist, and are left out of response rules
+func TestReqRateLimit_ExemptUserAgents(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, ExemptUserAgents: []string{"kube-probe"}}
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } !{ req.hdr(User-Agent) -m sub kube-probe }",
+	}, renderRules(t, r))
+
+	r.ExemptUserAgents = []string{"kube-probe", "Pingdom"}
+	r.ExemptUserAgentMaps = []maps.Path{"patterns/monitors"}
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 } !{ req.hdr(User-Agent) -m sub kube-probe Pingdom } !{ req.hdr(User-Agent) -m sub -f patterns/monitors }", r.condTest())
+
+	r.ExemptUserAgents = nil
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 } !{ req.hdr(User-Agent) -m sub -f patterns/monitors }", r.condTest())
+
+	r.Headers = true
+	for _, rule := range r.headerRules() {
+		assert.NotContains(t, rule.CondTest, "User-Agent")
+	}
+}
+
+// TestReqRateLimit_MaxStreams tests the limit of concurrent streams of HTTP/2 and HTTP/3 connections.
+// It validates that:
+// - Without MaxStreams, only the request rate is limited
+// - The streams condition only matches HTTP/2 and HTTP/3 connections, and excludes the whitelist
+// - The rule applies the action of the rate limit
+func TestReqRateLimit_MaxStreams(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 100, DenyStatusCode: 429}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 1)
+
+	r.MaxStreams = 50
+	r.WhitelistIPs = []string{"10.0.0.0/8"}
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 2)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 100 } !{ src 10.0.0.0/8 }", client.rules[0].CondTest)
+	streams := client.rules[1]
+	assert.Equal(t, "deny", streams.Type)
+	assert.Equal(t, int64(429), *streams.DenyStatus)
+	assert.Equal(t, "if", streams.Cond)
+	assert.Equal(t, "{ fc_http_major ge 2 } { fc_nb_streams gt 50 } !{ src 10.0.0.0/8 }", streams.CondTest)
+
+	r.Action = RateLimitActionSilentDrop
+	assert.Equal(t, "silent-drop", r.actionRule(r.streamsCondTest()).Type)
+}
+
+// TestReqRateLimit_BlacklistStatusCode tests the status codes of the deny rules.
+// It validates that:
+// - Blacklisted sources are denied with the BlacklistStatusCode, rate-exceeded clients with the DenyStatusCode
+// - Without BlacklistStatusCode, both are denied with the DenyStatusCode
+func TestReqRateLimit_BlacklistStatusCode(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{
+		TableName:           "RateLimit-1000",
+		ReqsLimit:           100,
+		DenyStatusCode:      429,
+		BlacklistIPs:        []string{"192.168.1.1"},
+		BlacklistMaps:       []maps.Path{"patterns/banned"},
+		BlacklistStatusCode: 403,
+	}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 3)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-1000) gt 100 }", client.rules[0].CondTest)
+	assert.Equal(t, int64(429), *client.rules[0].DenyStatus)
+	for _, rule := range client.rules[1:] {
+		assert.Equal(t, "deny", rule.Type)
+		assert.Equal(t, int64(403), *rule.DenyStatus)
+	}
+
+	r.BlacklistStatusCode = 0
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	for _, rule := range client.rules {
+		assert.Equal(t, int64(429), *rule.DenyStatus)
+	}
+}
+
+// TestReqRateLimit_NoLimit tests the rules of a rate limit without ReqsLimit, like an auto one
+// before a peak rate is observed.
+// It validates that:
+// - No rule depends on the request rate, nor draws the random number or flags clients
+// - Blacklisted sources, requests without the tracked key and connections with too many streams are still denied
+// - TCP frontends still reject blacklisted sources only
+func TestReqRateLimit_NoLimit(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}
+	r := ReqRateLimit{
+		TableName:       "RateLimit-1000",
+		DenyStatusCode:  429,
+		BlacklistIPs:    []string{"192.0.2.1"},
+		MissingKey:      "req.hdr(X-Api-Key)",
+		MaxStreams:      50,
+		LogTag:          "auto",
+		Headers:         true,
+		AdmitPercentage: 10,
+		LowWatermark:    5,
+	}
+
+	client := &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.rules, 3)
+	assert.Equal(t, "{ src 192.0.2.1 }", client.rules[0].CondTest)
+	assert.Equal(t, "!{ req.hdr(X-Api-Key) -m found }", client.rules[1].CondTest)
+	assert.Equal(t, "{ fc_http_major ge 2 } { fc_nb_streams gt 50 }", client.rules[2].CondTest)
+	for _, rule := range client.rules {
+		assert.Equal(t, "deny", rule.Type)
+	}
+	assert.Empty(t, client.responseRules)
+
+	frontend = &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-5432", Mode: "tcp"}}
+	client = &ruleRecorder{}
+	require.NoError(t, r.Create(client, frontend, ""))
+	require.Len(t, client.tcpRules, 1)
+	assert.Equal(t, "{ src 192.0.2.1 }", client.tcpRules[0].CondTest)
+}
+
+// TestReqRateLimit_ListPrecedence tests the precedence of the whitelist over the blacklist.
+// It validates that:
+// - By default, and with the blacklist precedence, a source in both lists is denied
+// - With the whitelist precedence, whitelisted sources, by address or pattern file, are not denied by the blacklist
+// - In TCP mode, whitelisted addresses and ports also spare blacklisted sources with the whitelist precedence
+func TestReqRateLimit_ListPrecedence(t *testing.T) {
+	limit := ReqRateLimit{
+		TableName:      "RateLimit-1000",
+		ReqsLimit:      10,
+		DenyStatusCode: 429,
+		WhitelistIPs:   []string{"192.0.2.1"},
+		WhitelistMaps:  []maps.Path{"patterns/partners"},
+		BlacklistIPs:   []string{"192.0.2.1", "198.51.100.0/24"},
+	}
+	for _, precedence := range []string{"", RateLimitListPrecedenceBlacklist} {
+		limit.ListPrecedence = precedence
+		assert.Equal(t, []string{
+			"frontend http",
+			"  http-request deny deny_status 429 if { src 192.0.2.1 198.51.100.0/24 }",
+			"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 192.0.2.1 } !{ src -f patterns/partners }",
+		}, renderRules(t, limit), precedence)
+	}
+
+	limit.ListPrecedence = RateLimitListPrecedenceWhitelist
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { src 192.0.2.1 198.51.100.0/24 } !{ src 192.0.2.1 } !{ src -f patterns/partners }",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 192.0.2.1 } !{ src -f patterns/partners }",
+	}, renderRules(t, limit))
+
+	limit.WhitelistMaps = nil
+	limit.WhitelistAddrPorts = []string{"198.51.100.7:5000"}
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-5432", Mode: "tcp"}}
+	assert.Equal(t, []string{
+		"frontend tcp-5432",
+		"  tcp-request connection set-var-fmt(sess.ratelimit_src) %[src]:%[src_port]",
+		"  tcp-request connection reject if { src 192.0.2.1 198.51.100.0/24 } !{ src 192.0.2.1 } !{ var(sess.ratelimit_src) -m str 198.51.100.7:5000 }",
+		"  tcp-request connection reject if { sc0_conn_rate(RateLimit-1000-tcp) gt 10 } !{ src 192.0.2.1 } !{ var(sess.ratelimit_src) -m str 198.51.100.7:5000 }",
+	}, renderFrontendRules(t, frontend, limit))
+}
+
+// TestReqRateLimit_Only tests restricting the rate limit to the listed sources.
+// It validates that:
+// - The limit only applies to sources matching the map, ANDed with the rate check
+// - Sources listed inline are matched in a single condition
+// - Whitelisted sources are still excluded, and blacklisted ones denied whatever the list
+// - TCP connections and rate limit headers are restricted the same way
+func TestReqRateLimit_Only(t *testing.T) {
+	limit := ReqRateLimit{
+		TableName:      "RateLimit-1000",
+		ReqsLimit:      10,
+		DenyStatusCode: 429,
+		OnlyMap:        "/etc/haproxy/maps/ratelimit-only.map",
+	}
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } { src -f /etc/haproxy/maps/ratelimit-only.map }",
+	}, renderRules(t, limit))
+
+	limit.OnlyMap = ""
+	limit.OnlyIPs = []string{"198.51.100.0/24", "203.0.113.7"}
+	limit.WhitelistIPs = []string{"198.51.100.1"}
+	limit.BlacklistIPs = []string{"192.0.2.1"}
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { src 192.0.2.1 }",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } { src 198.51.100.0/24 203.0.113.7 } !{ src 198.51.100.1 }",
+	}, renderRules(t, limit))
+
+	limit.BlacklistIPs = nil
+	limit.Headers = true
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } { src 198.51.100.0/24 203.0.113.7 } !{ src 198.51.100.1 }",
+		"  http-response set-header X-RateLimit-Remaining %[sc0_http_req_rate(RateLimit-1000),neg,add(10)] if { sc0_http_req_rate(RateLimit-1000) le 10 } { src 198.51.100.0/24 203.0.113.7 } !{ src 198.51.100.1 }",
+		"  http-response set-header X-RateLimit-Limit 10 if { sc0_http_req_rate(RateLimit-1000) le 10 } { src 198.51.100.0/24 203.0.113.7 } !{ src 198.51.100.1 }",
+	}, renderRules(t, limit))
+
+	limit.Headers = false
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-5432", Mode: "tcp"}}
+	assert.Equal(t, []string{
+		"frontend tcp-5432",
+		"  tcp-request connection reject if { sc0_conn_rate(RateLimit-1000-tcp) gt 10 } { src 198.51.100.0/24 203.0.113.7 } !{ src 198.51.100.1 }",
+	}, renderFrontendRules(t, frontend, limit))
+}
+
+// TestReqRateLimit_String tests the rendering of the rule for troubleshooting.
+// It validates that:
+// - The default status code is rendered when none is set, without modifying the rule
+// - The action of the rate limit is rendered
+func TestReqRateLimit_String(t *testing.T) {
+	r := ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, StickCounter: 1}
+	assert.Equal(t, "http-request deny deny_status 403 if { sc1_http_req_rate(RateLimit-1000) gt 10 }", r.String())
+	assert.Zero(t, r.DenyStatusCode)
+
+	r.Action = RateLimitActionTarpit
+	assert.Equal(t, "http-request tarpit deny_status 403 if { sc1_http_req_rate(RateLimit-1000) gt 10 }", r.String())
+
+	r.Action = RateLimitActionSilentDrop
+	assert.Equal(t, "http-request silent-drop if { sc1_http_req_rate(RateLimit-1000) gt 10 }", r.String())
+}
+
+// TestReqRateLimit_TCP tests the rate limit of a TCP frontend.
+// It validates that:
+// - Connections are tracked by source in a table of their own, storing their rate
+// - Connections exceeding the limit are rejected with sc0_conn_rate, unless whitelisted
+// - Blacklisted sources are rejected first, and HTTP only settings don't apply
+// - Other keys than the source address are rejected
+func TestReqRateLimit_TCP(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-5432", Mode: "tcp"}}
+	track := &ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src", PathPrefixes: []string{"/api"}}
+	limit := &ReqRateLimit{
+		TableName:      "RateLimit-1000",
+		ReqsLimit:      20,
+		DenyStatusCode: 429,
+		WhitelistIPs:   []string{"10.0.0.0/8"},
+		BlacklistIPs:   []string{"192.0.2.1"},
+		PathPrefixes:   []string{"/api"},
+		Headers:        true,
+	}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-tcp",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store conn_rate(1000)",
+		"frontend tcp-5432",
+		"  tcp-request connection track-sc0 src table RateLimit-1000-tcp",
+		"  tcp-request connection reject if { src 192.0.2.1 }",
+		"  tcp-request connection reject if { sc0_conn_rate(RateLimit-1000-tcp) gt 20 } !{ src 10.0.0.0/8 }",
+	}, renderFrontendRules(t, frontend, track, limit))
+
+	// Concurrent connections are kept
+	track = &ReqTrack{TableName: "RateLimit-1000-conn", TablePeriod: utils.PtrInt64(1000), TrackKey: "src", StickCounter: 1, Counter: RateLimitCounterConnCur}
+	limit = &ReqRateLimit{TableName: "RateLimit-1000-conn", ReqsLimit: 5, StickCounter: 1, Counter: RateLimitCounterConnCur}
+	assert.Contains(t, renderFrontendRules(t, frontend, track, limit),
+		"  tcp-request connection reject if { sc1_conn_cur(RateLimit-1000-conn-tcp) gt 5 }")
+
+	track = &ReqTrack{TableName: "RateLimit-1000", TrackKey: "req.hdr(X-Api-Key)"}
+	assert.ErrorContains(t, track.Create(&ruleRecorder{}, frontend, ""), "TCP mode")
+}
+
+// TestReqRateLimit_WhitelistAddrPorts tests the whitelist of source address and port pairs.
+// It validates that:
+// - The source address and port of connections are set in a variable before being rejected
+// - Connections from a whitelisted address and port are not rejected, along with the other whitelists
+// - Ports can't be whitelisted in HTTP mode
+func TestReqRateLimit_WhitelistAddrPorts(t *testing.T) {
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-5432", Mode: "tcp"}}
+	track := &ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src"}
+	limit := &ReqRateLimit{
+		TableName:          "RateLimit-1000",
+		ReqsLimit:          20,
+		WhitelistIPs:       []string{"10.0.0.0/8"},
+		WhitelistAddrPorts: []string{"192.0.2.10:5000", "2001:db8::1:6000"},
+	}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-tcp",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store conn_rate(1000)",
+		"frontend tcp-5432",
+		"  tcp-request connection track-sc0 src table RateLimit-1000-tcp",
+		"  tcp-request connection set-var-fmt(sess.ratelimit_src) %[src]:%[src_port]",
+		"  tcp-request connection reject if { sc0_conn_rate(RateLimit-1000-tcp) gt 20 } !{ src 10.0.0.0/8 } !{ var(sess.ratelimit_src) -m str 192.0.2.10:5000 2001:db8::1:6000 }",
+	}, renderFrontendRules(t, frontend, track, limit))
+
+	assert.ErrorContains(t, limit.Create(&ruleRecorder{}, &models.Frontend{FrontendBase: models.FrontendBase{Name: "http", Mode: "http"}}, ""), "TCP mode")
+}
+
+// TestReqRateLimit_Burst tests the rate limit denying requests only while the client bursts.
+// It validates that:
+// - The burst table is tracked with its own stick counter and period
+// - Requests are denied when both the rate limit and the burst limit are exceeded
+// - Without burst limit, the condition is unchanged
+func TestReqRateLimit_Burst(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-60000", TablePeriod: utils.PtrInt64(60000), TrackKey: "src"}
+	burst := &ReqTrack{TableName: "RateLimit-60000-burst-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src", StickCounter: 1}
+	limit := &ReqRateLimit{TableName: "RateLimit-60000", ReqsLimit: 600, DenyStatusCode: 429, WhitelistIPs: []string{"10.0.0.0/8"}, Burst: burst, BurstLimit: 20}
+	assert.Equal(t, []string{
+		"backend RateLimit-60000-burst-1000",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"backend RateLimit-60000",
+		"  stick-table type ip size 102400 expire 60000ms peers localinstance store http_req_rate(60000)",
+		"frontend http",
+		"  http-request track-sc0 src table RateLimit-60000",
+		"  http-request track-sc1 src table RateLimit-60000-burst-1000",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-60000) gt 600 } { sc1_http_req_rate(RateLimit-60000-burst-1000) gt 20 } !{ src 10.0.0.0/8 }",
+	}, renderRules(t, track, burst, limit))
+
+	limit.BurstLimit = 0
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-60000) gt 600 } !{ src 10.0.0.0/8 }", limit.condTest())
+}
+
+// TestReqRateLimit_DenyRate tests the shaping of the responses to rate limited requests.
+// It validates that:
+// - Requests exceeding the limit are counted in the DenyRate table, with its stick counter
+// - Beyond the DenyRateLimit, they are dropped by a rule evaluated before the action
+// - Requests to the redirect location, which are not redirected, are neither counted nor dropped
+// - Without DenyRateLimit, no table nor rule is added
+func TestReqRateLimit_DenyRate(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-10000", TablePeriod: utils.PtrInt64(10000), TrackKey: "src"}
+	denyRate := &ReqTrack{TableName: "RateLimit-10000-denials", TablePeriod: utils.PtrInt64(10000), TrackKey: "src", StickCounter: 1}
+	limit := &ReqRateLimit{TableName: "RateLimit-10000", ReqsLimit: 100, DenyStatusCode: 429, WhitelistIPs: []string{"10.0.0.0/8"}, DenyRate: denyRate, DenyRateLimit: 20}
+	assert.Equal(t, []string{
+		"backend RateLimit-10000-denials",
+		"  stick-table type ip size 102400 expire 10000ms peers localinstance store http_req_rate(10000)",
+		"backend RateLimit-10000",
+		"  stick-table type ip size 102400 expire 10000ms peers localinstance store http_req_rate(10000)",
+		"frontend http",
+		"  http-request track-sc0 src table RateLimit-10000",
+		"  http-request track-sc1 src table RateLimit-10000-denials if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 }",
+		"  http-request silent-drop if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 } { sc1_http_req_rate(RateLimit-10000-denials) gt 20 }",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 }",
+	}, renderRules(t, track, limit))
+
+	limit.RedirectLocation = "/slow-down"
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 } !{ path /slow-down }", limit.denyRateTrackRule().CondTest)
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 } !{ path /slow-down } { sc1_http_req_rate(RateLimit-10000-denials) gt 20 }", limit.denyRateDropRule().CondTest)
+
+	limit.RedirectLocation = ""
+	limit.DenyRateLimit = 0
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 }",
+	}, renderRules(t, limit))
+}
+
+// TestReqRateLimit_Hysteresis tests the denial of clients until their rate drops under the low watermark.
+// It validates that:
+// - The table stores the gpt0 flag along with the counter
+// - Clients are flagged above the limit and unflagged under the watermark, before the deny rule
+// - Flagged clients are denied, unless whitelisted, and the other rules of denied requests match them too
+// - Without LowWatermark, the rate is compared to the limit
+func TestReqRateLimit_Hysteresis(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-10000", TablePeriod: utils.PtrInt64(10000), TrackKey: "src", Hysteresis: true}
+	limit := &ReqRateLimit{TableName: "RateLimit-10000", ReqsLimit: 100, DenyStatusCode: 429, WhitelistIPs: []string{"10.0.0.0/8"}, LowWatermark: 80, LogTag: "ratelimit"}
+	assert.Equal(t, []string{
+		"backend RateLimit-10000",
+		"  stick-table type ip size 102400 expire 10000ms peers localinstance store http_req_rate(10000),gpt0",
+		"frontend http",
+		"  http-request track-sc0 src table RateLimit-10000",
+		"  http-request sc-set-gpt0(0) 1 if { sc0_http_req_rate(RateLimit-10000) gt 100 }",
+		"  http-request sc-set-gpt0(0) 0 if { sc0_http_req_rate(RateLimit-10000) lt 80 }",
+		"  http-request capture str(ratelimit:RateLimit-10000) len 25 if { sc0_get_gpt0(RateLimit-10000) gt 0 } !{ src 10.0.0.0/8 }",
+		"  http-request deny deny_status 429 if { sc0_get_gpt0(RateLimit-10000) gt 0 } !{ src 10.0.0.0/8 }",
+	}, renderRules(t, track, limit))
+
+	limit.LowWatermark = 0
+	assert.Equal(t, "{ sc0_http_req_rate(RateLimit-10000) gt 100 } !{ src 10.0.0.0/8 }", limit.condTest())
+}
@@ -0,0 +1,60 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// ReqSourceAllowList denies the requests, or rejects the connections of TCP frontends,
+// whose source is neither one of SrcIPs nor in one of SrcMaps.
+type ReqSourceAllowList struct {
+	SrcIPs  []string
+	SrcMaps []maps.Path
+}
+
+func (r ReqSourceAllowList) GetType() Type {
+	return REQ_DENY
+}
+
+func (r ReqSourceAllowList) Create(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
+	condTest := r.condTest()
+	if condTest == "" {
+		return nil
+	}
+	if frontend.Mode == "tcp" {
+		tcpRule := models.TCPRequestRule{
+			Type:     "content",
+			Action:   "reject",
+			Cond:     "if",
+			CondTest: condTest,
+		}
+		return client.FrontendTCPRequestRuleCreate(0, frontend.Name, tcpRule, ingressACL)
+	}
+	httpRule := models.HTTPRequestRule{
+		Type:       "deny",
+		DenyStatus: utils.PtrInt64(403),
+		Cond:       "if",
+		CondTest:   condTest,
+	}
+	return client.FrontendHTTPRequestRuleCreate(0, frontend.Name, httpRule, ingressACL)
+}
+
+// condTest returns the condition matching sources out of the allow list: HAProxy
+// ANDs the negated matches, so a source matching any of them is allowed.
+// It is empty when the allow list has no entry.
+func (r ReqSourceAllowList) condTest() string {
+	var conditions []string
+	if len(r.SrcIPs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("!{ src %s }", strings.Join(r.SrcIPs, " ")))
+	}
+	for _, mapPath := range r.SrcMaps {
+		conditions = append(conditions, fmt.Sprintf("!{ src -f %s }", mapPath))
+	}
+	return strings.Join(conditions, " ")
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/haproxytech/client-native/v6/models"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/maps"
+)
+
+// TestReqSourceAllowList_ConditionGeneration tests the condition denying sources out of the allow list.
+// It validates that:
+// - Addresses are matched inline, and each map or pattern file with -f
+// - The negated matches are ANDed, so a source matching any of them is allowed
+// - An empty allow list has no condition
+func TestReqSourceAllowList_ConditionGeneration(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ReqSourceAllowList
+		want string
+	}{
+		{
+			name: "addresses",
+			rule: ReqSourceAllowList{SrcIPs: []string{"10.0.0.0/8", "2001:db8::1"}},
+			want: "!{ src 10.0.0.0/8 2001:db8::1 }",
+		},
+		{
+			name: "map and pattern files",
+			rule: ReqSourceAllowList{SrcMaps: []maps.Path{"/etc/haproxy/maps/source-allowlist-abc.map", "patterns/admins", "patterns/vpn"}},
+			want: "!{ src -f /etc/haproxy/maps/source-allowlist-abc.map } !{ src -f patterns/admins } !{ src -f patterns/vpn }",
+		},
+		{
+			name: "addresses and pattern file",
+			rule: ReqSourceAllowList{SrcIPs: []string{"192.168.1.1"}, SrcMaps: []maps.Path{"patterns/admins"}},
+			want: "!{ src 192.168.1.1 } !{ src -f patterns/admins }",
+		},
+		{
+			name: "empty",
+			rule: ReqSourceAllowList{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.condTest())
+		})
+	}
+}
+
+// TestReqSourceAllowList_Render tests the rules denying sources out of the allow list.
+// It validates that:
+// - HTTP requests are denied with a 403
+// - Connections of TCP frontends are rejected
+// - An empty allow list creates no rule
+func TestReqSourceAllowList_Render(t *testing.T) {
+	rule := &ReqSourceAllowList{SrcIPs: []string{"10.0.0.1"}, SrcMaps: []maps.Path{"patterns/admins"}}
+	assert.Equal(t, []string{
+		"frontend http",
+		"  http-request deny deny_status 403 if !{ src 10.0.0.1 } !{ src -f patterns/admins }",
+	}, renderRules(t, rule))
+
+	tcp := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-8080", Mode: "tcp"}}
+	assert.Equal(t, []string{
+		"frontend tcp-8080",
+		"  tcp-request content reject if !{ src 10.0.0.1 } !{ src -f patterns/admins }",
+	}, renderFrontendRules(t, tcp, rule))
+
+	assert.Equal(t, []string{"frontend http"}, renderRules(t, &ReqSourceAllowList{}))
+}
@@ -1,8 +1,11 @@
 package rules
 
 import (
-	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/haproxytech/client-native/v6/models"
 
@@ -12,56 +15,430 @@ import (
 )
 
 type ReqTrack struct {
-	TableName   string
-	TablePeriod *int64
-	TableSize   *int64
-	TrackKey    string
+	TableName    string
+	TablePeriod  *int64
+	TableSize    *int64
+	TableExpire  *int64 // Expiration of the table entries in milliseconds, defaults to TablePeriod
+	TableType    string
+	TrackKey     string
+	PathPrefixes []string
+	// ExemptMethods are the HTTP methods of the requests which are not tracked
+	ExemptMethods []string
+	StickCounter  int64
+	Counter       string // Counter stored in the table, defaults to http_req_rate
+	// KeyParts are fetches appended to TrackKey, separated by '|', making a composite key
+	KeyParts []string
+	// SSLOnly restricts the tracking to connections whose TLS is terminated by HAProxy,
+	// for keys which are only set on them, like the SNI.
+	SSLOnly bool
+	// CostHeader is the header holding the cost of a request, up to maxRequestCost,
+	// added to the gpc0 of the table whose rate is limited instead of the request rate
+	CostHeader string
+	// MissingKeyAction is applied to requests without TrackKey, which the track rule then skips.
+	// Empty keeps the track rule unconditional, HAProxy not tracking requests without the key.
+	MissingKeyAction string
+	// Peers is the peers section synchronizing the table, defaults to LocalPeers
+	Peers string
+	// Backend places the rules in the backends requests are routed to, see SplitBackend
+	Backend bool
+	// Anonymize masks the address tracked by TrackKey with AnonymizeConverter,
+	// so the table never holds full client addresses
+	Anonymize bool
+	// ErrorStatuses are the response statuses added to the gpc1 of the table, whose
+	// rate is limited with RateLimitCounterGpc1Rate instead of the request rate
+	ErrorStatuses []int64
+	// Hysteresis stores the gpt0 flag of the clients exceeding the limit, see ReqRateLimit.LowWatermark
+	Hysteresis bool
+	// ID identifies the rate limit in logs, see ReqRateLimit.ID
+	ID string `json:"-"`
 }
 
 const (
 	defaultPeriod    = "1s"
 	defaultTableSize = "100k"
+	defaultTableType = "ip"
+	// compositeKeyLen is the length of the string keys of tables tracking a composite key,
+	// long enough for an IPv6 address and a path.
+	compositeKeyLen int64 = 256
+	// maxRequestCost bounds the cost of a request read from the CostHeader,
+	// as each unit of cost takes a rule.
+	maxRequestCost int64 = 10
+	// missingKeyBucket is the key of the bucket shared by requests without the tracked key
+	missingKeyBucket = "ratelimit-missing-key"
+	// LocalPeers is the peers section of the local instance, which only keeps
+	// the tables across reloads.
+	LocalPeers = "localinstance"
+	// AnonymizeConverter masks the last octet of IPv4 addresses and the last
+	// 80 bits of IPv6 addresses, see ReqTrack.Anonymize.
+	AnonymizeConverter = "ipmask(24,48)"
 )
 
+// Actions applied to requests missing the tracked key, see ReqTrack.MissingKeyAction
+const (
+	MissingKeyActionDeny   = "deny"
+	MissingKeyActionShared = "shared"
+	MissingKeyActionExempt = "exempt"
+)
+
+// String returns the rule tracking the requests, as written in the HAProxy configuration.
+func (r ReqTrack) String() string {
+	return withID(httpRequestRuleString(r.trackRule()), r.ID)
+}
+
+// TableString returns the definition of the tracking table of HTTP frontends, as written in
+// the backend declaring it, with the sizes and durations in the largest exact unit.
+func (r ReqTrack) TableString() string {
+	_ = r.applyDefaults() // r is a copy, the rule itself is not modified
+	stickTable := r.stickTable()
+	var line strings.Builder
+	fmt.Fprintf(&line, "stick-table type %s", stickTable.Type)
+	if stickTable.Keylen != nil {
+		fmt.Fprintf(&line, " len %d", *stickTable.Keylen)
+	}
+	fmt.Fprintf(&line, " size %s expire %s peers %s store %s",
+		formatTableSize(*stickTable.Size), formatTableDuration(*stickTable.Expire), stickTable.Peers,
+		r.tableStore(formatTableDuration(*r.TablePeriod)))
+	return line.String()
+}
+
+func (r ReqTrack) inBackend() bool {
+	return r.Backend
+}
+
 func (r ReqTrack) GetType() Type {
 	return REQ_TRACK
 }
 
 func (r ReqTrack) Create(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
 	if frontend.Mode == "tcp" {
-		return errors.New("request Track cannot be configured in TCP mode")
+		return r.createTCP(client, frontend, ingressACL)
 	}
 	err := r.applyDefaults()
 	if err != nil {
 		return err
 	}
+	r.declareTable(client)
+
+	// Costs are added once requests are tracked, by rules created first so they are evaluated after
+	costRules := r.costRules()
+	for i := len(costRules) - 1; i >= 0; i-- {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, costRules[i], ingressACL)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Create tracking table.
+	// Create rule
+	err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.trackRule(), ingressACL)
+	if err != nil {
+		return err
+	}
+	rateLimitTables.register(r.TableName)
+
+	// Responses are counted in the entry their request is tracked in
+	if len(r.ErrorStatuses) > 0 {
+		err = client.FrontendHTTPResponseRuleCreate(0, frontend.Name, r.errorCountRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Requests without the key are tracked together, by a rule exclusive with the track rule
+	if r.MissingKeyAction == MissingKeyActionShared {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, r.sharedTrackRule(), ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Key parts are stored in variables by rules created last, so they are evaluated first
+	for _, rule := range r.keyPartRules() {
+		err = client.FrontendHTTPRequestRuleCreate(0, frontend.Name, rule, ingressACL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTCP tracks the connections of a TCP frontend, whose requests can't be inspected:
+// the connection rate of source addresses is tracked instead of their request rate,
+// in a table of its own. Conditions on requests, like PathPrefixes, don't apply.
+func (r ReqTrack) createTCP(client api.HAProxyClient, frontend *models.Frontend, ingressACL string) error {
+	// Responses can't be inspected either, so their statuses aren't counted
+	if len(r.ErrorStatuses) > 0 {
+		return nil
+	}
+	if r.TrackKey != "src" || len(r.KeyParts) > 0 {
+		return fmt.Errorf("only source addresses can be tracked in TCP mode, not '%s'", r.trackKey())
+	}
+	r.TableName = tcpTableName(r.TableName)
+	r.Counter = tcpCounter(r.counter())
+	r.CostHeader = ""
+	r.Hysteresis = false
+	err := r.applyDefaults()
+	if err != nil {
+		return err
+	}
+	r.declareTable(client)
+
+	err = client.FrontendTCPRequestRuleCreate(0, frontend.Name, r.tcpTrackRule(), ingressACL)
+	if err != nil {
+		return err
+	}
+	rateLimitTables.register(r.TableName)
+	return nil
+}
+
+// declareTable declares the tracking table in a backend, unless another rule already did.
+func (r ReqTrack) declareTable(client api.HAProxyClient) {
+	stickTable := r.stickTable()
 	if !client.BackendUsed(r.TableName) {
 		backend := models.Backend{
 			BackendBase: models.BackendBase{
-				From: constants.DefaultsSectionName,
-				Name: r.TableName,
-				StickTable: &models.ConfigStickTable{
-					Peers: "localinstance",
-					Type:  "ip",
-					Size:  r.TableSize,
-					Store: fmt.Sprintf("http_req_rate(%d)", *r.TablePeriod),
-				},
+				From:       constants.DefaultsSectionName,
+				Name:       r.TableName,
+				StickTable: stickTable,
 			},
 		}
 		// Create tracking table.
 		client.BackendCreateOrUpdate(backend)
+	} else if backend, err := client.BackendGet(r.TableName); err == nil && backend.StickTable != nil && !backend.StickTable.Equal(*stickTable) {
+		// Tables shared by several rules are declared once, by the first one
+		logger.Warningf("stick-table '%s' is tracked with different settings, keeping the first ones", r.TableName)
 	}
+}
 
-	// Create rule
+// tcpTrackRule returns the rule tracking connections with the stick counter in the table.
+func (r ReqTrack) tcpTrackRule() models.TCPRequestRule {
+	return models.TCPRequestRule{
+		Type:              "connection",
+		Action:            "track-sc",
+		TrackStickCounter: utils.PtrInt64(r.StickCounter),
+		TrackKey:          r.trackKey(),
+		TrackTable:        r.TableName,
+	}
+}
+
+// tcpTableName returns the name of the table tracking the connections of TCP frontends,
+// which differs from the tables of HTTP frontends with the same name as it stores other counters.
+func tcpTableName(tableName string) string {
+	return tableName + "-tcp"
+}
+
+// tcpCounter returns the counter of a TCP frontend replacing the given one, the request
+// based counters being replaced by the connection rate.
+func tcpCounter(counter string) string {
+	switch counter {
+	case RateLimitCounterConnCur, RateLimitCounterBytesInRate, RateLimitCounterBytesOutRate:
+		return counter
+	default:
+		return RateLimitCounterConnRate
+	}
+}
+
+// trackKey returns the key tracked in the table: TrackKey followed by the
+// variables holding the KeyParts, concatenated with a '|' separator.
+func (r ReqTrack) trackKey() string {
+	var key strings.Builder
+	key.WriteString(r.TrackKey)
+	if r.Anonymize {
+		key.WriteString("," + AnonymizeConverter)
+	}
+	for _, part := range r.KeyParts {
+		fmt.Fprintf(&key, ",concat(|,txn.%s)", keyPartVarName(part))
+	}
+	return key.String()
+}
+
+// keyPartRules returns the rules storing each key part in its variable,
+// as the concat converter only appends variables.
+func (r ReqTrack) keyPartRules() []models.HTTPRequestRule {
+	httpRules := make([]models.HTTPRequestRule, 0, len(r.KeyParts))
+	for _, part := range r.KeyParts {
+		httpRules = append(httpRules, models.HTTPRequestRule{
+			Type:     "set-var",
+			VarName:  keyPartVarName(part),
+			VarScope: "txn",
+			VarExpr:  part,
+		})
+	}
+	return httpRules
+}
+
+// keyPartVarName returns the name of the variable holding a key part.
+// It is derived from the fetch, so rules of other ingresses storing the
+// same fetch set the same value.
+func keyPartVarName(part string) string {
+	return "ratelimit_key_" + utils.Hash([]byte(part))[:8]
+}
+
+// trackRule returns the rule tracking requests with the stick counter in the table.
+func (r ReqTrack) trackRule() models.HTTPRequestRule {
 	httpRule := models.HTTPRequestRule{
 		Type:                "track-sc",
-		TrackScStickCounter: utils.PtrInt64(0),
-		TrackScKey:          r.TrackKey,
+		TrackScStickCounter: utils.PtrInt64(r.StickCounter),
+		TrackScKey:          r.trackKey(),
+		TrackScTable:        r.TableName,
+	}
+	condTests := r.condTests()
+	if r.MissingKeyAction != "" {
+		condTests = append(condTests, fmt.Sprintf("{ %s -m found }", r.TrackKey))
+	}
+	if len(condTests) > 0 {
+		httpRule.Cond = "if"
+		httpRule.CondTest = strings.Join(condTests, " ")
+	}
+	return httpRule
+}
+
+// sharedTrackRule returns the rule tracking the requests without TrackKey
+// in a single entry of the table, shared by all of them.
+func (r ReqTrack) sharedTrackRule() models.HTTPRequestRule {
+	key := fmt.Sprintf("str(%s)", missingKeyBucket)
+	if r.TableType == "ip" {
+		key = "ipv4(0.0.0.0)"
+	}
+	condTests := append(r.condTests(), fmt.Sprintf("!{ %s -m found }", r.TrackKey))
+	return models.HTTPRequestRule{
+		Type:                "track-sc",
+		TrackScStickCounter: utils.PtrInt64(r.StickCounter),
+		TrackScKey:          key,
 		TrackScTable:        r.TableName,
+		Cond:                "if",
+		CondTest:            strings.Join(condTests, " "),
+	}
+}
+
+// condTests returns the conditions restricting the tracked requests.
+func (r ReqTrack) condTests() []string {
+	var condTests []string
+	if r.SSLOnly {
+		condTests = append(condTests, "{ ssl_fc }")
+	}
+	if len(r.PathPrefixes) > 0 {
+		condTests = append(condTests, fmt.Sprintf("{ path_beg %s }", strings.Join(r.PathPrefixes, " ")))
+	}
+	if len(r.ExemptMethods) > 0 {
+		condTests = append(condTests, fmt.Sprintf("!{ method %s }", strings.Join(r.ExemptMethods, " ")))
+	}
+	return condTests
+}
+
+// costRules returns the rules adding the cost of the tracked requests to gpc0, empty without
+// CostHeader. The cost is added one by one, as many times as the header value reaches, so
+// it is bounded by maxRequestCost and requests without a cost count once.
+func (r ReqTrack) costRules() []models.HTTPRequestRule {
+	if r.CostHeader == "" {
+		return nil
+	}
+	httpRules := make([]models.HTTPRequestRule, 0, maxRequestCost)
+	for cost := int64(1); cost <= maxRequestCost; cost++ {
+		condTests := r.condTests()
+		if cost > 1 {
+			condTests = append(condTests, fmt.Sprintf("{ req.hdr_val(%s) ge %d }", r.CostHeader, cost))
+		}
+		httpRule := models.HTTPRequestRule{
+			Type: "sc-inc-gpc0",
+			ScID: r.StickCounter,
+		}
+		if len(condTests) > 0 {
+			httpRule.Cond = "if"
+			httpRule.CondTest = strings.Join(condTests, " ")
+		}
+		httpRules = append(httpRules, httpRule)
+	}
+	return httpRules
+}
+
+// errorCountRule returns the rule adding the responses with one of the ErrorStatuses to gpc1.
+// The path of the request is not available in responses, but the counter of requests out
+// of PathPrefixes is not tracked, so it isn't incremented.
+func (r ReqTrack) errorCountRule() models.HTTPResponseRule {
+	statuses := make([]string, 0, len(r.ErrorStatuses))
+	for _, status := range r.ErrorStatuses {
+		statuses = append(statuses, strconv.FormatInt(status, 10))
+	}
+	return models.HTTPResponseRule{
+		Type:     "sc-inc-gpc1",
+		ScID:     r.StickCounter,
+		Cond:     "if",
+		CondTest: fmt.Sprintf("{ status %s }", strings.Join(statuses, " ")),
+	}
+}
+
+// stickTable returns the definition of the tracking table.
+func (r ReqTrack) stickTable() *models.ConfigStickTable {
+	stickTable := &models.ConfigStickTable{
+		Peers:  r.Peers,
+		Type:   r.TableType,
+		Size:   r.TableSize,
+		Expire: r.TableExpire,
+		Store:  r.tableStore(strconv.FormatInt(*r.TablePeriod, 10)),
+	}
+	if stickTable.Peers == "" {
+		stickTable.Peers = LocalPeers
 	}
-	return client.FrontendHTTPRequestRuleCreate(0, frontend.Name, httpRule, ingressACL)
+	if len(r.KeyParts) > 0 {
+		// Composite keys are strings, longer than the 32 bytes HAProxy keeps by default
+		stickTable.Type = "string"
+		stickTable.Keylen = utils.PtrInt64(compositeKeyLen)
+	}
+	return stickTable
+}
+
+// tableStore returns the data stored in the table, its counter being computed over period.
+func (r ReqTrack) tableStore(period string) string {
+	store := fmt.Sprintf("%s(%s)", r.counter(), period)
+	if r.Counter == RateLimitCounterConnCur {
+		// Concurrent connections don't depend on a period
+		store = RateLimitCounterConnCur
+	}
+	if r.Hysteresis {
+		store += ",gpt0"
+	}
+	return store
+}
+
+// formatTableSize formats a number of table entries with the largest g, m or k unit
+// dividing it, as accepted by HAProxy (1k = 1024).
+func formatTableSize(size int64) string {
+	units := []struct {
+		name       string
+		multiplier int64
+	}{{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}}
+	for _, unit := range units {
+		if size != 0 && size%unit.multiplier == 0 {
+			return strconv.FormatInt(size/unit.multiplier, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// formatTableDuration formats a duration in milliseconds with the largest d, h, m or s
+// unit dividing it, as accepted by HAProxy.
+func formatTableDuration(ms int64) string {
+	units := []struct {
+		name string
+		ms   int64
+	}{{"d", 86400000}, {"h", 3600000}, {"m", 60000}, {"s", 1000}}
+	for _, unit := range units {
+		if ms != 0 && ms%unit.ms == 0 {
+			return strconv.FormatInt(ms/unit.ms, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(ms, 10) + "ms"
+}
+
+// counter returns the counter stored in the table.
+func (r ReqTrack) counter() string {
+	if r.CostHeader != "" {
+		return RateLimitCounterGpc0Rate
+	}
+	if r.Counter == "" {
+		return RateLimitCounterReqRate
+	}
+	return r.Counter
 }
 
 func (r *ReqTrack) applyDefaults() error {
@@ -72,6 +449,10 @@ func (r *ReqTrack) applyDefaults() error {
 		}
 		r.TablePeriod = utils.PtrInt64(*period)
 	}
+	if r.TableExpire == nil {
+		// Entries are forgotten once their rate over the period is back to zero
+		r.TableExpire = utils.PtrInt64(*r.TablePeriod)
+	}
 	if r.TableSize == nil {
 		size, err := utils.ParseSize(defaultTableSize)
 		if err != nil {
@@ -79,5 +460,54 @@ func (r *ReqTrack) applyDefaults() error {
 		}
 		r.TableSize = size
 	}
+	if r.TableType == "" {
+		r.TableType = defaultTableType
+	}
 	return nil
 }
+
+// rateLimitTables registers the stick-tables tracked by the rules created while
+// refreshing the rules, or placed in backends. They are listed once the refresh is done.
+var rateLimitTables = &tableRegistry{}
+
+type tableRegistry struct {
+	mu      sync.RWMutex
+	pending map[string]struct{}
+	active  []string
+}
+
+func (t *tableRegistry) register(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = map[string]struct{}{}
+	}
+	t.pending[name] = struct{}{}
+}
+
+// commit makes the tables registered since the previous commit the listed ones.
+// Tables of the rules placed in backends are registered before the refresh.
+func (t *tableRegistry) commit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := make([]string, 0, len(t.pending))
+	for name := range t.pending {
+		active = append(active, name)
+	}
+	slices.Sort(active)
+	t.active = active
+	clear(t.pending)
+}
+
+func (t *tableRegistry) list() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]string{}, t.active...)
+}
+
+// RateLimitTables returns the sorted names of the stick-tables tracked by the
+// rate limits of the current configuration, which can be inspected with the
+// "show table" command of the HAProxy Runtime API.
+func RateLimitTables() []string {
+	return rateLimitTables.list()
+}
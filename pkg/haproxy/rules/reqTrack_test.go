@@ -0,0 +1,452 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/haproxytech/client-native/v6/models"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+// TestReqTrack_StickTable tests the generated tracking table definition.
+// It validates that:
+// - Without expiration, entries expire after the rate-counter period
+// - An expiration longer than the period is kept, independently of the stored http_req_rate period
+// - Defaults apply to the period, size and type of the table
+func TestReqTrack_StickTable(t *testing.T) {
+	track := ReqTrack{TableName: "RateLimit-10000", TablePeriod: utils.PtrInt64(10000)}
+	require.NoError(t, track.applyDefaults())
+	table := track.stickTable()
+	assert.Equal(t, "http_req_rate(10000)", table.Store)
+	require.NotNil(t, table.Expire)
+	assert.Equal(t, int64(10000), *table.Expire)
+
+	track = ReqTrack{TableName: "RateLimit-10000", TablePeriod: utils.PtrInt64(10000), TableExpire: utils.PtrInt64(300000)}
+	require.NoError(t, track.applyDefaults())
+	table = track.stickTable()
+	assert.Equal(t, "http_req_rate(10000)", table.Store)
+	require.NotNil(t, table.Expire)
+	assert.Equal(t, int64(300000), *table.Expire)
+
+	track = ReqTrack{TableName: "RateLimit-1000"}
+	require.NoError(t, track.applyDefaults())
+	table = track.stickTable()
+	assert.Equal(t, "http_req_rate(1000)", table.Store)
+	assert.Equal(t, int64(1000), *table.Expire)
+	assert.Equal(t, int64(100*1024), *table.Size)
+	assert.Equal(t, "ip", table.Type)
+	assert.Equal(t, "localinstance", table.Peers)
+}
+
+// TestReqTrack_CounterStore tests the counter stored in the tracking table.
+// It validates that:
+// - Rate counters are stored over the period of the table
+// - conn_cur is stored without period
+func TestReqTrack_CounterStore(t *testing.T) {
+	for counter, want := range map[string]string{
+		"":                           "http_req_rate(60000)",
+		RateLimitCounterReqRate:      "http_req_rate(60000)",
+		RateLimitCounterBytesInRate:  "bytes_in_rate(60000)",
+		RateLimitCounterBytesOutRate: "bytes_out_rate(60000)",
+		RateLimitCounterConnCur:      "conn_cur",
+	} {
+		track := ReqTrack{TableName: "RateLimit-60000", TablePeriod: utils.PtrInt64(60000), Counter: counter}
+		require.NoError(t, track.applyDefaults())
+		assert.Equal(t, want, track.stickTable().Store, counter)
+	}
+}
+
+// TestReqTrack_TrackRule tests the generated track-sc rule.
+// It validates that:
+// - The rule tracks the key in the table with the stick counter of the rule (track-sc0, track-sc1, track-sc2)
+// - Path prefixes restrict the tracking
+func TestReqTrack_TrackRule(t *testing.T) {
+	for _, counter := range []int64{0, 1, 2} {
+		rule := ReqTrack{TableName: "RateLimit-1000", TrackKey: "src", StickCounter: counter}.trackRule()
+		assert.Equal(t, "track-sc", rule.Type)
+		require.NotNil(t, rule.TrackScStickCounter)
+		assert.Equal(t, counter, *rule.TrackScStickCounter)
+		assert.Equal(t, "src", rule.TrackScKey)
+		assert.Equal(t, "RateLimit-1000", rule.TrackScTable)
+		assert.Empty(t, rule.CondTest)
+	}
+
+	rule := ReqTrack{TableName: "RateLimit-1000", TrackKey: "src", StickCounter: 1, PathPrefixes: []string{"/api", "/login"}}.trackRule()
+	assert.Equal(t, "if", rule.Cond)
+	assert.Equal(t, "{ path_beg /api /login }", rule.CondTest)
+
+	rule = ReqTrack{TableName: "RateLimit-1000", TrackKey: "src", PathPrefixes: []string{"/api"}, ExemptMethods: []string{"GET", "OPTIONS"}}.trackRule()
+	assert.Equal(t, "if", rule.Cond)
+	assert.Equal(t, "{ path_beg /api } !{ method GET OPTIONS }", rule.CondTest)
+}
+
+// TestReqTrack_CostHeader tests the weighting of requests by the cost read from a header.
+// It validates that:
+// - The table stores the gpc0 rate, incremented once per request then once per unit of cost up to maxRequestCost
+// - The increments are evaluated after the tracking, with the same conditions
+// - The rate limit denies requests whose gpc0 rate exceeds the limit
+func TestReqTrack_CostHeader(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src", StickCounter: 1, PathPrefixes: []string{"/search"}, CostHeader: "X-Cost"}
+	limit := &ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 50, StickCounter: 1, Counter: RateLimitCounterGpc0Rate, DenyStatusCode: 429, PathPrefixes: []string{"/search"}}
+	want := []string{
+		"backend RateLimit-1000",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store gpc0_rate(1000)",
+		"frontend http",
+		"  http-request track-sc1 src table RateLimit-1000 if { path_beg /search }",
+		"  http-request sc-inc-gpc0(1) if { path_beg /search }",
+	}
+	for cost := 2; cost <= int(maxRequestCost); cost++ {
+		want = append(want, fmt.Sprintf("  http-request sc-inc-gpc0(1) if { path_beg /search } { req.hdr_val(X-Cost) ge %d }", cost))
+	}
+	want = append(want, "  http-request deny deny_status 429 if { sc1_gpc0_rate(RateLimit-1000) gt 50 } { path_beg /search }")
+	assert.Equal(t, want, renderRules(t, track, limit))
+
+	track = &ReqTrack{TableName: "RateLimit-1000", TrackKey: "src"}
+	assert.Empty(t, track.costRules())
+}
+
+// TestReqTrack_ErrorStatuses tests the rate limit of the responses with an error status.
+// It validates that:
+// - The table stores the gpc1 rate, incremented by the responses with one of the statuses
+// - Requests are tracked unconditionally but for the path, and denied once the gpc1 rate exceeds the limit
+// - Neither the response rule nor the rate limit are created in TCP frontends
+func TestReqTrack_ErrorStatuses(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-60000-errors", TablePeriod: utils.PtrInt64(60000), TrackKey: "src", StickCounter: 1, PathPrefixes: []string{"/login"}, Counter: RateLimitCounterGpc1Rate, ErrorStatuses: []int64{401, 403}}
+	limit := &ReqRateLimit{TableName: "RateLimit-60000-errors", ReqsLimit: 5, StickCounter: 1, Counter: RateLimitCounterGpc1Rate, DenyStatusCode: 429, PathPrefixes: []string{"/login"}}
+	assert.Equal(t, []string{
+		"backend RateLimit-60000-errors",
+		"  stick-table type ip size 102400 expire 60000ms peers localinstance store gpc1_rate(60000)",
+		"frontend http",
+		"  http-request track-sc1 src table RateLimit-60000-errors if { path_beg /login }",
+		"  http-request deny deny_status 429 if { sc1_gpc1_rate(RateLimit-60000-errors) gt 5 } { path_beg /login }",
+		"  http-response sc-inc-gpc1(1) if { status 401 403 }",
+	}, renderRules(t, track, limit))
+
+	tcp := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp", Mode: "tcp"}}
+	assert.Equal(t, []string{"frontend tcp"}, renderFrontendRules(t, tcp, track, limit))
+}
+
+// TestReqTrack_Peers tests the peers section synchronizing the tracking table.
+// It validates that:
+// - Tables are synchronized with the local instance by default
+// - The table definition references the Peers section when set, in HTTP and TCP frontends
+func TestReqTrack_Peers(t *testing.T) {
+	track := &ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TrackKey: "src", Peers: "mypeers"}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000",
+		"  stick-table type ip size 102400 expire 1000ms peers mypeers store http_req_rate(1000)",
+		"frontend http",
+		"  http-request track-sc0 src table RateLimit-1000",
+	}, renderRules(t, track))
+
+	tcp := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-8080", Mode: "tcp"}}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-tcp",
+		"  stick-table type ip size 102400 expire 1000ms peers mypeers store conn_rate(1000)",
+		"frontend tcp-8080",
+		"  tcp-request connection track-sc0 src table RateLimit-1000-tcp",
+	}, renderFrontendRules(t, tcp, track))
+
+	track.Peers = ""
+	assert.Equal(t, LocalPeers, track.stickTable().Peers)
+}
+
+// TestReqTrack_MissingKeyAction tests the rules generated for requests without the tracked key.
+// It validates that:
+// - By default, the track rule is unconditional
+// - Requests without the key are not tracked when exempted
+// - They share a single entry of the table, typed after the table, when grouped
+// - They are denied, unless whitelisted, when denied
+func TestReqTrack_MissingKeyAction(t *testing.T) {
+	table := []string{
+		"backend RateLimit-1000",
+		"  stick-table type string size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"frontend http",
+	}
+	tests := []struct {
+		name   string
+		action string
+		want   []string
+	}{
+		{
+			name: "default",
+			want: []string{"  http-request track-sc0 req.hdr(X-Api-Key) table RateLimit-1000"},
+		},
+		{
+			name:   "exempt",
+			action: MissingKeyActionExempt,
+			want:   []string{"  http-request track-sc0 req.hdr(X-Api-Key) table RateLimit-1000 if { req.hdr(X-Api-Key) -m found }"},
+		},
+		{
+			name:   "shared",
+			action: MissingKeyActionShared,
+			want: []string{
+				"  http-request track-sc0 str(ratelimit-missing-key) table RateLimit-1000 if !{ req.hdr(X-Api-Key) -m found }",
+				"  http-request track-sc0 req.hdr(X-Api-Key) table RateLimit-1000 if { req.hdr(X-Api-Key) -m found }",
+			},
+		},
+		{
+			name:   "deny",
+			action: MissingKeyActionDeny,
+			want: []string{
+				"  http-request track-sc0 req.hdr(X-Api-Key) table RateLimit-1000 if { req.hdr(X-Api-Key) -m found }",
+				"  http-request deny deny_status 429 if !{ req.hdr(X-Api-Key) -m found } !{ src 10.0.0.0/8 }",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := &ReqTrack{TableName: "RateLimit-1000", TablePeriod: utils.PtrInt64(1000), TableType: "string", TrackKey: "req.hdr(X-Api-Key)", MissingKeyAction: tt.action}
+			limit := &ReqRateLimit{TableName: "RateLimit-1000", ReqsLimit: 10, DenyStatusCode: 429, WhitelistIPs: []string{"10.0.0.0/8"}}
+			if tt.action == MissingKeyActionDeny {
+				limit.MissingKey = track.TrackKey
+			}
+			want := append(append(append([]string{}, table...), tt.want...),
+				"  http-request deny deny_status 429 if { sc0_http_req_rate(RateLimit-1000) gt 10 } !{ src 10.0.0.0/8 }")
+			assert.Equal(t, want, renderRules(t, track, limit))
+		})
+	}
+
+	track := ReqTrack{TableName: "RateLimit-1000", TableType: "ip", TrackKey: "req.hdr_ip(X-Forwarded-For,-1)", MissingKeyAction: MissingKeyActionShared}
+	assert.Equal(t, "ipv4(0.0.0.0)", track.sharedTrackRule().TrackScKey)
+}
+
+// TestReqTrack_ForwardedForKey tests the track-sc rule of a client IP taken from X-Forwarded-For.
+// It validates that:
+// - The track key is the X-Forwarded-For fetch, counted from the last entry
+// - The stick counter of the rule is kept
+func TestReqTrack_ForwardedForKey(t *testing.T) {
+	rule := ReqTrack{TableName: "RateLimit-1000-0f1e2d3c", TrackKey: "req.hdr_ip(X-Forwarded-For,-2)", StickCounter: 1}.trackRule()
+	assert.Equal(t, "track-sc", rule.Type)
+	assert.Equal(t, int64(1), *rule.TrackScStickCounter)
+	assert.Equal(t, "req.hdr_ip(X-Forwarded-For,-2)", rule.TrackScKey)
+	assert.Equal(t, "RateLimit-1000-0f1e2d3c", rule.TrackScTable)
+}
+
+// TestReqTrack_CompositeKey tests the tracking of a composite key.
+// It validates that:
+// - The key parts are stored in variables named after their fetch and appended to the key with concat
+// - The table is string typed with keys long enough for an address and a path
+// - Without key parts, the key, the table type and the key length are not changed
+func TestReqTrack_CompositeKey(t *testing.T) {
+	track := ReqTrack{TableName: "RateLimit-1000-0f1e2d3c", TrackKey: "src", KeyParts: []string{"path", "hdr(host)"}}
+	require.NoError(t, track.applyDefaults())
+	pathVar, hostVar := keyPartVarName("path"), keyPartVarName("hdr(host)")
+	assert.NotEqual(t, pathVar, hostVar)
+
+	rule := track.trackRule()
+	assert.Equal(t, "src,concat(|,txn."+pathVar+"),concat(|,txn."+hostVar+")", rule.TrackScKey)
+
+	varRules := track.keyPartRules()
+	require.Len(t, varRules, 2)
+	for i, part := range []string{"path", "hdr(host)"} {
+		assert.Equal(t, "set-var", varRules[i].Type)
+		assert.Equal(t, "txn", varRules[i].VarScope)
+		assert.Equal(t, keyPartVarName(part), varRules[i].VarName)
+		assert.Equal(t, part, varRules[i].VarExpr)
+	}
+
+	table := track.stickTable()
+	assert.Equal(t, "string", table.Type)
+	require.NotNil(t, table.Keylen)
+	assert.Equal(t, int64(256), *table.Keylen)
+
+	track = ReqTrack{TableName: "RateLimit-1000", TrackKey: "src"}
+	require.NoError(t, track.applyDefaults())
+	assert.Equal(t, "src", track.trackRule().TrackScKey)
+	assert.Empty(t, track.keyPartRules())
+	assert.Equal(t, "ip", track.stickTable().Type)
+	assert.Nil(t, track.stickTable().Keylen)
+}
+
+// TestReqTrack_SNIKey tests the tracking of the TLS SNI.
+// It validates that:
+// - The SNI is only tracked on connections whose TLS is terminated by HAProxy
+// - Path prefixes still restrict the tracking
+// - The table is string typed
+func TestReqTrack_SNIKey(t *testing.T) {
+	track := ReqTrack{TableName: "RateLimit-1000-0f1e2d3c", TrackKey: "ssl_fc_sni,lower", TableType: "string", SSLOnly: true}
+	require.NoError(t, track.applyDefaults())
+	rule := track.trackRule()
+	assert.Equal(t, "ssl_fc_sni,lower", rule.TrackScKey)
+	assert.Equal(t, "if", rule.Cond)
+	assert.Equal(t, "{ ssl_fc }", rule.CondTest)
+	assert.Equal(t, "string", track.stickTable().Type)
+
+	track.PathPrefixes = []string{"/api"}
+	assert.Equal(t, "{ ssl_fc } { path_beg /api }", track.trackRule().CondTest)
+}
+
+// TestReqTrack_JWTClaimKey tests the tracking of a claim of the bearer token.
+// It validates that:
+// - The claim is read from the JWT payload and tracked in a string table
+// - Requests without token are not tracked when exempted
+func TestReqTrack_JWTClaimKey(t *testing.T) {
+	track := &ReqTrack{
+		TableName:   "RateLimit-1000-0f1e2d3c",
+		TablePeriod: utils.PtrInt64(1000),
+		TrackKey:    "http_auth_bearer,jwt_payload_query('$.sub')",
+		TableType:   "string",
+	}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-0f1e2d3c",
+		"  stick-table type string size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"frontend http",
+		"  http-request track-sc0 http_auth_bearer,jwt_payload_query('$.sub') table RateLimit-1000-0f1e2d3c",
+	}, renderRules(t, track))
+
+	track.MissingKeyAction = MissingKeyActionExempt
+	assert.Equal(t, "http-request track-sc0 http_auth_bearer,jwt_payload_query('$.sub') table RateLimit-1000-0f1e2d3c if { http_auth_bearer,jwt_payload_query('$.sub') -m found }", track.String())
+}
+
+// TestReqTrack_Anonymize tests the masking of the tracked addresses.
+// It validates that:
+// - IPv4 addresses keep their first 3 octets and IPv6 addresses their first 48 bits
+// - The mask applies to the addresses of X-Forwarded-For and to the address part of composite keys
+// - TCP frontends track masked source addresses too
+func TestReqTrack_Anonymize(t *testing.T) {
+	track := &ReqTrack{
+		TableName:   "RateLimit-1000-0a1b2c3d",
+		TablePeriod: utils.PtrInt64(1000),
+		TrackKey:    "src",
+		Anonymize:   true,
+	}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-0a1b2c3d",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"frontend http",
+		"  http-request track-sc0 src,ipmask(24,48) table RateLimit-1000-0a1b2c3d",
+	}, renderRules(t, track))
+
+	track.TableType = "ipv6"
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-0a1b2c3d",
+		"  stick-table type ipv6 size 102400 expire 1000ms peers localinstance store http_req_rate(1000)",
+		"frontend http",
+		"  http-request track-sc0 src,ipmask(24,48) table RateLimit-1000-0a1b2c3d",
+	}, renderRules(t, track))
+
+	track.TrackKey = "req.hdr_ip(X-Forwarded-For,-1)"
+	assert.Equal(t, "http-request track-sc0 req.hdr_ip(X-Forwarded-For,-1),ipmask(24,48) table RateLimit-1000-0a1b2c3d", track.String())
+
+	track.TrackKey = "src"
+	track.KeyParts = []string{"hdr(X-Tenant)"}
+	assert.Equal(t, "http-request track-sc0 src,ipmask(24,48),concat(|,txn.ratelimit_key_ab286314) table RateLimit-1000-0a1b2c3d", track.String())
+
+	track.KeyParts = nil
+	track.TableType = ""
+	frontend := &models.Frontend{FrontendBase: models.FrontendBase{Name: "tcp-443", Mode: "tcp"}}
+	assert.Equal(t, []string{
+		"backend RateLimit-1000-0a1b2c3d-tcp",
+		"  stick-table type ip size 102400 expire 1000ms peers localinstance store conn_rate(1000)",
+		"frontend tcp-443",
+		"  tcp-request connection track-sc0 src,ipmask(24,48) table RateLimit-1000-0a1b2c3d-tcp",
+	}, renderFrontendRules(t, frontend, track))
+}
+
+// TestReqTrack_TableRegistry tests the listing of rate-limit tables.
+// It validates that:
+// - Registered tables are listed once committed, sorted and without duplicates
+// - Tables not registered again since the previous commit are no longer listed
+func TestReqTrack_TableRegistry(t *testing.T) {
+	registry := &tableRegistry{}
+	registry.register("RateLimit-2000")
+	registry.register("RateLimit-1000")
+	registry.register("RateLimit-2000")
+	assert.Empty(t, registry.list())
+	registry.commit()
+	assert.Equal(t, []string{"RateLimit-1000", "RateLimit-2000"}, registry.list())
+
+	registry.register("RateLimit-1000")
+	registry.commit()
+	assert.Equal(t, []string{"RateLimit-1000"}, registry.list())
+
+	registry.commit()
+	assert.Empty(t, registry.list())
+}
+
+// TestReqTrack_String tests the rendering of the track rule for troubleshooting.
+// It validates that:
+// - The stick counter, the key and the table are rendered
+// - The condition is rendered when the tracking is scoped
+func TestReqTrack_String(t *testing.T) {
+	track := ReqTrack{TableName: "RateLimit-1000", TrackKey: "src", StickCounter: 2}
+	assert.Equal(t, "http-request track-sc2 src table RateLimit-1000", track.String())
+
+	track.PathPrefixes = []string{"/api"}
+	assert.Equal(t, "http-request track-sc2 src table RateLimit-1000 if { path_beg /api }", track.String())
+}
+
+// TestReqTrack_TableString tests the rendering of the tracking table definition for review.
+// It validates that:
+// - Sizes and durations are rendered with the largest exact unit, in milliseconds otherwise
+// - Defaults apply to the period, size, expiration and type of the table
+// - Composite keys render the key length, and the stored data follows the counter
+// - The rule itself is left unmodified
+func TestReqTrack_TableString(t *testing.T) {
+	tests := []struct {
+		name  string
+		track ReqTrack
+		want  string
+	}{
+		{
+			name:  "defaults",
+			track: ReqTrack{TableName: "RateLimit-1000"},
+			want:  "stick-table type ip size 100k expire 1s peers localinstance store http_req_rate(1s)",
+		},
+		{
+			name:  "period and size",
+			track: ReqTrack{TableName: "RateLimit-10000", TablePeriod: utils.PtrInt64(10000), TableSize: utils.PtrInt64(1 << 20)},
+			want:  "stick-table type ip size 1m expire 10s peers localinstance store http_req_rate(10s)",
+		},
+		{
+			name:  "expiration longer than the period",
+			track: ReqTrack{TableName: "RateLimit-60000", TablePeriod: utils.PtrInt64(60000), TableExpire: utils.PtrInt64(3600000), TableSize: utils.PtrInt64(5000)},
+			want:  "stick-table type ip size 5000 expire 1h peers localinstance store http_req_rate(1m)",
+		},
+		{
+			name:  "period without exact unit",
+			track: ReqTrack{TableName: "RateLimit-1500", TablePeriod: utils.PtrInt64(1500), TableSize: utils.PtrInt64(200 * 1024), Peers: "mypeers"},
+			want:  "stick-table type ip size 200k expire 1500ms peers mypeers store http_req_rate(1500ms)",
+		},
+		{
+			name:  "composite key and hysteresis",
+			track: ReqTrack{TableName: "RateLimit-86400000", TablePeriod: utils.PtrInt64(86400000), KeyParts: []string{"path"}, Hysteresis: true},
+			want:  "stick-table type string len 256 size 100k expire 1d peers localinstance store http_req_rate(1d),gpt0",
+		},
+		{
+			name:  "concurrent connections",
+			track: ReqTrack{TableName: "RateLimit-1000", Counter: RateLimitCounterConnCur},
+			want:  "stick-table type ip size 100k expire 1s peers localinstance store conn_cur",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.track.TableString())
+		})
+	}
+
+	// Defaults are only applied to the rendered table
+	track := ReqTrack{TableName: "RateLimit-1000"}
+	_ = track.TableString()
+	assert.Nil(t, track.TablePeriod)
+	assert.Nil(t, track.TableSize)
+}
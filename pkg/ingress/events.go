@@ -0,0 +1,99 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/annotations/common"
+	"github.com/haproxytech/kubernetes-ingress/pkg/store"
+)
+
+// AnnotationErrorReason is the reason of the events reporting annotation errors.
+const AnnotationErrorReason = "InvalidAnnotation"
+
+// eventSourceComponent is the component emitting the events.
+const eventSourceComponent = "haproxy-ingress-controller"
+
+// eventClient is the client used to report annotation errors, they are only logged when nil.
+var eventClient kubernetes.Interface
+
+// SetEventClient sets the client used to report the annotation errors of the ingresses as events.
+func SetEventClient(client kubernetes.Interface) {
+	eventClient = client
+}
+
+// annotationError returns the record of the error of the named annotation, with the value
+// carried by the error, or else the one set in the given annotations.
+func annotationError(name string, err error, annotations ...map[string]string) store.AnnotationError {
+	record := store.AnnotationError{Name: name, Reason: err.Error()}
+	var annErr *common.AnnotationError
+	if errors.As(err, &annErr) {
+		record.Name, record.Value = annErr.Name, annErr.Value
+	} else {
+		record.Value = common.GetValue(name, annotations...)
+	}
+	return record
+}
+
+// annotationErrorEvent returns the Warning event reporting the annotation error on the ingress.
+func annotationErrorEvent(ing *networkingv1.Ingress, annErr store.AnnotationError, now time.Time) *corev1.Event {
+	timestamp := metav1.NewTime(now)
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Same naming as the client-go event recorder
+			Name:      fmt.Sprintf("%s.%x", ing.Name, now.UnixNano()),
+			Namespace: ing.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:            "Ingress",
+			APIVersion:      "networking.k8s.io/v1",
+			Namespace:       ing.Namespace,
+			Name:            ing.Name,
+			UID:             ing.UID,
+			ResourceVersion: ing.ResourceVersion,
+		},
+		Reason:         AnnotationErrorReason,
+		Message:        fmt.Sprintf("annotation %s: value '%s': %s", annErr.Name, annErr.Value, annErr.Reason),
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: eventSourceComponent},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+	}
+}
+
+// reportAnnotationErrors reports the annotation errors of the ingress in the background,
+// so the sync doesn't wait for the API server.
+func (i *Ingress) reportAnnotationErrors() {
+	if eventClient == nil || len(i.resource.AnnotationErrors) == 0 {
+		return
+	}
+	namespace, name, annErrs := i.resource.Namespace, i.resource.Name, i.resource.AnnotationErrors
+	go func() {
+		logger.Error(recordAnnotationErrors(eventClient, namespace, name, annErrs))
+	}()
+}
+
+// recordAnnotationErrors emits a Warning event on the ingress for every annotation
+// which couldn't be processed, so users see them without reading the controller logs.
+func recordAnnotationErrors(client kubernetes.Interface, namespace, name string, annErrs []store.AnnotationError) error {
+	ing, err := client.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get ingress %s/%s to report annotation errors: %w", namespace, name, err)
+	}
+	var errs []error
+	for _, annErr := range annErrs {
+		event := annotationErrorEvent(ing, annErr, time.Now())
+		if _, err = client.CoreV1().Events(ing.Namespace).Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to report annotation %s error on ingress %s/%s: %w", annErr.Name, ing.Namespace, ing.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -15,6 +15,8 @@
 package ingress
 
 import (
+	"slices"
+
 	"github.com/haproxytech/kubernetes-ingress/pkg/annotations"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/certs"
@@ -31,6 +33,7 @@ type Ingress struct {
 	resource        *store.Ingress
 	controllerClass string
 	ruleIDs         []rules.RuleID
+	backendRules    rules.List
 	allowEmptyClass bool
 	sslPassthrough  bool
 }
@@ -70,6 +73,7 @@ func (i *Ingress) handlePath(k store.K8s, h haproxy.HAProxy, host string, path *
 		return err
 	}
 	// Backend
+	svc.SetBackendRules(i.backendRules)
 	err = svc.HandleBackend(k, h, a)
 	if err != nil {
 		return err
@@ -126,13 +130,65 @@ func (i *Ingress) handlePath(k store.K8s, h haproxy.HAProxy, host string, path *
 func (i *Ingress) handleAnnotations(k store.K8s, h haproxy.HAProxy) {
 	var err error
 	result := rules.List{}
+	svcAnnotations := i.serviceAnnotations(k)
+	policyAnnotations, err := annotations.RateLimitPolicy(k, i.resource)
+	if err != nil {
+		logger.Errorf("Ingress '%s/%s': %s", i.resource.Namespace, i.resource.Name, err)
+	}
+	previousErrors := i.resource.AnnotationErrors
+	i.resource.AnnotationErrors = nil
 	for _, a := range i.annotations.Frontend(i.resource, &result, h.Maps) {
-		err = a.Process(k, i.resource.Annotations, k.ConfigMaps.Main.Annotations)
+		err = a.Process(k, i.resource.Annotations, svcAnnotations, policyAnnotations, k.ConfigMaps.Main.Annotations)
 		if err != nil {
 			logger.Errorf("Ingress '%s/%s': annotation %s: %s", i.resource.Namespace, i.resource.Name, a.GetName(), err)
+			i.resource.AnnotationErrors = append(i.resource.AnnotationErrors, annotationError(a.GetName(), err, i.resource.Annotations, svcAnnotations, policyAnnotations))
 		}
 	}
-	i.ruleIDs = addRules(result, h, true)
+	// Annotation errors are reported once, when they change
+	if !slices.Equal(previousErrors, i.resource.AnnotationErrors) {
+		i.reportAnnotationErrors()
+	}
+	// Rules placed in backends only apply to the requests routed to them
+	frontendRules, backendRules := result.SplitBackend()
+	i.ruleIDs = addRules(frontendRules, h, true)
+	i.backendRules = backendRules
+	// Their ids are set by the routes of the ingress too, for their ingress ACL
+	for _, rule := range backendRules {
+		i.ruleIDs = append(i.ruleIDs, rules.GetID(rule))
+	}
+}
+
+// serviceAnnotations returns the rate-limit annotations of the services the ingress
+// routes to, which apply to the whole ingress unless the ingress sets them.
+// An annotation set to different values by these services is ignored.
+func (i *Ingress) serviceAnnotations(k store.K8s) map[string]string {
+	result := map[string]string{}
+	conflicts := map[string]struct{}{}
+	seen := map[string]struct{}{}
+	for _, rule := range i.resource.Rules {
+		for _, path := range rule.Paths {
+			key := path.SvcNamespace + "/" + path.SvcName
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			svc, err := k.GetService(path.SvcNamespace, path.SvcName)
+			if err != nil {
+				continue
+			}
+			for name, value := range annotations.RateLimitAnnotations(svc.Annotations) {
+				if current, ok := result[name]; ok && current != value {
+					conflicts[name] = struct{}{}
+				}
+				result[name] = value
+			}
+		}
+	}
+	for name := range conflicts {
+		logger.Warningf("Ingress '%s/%s': annotation %s: ignoring the different values set by its services", i.resource.Namespace, i.resource.Name, name)
+		delete(result, name)
+	}
+	return result
 }
 
 func HandleCfgMapAnnotations(k store.K8s, h haproxy.HAProxy, a annotations.Annotations) {
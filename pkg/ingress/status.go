@@ -3,7 +3,9 @@ package ingress
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net"
+	"strconv"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
@@ -50,3 +52,90 @@ func (i *Ingress) UpdateStatus(client *kubernetes.Clientset, disableStatusUpdate
 	// Allow to store the publish service addresses affected to the ingress for future comparison in update test.
 	return nil
 }
+
+// Annotations reporting the rate limit applied to an ingress.
+// They are not prefixed by haproxy.org/ so they are not read back as configuration.
+const (
+	RateLimitTableAnnotation     = "status.haproxy.org/rate-limit-table"
+	RateLimitPeriodAnnotation    = "status.haproxy.org/rate-limit-period"
+	RateLimitRequestsAnnotation  = "status.haproxy.org/rate-limit-requests"
+	RateLimitWhitelistAnnotation = "status.haproxy.org/rate-limit-whitelist"
+	RateLimitIDAnnotation        = "status.haproxy.org/rate-limit-id"
+)
+
+var rateLimitAnnotations = []string{
+	RateLimitTableAnnotation,
+	RateLimitPeriodAnnotation,
+	RateLimitRequestsAnnotation,
+	RateLimitWhitelistAnnotation,
+	RateLimitIDAnnotation,
+}
+
+// rateLimitStatusAnnotations returns the annotations reporting the rate limit of the ingress,
+// nil when the ingress is not rate limited.
+func (i *Ingress) rateLimitStatusAnnotations() map[string]string {
+	rateLimit := i.resource.RateLimit
+	if rateLimit == nil {
+		return nil
+	}
+	annotations := map[string]string{
+		RateLimitTableAnnotation:     rateLimit.TableName,
+		RateLimitPeriodAnnotation:    strconv.FormatInt(rateLimit.Period, 10),
+		RateLimitRequestsAnnotation:  strconv.FormatInt(rateLimit.ReqsLimit, 10),
+		RateLimitWhitelistAnnotation: strconv.FormatBool(rateLimit.Whitelisted),
+	}
+	// The table name changes along with the annotations, the id is the stable way to identify the rate limit
+	if rateLimit.ID != "" {
+		annotations[RateLimitIDAnnotation] = rateLimit.ID
+	}
+	return annotations
+}
+
+// mergeRateLimitAnnotations sets the wanted rate limit annotations in annotations,
+// removing the ones not wanted, and returns true if annotations changed.
+func mergeRateLimitAnnotations(annotations, wanted map[string]string) (changed bool) {
+	for _, name := range rateLimitAnnotations {
+		value, ok := wanted[name]
+		current, found := annotations[name]
+		switch {
+		case ok && (!found || current != value):
+			annotations[name] = value
+			changed = true
+		case !ok && found:
+			delete(annotations, name)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// RateLimitAnnotationsChanged returns true if the rate limit annotations already on the ingress
+// don't report the rate limit computed in the last sync.
+func (i *Ingress) RateLimitAnnotationsChanged() bool {
+	return mergeRateLimitAnnotations(maps.Clone(i.resource.Annotations), i.rateLimitStatusAnnotations())
+}
+
+// UpdateRateLimitAnnotations writes the rate limit of the ingress in its annotations,
+// removing them when the ingress is no longer rate limited.
+// The ingress is only updated when the annotations differ.
+func (i *Ingress) UpdateRateLimitAnnotations(client *kubernetes.Clientset) error {
+	ingSource, err := client.NetworkingV1().Ingresses(i.resource.Namespace).Get(context.Background(), i.resource.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update rate limit annotations of ingress %s/%s: %w", i.resource.Namespace, i.resource.Name, err)
+	}
+	annotations := maps.Clone(ingSource.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if !mergeRateLimitAnnotations(annotations, i.rateLimitStatusAnnotations()) {
+		return nil
+	}
+	ingCopy := ingSource.DeepCopy()
+	ingCopy.Annotations = annotations
+	_, err = client.NetworkingV1().Ingresses(i.resource.Namespace).Update(context.Background(), ingCopy, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update rate limit annotations of ingress %s/%s: %w", i.resource.Namespace, i.resource.Name, err)
+	}
+	logger.Tracef("Successful update of rate limit annotations in ingress %s/%s", i.resource.Namespace, i.resource.Name)
+	return nil
+}
@@ -51,6 +51,7 @@ func (k k8s) runCRDefinitionsInformer(eventChan chan GroupKind, stop chan struct
 				crd.Spec.Names.Kind == "Backend" ||
 				crd.Spec.Names.Kind == "TCP" ||
 				crd.Spec.Names.Kind == "Frontend" ||
+				crd.Spec.Names.Kind == "RateLimitPolicy" ||
 				crd.Spec.Names.Kind == "ValidationRules") {
 				return
 			}
@@ -135,6 +136,8 @@ func (k k8s) RunCRSCreationMonitoring(eventChan chan k8ssync.SyncDataEvent, stop
 							}
 						case "Frontend":
 							crsV3[groupKind.Kind] = NewFrontendCRV3()
+						case "RateLimitPolicy":
+							crsV3[groupKind.Kind] = NewRateLimitPolicyCRV3()
 						}
 						if ok {
 							logger.Info("Custom resource definition created, adding CR watcher for " + crsV3[groupKind.Kind].GetKind() + " " + groupKind.Group)
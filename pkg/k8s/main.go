@@ -168,6 +168,7 @@ func New(osArgs utils.OSArgs, whitelist map[string]struct{}, publishSvc *utils.N
 	k.registerCoreCRV3(NewDefaultsCRV3())
 	k.registerCoreCRV3(NewBackendCRV3())
 	k.registerCoreCRV3(NewTCPCRV3())
+	k.registerCoreCRV3(NewRateLimitPolicyCRV3())
 	if osArgs.CustomValidationRules.Name != "" {
 		k.registerCoreCRV3(NewValidationCRV3())
 	}
@@ -296,7 +297,10 @@ func (k k8s) runInformers(eventChan chan k8ssync.SyncDataEvent, stop chan struct
 	go svci.Run(stop)
 	seci := k.getSecretInformer(eventChan, factory)
 	go seci.Run(stop)
-	*informersSynced = append(*informersSynced, svci.HasSynced, nsi.HasSynced, seci.HasSynced)
+	// ConfigMaps referenced by annotations, e.g. rate-limit-whitelist
+	cmi := k.getConfigMapInformer(eventChan, factory)
+	go cmi.Run(stop)
+	*informersSynced = append(*informersSynced, svci.HasSynced, nsi.HasSynced, seci.HasSynced, cmi.HasSynced)
 
 	k.runConfigMapInformers(eventChan, stop, informersSynced, osArgs.ConfigMap)
 	k.runConfigMapInformers(eventChan, stop, informersSynced, osArgs.ConfigMapTCPServices)
@@ -28,8 +28,7 @@ func TransformIngress(obj interface{}) (interface{}, error) {
 		return obj, nil
 	}
 
-	// Fields to remove
-	ing.Labels = nil
+	// Fields to remove, labels are kept to select the rate limit policies of the ingress
 	ing.ObjectMeta.ManagedFields = nil
 
 	// Remove duplicates
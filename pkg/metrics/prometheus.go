@@ -22,6 +22,11 @@ type PrometheusMetricsManager struct {
 
 	// runtime socket
 	runtimeSocketCounterVec *prometheus.CounterVec
+
+	// rate limit
+	rateLimitDenied                 *rateLimitDeniedCollector
+	rateLimitWhitelistEntries       *prometheus.GaugeVec
+	rateLimitWhitelistRegenerations *prometheus.CounterVec
 }
 
 var (
@@ -54,10 +59,31 @@ func New() PrometheusMetricsManager {
 			Help: "1 = there's a pending haproxy configuration that is not valid so not applicable, 0 = haproxy configuration applied",
 		})
 
+		// rate limit
+		rateLimitDenied := newRateLimitDeniedCollector()
+		prometheus.MustRegister(rateLimitDenied)
+		rateLimitWhitelistEntries := promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "haproxy_ingress_ratelimit_whitelist_map_entries",
+				Help: "The number of entries of a rate limit whitelist map, partitioned by namespace, ingress and map",
+			},
+			[]string{"namespace", "ingress", "map"},
+		)
+		rateLimitWhitelistRegenerations := promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "haproxy_ingress_ratelimit_whitelist_map_regenerations_total",
+				Help: "The number of times the content of a rate limit whitelist map changed, partitioned by namespace, ingress and map",
+			},
+			[]string{"namespace", "ingress", "map"},
+		)
+
 		pmm = PrometheusMetricsManager{
-			reloadsCounterVec:       reloadCounter,
-			runtimeSocketCounterVec: runtimeSocketCounter,
-			unableToSyncGauge:       unableToSyncGauge,
+			reloadsCounterVec:               reloadCounter,
+			runtimeSocketCounterVec:         runtimeSocketCounter,
+			unableToSyncGauge:               unableToSyncGauge,
+			rateLimitDenied:                 rateLimitDenied,
+			rateLimitWhitelistEntries:       rateLimitWhitelistEntries,
+			rateLimitWhitelistRegenerations: rateLimitWhitelistRegenerations,
 		}
 	})
 	return pmm
@@ -79,6 +105,26 @@ func (pmm PrometheusMetricsManager) UpdateRuntimeMetrics(object string, err erro
 	}
 }
 
+// SetRateLimitDeniedSource sets where the requests denied by rate limits are read from at scrape time.
+func (pmm PrometheusMetricsManager) SetRateLimitDeniedSource(source RateLimitDeniedSource) {
+	pmm.rateLimitDenied.setSource(source)
+}
+
+// UpdateRateLimitWhitelistMapMetrics sets the number of entries of a rate limit whitelist map,
+// and counts a regeneration when its content changed since the previous sync.
+func (pmm PrometheusMetricsManager) UpdateRateLimitWhitelistMapMetrics(namespace, ingress, mapName string, entries int, regenerated bool) {
+	pmm.rateLimitWhitelistEntries.WithLabelValues(namespace, ingress, mapName).Set(float64(entries))
+	if regenerated {
+		pmm.rateLimitWhitelistRegenerations.WithLabelValues(namespace, ingress, mapName).Inc()
+	}
+}
+
+// DeleteMapMetrics stops reporting the entries of a map removed on refresh.
+// Its regenerations are kept, like the other counters.
+func (pmm PrometheusMetricsManager) DeleteMapMetrics(mapName string) {
+	pmm.rateLimitWhitelistEntries.DeletePartialMatch(prometheus.Labels{"map": mapName})
+}
+
 func (pmm PrometheusMetricsManager) SetUnableSyncGauge() {
 	pmm.unableToSyncGauge.Set(float64(1))
 }
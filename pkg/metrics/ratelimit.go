@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/haproxytech/kubernetes-ingress/pkg/utils"
+)
+
+var logger = utils.GetLogger()
+
+// RateLimitDeniedSource returns the runtime "show table" output of the table
+// counting the requests denied by rate limits.
+type RateLimitDeniedSource func() (string, error)
+
+// rateLimitDeniedCollector exposes the requests denied by the rate limit of each ingress:
+//
+//	haproxy_ingress_ratelimit_denied_total{namespace="default",ingress="api",id="checkout"} 42
+//
+// The id is the rate-limit-id of the ingress, empty when not set.
+// Counters are read from HAProxy at scrape time, they are kept across reloads by the
+// stick-table and reset when HAProxy restarts.
+type rateLimitDeniedCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.RWMutex
+	source RateLimitDeniedSource
+}
+
+func newRateLimitDeniedCollector() *rateLimitDeniedCollector {
+	return &rateLimitDeniedCollector{
+		desc: prometheus.NewDesc(
+			"haproxy_ingress_ratelimit_denied_total",
+			"The number of requests denied by the rate limit of an ingress",
+			[]string{"namespace", "ingress", "id"},
+			nil,
+		),
+	}
+}
+
+func (c *rateLimitDeniedCollector) setSource(source RateLimitDeniedSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.source = source
+}
+
+func (c *rateLimitDeniedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *rateLimitDeniedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	source := c.source
+	c.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	output, err := source()
+	if err != nil {
+		// The table only exists once a rate limit counts its denied requests
+		logger.Debugf("unable to read rate limit denied requests: %s", err)
+		return
+	}
+	for key, denied := range ParseRateLimitDenied(output) {
+		namespace, ingress, id := splitRateLimitDeniedKey(key)
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, denied, namespace, ingress, id)
+	}
+}
+
+// splitRateLimitDeniedKey splits a key of the RateLimitDenied table, "<namespace>/<ingress>"
+// followed by "/<id>" when the rate limit has a rate-limit-id.
+func splitRateLimitDeniedKey(key string) (namespace, ingress, id string) {
+	namespace, ingress, _ = strings.Cut(key, "/")
+	ingress, id, _ = strings.Cut(ingress, "/")
+	return namespace, ingress, id
+}
+
+// ParseRateLimitDenied returns the http_req_cnt of every key of a "show table" output, e.g.
+//
+//	# table: RateLimitDenied, type: string, size:10240, used:1
+//	0x55d0c8a3e2a0: key=default/api use=0 exp=0 shard=0 http_req_cnt=42
+func ParseRateLimitDenied(output string) map[string]float64 {
+	result := map[string]float64{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "0x") {
+			continue
+		}
+		var key string
+		var count float64
+		found := false
+		for _, field := range strings.Fields(line) {
+			name, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch name {
+			case "key":
+				key = value
+			case "http_req_cnt":
+				v, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					continue
+				}
+				count = float64(v)
+				found = true
+			}
+		}
+		if key != "" && found {
+			result[key] = count
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseRateLimitDenied tests reading the denied requests from the runtime "show table" output.
+// It validates that:
+// - The http_req_cnt of every key is returned
+// - The table header and empty lines are ignored
+// - Entries without counter are ignored
+func TestParseRateLimitDenied(t *testing.T) {
+	output := `# table: RateLimitDenied, type: string, size:10240, used:3
+0x55d0c8a3e2a0: key=default/api use=0 exp=0 shard=0 http_req_cnt=42
+0x55d0c8a3e3b0: key=shop/front use=1 exp=0 shard=1 http_req_cnt=7
+0x55d0c8a3e4c0: key=shop/admin use=0 exp=0 shard=0
+
+`
+	assert.Equal(t, map[string]float64{
+		"default/api": 42,
+		"shop/front":  7,
+	}, ParseRateLimitDenied(output))
+
+	assert.Empty(t, ParseRateLimitDenied("# table: RateLimitDenied, type: string, size:10240, used:0\n"))
+}
+
+// TestSplitRateLimitDeniedKey tests the labels read from the keys of the RateLimitDenied table.
+// It validates that:
+// - Keys without rate-limit-id get an empty id
+// - The rate-limit-id following the ingress name is returned as the id
+func TestSplitRateLimitDeniedKey(t *testing.T) {
+	namespace, ingress, id := splitRateLimitDeniedKey("default/api")
+	assert.Equal(t, []string{"default", "api", ""}, []string{namespace, ingress, id})
+
+	namespace, ingress, id = splitRateLimitDeniedKey("shop/front/checkout-api")
+	assert.Equal(t, []string{"shop", "front", "checkout-api"}, []string{namespace, ingress, id})
+}
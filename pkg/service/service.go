@@ -28,6 +28,7 @@ import (
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/api"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/certs"
 	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/instance"
+	"github.com/haproxytech/kubernetes-ingress/pkg/haproxy/rules"
 	"github.com/haproxytech/kubernetes-ingress/pkg/rules/acls"
 	"github.com/haproxytech/kubernetes-ingress/pkg/rules/httprequests"
 	"github.com/haproxytech/kubernetes-ingress/pkg/store"
@@ -51,6 +52,8 @@ type Service struct {
 	newBackend    bool
 	standalone    bool
 	serversToEdit bool
+	// backendRules are the rules of the ingress placed in the backend, see SetBackendRules
+	backendRules rules.List
 }
 
 // New returns a Service instance to handle the k8s IngressPath resource given in params.
@@ -139,6 +142,12 @@ func (s *Service) GetBackendName() (name string, err error) {
 	return name, err
 }
 
+// SetBackendRules sets the rules of the ingress to place in the backend of the service,
+// like the rate limits whose scope is the backend.
+func (s *Service) SetBackendRules(list rules.List) {
+	s.backendRules = list
+}
+
 // HandleBackend processes a Service and creates/updates corresponding backend configuration in HAProxy
 func (s *Service) HandleBackend(storeK8s store.K8s, client api.HAProxyClient, a annotations.Annotations) (err error) {
 	var newBackend *v3.BackendSpec
@@ -161,7 +170,15 @@ func (s *Service) HandleBackend(storeK8s store.K8s, client api.HAProxyClient, a
 	// acls
 	acls.PopulateBackend(client, newBackend.BackendBase.Name, newBackend.ACLList)
 	// HTTP requests
-	httprequests.PopulateBackend(client, newBackend.BackendBase.Name, newBackend.HTTPRequestRuleList)
+	if newBackend.BackendBase.Mode == "http" {
+		// Along with the rules of the ingresses routed to the backend, once they are all processed
+		rules.SetBackendHTTPRequests(newBackend.BackendBase.Name, newBackend.HTTPRequestRuleList, s.backendRules)
+	} else {
+		if len(s.backendRules) > 0 {
+			logger.Errorf("service '%s/%s': backend rules: %s", s.resource.Namespace, s.resource.Name, rules.ErrBackendRule)
+		}
+		httprequests.PopulateBackend(client, newBackend.BackendBase.Name, newBackend.HTTPRequestRuleList)
+	}
 
 	// config-snippet: backend
 	backendCfgSnippetHandler := annotations.NewCfgSnippet(
@@ -83,6 +83,28 @@ func (m *UpdateStatusManagerImpl) Update(k store.K8s, h haproxy.HAProxy, a annot
 		}()
 	}
 
+	// Rate limit annotations are written whenever they don't report the rate limit of the last sync,
+	// independently of the publish service addresses.
+	var rateLimited []*ingress.Ingress
+	for _, namespace := range k.Namespaces {
+		if !namespace.Relevant {
+			continue
+		}
+		for _, ingResource := range namespace.Ingresses {
+			i := ingress.New(ingResource, m.ingressClass, m.emptyIngressClass, a)
+			if i.RateLimitAnnotationsChanged() {
+				rateLimited = append(rateLimited, i)
+			}
+		}
+	}
+	if len(rateLimited) > 0 && !m.disableIngressStatusUpdate {
+		go func() {
+			for _, ing := range rateLimited {
+				errs.Add(ing.UpdateRateLimitAnnotations(m.client))
+			}
+		}()
+	}
+
 	k.UpdateAllIngresses = false
 	m.updateIngresses = nil
 	return err
@@ -91,6 +91,7 @@ func (n ingressNetworkingV1Strategy) ConvertIngress(enableUserAnnotations bool)
 			Name:        n.ig.GetName(),
 			Class:       getIgClass(n.ig.Spec.IngressClassName),
 			Annotations: CopyAnnotationsWithFilter(n.ig.GetAnnotations(), enableUserAnnotations),
+			Labels:      n.ig.GetLabels(),
 			Rules: func(ingressRules []networkingv1.IngressRule) map[string]*IngressRule {
 				rules := make(map[string]*IngressRule)
 				for _, k8sRule := range ingressRules {
@@ -86,6 +86,8 @@ func (k *K8s) EventIngress(ns *Namespace, data *Ingress, uid types.UID, resource
 			if data.Annotations["ingress.class"] != oldIngress.Annotations["ingress.class"] {
 				data.ClassUpdated = true
 			}
+			// Keep the reported annotation errors so they are not reported again
+			data.AnnotationErrors = oldIngress.AnnotationErrors
 
 			for _, rule := range oldIngress.Rules {
 				for _, path := range rule.Paths {
@@ -280,7 +282,7 @@ func (k *K8s) EventConfigMap(ns *Namespace, data *ConfigMap) (updateRequired boo
 	case k.ConfigMaps.PatternFiles.Namespace == ns.Name && k.ConfigMaps.PatternFiles.Name == data.Name:
 		cm = k.ConfigMaps.PatternFiles
 	default:
-		return false
+		return k.eventNamespaceConfigMap(ns, data)
 	}
 	switch data.Status {
 	case ADDED:
@@ -310,6 +312,26 @@ func (k *K8s) EventConfigMap(ns *Namespace, data *ConfigMap) (updateRequired boo
 	return updateRequired
 }
 
+// eventNamespaceConfigMap stores configmaps that don't configure the controller
+// so they can be referenced by annotations.
+func (k *K8s) eventNamespaceConfigMap(ns *Namespace, data *ConfigMap) (updateRequired bool) {
+	switch data.Status {
+	case ADDED, MODIFIED:
+		if old, ok := ns.ConfigMaps[data.Name]; ok && old.Status != DELETED && old.Equal(data) {
+			return false
+		}
+		ns.ConfigMaps[data.Name] = data
+		updateRequired = true
+	case DELETED:
+		old, ok := ns.ConfigMaps[data.Name]
+		if ok {
+			old.Status = DELETED
+			updateRequired = true
+		}
+	}
+	return updateRequired
+}
+
 func (k *K8s) EventSecret(ns *Namespace, data *Secret) (updateRequired bool) {
 	updateRequired = false
 	switch data.Status {
@@ -44,6 +44,8 @@ type K8s struct {
 	PublishServiceAddresses      []string
 	UpdateAllIngresses           bool
 	IngressesByService           map[string]*utils.OrderedSet[string, *Ingress] // service fqn -> ingress name -> ingress
+	RateLimitClusterCIDRs        []string                                       // whitelisted in every rate limit
+	RateLimitDefaultStatusCode   int64                                          // denying requests without rate-limit-status-code, 0 for 403
 }
 
 type NamespacesWatch struct {
@@ -139,6 +141,14 @@ func (k *K8s) Clean() {
 				data.Status = EMPTY
 			}
 		}
+		for _, data := range namespace.ConfigMaps {
+			switch data.Status {
+			case DELETED:
+				delete(namespace.ConfigMaps, data.Name)
+			default:
+				data.Status = EMPTY
+			}
+		}
 		for _, cr := range namespace.CRs.TCPsPerCR {
 			switch cr.Status {
 			case DELETED:
@@ -176,14 +186,16 @@ func (k K8s) GetNamespace(name string) *Namespace {
 		Services:                 make(map[string]*Service),
 		Ingresses:                make(map[string]*Ingress),
 		Secret:                   make(map[string]*Secret),
+		ConfigMaps:               make(map[string]*ConfigMap),
 		HAProxyRuntime:           make(map[string]map[string]*RuntimeBackend),
 		HAProxyRuntimeStandalone: make(map[string]map[string]map[string]*RuntimeBackend),
 		CRs: &CustomResources{
-			Global:    make(map[string]*models.Global),
-			Defaults:  make(map[string]*models.Defaults),
-			Backends:  make(map[string]*v3.BackendSpec),
-			TCPsPerCR: make(map[string]*TCPs),
-			Frontends: make(map[string]*v3.FrontendSpec),
+			Global:            make(map[string]*models.Global),
+			Defaults:          make(map[string]*models.Defaults),
+			Backends:          make(map[string]*v3.BackendSpec),
+			TCPsPerCR:         make(map[string]*TCPs),
+			Frontends:         make(map[string]*v3.FrontendSpec),
+			RateLimitPolicies: make(map[string]*v3.RateLimitPolicySpec),
 		},
 		Gateways:        make(map[string]*Gateway),
 		TCPRoutes:       make(map[string]*TCPRoute),
@@ -210,6 +222,23 @@ func (k K8s) GetSecret(namespace, name string) (*Secret, error) {
 	return secret, nil
 }
 
+// GetConfigMap returns a configmap which is not one of the controller configmaps,
+// typically one referenced by an annotation.
+func (k K8s) GetConfigMap(namespace, name string) (*ConfigMap, error) {
+	ns, ok := k.Namespaces[namespace]
+	if !ok {
+		return nil, fmt.Errorf("configmap '%s/%s' does not exist, namespace not found", namespace, name)
+	}
+	cm, cmOK := ns.ConfigMaps[name]
+	if !cmOK {
+		return nil, ErrNotFound(fmt.Errorf("configmap '%s/%s' does not exist", namespace, name))
+	}
+	if cm.Status == DELETED {
+		return nil, ErrNotFound(fmt.Errorf("configmap '%s/%s' deleted", namespace, name))
+	}
+	return cm, nil
+}
+
 func (k K8s) GetService(namespace, name string) (*Service, error) {
 	ns, nsOk := k.Namespaces[namespace]
 	if !nsOk {
@@ -88,6 +88,7 @@ type RuntimeBackend struct {
 type Namespace struct {
 	_                        [0]int
 	Secret                   map[string]*Secret
+	ConfigMaps               map[string]*ConfigMap // configmaps referenced by annotations
 	Ingresses                map[string]*Ingress
 	Endpoints                map[string]map[string]*Endpoints // service -> sliceName -> Endpoints
 	Services                 map[string]*Service
@@ -108,8 +109,10 @@ type CustomResources struct {
 	Defaults  map[string]*models.Defaults
 	Backends  map[string]*v3.BackendSpec
 	Frontends map[string]*v3.FrontendSpec
-	TCPsPerCR map[string]*TCPs // key is the TCP CR name
-	AllTCPs   TCPResourceList
+	// RateLimitPolicies rate limit the ingresses of the namespace selected by their labels
+	RateLimitPolicies map[string]*v3.RateLimitPolicySpec
+	TCPsPerCR         map[string]*TCPs // key is the TCP CR name
+	AllTCPs           TCPResourceList
 }
 
 type IngressClass struct {
@@ -146,6 +149,26 @@ type Ingress struct {
 	Ignored      bool // true if resource ignored because of non matching Controller Class
 	ClassUpdated bool
 	Faked        bool
+	RateLimit    *RateLimitStatus // Rate limit applied to the ingress, reported in its annotations
+	// AnnotationErrors are the errors of the annotations of the ingress, reported as events
+	AnnotationErrors []AnnotationError
+}
+
+// AnnotationError describes an annotation of an ingress which couldn't be processed.
+type AnnotationError struct {
+	Name   string
+	Value  string
+	Reason string
+}
+
+// RateLimitStatus describes the rate limit computed from the annotations of an ingress.
+type RateLimitStatus struct {
+	TableName    string
+	Period       int64 // In milliseconds
+	ReqsLimit    int64
+	Whitelisted  bool
+	WhitelistMap string // Pattern file of the whitelist, empty when addresses are inlined
+	ID           string // rate-limit-id of the rate limit, empty when not set
 }
 
 // IngressTLS describes the transport layer security associated with an Ingress.
@@ -180,6 +203,7 @@ type Secret struct {
 
 type IngressCore struct {
 	Annotations    map[string]string
+	Labels         map[string]string // Selecting the rate limit policies of the ingress
 	Rules          map[string]*IngressRule
 	DefaultBackend *IngressPath
 	TLS            map[string]*IngressTLS
@@ -127,4 +127,8 @@ type OSArgs struct {
 	DisableIngressStatusUpdate        bool           `long:"disable-ingress-status-update" description:"If true, disables updating the status field of Ingress resources"`
 	EnableCustomAnnotationsOnIngress  bool           `long:"enable-custom-annotations-on-ingress" description:"allow custom user annotations on ingress"`
 	CustomValidationRules             NamespaceValue `long:"custom-validation-rules" description:"custom validation rules object" default:""`
+	RateLimitClusterCIDRs             []string       `long:"rate-limit-cluster-cidrs" description:"pod and service CIDRs whitelisted in every rate limit"`
+	RateLimitDefaultStatusCode        int64          `long:"rate-limit-default-status-code" description:"status code denying the requests of the rate limits without rate-limit-status-code"`
+	MapsWriteRetries                  int            `long:"maps-write-retries" default:"3" description:"number of retries of map file writes failing on a transient error"`
+	MapsWriteTimeout                  time.Duration  `long:"maps-write-timeout" default:"5s" description:"maximum time spent retrying a map file write"`
 }
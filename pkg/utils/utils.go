@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -58,52 +59,114 @@ func ParseInt(data string) (v int64, err error) {
 	return v, err
 }
 
+// timeUnits holds the time units accepted by ParseTime, in milliseconds.
+var timeUnits = map[string]float64{
+	"ms": 1,
+	"s":  1000,
+	"m":  1000 * 60,
+	"h":  1000 * 60 * 60,
+	"d":  1000 * 60 * 60 * 24,
+}
+
+// ParseTime returns the time in milliseconds of data, which is either a number
+// of milliseconds or a sequence of numbers, possibly fractional, each followed by
+// a time unit (ms, s, m, h or d), e.g. "10s", "1m30s" or "1.5h".
 func ParseTime(data string) (*int64, error) {
 	var v int64
 	var err error
-	switch {
-	case strings.HasSuffix(data, "ms"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(data, "ms"), 10, 64)
-	case strings.HasSuffix(data, "s"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(data, "s"), 10, 64)
-		v *= 1000
-	case strings.HasSuffix(data, "m"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(data, "m"), 10, 64)
-		v = v * 1000 * 60
-	case strings.HasSuffix(data, "h"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(data, "h"), 10, 64)
-		v = v * 1000 * 60 * 60
-	case strings.HasSuffix(data, "d"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(data, "d"), 10, 64)
-		v = v * 1000 * 60 * 60 * 24
-	default:
-		v, err = strconv.ParseInt(data, 10, 64)
+	if v, err = strconv.ParseInt(data, 10, 64); err == nil {
+		return &v, nil
+	}
+	v = 0
+	s := data
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return &v, fmt.Errorf("invalid time '%s'", data)
+	}
+	var total float64
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return &v, fmt.Errorf("invalid time '%s'", data)
+		}
+		var number float64
+		number, err = strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return &v, fmt.Errorf("invalid time '%s'", data)
+		}
+		s = s[i:]
+		j := strings.IndexFunc(s, func(r rune) bool { return r < 'a' || r > 'z' })
+		if j == -1 {
+			j = len(s)
+		}
+		unit, ok := timeUnits[s[:j]]
+		if !ok {
+			return &v, fmt.Errorf("invalid time unit '%s' in '%s'", s[:j], data)
+		}
+		total += number * unit
+		s = s[j:]
 	}
-	return &v, err
+	v = int64(math.Round(total))
+	if negative {
+		v = -v
+	}
+	return &v, nil
+}
+
+// sizeUnits are the multipliers of the size units HAProxy accepts.
+var sizeUnits = map[string]int64{
+	"k": 1024,
+	"m": 1024 * 1024,
+	"g": 1024 * 1024 * 1024,
 }
 
+// ParseSize parses an integer followed by an optional k, m or g unit, in lower
+// or upper case, like HAProxy does (1k = 1024). The value is returned without
+// unit, so it is written in the configuration as a plain integer.
 func ParseSize(size string) (*int64, error) {
-	var v int64
-	var err error
-	switch {
-	case strings.HasSuffix(size, "k"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(size, "k"), 10, 64)
-		v *= 1024
-	case strings.HasSuffix(size, "m"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(size, "m"), 10, 64)
-		v = v * 1024 * 1024
-	case strings.HasSuffix(size, "g"):
-		v, err = strconv.ParseInt(strings.TrimSuffix(size, "g"), 10, 64)
-		v = v * 1024 * 1024 * 1024
-	default:
-		v, err = strconv.ParseInt(size, 10, 64)
+	number, multiplier := size, int64(1)
+	if n := len(size); n > 0 {
+		if m, ok := sizeUnits[strings.ToLower(size[n-1:])]; ok {
+			number, multiplier = size[:n-1], m
+		}
 	}
+	v, err := strconv.ParseInt(number, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid size '%s', expecting an integer with an optional k, m or g unit", size)
+	}
+	if v > math.MaxInt64/multiplier || v < math.MinInt64/multiplier {
+		return nil, fmt.Errorf("size '%s' is out of range", size)
 	}
+	v *= multiplier
 	return &v, nil
 }
 
+// ListLine is a line of a list maintained by hand, with its 1-based number.
+type ListLine struct {
+	Number int
+	Text   string
+}
+
+// ParseListLines returns the trimmed lines of a list without their '#' comments,
+// including trailing ones. Blank lines and comment-only lines are skipped.
+func ParseListLines(content string) []ListLine {
+	var lines []ListLine
+	for i, line := range strings.Split(content, "\n") {
+		if comment := strings.Index(line, "#"); comment != -1 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, ListLine{Number: i + 1, Text: line})
+	}
+	return lines
+}
+
 func GetBoolValue(dataValue, dataName string) (result bool, err error) {
 	result, err = strconv.ParseBool(dataValue)
 	if err != nil {
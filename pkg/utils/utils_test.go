@@ -0,0 +1,126 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseTime tests the conversion of time values to milliseconds.
+// It validates that:
+// - Numbers without unit are milliseconds
+// - Single unit values keep their previous meaning (e.g. 10s is 10000)
+// - Compound values like 1m30s add up each part
+// - Fractional values are accepted and rounded to the millisecond
+// - Unknown units, missing numbers and missing units are rejected
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "500", want: 500},
+		{input: "500ms", want: 500},
+		{input: "10s", want: 10000},
+		{input: "1m", want: 60000},
+		{input: "1h", want: 3600000},
+		{input: "1d", want: 86400000},
+		{input: "1m30s", want: 90000},
+		{input: "1h30m", want: 5400000},
+		{input: "2s500ms", want: 2500},
+		{input: "1.5s", want: 1500},
+		{input: "0.5m", want: 30000},
+		{input: "1.1s", want: 1100},
+		{input: "-5s", want: -5000},
+		{input: "", wantErr: true},
+		{input: "10x", wantErr: true},
+		{input: "s", wantErr: true},
+		{input: "1m30", wantErr: true},
+		{input: "1..5s", wantErr: true},
+		{input: "1m 30s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTime(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+// TestParseSize tests the conversion of sizes to a number without unit.
+// It validates that:
+// - Numbers without unit are kept as is
+// - k, m and g units are multiples of 1024, in lower or upper case
+// - Units HAProxy doesn't accept, missing numbers and overflowing values are rejected
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "1000", want: 1000},
+		{input: "200k", want: 200 * 1024},
+		{input: "200K", want: 200 * 1024},
+		{input: "1m", want: 1024 * 1024},
+		{input: "1M", want: 1024 * 1024},
+		{input: "2g", want: 2 * 1024 * 1024 * 1024},
+		{input: "2G", want: 2 * 1024 * 1024 * 1024},
+		{input: "0", want: 0},
+		{input: "200kb", wantErr: true},
+		{input: "200 k", wantErr: true},
+		{input: "1t", wantErr: true},
+		{input: "1.5m", wantErr: true},
+		{input: "k", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "9999999999g", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+// TestParseListLines tests the parsing of lists maintained by hand.
+// It validates that:
+// - Lines starting with '#' are skipped
+// - Trailing comments are stripped
+// - Blank and whitespace-only lines are skipped
+// - Entries are trimmed and keep their line number
+func TestParseListLines(t *testing.T) {
+	content := "# office networks\n10.0.0.0/8\n\n  \t \n192.168.1.1   # gateway\n  172.16.0.0/12\t\n   # disabled: 10.1.1.1\n"
+	assert.Equal(t, []ListLine{
+		{Number: 2, Text: "10.0.0.0/8"},
+		{Number: 5, Text: "192.168.1.1"},
+		{Number: 6, Text: "172.16.0.0/12"},
+	}, ParseListLines(content))
+	assert.Empty(t, ParseListLines(""))
+	assert.Empty(t, ParseListLines("# only a comment\n \n"))
+	assert.Equal(t, []ListLine{{Number: 1, Text: "10.0.0.1, 10.0.0.2"}}, ParseListLines("10.0.0.1, 10.0.0.2"))
+}